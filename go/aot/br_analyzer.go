@@ -7,9 +7,10 @@ func isBrTarget(block binary.Expr) bool {
 	return n > 0 && block[n-1].Opcode == 0xFF
 }
 
+// analyzeBr marks br targets with 0xFF, it works on a copy
+// of the code so that the module can still be used elsewhere
 func analyzeBr(code binary.Code) []binary.Instruction {
-	expr := code.Expr
-	brTargets := analyzeExpr(0, expr)
+	expr, brTargets := analyzeExpr(0, code.Expr)
 	for _, target := range brTargets {
 		if target == 0 {
 			return append(expr, binary.Instruction{Opcode: 0xFF})
@@ -18,32 +19,56 @@ func analyzeBr(code binary.Code) []binary.Instruction {
 	return expr
 }
 
-func analyzeExpr(depth uint32, expr binary.Expr) (allTargets []uint32) {
+func analyzeExpr(depth uint32, expr binary.Expr) (binary.Expr, []uint32) {
+	expr = append(binary.Expr{}, expr...)
+	var allTargets []uint32
 	for i, instr := range expr {
 		switch instr.Opcode {
 		case binary.Block, binary.Loop:
 			args := instr.Args.(binary.BlockArgs)
-			targets := analyzeExpr(depth+1, args.Instrs)
-			for _, target := range targets {
-				if target == depth+1 {
-					args.Instrs = append(args.Instrs, binary.Instruction{Opcode: 0xFF})
-					expr[i].Args = args // hack!
-					break
-				}
+			var targets []uint32
+			args.Instrs, targets = analyzeExpr(depth+1, args.Instrs)
+			if containsTarget(targets, depth+1) {
+				args.Instrs = append(args.Instrs, binary.Instruction{Opcode: 0xFF})
 			}
+			expr[i].Args = args
 			allTargets = append(allTargets, targets...)
 		case binary.If:
 			args := instr.Args.(binary.IfArgs)
-			targets := analyzeExpr(depth+1, args.Instrs1)
-			targets2 := analyzeExpr(depth+1, args.Instrs2)
+			var targets, targets2 []uint32
+			args.Instrs1, targets = analyzeExpr(depth+1, args.Instrs1)
+			args.Instrs2, targets2 = analyzeExpr(depth+1, args.Instrs2)
 			targets = append(targets, targets2...)
-			for _, target := range targets {
-				if target == depth+1 {
-					args.Instrs1 = append(args.Instrs1, binary.Instruction{Opcode: 0xFF})
-					expr[i].Args = args // hack!
-					break
-				}
+			if containsTarget(targets, depth+1) {
+				args.Instrs1 = append(args.Instrs1, binary.Instruction{Opcode: 0xFF})
 			}
+			expr[i].Args = args
+			allTargets = append(allTargets, targets...)
+		case binary.Try:
+			args := instr.Args.(binary.TryArgs)
+			var targets, targets2 []uint32
+			args.Instrs, targets = analyzeExpr(depth+1, args.Instrs)
+			args.Handlers = append([]binary.Handler{}, args.Handlers...)
+			for j := range args.Handlers {
+				args.Handlers[j].Instrs, targets2 = analyzeExpr(depth+1, args.Handlers[j].Instrs)
+				targets = append(targets, targets2...)
+			}
+			if containsTarget(targets, depth+1) {
+				args.Instrs = append(args.Instrs, binary.Instruction{Opcode: 0xFF})
+			}
+			expr[i].Args = args
+			allTargets = append(allTargets, targets...)
+		case binary.TryTable:
+			args := instr.Args.(binary.TryTableArgs)
+			var targets []uint32
+			args.Instrs, targets = analyzeExpr(depth+1, args.Instrs)
+			if containsTarget(targets, depth+1) {
+				args.Instrs = append(args.Instrs, binary.Instruction{Opcode: 0xFF})
+			}
+			for _, ct := range args.Catches {
+				targets = append(targets, depth-ct.Label)
+			}
+			expr[i].Args = args
 			allTargets = append(allTargets, targets...)
 		case binary.Br:
 			allTargets = append(allTargets, depth-instr.Args.(uint32))
@@ -59,5 +84,14 @@ func analyzeExpr(depth uint32, expr binary.Expr) (allTargets []uint32) {
 			//allTargets = append(allTargets, 0)
 		}
 	}
-	return
+	return expr, allTargets
+}
+
+func containsTarget(targets []uint32, target uint32) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
 }
@@ -6,10 +6,15 @@ import (
 )
 
 func Compile(module binary.Module) {
+	fmt.Println(Generate(module))
+}
+
+// Generate returns the Go source of the plugin compiled from module.
+func Generate(module binary.Module) string {
 	c := &moduleCompiler{
 		printer:    newPrinter(),
 		moduleInfo: newModuleInfo(module),
 	}
 	c.compile()
-	fmt.Println(c.sb.String())
+	return c.sb.String()
 }
@@ -0,0 +1,302 @@
+package aot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"wasm.go/binary"
+)
+
+// try bodies are compiled to closures which recover exceptions,
+// branches out of them return the target block plus one, tail
+// calls return the callee plus one negated
+type tryInfo struct {
+	blockIdx int
+	recovers bool
+	targets  map[int]bool // blocks branched to out of the closure
+	tail     bool         // has tail calls
+}
+
+func (c *internalFuncCompiler) innerTry() *tryInfo {
+	if len(c.tries) > 0 {
+		return c.tries[len(c.tries)-1]
+	}
+	return nil
+}
+
+func (c *internalFuncCompiler) newTmp() string {
+	c.tmpIdx++
+	return fmt.Sprintf("t%d", c.tmpIdx-1)
+}
+
+// branches to block n, whose results are already in place
+func (c *internalFuncCompiler) genJump(n int) string {
+	if t := c.innerTry(); t != nil && n <= t.blockIdx {
+		if n == t.blockIdx {
+			return "return 0"
+		}
+		t.targets[n] = true
+		return fmt.Sprintf("return %d", n+1)
+	}
+	if c.blocks[n].opcode == binary.Loop {
+		return "continue " + c.getLabelName(n)
+	}
+	return "break " + c.getLabelName(n)
+}
+
+/*
+	l0: for {
+		var t0 *instance.Exception
+		t1 := func() int {
+			defer func() { ... t0 = catchException(r) ... }()
+			...
+			return 0
+		}()
+		if t0 != nil {
+			... // handlers
+		}
+		switch t1 {
+			... // branches out of the closure
+		}
+		break
+	}
+*/
+func (c *internalFuncCompiler) emitTry(instr binary.Instruction) {
+	var bt binary.FuncType
+	var body []binary.Instruction
+	var handlers []binary.Handler
+	var catches []binary.Catch
+	skip := 0 // try closures the delegated exception skips
+	switch args := instr.Args.(type) {
+	case binary.TryArgs:
+		bt = c.moduleInfo.module.GetBlockType(args.BT)
+		body, handlers = args.Instrs, args.Handlers
+		if args.Delegate != nil {
+			target := len(c.blocks) - 1 - int(*args.Delegate)
+			for _, t := range c.tries {
+				if t.recovers && t.blockIdx > target {
+					skip++
+				}
+			}
+		}
+	case binary.TryTableArgs:
+		bt = c.moduleInfo.module.GetBlockType(args.BT)
+		body, catches = args.Instrs, args.Catches
+	}
+
+	c.printIndents()
+	c.enterBlock(instr.Opcode, bt)
+	bi := c.blocks[len(c.blocks)-1]
+	n := c.blockDepth() - 1
+	if isBrTarget(body) {
+		c.printf("%s: for { // %s\n", c.getLabelName(n), instr.GetOpname())
+	} else {
+		c.printf("{ // %s %s\n", c.getLabelName(n), instr.GetOpname())
+	}
+
+	try := &tryInfo{
+		blockIdx: n,
+		recovers: len(handlers) > 0 || len(catches) > 0 || skip > 0,
+		targets:  map[int]bool{},
+	}
+	exn, br := "", c.newTmp()
+	if len(handlers) > 0 || len(catches) > 0 {
+		exn = c.newTmp()
+		c.printIndents()
+		c.printf("var %s *instance.Exception\n", exn)
+	}
+	c.printIndents()
+	c.printf("%s := func() int {\n", br)
+	if skip > 0 {
+		c.printIndents()
+		c.printf("defer func() { if r := recover(); r != nil { panic(&delegation{catchException(r), %d}) } }()\n", skip)
+	} else if try.recovers {
+		c.printIndents()
+		c.printf("defer func() { if r := recover(); r != nil { %s = catchException(r) } }()\n", exn)
+	}
+	c.tries = append(c.tries, try)
+	c.emitInstrs(body)
+	c.tries = c.tries[:len(c.tries)-1]
+	c.printIndents()
+	c.println("return 0")
+	c.printIndents()
+	c.println("}()")
+
+	if exn != "" {
+		c.printIndents()
+		c.printf("if %s != nil {\n", exn)
+		if len(handlers) > 0 {
+			c.emitHandlers(handlers, exn)
+		} else {
+			c.emitCatches(catches, exn)
+		}
+		c.printIndents()
+		c.println("}")
+	}
+	c.emitTryExits(try, br)
+
+	c.exitBlock()
+	c.stackPtr = bi.stackPtr + bi.resultCnt
+	if isBrTarget(body) {
+		c.printIndentsPlus(1)
+		c.printf("break %s\n", c.getLabelName(c.blockDepth()))
+	}
+	c.printIndents()
+	c.printf("} // end of %s\n", c.getLabelName(c.blockDepth()))
+}
+
+// catch and catch_all run in place of the try block
+func (c *internalFuncCompiler) emitHandlers(handlers []binary.Handler, exn string) {
+	bi := &c.blocks[len(c.blocks)-1]
+	bi.exn = exn
+	for i, h := range handlers {
+		c.printIndents()
+		c.printIf(i > 0, "} else ", "")
+		if h.CatchAll {
+			c.println("{ // catch_all")
+		} else {
+			c.printf("if %s.Tag == m.tags[%d] { // catch\n", exn, h.Tag)
+		}
+		c.stackPtr = bi.stackPtr
+		if !h.CatchAll {
+			for j, vt := range c.moduleInfo.tagTypes[h.Tag].ParamTypes {
+				c.printIndents()
+				c.printf("s%d = %s\n", c.stackPush(),
+					genU64(vt, fmt.Sprintf("%s.Args[%d]", exn, j)))
+			}
+		}
+		c.emitInstrs(h.Instrs)
+	}
+	c.printIndents()
+	if last := handlers[len(handlers)-1]; !last.CatchAll {
+		c.printf("} else { panic(%s) }\n", exn)
+	} else {
+		c.println("}")
+	}
+}
+
+// catch clauses of try_table branch with the payload
+func (c *internalFuncCompiler) emitCatches(catches []binary.Catch, exn string) {
+	n := len(c.blocks) - 1
+	for i, ct := range catches {
+		catchAll := ct.Kind == binary.ClauseCatchAll || ct.Kind == binary.ClauseCatchAllRef
+		c.printIndents()
+		c.printIf(i > 0, "} else ", "")
+		if catchAll {
+			c.print("{ ")
+		} else {
+			c.printf("if %s.Tag == m.tags[%d] { ", exn, ct.Tag)
+		}
+		target := n - 1 - int(ct.Label)
+		slot := c.blocks[target].stackPtr
+		if !catchAll {
+			for j, vt := range c.moduleInfo.tagTypes[ct.Tag].ParamTypes {
+				c.printf("s%d = %s; ", slot,
+					genU64(vt, fmt.Sprintf("%s.Args[%d]", exn, j)))
+				slot++
+			}
+		}
+		if ct.Kind == binary.ClauseCatchRef || ct.Kind == binary.ClauseCatchAllRef {
+			c.printf("s%d = m.refs.Put(%s); ", slot, exn)
+			slot++
+		}
+		if slot > c.stackMax {
+			c.stackMax = slot
+		}
+		c.printf("%s // catch %d\n", c.genJump(target), ct.Label)
+		if catchAll {
+			c.printIndents()
+			c.println("}")
+			return
+		}
+	}
+	c.printIndents()
+	c.printf("} else { panic(%s) }\n", exn)
+}
+
+// passes the branches and tail calls out of the closure on
+func (c *internalFuncCompiler) emitTryExits(try *tryInfo, br string) {
+	targets := make([]int, 0, len(try.targets))
+	for target := range try.targets {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+
+	c.printIndents()
+	c.printf("switch %s {\n", br)
+	for _, target := range targets {
+		c.printIndents()
+		c.printf("case %d: ", target+1)
+		if target == 0 && c.innerTry() == nil {
+			vals := make([]string, 0, c.nResults+1)
+			for i := 0; i < c.nResults; i++ {
+				vals = append(vals, fmt.Sprintf("s%d", i))
+			}
+			if c.tail {
+				vals = append(vals, "0")
+			}
+			c.printf("return %s // return\n", strings.Join(vals, ", "))
+		} else {
+			c.println(c.genJump(target))
+		}
+	}
+	c.printIndents()
+	c.println("}")
+
+	if try.tail {
+		c.printIndents()
+		if t := c.innerTry(); t != nil {
+			t.tail = true
+			c.printf("if %s < 0 { return %s }\n", br, br)
+		} else {
+			c.printf("if %s < 0 { return %s-%s }\n",
+				br, strings.Repeat("0, ", c.nResults), br)
+		}
+	}
+}
+
+func (c *internalFuncCompiler) emitThrow(tagIdx uint32) {
+	ft := c.moduleInfo.tagTypes[tagIdx]
+	c.stackPtr -= len(ft.ParamTypes)
+	c.printf("panic(&instance.Exception{Tag: m.tags[%d], Args: []interface{}{", tagIdx)
+	for i, vt := range ft.ParamTypes {
+		c.printIf(i > 0, ", ", "")
+		c.print(genWasmVal(vt, fmt.Sprintf("s%d", c.stackPtr+i)))
+	}
+	c.printf("}}) // throw tag#%d\n", tagIdx)
+}
+
+// the uint64 of a wasm value
+func genU64(vt binary.ValType, val string) string {
+	switch vt {
+	case binary.ValTypeI32:
+		return fmt.Sprintf("uint64(uint32(%s.(int32)))", val)
+	case binary.ValTypeI64:
+		return fmt.Sprintf("uint64(%s.(int64))", val)
+	case binary.ValTypeF32:
+		return fmt.Sprintf("_u32(%s.(float32))", val)
+	case binary.ValTypeF64:
+		return fmt.Sprintf("_u64(%s.(float64))", val)
+	case binary.ValTypeFuncRef:
+		return fmt.Sprintf("m.putFuncRef(%s)", val)
+	default:
+		return fmt.Sprintf("m.refs.Put(%s)", val)
+	}
+}
+
+// the wasm value of a uint64
+func genWasmVal(vt binary.ValType, val string) string {
+	switch vt {
+	case binary.ValTypeI32:
+		return fmt.Sprintf("int32(%s)", val)
+	case binary.ValTypeI64:
+		return fmt.Sprintf("int64(%s)", val)
+	case binary.ValTypeF32:
+		return fmt.Sprintf("_f32(%s)", val)
+	case binary.ValTypeF64:
+		return fmt.Sprintf("_f64(%s)", val)
+	default:
+		return fmt.Sprintf("m.refs.Get(%s)", val)
+	}
+}
@@ -14,10 +14,10 @@ func newExportedFuncCompiler(importedFuncCount int) *exportedFuncCompiler {
 	}
 }
 
-func (c *exportedFuncCompiler) compile(expIdx, fIdx int, ft binary.FuncType) string {
-	c.printf("func (m *aotModule) exported%d(args []interface{}) ([]interface{}, error) {\n", expIdx)
+func (c *exportedFuncCompiler) compile(name string, fIdx int, ft binary.FuncType) string {
+	c.printf("func (m *aotModule) %s(args []interface{}) ([]interface{}, error) {\n", name)
 	if fIdx < c.importedFuncCount {
-		c.printf("\treturn m.f%d(args...)\n", fIdx)
+		c.printf("\treturn m.importedFuncs[%d].Call(args...)\n", fIdx)
 	} else {
 		c.print("\t")
 		c.genResults(len(ft.ResultTypes))
@@ -46,13 +46,17 @@ func (c *exportedFuncCompiler) genParams(ft binary.FuncType) {
 		c.printIf(i > 0, ", ", "")
 		switch vt {
 		case binary.ValTypeI32:
-			c.printf("uint64(args[%d].(int32))", i)
+			c.printf("uint64(uint32(args[%d].(int32)))", i)
 		case binary.ValTypeI64:
 			c.printf("uint64(args[%d].(int64))", i)
 		case binary.ValTypeF32:
 			c.printf("_u32(args[%d].(float32))", i)
 		case binary.ValTypeF64:
 			c.printf("_u64(args[%d].(float64))", i)
+		case binary.ValTypeFuncRef:
+			c.printf("m.putFuncRef(args[%d])", i)
+		case binary.ValTypeExternRef, binary.ValTypeExnRef:
+			c.printf("m.refs.Put(args[%d])", i)
 		}
 	}
 }
@@ -73,6 +77,8 @@ func (c *exportedFuncCompiler) genReturn(ft binary.FuncType) {
 				c.printf("_f32(r%d)", i)
 			case binary.ValTypeF64:
 				c.printf("_f64(r%d)", i)
+			case binary.ValTypeFuncRef, binary.ValTypeExternRef, binary.ValTypeExnRef:
+				c.printf("m.refs.Get(r%d)", i)
 			}
 		}
 		c.println("}, nil")
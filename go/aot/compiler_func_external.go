@@ -37,23 +37,29 @@ func (c *externalFuncCompiler) genFuncBody(idx int, ft binary.FuncType) {
 			c.printf("_f32(a%d)", i)
 		case binary.ValTypeF64:
 			c.printf("_f64(a%d)", i)
+		case binary.ValTypeFuncRef, binary.ValTypeExternRef, binary.ValTypeExnRef:
+			c.printf("m.refs.Get(a%d)", i)
 		}
 	}
 	c.println(")")
-	c.println("\tif err != nil {} // TODO")
+	c.println("\tif err != nil { panic(err) }")
 	if len(ft.ResultTypes) > 0 {
 		c.print("\treturn ")
 		for i, vt := range ft.ResultTypes {
 			c.printIf(i > 0, ", ", "")
 			switch vt {
 			case binary.ValTypeI32:
-				c.printf("uint64(results[%d].(int32))", i)
+				c.printf("uint64(uint32(results[%d].(int32)))", i)
 			case binary.ValTypeI64:
 				c.printf("uint64(results[%d].(int64))", i)
 			case binary.ValTypeF32:
 				c.printf("_u32(results[%d].(float32))", i)
 			case binary.ValTypeF64:
 				c.printf("_u64(results[%d].(float64))", i)
+			case binary.ValTypeFuncRef:
+				c.printf("m.putFuncRef(results[%d])", i)
+			case binary.ValTypeExternRef, binary.ValTypeExnRef:
+				c.printf("m.refs.Put(results[%d])", i)
 			}
 		}
 	}
@@ -3,6 +3,7 @@ package aot
 import (
 	"fmt"
 	"math"
+	"regexp"
 	"strings"
 
 	"wasm.go/binary"
@@ -17,6 +18,8 @@ type internalFuncCompiler struct {
 	blocks     []blockInfo
 	cntByDepth map[int]int // blockDepth -> blockCount
 	nResults   int
+	tail       bool // has tail calls, see genTailTrampoline()
+	tries      []*tryInfo
 }
 
 type blockInfo struct {
@@ -24,6 +27,7 @@ type blockInfo struct {
 	paramCnt  int
 	resultCnt int
 	stackPtr  int
+	exn       string // the caught exception, in catch handlers
 }
 
 func newInternalFuncCompiler(moduleInfo moduleInfo) *internalFuncCompiler {
@@ -99,11 +103,20 @@ func (c *internalFuncCompiler) compile(idx int,
 	resultCount := len(ft.ResultTypes)
 	localCount := int(code.GetLocalCount())
 	c.nResults = resultCount
+	c.tail = c.moduleInfo.tailFuncs[idx]
 
-	c.printf("func (m *aotModule) f%d(", idx)
-	c.genParams(paramCount)
-	c.print(")")
-	c.genResults(resultCount)
+	if c.tail {
+		c.genTailTrampoline(idx, ft)
+		c.printf("func (m *aotModule) f%d_tail(", idx)
+		c.genParams(paramCount)
+		c.print(")")
+		c.genTailResults(resultCount)
+	} else {
+		c.printf("func (m *aotModule) f%d(", idx)
+		c.genParams(paramCount)
+		c.print(")")
+		c.genResults(resultCount)
+	}
 	c.print(" {\n")
 	c.genLocals(paramCount, localCount)
 	c.println("\t// stack")
@@ -147,18 +160,14 @@ func genVarNotUsedCheat(s string,
 	return "\tif false { print(" + strings.Join(unusedVars, ", ") +
 		") } // 'xxx declared and not used' cheat\n"
 }
+// a variable is used if it is read somewhere, assignments
+// (including multi-value ones like `s0, s1 = ...`) do not count
 func isVarUsed(s, v string) bool {
-	assign := v + " = "
-	for len(s) > 0 {
-		idx := strings.Index(s, v)
-		if idx < 0 {
-			return false
-		}
-		s = s[idx:]
-		if !strings.HasPrefix(s, assign) {
+	re := regexp.MustCompile(`\b` + v + `\b((, [as]\d+)* = )?`)
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		if m[1] == "" {
 			return true
 		}
-		s = s[len(assign):]
 	}
 	return false
 }
@@ -192,20 +201,62 @@ func (c *internalFuncCompiler) genFuncBody(
 
 	expr := analyzeBr(code)
 	c.emitBlock(binary.Call, ft, expr)
-	if len(ft.ResultTypes) > 0 {
+	if len(ft.ResultTypes) > 0 || c.tail {
 		//c.printf("\treturn s%d\n", c.stackPtr-1)
 		c.print("\treturn ")
-		for i := c.nResults - 1; i >= 0; i-- {
-			c.printIf(i < c.nResults-1, ", ", "")
-			c.printf("s%d", c.stackPtr-1-i)
-		}
+		c.printReturnVals()
 		c.println(" // return!")
 	}
 }
 
+// funcs with tail calls are split into fN_tail, which returns
+// the next func to call (plus one, 0 means done), and fN which
+// keeps calling them so the Go stack does not grow
+func (c *internalFuncCompiler) genTailTrampoline(idx int, ft binary.FuncType) {
+	paramCount := len(ft.ParamTypes)
+	resultCount := len(ft.ResultTypes)
+	group := c.moduleInfo.getTailGroup(ft.ResultTypes)
+
+	results := ""
+	for i := 0; i < resultCount; i++ {
+		results += fmt.Sprintf("r%d, ", i)
+	}
+	c.printf("func (m *aotModule) f%d(", idx)
+	c.genParams(paramCount)
+	c.print(")")
+	c.genResults(resultCount)
+	c.print(" {\n")
+	c.printf("\t%snext := m.f%d_tail(", results, idx)
+	for i := 0; i < paramCount; i++ {
+		c.printIf(i > 0, ", ", "")
+		c.printf("a%d", i)
+	}
+	c.println(")")
+	c.println("\tfor next != 0 {")
+	c.printf("\t\t%snext = m.tail%d(next)\n", results, group)
+	c.println("\t}")
+	if resultCount > 0 {
+		c.printf("\treturn %s\n", strings.TrimSuffix(results, ", "))
+	}
+	c.println("}")
+}
+
+func (c *internalFuncCompiler) genTailResults(resultCount int) {
+	if resultCount == 0 {
+		c.print(" int")
+		return
+	}
+	c.print(" (")
+	for i := 0; i < resultCount; i++ {
+		c.print("uint64, ")
+	}
+	c.print("int)")
+}
+
 func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	switch instr.Opcode {
 	case binary.Block, binary.Loop, binary.If:
+	case binary.Try, binary.TryTable:
 	case binary.BrTable:
 	case 0xFF:
 	default:
@@ -228,6 +279,17 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 		c.emitBlock(binary.Loop, bt, blockArgs.Instrs)
 	case binary.If:
 		c.emitIf(instr.Args.(binary.IfArgs))
+	case binary.Try, binary.TryTable:
+		c.emitTry(instr)
+	case binary.Throw:
+		c.emitThrow(instr.Args.(uint32))
+	case binary.Rethrow:
+		labelIdx := instr.Args.(uint32)
+		c.printf("panic(%s) // %s %d\n",
+			c.blocks[len(c.blocks)-1-int(labelIdx)].exn, opname, labelIdx)
+	case binary.ThrowRef:
+		c.printf("m.throwRef(s%d) // %s\n", c.stackPtr-1, opname)
+		c.stackPop()
 	case binary.Br:
 		c.emitBr(instr.Args.(uint32))
 	case binary.BrIf:
@@ -239,11 +301,15 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.Call:
 		c.emitCall(int(instr.Args.(uint32)))
 	case binary.CallIndirect:
-		c.emitCallIndirect(int(instr.Args.(uint32)))
+		c.emitCallIndirect(instr.Args.(binary.CallIndirectArgs))
+	case binary.ReturnCall:
+		c.emitReturnCall(int(instr.Args.(uint32)))
+	case binary.ReturnCallIndirect:
+		c.emitReturnCallIndirect(instr.Args.(binary.CallIndirectArgs))
 	case binary.Drop:
 		c.printf("// %s\n", opname)
 		c.stackPop()
-	case binary.Select:
+	case binary.Select, binary.SelectT:
 		c.printf("if s%d == 0 { s%d = s%d } // %s\n",
 			c.stackPtr-1, c.stackPtr-3, c.stackPtr-2, opname)
 		c.stackPtr -= 2
@@ -258,50 +324,54 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 		c.printf("a%d = s%d // %s %d\n",
 			instr.Args, c.stackPtr-1, opname, instr.Args)
 	case binary.GlobalGet:
-		c.printf("s%d = m.globals[%d].GetAsU64() // %s %d\n",
-			c.stackPush(), instr.Args, opname, instr.Args)
+		c.emitGlobalGet(instr.Args.(uint32), opname)
 	case binary.GlobalSet:
-		c.printf("m.globals[%d].SetAsU64(s%d) // %s %d\n",
-			instr.Args, c.stackPtr-1, opname, instr.Args)
-		c.stackPtr--
+		c.emitGlobalSet(instr.Args.(uint32), opname)
+	case binary.TableGet:
+		c.printf("s%d = m.refs.Put(m.tables[%d].GetElem(uint32(s%d))) // %s\n",
+			c.stackPtr-1, instr.Args, c.stackPtr-1, opname)
+	case binary.TableSet:
+		c.printf("m.tables[%d].SetElem(uint32(s%d), m.refs.Get(s%d)) // %s\n",
+			instr.Args, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 2
 	case binary.I32Load, binary.F32Load:
-		c.emitLoad(instr, "s%d = uint64(m.readU32(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU32(%d, %d + s%d)) // %s\n")
 	case binary.I64Load, binary.F64Load:
-		c.emitLoad(instr, "s%d = m.readU64(%d + s%d) // %s\n")
+		c.emitLoad(instr, "s%d = m.readU64(%d, %d + s%d) // %s\n")
 	case binary.I32Load8S:
-		c.emitLoad(instr, "s%d = uint64(int8(m.readU8(%d + s%d))) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(int8(m.readU8(%d, %d + s%d))) // %s\n")
 	case binary.I32Load8U:
-		c.emitLoad(instr, "s%d = uint64(m.readU8(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU8(%d, %d + s%d)) // %s\n")
 	case binary.I32Load16S:
-		c.emitLoad(instr, "s%d = uint64(int16(m.readU16(%d + s%d))) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(int16(m.readU16(%d, %d + s%d))) // %s\n")
 	case binary.I32Load16U:
-		c.emitLoad(instr, "s%d = uint64(m.readU16(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU16(%d, %d + s%d)) // %s\n")
 	case binary.I64Load8S:
-		c.emitLoad(instr, "s%d = uint64(int8(m.readU8(%d + s%d))) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(int8(m.readU8(%d, %d + s%d))) // %s\n")
 	case binary.I64Load8U:
-		c.emitLoad(instr, "s%d = uint64(m.readU8(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU8(%d, %d + s%d)) // %s\n")
 	case binary.I64Load16S:
-		c.emitLoad(instr, "s%d = uint64(int16(m.readU16(%d + s%d))) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(int16(m.readU16(%d, %d + s%d))) // %s\n")
 	case binary.I64Load16U:
-		c.emitLoad(instr, "s%d = uint64(m.readU16(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU16(%d, %d + s%d)) // %s\n")
 	case binary.I64Load32S:
-		c.emitLoad(instr, "s%d = uint64(int32(m.readU32(%d + s%d))) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(int32(m.readU32(%d, %d + s%d))) // %s\n")
 	case binary.I64Load32U:
-		c.emitLoad(instr, "s%d = uint64(m.readU32(%d + s%d)) // %s\n")
+		c.emitLoad(instr, "s%d = uint64(m.readU32(%d, %d + s%d)) // %s\n")
 	case binary.I32Store, binary.F32Store:
-		c.emitStore(instr, "m.writeU32(%d + s%d, uint32(s%d)) // %s\n")
+		c.emitStore(instr, "m.writeU32(%d, %d + s%d, uint32(s%d)) // %s\n")
 	case binary.I64Store, binary.F64Store:
-		c.emitStore(instr, "m.writeU64(%d + s%d, s%d) // %s\n")
+		c.emitStore(instr, "m.writeU64(%d, %d + s%d, s%d) // %s\n")
 	case binary.I32Store8, binary.I64Store8:
-		c.emitStore(instr, "m.writeU8(%d + s%d, byte(s%d)) // %s\n")
+		c.emitStore(instr, "m.writeU8(%d, %d + s%d, byte(s%d)) // %s\n")
 	case binary.I32Store16, binary.I64Store16:
-		c.emitStore(instr, "m.writeU16(%d + s%d, uint16(s%d)) // %s\n")
+		c.emitStore(instr, "m.writeU16(%d, %d + s%d, uint16(s%d)) // %s\n")
 	case binary.I64Store32:
-		c.emitStore(instr, "m.writeU32(%d + s%d, uint32(s%d)) // %s\n")
+		c.emitStore(instr, "m.writeU32(%d, %d + s%d, uint32(s%d)) // %s\n")
 	case binary.MemorySize:
-		c.emitMemSize(opname)
+		c.emitMemSize(instr.Args.(uint32), opname)
 	case binary.MemoryGrow:
-		c.emitMemGrow(opname)
+		c.emitMemGrow(instr.Args.(uint32), opname)
 	case binary.I32Const:
 		c.emitConst(uint64(uint32(instr.Args.(int32))), opname, instr.Args)
 	case binary.I64Const:
@@ -355,7 +425,7 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.I64GeS:
 		c.emitI64BinCmpS(">=", opname)
 	case binary.I64GeU:
-		c.emitI32BinCmpU(">=", opname)
+		c.emitI64BinCmpU(">=", opname)
 	case binary.F32Eq:
 		c.emitF32BinCmp("==", opname)
 	case binary.F32Ne:
@@ -396,7 +466,9 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.I32Mul:
 		c.emitI32BinArithU("*", opname)
 	case binary.I32DivS:
-		c.emitI32BinArithS("/", opname)
+		c.printf("s%d = i32DivS(int32(s%d), int32(s%d)) // %s\n",
+			c.stackPtr-2, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPop()
 	case binary.I32DivU:
 		c.emitI32BinArithU("/", opname)
 	case binary.I32RemS:
@@ -445,7 +517,9 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.I64Mul:
 		c.emitI64BinArithU("*", opname)
 	case binary.I64DivS:
-		c.emitI64BinArithS("/", opname)
+		c.printf("s%d = i64DivS(int64(s%d), int64(s%d)) // %s\n",
+			c.stackPtr-2, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPop()
 	case binary.I64DivU:
 		c.emitI64BinArithU("/", opname)
 	case binary.I64RemS:
@@ -501,9 +575,9 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.F32Div:
 		c.emitF32BinArith("/", opname)
 	case binary.F32Min:
-		c.emitF32BinFC("math.Min", opname)
+		c.emitF32BinF("f32Min", opname)
 	case binary.F32Max:
-		c.emitF32BinFC("math.Max", opname)
+		c.emitF32BinF("f32Max", opname)
 	case binary.F32CopySign:
 		c.emitF32BinFC("math.Copysign", opname)
 	case binary.F64Abs:
@@ -529,25 +603,25 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.F64Div:
 		c.emitF64BinArith("/", opname)
 	case binary.F64Min:
-		c.emitF64BinFC("math.Min", opname)
+		c.emitF64BinFC("f64Min", opname)
 	case binary.F64Max:
-		c.emitF64BinFC("math.Max", opname)
+		c.emitF64BinFC("f64Max", opname)
 	case binary.F64CopySign:
 		c.emitF64BinFC("math.Copysign", opname)
 	case binary.I32WrapI64:
 		c.printf("s%d = uint64(uint32(s%d)) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I32TruncF32S:
-		c.printf("s%d = uint64(uint32(int32(math.Trunc(float64(_f32(s%d)))))) // %s\n",
+		c.printf("s%d = uint64(uint32(int32(truncF(float64(_f32(s%d)), -2147483648, 2147483648)))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I32TruncF32U:
-		c.printf("s%d = uint64(uint32(math.Trunc(float64(_f32(s%d))))) // %s\n",
+		c.printf("s%d = uint64(uint32(truncF(float64(_f32(s%d)), 0, 4294967296))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I32TruncF64S:
-		c.printf("s%d = uint64(uint32(int32(math.Trunc(_f64(s%d))))) // %s\n",
+		c.printf("s%d = uint64(uint32(int32(truncF(_f64(s%d), -2147483648, 2147483648)))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I32TruncF64U:
-		c.printf("s%d = uint64(uint32(math.Trunc(_f64(s%d)))) // %s\n",
+		c.printf("s%d = uint64(uint32(truncF(_f64(s%d), 0, 4294967296))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I64ExtendI32S:
 		c.printf("s%d = uint64(int64(int32(s%d))) // %s\n",
@@ -556,16 +630,16 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 		c.printf("s%d = uint64(uint32(s%d)) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I64TruncF32S:
-		c.printf("s%d = uint64(int64(math.Trunc(float64(_f32(s%d))))) // %s\n",
+		c.printf("s%d = uint64(int64(truncF(float64(_f32(s%d)), -9223372036854775808, 9223372036854775808))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I64TruncF32U:
-		c.printf("s%d = uint64(math.Trunc(float64(_f32(s%d)))) // %s\n",
+		c.printf("s%d = uint64(truncF(float64(_f32(s%d)), 0, 18446744073709551616)) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I64TruncF64S:
-		c.printf("s%d = uint64(int64(math.Trunc(_f64(s%d)))) // %s\n",
+		c.printf("s%d = uint64(int64(truncF(_f64(s%d), -9223372036854775808, 9223372036854775808))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.I64TruncF64U:
-		c.printf("s%d = uint64(math.Trunc(_f64(s%d))) // %s\n",
+		c.printf("s%d = uint64(truncF(_f64(s%d), 0, 18446744073709551616)) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
 	case binary.F32ConvertI32S:
 		c.printf("s%d = _u32(float32(int32(s%d))) // %s\n",
@@ -620,12 +694,40 @@ func (c *internalFuncCompiler) emitInstr(instr binary.Instruction) {
 	case binary.I64Extend32S:
 		c.printf("s%d = uint64(int64(int32(s%d))) // %s\n",
 			c.stackPtr-1, c.stackPtr-1, opname)
+	case binary.RefNull:
+		c.emitConst(0, opname, binary.ValTypeToStr(instr.Args.(binary.ValType)))
+	case binary.RefIsNull:
+		c.printf("s%d = b2i(s%d == 0) // %s\n",
+			c.stackPtr-1, c.stackPtr-1, opname)
+	case binary.RefFunc:
+		c.printf("s%d = m.refs.Put(m.funcRefs[%d]) // %s %d\n",
+			c.stackPush(), instr.Args, opname, instr.Args)
+	case binary.NumericPrefix:
+		c.emitNumericInstr(instr.Args.(binary.PrefixArgs), opname)
+	case binary.AtomicPrefix:
+		c.emitAtomicInstr(instr.Args.(binary.PrefixArgs), opname)
+	case binary.SimdPrefix:
+		panic(fmt.Errorf("unsupported instruction: %s", opname))
 	case 0xFF:
 	default:
 		c.printf("// 0x%X ???\n", instr.Opcode)
 	}
 }
 
+// code after br, br_table, return(_call), throws and unreachable is dead,
+// its operands may not even exist
+func (c *internalFuncCompiler) emitInstrs(instrs []binary.Instruction) {
+	for _, instr := range instrs {
+		c.emitInstr(instr)
+		switch instr.Opcode {
+		case binary.Br, binary.BrTable, binary.Return, binary.Unreachable,
+			binary.ReturnCall, binary.ReturnCallIndirect,
+			binary.Throw, binary.Rethrow, binary.ThrowRef:
+			return
+		}
+	}
+}
+
 /*
 l0: for {
 	... // break
@@ -642,10 +744,10 @@ func (c *internalFuncCompiler) emitBlock(
 	} else {
 		c.printf("{ // %s\n", c.getLabelName(c.blockDepth()-1))
 	}
-	for _, instr := range expr {
-		c.emitInstr(instr)
-	}
+	bi := c.blocks[len(c.blocks)-1]
+	c.emitInstrs(expr)
 	c.exitBlock()
+	c.stackPtr = bi.stackPtr + bi.resultCnt
 	if isBrTarget(expr) {
 		c.printIndentsPlus(1)
 		c.printf("break %s\n", c.getLabelName(c.blockDepth()))
@@ -680,6 +782,7 @@ l0: for {
 func (c *internalFuncCompiler) emitIf(ifArgs binary.IfArgs) {
 	bt := c.moduleInfo.module.GetBlockType(ifArgs.BT)
 	c.enterBlock(binary.If, bt)
+	bi := c.blocks[len(c.blocks)-1]
 	if isBrTarget(ifArgs.Instrs1) {
 		c.printIndentsPlus(-1)
 		c.printf("%s: for {\n", c.getLabelName(c.blockDepth()-1))
@@ -689,21 +792,18 @@ func (c *internalFuncCompiler) emitIf(ifArgs binary.IfArgs) {
 	c.printf("if s%d > 0 { // if@%d\n", c.stackPtr-1, len(c.blocks)-1)
 	c.stackPop()
 	stackPtr := c.stackPtr
-	for _, instr := range ifArgs.Instrs1 {
-		c.emitInstr(instr)
-	}
+	c.emitInstrs(ifArgs.Instrs1)
 	c.stackPtr = stackPtr
 	if len(ifArgs.Instrs2) > 0 {
 		c.printIndentsPlus(-1)
 		c.println("} else {")
 	}
-	for _, instr := range ifArgs.Instrs2 {
-		c.emitInstr(instr)
-	}
+	c.emitInstrs(ifArgs.Instrs2)
 	c.printIndentsPlus(-1)
 	c.printf("} // end if@%d\n", len(c.blocks)-1)
 
 	c.exitBlock()
+	c.stackPtr = bi.stackPtr + bi.resultCnt
 	if isBrTarget(ifArgs.Instrs1) {
 		c.printIndents()
 		c.printf("break } // end of %s\n", c.getLabelName(c.blockDepth()-1))
@@ -721,8 +821,7 @@ func (c *internalFuncCompiler) emitBr(labelIdx uint32) {
 		c.printf("s%d = s%d; ",
 			targetBlock.stackPtr+i, c.stackPtr-resultCnt+i)
 	}
-	c.printIf(targetBlock.opcode == binary.Loop, "continue", "break")
-	c.printf(" %s // br %d\n", c.getLabelName(n), labelIdx)
+	c.printf("%s // br %d\n", c.genJump(n), labelIdx)
 }
 func (c *internalFuncCompiler) emitBrIf(labelIdx uint32) {
 	n := len(c.blocks) - int(labelIdx) - 1
@@ -736,8 +835,7 @@ func (c *internalFuncCompiler) emitBrIf(labelIdx uint32) {
 		c.printf("s%d = s%d; ",
 			targetBlock.stackPtr+i, c.stackPtr-resultCnt+i-1)
 	}
-	c.printIf(targetBlock.opcode == binary.Loop, "continue", "break")
-	c.printf(" %s } // br_if %d\n", c.getLabelName(n), labelIdx)
+	c.printf("%s } // br_if %d\n", c.genJump(n), labelIdx)
 	c.stackPop()
 }
 func (c *internalFuncCompiler) emitBrTable(btArgs binary.BrTableArgs) {
@@ -763,21 +861,33 @@ func (c *internalFuncCompiler) emitBrTable(btArgs binary.BrTableArgs) {
 			c.printf("s%d = s%d; ",
 				targetBlock.stackPtr+i, c.stackPtr-resultCnt+i-1)
 		}
-		c.printIf(c.blocks[n].opcode == binary.Loop, "continue ", "break ")
-		c.printf("%s //\n", c.getLabelName(n))
+		c.printf("%s //\n", c.genJump(n))
 	}
 	c.printIndents()
 	c.println("}")
 	c.stackPop()
 }
 func (c *internalFuncCompiler) emitReturn() {
+	if c.innerTry() != nil {
+		for i := 0; i < c.nResults; i++ {
+			c.printf("s%d = s%d; ", i, c.stackPtr-c.nResults+i)
+		}
+		c.printf("%s // return\n", c.genJump(0))
+		return
+	}
 	//c.printf("return s%d // return\n", c.stackPtr-1)
 	c.print("return ")
+	c.printReturnVals()
+	c.println(" // return")
+}
+func (c *internalFuncCompiler) printReturnVals() {
 	for i := c.nResults - 1; i >= 0; i-- {
 		c.printIf(i < c.nResults-1, ", ", "")
 		c.printf("s%d", c.stackPtr-1-i)
 	}
-	c.println(" // return")
+	if c.tail {
+		c.printIf(c.nResults > 0, ", 0", "0")
+	}
 }
 
 func (c *internalFuncCompiler) emitCall(funcIdx int) {
@@ -796,62 +906,152 @@ func (c *internalFuncCompiler) emitCall(funcIdx int) {
 		c.printIf(i > 0, ", ", "")
 		c.printf("s%d", c.stackPtr+i)
 	}
-	c.stackPtr += resultCount
+	for i := 0; i < resultCount; i++ {
+		c.stackPush()
+	}
 	c.printf(") // call func#%d\n", funcIdx)
 }
-func (c *internalFuncCompiler) emitCallIndirect(typeIdx int) {
+func (c *internalFuncCompiler) emitCallIndirect(args binary.CallIndirectArgs) {
 	elemIdx := c.stackPtr - 1
 	c.stackPop()
 
-	ft := c.moduleInfo.module.TypeSec[typeIdx]
+	ft := c.moduleInfo.module.TypeSec[args.Type]
 	resultCount := len(ft.ResultTypes)
 	c.stackPtr -= len(ft.ParamTypes)
-	if resultCount > 0 {
-		c.printf("t%d, _ := ", c.tmpIdx)
-		c.tmpIdx++
-	}
+	c.printf("t%d, err := ", c.tmpIdx)
+	c.tmpIdx++
 
-	c.printf("m.table.GetElem(uint32(s%d)).Call(", elemIdx)
-	for i := range ft.ParamTypes {
+	c.printf("m.getElem(%d, s%d, %q).Call(", args.Table, elemIdx, ft.GetSignature())
+	for i, vt := range ft.ParamTypes {
 		c.printIf(i > 0, ", ", "")
-		c.printf("s%d", c.stackPtr+i) // TODO
+		switch vt {
+		case binary.ValTypeI32:
+			c.printf("int32(s%d)", c.stackPtr+i)
+		case binary.ValTypeI64:
+			c.printf("int64(s%d)", c.stackPtr+i)
+		case binary.ValTypeF32:
+			c.printf("_f32(s%d)", c.stackPtr+i)
+		case binary.ValTypeF64:
+			c.printf("_f64(s%d)", c.stackPtr+i)
+		case binary.ValTypeFuncRef, binary.ValTypeExternRef, binary.ValTypeExnRef:
+			c.printf("m.refs.Get(s%d)", c.stackPtr+i)
+		}
 	}
-	c.printf(") // call_indirect type#%d\n", typeIdx)
+	c.printf(") // call_indirect type#%d table#%d\n", args.Type, args.Table)
+	c.printIndents()
+	c.printf("if err != nil { panic(err) }\n")
 
 	if resultCount > 0 {
-		c.printIndents()
 		for i, vt := range ft.ResultTypes {
+			c.printIndents()
 			switch vt {
 			case binary.ValTypeI32:
-				c.printf("s%d = uint64(t%d[%d].(int32))\n", c.stackPtr, c.tmpIdx-1, i)
+				c.printf("s%d = uint64(uint32(t%d[%d].(int32)))\n", c.stackPtr, c.tmpIdx-1, i)
 			case binary.ValTypeI64:
 				c.printf("s%d = uint64(t%d[%d].(int64))\n", c.stackPtr, c.tmpIdx-1, i)
 			case binary.ValTypeF32:
 				c.printf("s%d = _u32(t%d[%d].(float32))\n", c.stackPtr, c.tmpIdx-1, i)
 			case binary.ValTypeF64:
 				c.printf("s%d = _u64(t%d[%d].(float64))\n", c.stackPtr, c.tmpIdx-1, i)
+			case binary.ValTypeFuncRef:
+				c.printf("s%d = m.putFuncRef(t%d[%d])\n", c.stackPtr, c.tmpIdx-1, i)
+			case binary.ValTypeExternRef, binary.ValTypeExnRef:
+				c.printf("s%d = m.refs.Put(t%d[%d])\n", c.stackPtr, c.tmpIdx-1, i)
 			}
-			c.stackPtr++
+			c.stackPush()
 		}
+	} else {
+		c.printIndents()
+		c.printf("_ = t%d\n", c.tmpIdx-1)
+	}
+}
+
+// return_call to an internal func returns the callee (plus one)
+// and leaves its args in m.tailArgs, the trampoline does the call
+func (c *internalFuncCompiler) emitReturnCall(funcIdx int) {
+	if funcIdx < len(c.moduleInfo.importedFuncs) {
+		c.emitCall(funcIdx)
+		c.printIndents()
+		c.emitReturn()
+		return
+	}
+	ft := c.moduleInfo.getFuncType(funcIdx)
+	c.stackPtr -= len(ft.ParamTypes)
+	c.emitTailArgs(c.stackPtr, len(ft.ParamTypes))
+	c.printf("%d // return_call func#%d\n", funcIdx+1, funcIdx)
+}
+func (c *internalFuncCompiler) emitReturnCallIndirect(args binary.CallIndirectArgs) {
+	if len(c.moduleInfo.tailRefs) > 0 {
+		ft := c.moduleInfo.module.TypeSec[args.Type]
+		elemIdx := c.stackPtr - 1
+		c.printf("if next, ok := m.tailRefs[m.getElem(%d, s%d, %q)]; ok {\n",
+			args.Table, elemIdx, ft.GetSignature())
+		c.printIndentsPlus(1)
+		c.emitTailArgs(elemIdx-len(ft.ParamTypes), len(ft.ParamTypes))
+		c.println("next // return_call_indirect")
+		c.printIndents()
+		c.println("}")
+		c.printIndents()
+	}
+	c.emitCallIndirect(args)
+	c.printIndents()
+	c.emitReturn()
+}
+func (c *internalFuncCompiler) emitTailArgs(argIdx, argCount int) {
+	c.print("m.tailArgs = append(m.tailArgs[:0]")
+	for i := 0; i < argCount; i++ {
+		c.printf(", s%d", argIdx+i)
+	}
+	c.print("); return ")
+	if t := c.innerTry(); t != nil {
+		t.tail = true
+		c.print("-")
+		return
+	}
+	for i := 0; i < c.nResults; i++ {
+		c.print("0, ")
+	}
+}
+
+func (c *internalFuncCompiler) emitGlobalGet(gIdx uint32, opname string) {
+	if binary.IsRefType(c.moduleInfo.globalTypes[gIdx].ValType) {
+		c.printf("s%d = m.refs.Put(m.globals[%d].Get()) // %s %d\n",
+			c.stackPush(), gIdx, opname, gIdx)
+	} else {
+		c.printf("s%d = m.globals[%d].GetAsU64() // %s %d\n",
+			c.stackPush(), gIdx, opname, gIdx)
+	}
+}
+
+func (c *internalFuncCompiler) emitGlobalSet(gIdx uint32, opname string) {
+	if binary.IsRefType(c.moduleInfo.globalTypes[gIdx].ValType) {
+		c.printf("m.globals[%d].Set(m.refs.Get(s%d)) // %s %d\n",
+			gIdx, c.stackPtr-1, opname, gIdx)
+	} else {
+		c.printf("m.globals[%d].SetAsU64(s%d) // %s %d\n",
+			gIdx, c.stackPtr-1, opname, gIdx)
 	}
+	c.stackPtr--
 }
 
 func (c *internalFuncCompiler) emitLoad(instr binary.Instruction, tmpl string) {
-	// s%d = m.readU32(%d + s%d) // %s\n
-	c.printf(tmpl, c.stackPtr-1, instr.Args.(binary.MemArg).Offset, c.stackPtr-1, instr.GetOpname())
+	// s%d = m.readU32(%d, %d + s%d) // %s\n
+	memArg := instr.Args.(binary.MemArg)
+	c.printf(tmpl, c.stackPtr-1, memArg.Mem, memArg.Offset, c.stackPtr-1, instr.GetOpname())
 }
 func (c *internalFuncCompiler) emitStore(instr binary.Instruction, tmpl string) {
-	// m.writeU32(%d + s%d, uint32(s%d)) // %s\n
-	c.printf(tmpl, instr.Args.(binary.MemArg).Offset, c.stackPtr-2, c.stackPtr-1, instr.GetOpname())
+	// m.writeU32(%d, %d + s%d, uint32(s%d)) // %s\n
+	memArg := instr.Args.(binary.MemArg)
+	c.printf(tmpl, memArg.Mem, memArg.Offset, c.stackPtr-2, c.stackPtr-1, instr.GetOpname())
 	c.stackPtr -= 2
 }
-func (c *internalFuncCompiler) emitMemSize(opname string) {
-	c.printf("s%d = uint64(m.memory.Size()) // %s\n",
-		c.stackPush(), opname)
+func (c *internalFuncCompiler) emitMemSize(memIdx uint32, opname string) {
+	c.printf("s%d = uint64(m.memories[%d].Size()) // %s\n",
+		c.stackPush(), memIdx, opname)
 }
-func (c *internalFuncCompiler) emitMemGrow(opname string) {
-	c.printf("s%d = uint64(m.memory.Grow(uint32(s%d))) // %s\n",
-		c.stackPtr-1, c.stackPtr-1, opname)
+func (c *internalFuncCompiler) emitMemGrow(memIdx uint32, opname string) {
+	c.printf("s%d = uint64(uint32(m.memories[%d].Grow(uint64(uint32(s%d))))) // %s\n",
+		c.stackPtr-1, memIdx, c.stackPtr-1, opname)
 }
 
 func (c *internalFuncCompiler) emitConst(val uint64, opname string, arg interface{}) {
@@ -921,6 +1121,116 @@ func (c *internalFuncCompiler) emitF32BinFC(funcName, opname string) {
 	c.stackPop()
 }
 
+func (c *internalFuncCompiler) emitF32BinF(funcName, opname string) {
+	c.printf("s%d = _u32(%s(_f32(s%d), _f32(s%d))) // %s\n",
+		c.stackPtr-2, funcName, c.stackPtr-2, c.stackPtr-1, opname)
+	c.stackPop()
+}
+
+func (c *internalFuncCompiler) emitNumericInstr(args binary.PrefixArgs, opname string) {
+	switch args.SubOp {
+	case binary.MemoryInit:
+		initArgs := args.Args.(binary.MemoryInitArgs)
+		c.printf("m.memoryInit(%d, %d, uint32(s%d), uint32(s%d), uint32(s%d)) // %s\n",
+			initArgs.Mem, initArgs.Data, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	case binary.DataDrop:
+		c.printf("m.datas[%d] = nil // %s\n", args.Args, opname)
+	case binary.MemoryCopy:
+		copyArgs := args.Args.(binary.MemoryCopyArgs)
+		c.printf("m.memoryCopy(%d, %d, uint32(s%d), uint32(s%d), uint32(s%d)) // %s\n",
+			copyArgs.Dst, copyArgs.Src, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	case binary.MemoryFill:
+		c.printf("m.memoryFill(%d, uint32(s%d), byte(s%d), uint32(s%d)) // %s\n",
+			args.Args, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	case binary.TableInit:
+		initArgs := args.Args.(binary.TableInitArgs)
+		c.printf("m.tableInit(%d, %d, uint32(s%d), uint32(s%d), uint32(s%d)) // %s\n",
+			initArgs.Table, initArgs.Elem, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	case binary.ElemDrop:
+		c.printf("m.elems[%d] = nil // %s\n", args.Args, opname)
+	case binary.TableCopy:
+		copyArgs := args.Args.(binary.TableCopyArgs)
+		c.printf("m.tableCopy(%d, %d, uint32(s%d), uint32(s%d), uint32(s%d)) // %s\n",
+			copyArgs.Dst, copyArgs.Src, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	case binary.TableGrow:
+		c.printf("s%d = uint64(m.tables[%d].Grow(uint32(s%d), m.refs.Get(s%d))) // %s\n",
+			c.stackPtr-2, args.Args, c.stackPtr-1, c.stackPtr-2, opname)
+		c.stackPtr--
+	case binary.TableSize:
+		c.printf("s%d = uint64(m.tables[%d].Size()) // %s\n", c.stackPush(), args.Args, opname)
+	case binary.TableFill:
+		c.printf("m.tableFill(%d, uint32(s%d), s%d, uint32(s%d)) // %s\n",
+			args.Args, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 3
+	default:
+		c.emitTruncSat(args.SubOp, opname)
+	}
+}
+
+// atomics go through the interpreter helpers which lock shared memories
+func (c *internalFuncCompiler) emitAtomicInstr(args binary.PrefixArgs, opname string) {
+	if args.SubOp == binary.AtomicFence {
+		c.printf("// %s\n", opname)
+		return
+	}
+	memArg := args.Args.(binary.MemArg)
+	switch op := args.SubOp; op {
+	case binary.MemoryAtomicNotify:
+		c.printf("s%d = uint64(interpreter.AtomicNotify(m.memories[%d], %d + s%d, uint32(s%d))) // %s\n",
+			c.stackPtr-2, memArg.Mem, memArg.Offset, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr--
+	case binary.MemoryAtomicWait32:
+		c.printf("s%d = uint64(interpreter.AtomicWait(m.memories[%d], %d + s%d, 4, uint64(uint32(s%d)), int64(s%d))) // %s\n",
+			c.stackPtr-3, memArg.Mem, memArg.Offset, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 2
+	case binary.MemoryAtomicWait64:
+		c.printf("s%d = uint64(interpreter.AtomicWait(m.memories[%d], %d + s%d, 8, s%d, int64(s%d))) // %s\n",
+			c.stackPtr-3, memArg.Mem, memArg.Offset, c.stackPtr-3, c.stackPtr-2, c.stackPtr-1, opname)
+		c.stackPtr -= 2
+	default:
+		_, bitWidth := binary.AtomicAccess(op)
+		switch group := (op - binary.I32AtomicLoad) / 7; group {
+		case 0: // load
+			c.printf("s%d = interpreter.AtomicRMW(m.memories[%d], %d + s%d, %d, nil) // %s\n",
+				c.stackPtr-1, memArg.Mem, memArg.Offset, c.stackPtr-1, bitWidth/8, opname)
+		case 1: // store
+			c.printf("interpreter.AtomicRMW(m.memories[%d], %d + s%d, %d, func(uint64) uint64 { return s%d }) // %s\n",
+				memArg.Mem, memArg.Offset, c.stackPtr-2, bitWidth/8, c.stackPtr-1, opname)
+			c.stackPtr -= 2
+		case 8: // cmpxchg
+			c.printf("s%d = interpreter.AtomicRMW(m.memories[%d], %d + s%d, %d, func(v uint64) uint64 { if v == s%d&%#x { return s%d }; return v }) // %s\n",
+				c.stackPtr-3, memArg.Mem, memArg.Offset, c.stackPtr-3, bitWidth/8,
+				c.stackPtr-2, uint64(1)<<bitWidth-1, c.stackPtr-1, opname)
+			c.stackPtr -= 2
+		default:
+			expr := []string{"v + s%d", "v - s%d", "v & s%d", "v | s%d", "v ^ s%d", "s%d"}[group-2]
+			c.printf("s%d = interpreter.AtomicRMW(m.memories[%d], %d + s%d, %d, func(v uint64) uint64 { return "+expr+" }) // %s\n",
+				c.stackPtr-2, memArg.Mem, memArg.Offset, c.stackPtr-2, bitWidth/8, c.stackPtr-1, opname)
+			c.stackPtr--
+		}
+	}
+}
+
+func (c *internalFuncCompiler) emitTruncSat(subOp uint32, opname string) {
+	tmpl := []string{
+		"uint64(uint32(int32(truncSatS(float64(_f32(s%d)), 32))))",
+		"uint64(uint32(truncSatU(float64(_f32(s%d)), 32)))",
+		"uint64(uint32(int32(truncSatS(_f64(s%d), 32))))",
+		"uint64(uint32(truncSatU(_f64(s%d), 32)))",
+		"uint64(truncSatS(float64(_f32(s%d)), 64))",
+		"truncSatU(float64(_f32(s%d)), 64)",
+		"uint64(truncSatS(_f64(s%d), 64))",
+		"truncSatU(_f64(s%d), 64)",
+	}[subOp]
+	c.printf("s%d = "+tmpl+" // %s\n",
+		c.stackPtr-1, c.stackPtr-1, opname)
+}
+
 func (c *internalFuncCompiler) emitF64BinCmp(operator, opname string) {
 	c.printf("s%d = b2i(_f64(s%d) %s _f64(s%d)) // %s\n",
 		c.stackPtr-2, c.stackPtr-2, operator, c.stackPtr-1, opname)
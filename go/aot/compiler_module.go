@@ -1,9 +1,12 @@
 package aot
 
 import (
+	"fmt"
 	"math"
+	"strings"
 
 	"wasm.go/binary"
+	"wasm.go/validator"
 )
 
 type moduleCompiler struct {
@@ -12,19 +15,36 @@ type moduleCompiler struct {
 }
 
 func (c *moduleCompiler) compile() {
+	c.checkSupported()
 	c.genModule()
 	c.genDummy()
 	c.genNew()
 	c.println("")
 	c.genMemInit()
 	c.println("")
+	c.genTableInit()
+	c.println("")
 	c.genExternalFuncs()
 	c.genInternalFuncs()
+	c.genTailDispatchers()
 	c.genExportedFuncs()
+	c.genRefFuncs()
 	c.genInstanceImpl()
 	c.genUtils()
 }
 
+// Features are the proposals the generated code supports: it keeps
+// every value in a uint64, so v128 can't be compiled, and addresses
+// are assumed to be i32.
+var Features = binary.Features{}.Disable(binary.FeatureSIMD | binary.FeatureMemory64)
+
+func (c *moduleCompiler) checkSupported() {
+	opts := binary.DecodeOptions{Features: Features}
+	if err := validator.ValidateWithOptions(c.module, opts); err != nil {
+		panic(err)
+	}
+}
+
 func (c *moduleCompiler) genModule() {
 	c.print(`// Code generated by wasm.go. DO NOT EDIT.
 
@@ -32,6 +52,7 @@ package main
 
 import (
 	gobin "encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/bits"
@@ -45,9 +66,29 @@ var LE = gobin.LittleEndian
 
 type aotModule struct {
 	importedFuncs []instance.Function
-	table         instance.Table
-	memory        instance.Memory
+	funcRefs      []instance.Function // nil if never referenced
+	tables        []instance.Table
+	memories      []instance.Memory
 	globals       []instance.Global
+	tags          []instance.Tag
+	elems         [][]interface{} // nil if dropped
+	datas         [][]byte        // nil if dropped
+	refs          interpreter.RefStore
+	tailArgs      []uint64                  // args of the pending tail call
+	tailRefs      map[instance.Function]int // return_call_indirect targets
+}
+
+type aotFunc struct {
+	_type binary.FuncType
+	call  func(args []interface{}) ([]interface{}, error)
+}
+
+func (f aotFunc) Type() binary.FuncType {
+	return f._type
+}
+
+func (f aotFunc) Call(args ...interface{}) ([]interface{}, error) {
+	return safeCall(f.call, args)
 }
 `)
 }
@@ -70,68 +111,239 @@ func (c *moduleCompiler) genNew() {
 func Instantiate(mm instance.Map) (instance.Module, error) {
 	m := &aotModule{
 		importedFuncs: make([]instance.Function, %d),
+		funcRefs:      make([]instance.Function, %d),
+		tables:        make([]instance.Table, %d),
+		memories:      make([]instance.Memory, %d),
 		globals:       make([]instance.Global, %d),
+		tags:          make([]instance.Tag, %d),
+		elems:         make([][]interface{}, %d),
+		datas:         make([][]byte, %d),
 	}
-`, funcCount, globalCount)
+`, funcCount, funcCount+len(c.module.FuncSec), len(c.importedTables)+len(c.module.TableSec),
+		len(c.importedMemories)+len(c.module.MemSec), globalCount, len(c.tagTypes),
+		len(c.module.ElemSec), len(c.module.DataSec))
 
 	for i, imp := range c.importedFuncs {
 		ft := c.module.TypeSec[imp.Desc.FuncType]
 		c.printf(`	m.importedFuncs[%d] = mm["%s"].GetMember("%s").(instance.Function) // %s%s`,
 			i, imp.Module, imp.Name, ft.GetSignature(), "\n")
 	}
-	if len(c.importedTables) > 0 {
-		c.printf(`	m.table = mm["%s"].GetMember("%s").(instance.Table)%s`,
-			c.importedTables[0].Module, c.importedTables[0].Name, "\n")
-	} else if len(c.module.TableSec) > 0 {
-		c.printf("	m.table = interpreter.NewTable(%d, %d)\n",
-			c.module.TableSec[0].Limits.Min, c.module.TableSec[0].Limits.Max)
+	for _, fIdx := range c.refFuncs {
+		if int(fIdx) < funcCount {
+			c.printf("	m.funcRefs[%d] = m.importedFuncs[%d]\n", fIdx, fIdx)
+		} else {
+			c.printf("	m.funcRefs[%d] = &aotFunc{%s, m.ref%d}\n",
+				fIdx, genFuncType(c.getFuncType(int(fIdx))), fIdx)
+		}
+	}
+	if len(c.tailRefs) > 0 {
+		c.println("	m.tailRefs = map[instance.Function]int{}")
+	}
+	for _, fIdx := range c.tailRefs {
+		c.printf("	m.tailRefs[m.funcRefs[%d]] = %d\n", fIdx, fIdx+1)
+	}
+	for i, imp := range c.importedTables {
+		c.printf(`	m.tables[%d] = mm["%s"].GetMember("%s").(instance.Table)%s`,
+			i, imp.Module, imp.Name, "\n")
+	}
+	for i, tt := range c.module.TableSec {
+		c.printf("	m.tables[%d] = interpreter.NewTable(%d, %d, %d)\n",
+			len(c.importedTables)+i, tt.ElemType, tt.Limits.Min, tt.Limits.Max)
+	}
+	for i, imp := range c.importedMemories {
+		c.printf(`	m.memories[%d] = mm["%s"].GetMember("%s").(instance.Memory)%s`,
+			i, imp.Module, imp.Name, "\n")
 	}
-	if len(c.importedMemories) > 0 {
-		c.printf(`	m.memory = mm["%s"].GetMember("%s").(instance.Memory)%s`,
-			c.importedTables[0].Module, c.importedTables[0].Name, "\n")
-	} else if len(c.module.MemSec) > 0 {
-		c.printf("	m.memory = interpreter.NewMemory(%d, %d)\n",
-			c.module.MemSec[0].Min, c.module.MemSec[0].Max)
+	for i, mt := range c.module.MemSec {
+		newMemory := "NewMemory"
+		if mt.Shared {
+			newMemory = "NewSharedMemory"
+		}
+		c.printf("	m.memories[%d] = interpreter.%s(%d, %d)\n",
+			len(c.importedMemories)+i, newMemory, mt.Min, mt.Max)
 	}
 	for i, imp := range c.importedGlobals {
 		c.printf(`	m.globals[%d] = mm["%s"].GetMember("%s").(instance.Global)%s`,
 			i, imp.Module, imp.Name, "\n")
 	}
 	for i, g := range c.module.GlobalSec {
-		c.printf("	m.globals[%d] = interpreter.NewGlobal(%d, %t, %d)\n",
-			len(c.importedGlobals)+i, g.Type.ValType, g.Type.Mut == 1, getConstVal(g.Init))
+		gIdx := len(c.importedGlobals) + i
+		if !binary.IsRefType(g.Type.ValType) {
+			c.printf("	m.globals[%d] = interpreter.NewGlobal(%d, %t, %s)\n",
+				gIdx, g.Type.ValType, g.Type.Mut == 1, genConstExpr(g.Init))
+			continue
+		}
+		c.printf("	m.globals[%d] = interpreter.NewGlobal(%d, %t, 0)\n",
+			gIdx, g.Type.ValType, g.Type.Mut == 1)
+		if ref := genRefConstExpr(g.Init); ref != "nil" {
+			c.printf("	m.globals[%d].Set(%s)\n", gIdx, ref)
+		}
+	}
+
+	for i, imp := range c.importedTags {
+		c.printf(`	m.tags[%d] = mm["%s"].GetMember("%s").(instance.Tag)%s`,
+			i, imp.Module, imp.Name, "\n")
+	}
+	for i := range c.module.TagSec {
+		tIdx := len(c.importedTags) + i
+		c.printf("	m.tags[%d] = interpreter.NewTag(%s...)\n",
+			tIdx, genValTypes(c.tagTypes[tIdx].ParamTypes))
 	}
 
-	c.println("	m.initMem()")
-	c.println("	return m, nil // TODO\n}")
+	c.print(`	if _, err := safeCall(func([]interface{}) ([]interface{}, error) {
+		m.initTable()
+		m.initMem()
+		return nil, nil
+	}, nil); err != nil {
+		return nil, err
+	}
+`)
+	if c.module.StartSec != nil {
+		c.printf(`	if _, err := safeCall(func([]interface{}) ([]interface{}, error) {
+		m.f%d()
+		return nil, nil
+	}, nil); err != nil {
+		return nil, err
+	}
+`, *c.module.StartSec)
+	}
+	c.println("	return m, nil\n}")
 }
 
 func (c *moduleCompiler) genMemInit() {
 	c.println("func (m *aotModule) initMem() {")
-	for _, data := range c.module.DataSec {
-		if len(data.Init) > 0 {
-			offset := getConstVal(data.Offset)
-			c.printf("	m.memory.Write(%d, []byte(%q))\n",
-				offset, data.Init)
+	for i, data := range c.module.DataSec {
+		c.printf("	m.datas[%d] = []byte(%q)\n", i, data.Init)
+		if data.Mode == binary.SegModeActive {
+			c.printf("	m.memoryInit(%d, %d, uint32(%s), 0, %d)\n",
+				data.Mem, i, genConstExpr(data.Offset), len(data.Init))
+			c.printf("	m.datas[%d] = nil\n", i)
+		}
+	}
+	c.println("}")
+}
+
+func (c *moduleCompiler) genTableInit() {
+	c.println("func (m *aotModule) initTable() {")
+	for i, elem := range c.module.ElemSec {
+		if elem.Mode == binary.SegModeDeclarative {
+			continue
+		}
+		c.printf("	m.elems[%d] = []interface{}{", i)
+		for j, fIdx := range elem.Init {
+			c.printIf(j > 0, ", ", "")
+			c.printf("m.funcRefs[%d]", fIdx)
+		}
+		for j, expr := range elem.Exprs {
+			c.printIf(j > 0, ", ", "")
+			c.print(genRefConstExpr(expr))
+		}
+		c.println("}")
+		if elem.Mode == binary.SegModeActive {
+			c.printf("	m.tableInit(%d, %d, uint32(%s), 0, %d)\n",
+				elem.Table, i, genConstExpr(elem.Offset), elem.Len())
+			c.printf("	m.elems[%d] = nil\n", i)
 		}
 	}
 	c.println("}")
 }
 
-func getConstVal(constExpr []binary.Instruction) interface{} {
+// operand of an extended constant expression, constants are folded
+// since Go rejects overflowing constant arithmetic
+type constOperand struct {
+	expr    string
+	val     uint64
+	isConst bool
+}
+
+// the value of a constant expression as uint64,
+// globals are initialized before the memory and the table
+func genConstExpr(constExpr []binary.Instruction) string {
 	if len(constExpr) == 0 {
-		return 0
+		return "0"
+	}
+	var stack []constOperand
+	push := func(val uint64) {
+		stack = append(stack, constOperand{val: val, isConst: true})
+	}
+	for _, instr := range constExpr {
+		switch instr.Opcode {
+		case binary.I32Const:
+			push(uint64(uint32(instr.Args.(int32))))
+		case binary.I64Const:
+			push(uint64(instr.Args.(int64)))
+		case binary.F32Const:
+			push(uint64(math.Float32bits(instr.Args.(float32))))
+		case binary.F64Const:
+			push(math.Float64bits(instr.Args.(float64)))
+		case binary.GlobalGet:
+			stack = append(stack, constOperand{
+				expr: fmt.Sprintf("m.globals[%d].GetAsU64()", instr.Args.(uint32))})
+		case binary.I32Add, binary.I32Sub, binary.I32Mul,
+			binary.I64Add, binary.I64Sub, binary.I64Mul:
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			if a.isConst && b.isConst {
+				push(foldConstOp(instr.Opcode, a.val, b.val))
+			} else {
+				stack = append(stack, constOperand{expr: genConstOp(instr.Opcode, a, b)})
+			}
+		default:
+			panic(fmt.Errorf("unsupported constant expression: %s", instr))
+		}
+	}
+	return stack[0].String()
+}
+
+func (opd constOperand) String() string {
+	if opd.isConst {
+		return fmt.Sprintf("%d", opd.val)
+	}
+	return opd.expr
+}
+
+func foldConstOp(opcode byte, a, b uint64) uint64 {
+	var r uint64
+	switch opcode {
+	case binary.I32Add, binary.I64Add:
+		r = a + b
+	case binary.I32Sub, binary.I64Sub:
+		r = a - b
+	default:
+		r = a * b
+	}
+	if opcode < binary.I64Add {
+		r = uint64(uint32(r))
+	}
+	return r
+}
+
+func genConstOp(opcode byte, a, b constOperand) string {
+	op := map[byte]string{
+		binary.I32Add: "+", binary.I32Sub: "-", binary.I32Mul: "*",
+		binary.I64Add: "+", binary.I64Sub: "-", binary.I64Mul: "*",
+	}[opcode]
+	if opcode < binary.I64Add {
+		return fmt.Sprintf("uint64(uint32(%s) %s uint32(%s))", a, op, b)
+	}
+	return fmt.Sprintf("(%s %s %s)", a, op, b)
+}
+
+// the value of a constant expression of a reference type
+func genRefConstExpr(constExpr []binary.Instruction) string {
+	if len(constExpr) == 0 {
+		return "nil"
 	}
 	instr := constExpr[len(constExpr)-1]
 	switch instr.Opcode {
-	case binary.I32Const, binary.I64Const:
-		return instr.Args
-	case binary.F32Const:
-		return math.Float32bits(instr.Args.(float32))
-	case binary.F64Const:
-		return math.Float64bits(instr.Args.(float64))
+	case binary.RefNull:
+		return "nil"
+	case binary.RefFunc:
+		return fmt.Sprintf("m.funcRefs[%d]", instr.Args.(uint32))
+	case binary.GlobalGet:
+		return fmt.Sprintf("m.globals[%d].Get()", instr.Args.(uint32))
 	default:
-		panic("TODO")
+		panic(fmt.Errorf("unsupported constant expression: %s", instr))
 	}
 }
 
@@ -156,6 +368,60 @@ func (c *moduleCompiler) genInternalFuncs() {
 	}
 }
 
+// tailN(next) calls func next-1 which returns the types of
+// tail group N, see internalFuncCompiler.genTailTrampoline()
+func (c *moduleCompiler) genTailDispatchers() {
+	for i, g := range c.tailGroups {
+		resultCount := len(g.resultTypes)
+		ft := binary.FuncType{ResultTypes: g.resultTypes}
+		c.printf("// tail calls %s\n", ft.GetSignature())
+		c.printf("func (m *aotModule) tail%d(next int)", i)
+		if resultCount == 0 {
+			c.println(" int {")
+		} else {
+			c.print(" (")
+			for j := 0; j < resultCount; j++ {
+				c.print("uint64, ")
+			}
+			c.println("int) {")
+		}
+		if len(g.funcs) > 0 {
+			c.println("	a := m.tailArgs")
+		}
+		c.println("	switch next - 1 {")
+		for _, fIdx := range g.funcs {
+			paramCount := len(c.getFuncType(int(fIdx)).ParamTypes)
+			args := ""
+			for j := 0; j < paramCount; j++ {
+				if j > 0 {
+					args += ", "
+				}
+				args += fmt.Sprintf("a[%d]", j)
+			}
+			c.printf("	case %d:\n", fIdx)
+			if c.tailFuncs[int(fIdx)] {
+				c.printf("		return m.f%d_tail(%s)\n", fIdx, args)
+				continue
+			}
+			results := ""
+			for j := 0; j < resultCount; j++ {
+				results += fmt.Sprintf("r%d, ", j)
+			}
+			if resultCount > 0 {
+				c.printf("		%s := m.f%d(%s)\n",
+					strings.TrimSuffix(results, ", "), fIdx, args)
+			} else {
+				c.printf("		m.f%d(%s)\n", fIdx, args)
+			}
+			c.printf("		return %s0\n", results)
+		}
+		c.println("	}")
+		c.println("	panic(\"unreachable\")")
+		c.println("}")
+		c.println("")
+	}
+}
+
 func (c *moduleCompiler) genExportedFuncs() {
 	for i, exp := range c.module.ExportSec {
 		if exp.Desc.Tag == binary.ExportTagFunc {
@@ -163,7 +429,20 @@ func (c *moduleCompiler) genExportedFuncs() {
 			fIdx := int(exp.Desc.Idx)
 			ft := c.getFuncType(fIdx)
 			c.printf("// %s %s\n", exp.Name, ft.GetSignature())
-			c.println(fc.compile(i, fIdx, ft))
+			c.println(fc.compile(fmt.Sprintf("exported%d", i), fIdx, ft))
+		}
+	}
+}
+
+// funcs referenced by element segments or ref.func,
+// imported ones are referenced as they are
+func (c *moduleCompiler) genRefFuncs() {
+	for _, fIdx := range c.refFuncs {
+		if int(fIdx) >= len(c.importedFuncs) {
+			fc := newExportedFuncCompiler(len(c.importedFuncs))
+			ft := c.getFuncType(int(fIdx))
+			c.printf("// func#%d %s\n", fIdx, ft.GetSignature())
+			c.println(fc.compile(fmt.Sprintf("ref%d", fIdx), int(fIdx), ft))
 		}
 	}
 }
@@ -175,18 +454,42 @@ func (c *moduleCompiler) genInstanceImpl() {
 }
 
 func (c *moduleCompiler) genGetMember() {
-	c.print(`// instance.Instance
-func (m *aotModule) GetMember(name string) interface{} {
-	panic("TODO")
-}`)
+	c.println(`// instance.Instance
+func (m *aotModule) GetMember(name string) interface{} {`)
+	c.println("	switch name {")
+	for i, exp := range c.module.ExportSec {
+		c.printf("	case %q: ", exp.Name)
+		switch exp.Desc.Tag {
+		case binary.ExportTagFunc:
+			ft := c.getFuncType(int(exp.Desc.Idx))
+			c.printf("return aotFunc{%s, m.exported%d}\n", genFuncType(ft), i)
+		case binary.ExportTagTable:
+			c.printf("return m.tables[%d]\n", exp.Desc.Idx)
+		case binary.ExportTagMem:
+			c.printf("return m.memories[%d]\n", exp.Desc.Idx)
+		case binary.ExportTagGlobal:
+			c.printf("return m.globals[%d]\n", exp.Desc.Idx)
+		case binary.ExportTagTag:
+			c.printf("return m.tags[%d]\n", exp.Desc.Idx)
+		}
+	}
+	c.println("	}")
+	c.print("	return nil\n}")
 }
 func (c *moduleCompiler) genAccGlobalVal() {
 	c.print(`
 func (m *aotModule) GetGlobalVal(name string) (interface{}, error) {
-	panic("TODO")
+	if g, ok := m.GetMember(name).(instance.Global); ok {
+		return g.Get(), nil
+	}
+	return nil, fmt.Errorf("global not found: %s", name)
 }
 func (m *aotModule) SetGlobalVal(name string, val interface{}) error {
-	panic("TODO")
+	if g, ok := m.GetMember(name).(instance.Global); ok {
+		g.Set(val)
+		return nil
+	}
+	return fmt.Errorf("global not found: %s", name)
 }`)
 }
 func (c *moduleCompiler) genInvokeFunc() {
@@ -194,8 +497,8 @@ func (c *moduleCompiler) genInvokeFunc() {
 	c.println(`func (m *aotModule) InvokeFunc(name string, args ...interface{}) ([]interface{}, error) {`)
 	c.println("	switch name {")
 	for i, exp := range c.module.ExportSec {
-		if exp.Desc.Tag == binary.ImportTagFunc {
-			c.printf("	case \"%s\": return m.exported%d(args)\n", exp.Name, i)
+		if exp.Desc.Tag == binary.ExportTagFunc {
+			c.printf("	case \"%s\": return safeCall(m.exported%d, args)\n", exp.Name, i)
 		}
 	}
 	c.println(`	default: return nil, fmt.Errorf("func not found: %s", name)`)
@@ -203,50 +506,309 @@ func (c *moduleCompiler) genInvokeFunc() {
 	c.println("}")
 }
 
+// binary.FuncType{ParamTypes: []binary.ValType{...}, ResultTypes: ...}
+func genFuncType(ft binary.FuncType) string {
+	return fmt.Sprintf("binary.FuncType{ParamTypes: %s, ResultTypes: %s}",
+		genValTypes(ft.ParamTypes), genValTypes(ft.ResultTypes))
+}
+func genValTypes(vts []binary.ValType) string {
+	s := "[]binary.ValType{"
+	for i, vt := range vts {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("0x%X", vt)
+	}
+	return s + "}"
+}
+
 func (c *moduleCompiler) genUtils() {
 	c.print(`
 // memory read
-func (m *aotModule) readU8(offset uint64) byte {
+func (m *aotModule) readU8(mem uint32, offset uint64) byte {
 	var buf [1]byte
-	m.memory.Read(offset, buf[:])
+	m.memories[mem].Read(offset, buf[:])
 	return buf[0]
 }
-func (m *aotModule) readU16(offset uint64) uint16 {
+func (m *aotModule) readU16(mem uint32, offset uint64) uint16 {
 	var buf [2]byte
-	m.memory.Read(offset, buf[:])
+	m.memories[mem].Read(offset, buf[:])
 	return LE.Uint16(buf[:])
 }
-func (m *aotModule) readU32(offset uint64) uint32 {
+func (m *aotModule) readU32(mem uint32, offset uint64) uint32 {
 	var buf [4]byte
-	m.memory.Read(offset, buf[:])
+	m.memories[mem].Read(offset, buf[:])
 	return LE.Uint32(buf[:])
 }
-func (m *aotModule) readU64(offset uint64) uint64 {
+func (m *aotModule) readU64(mem uint32, offset uint64) uint64 {
 	var buf [8]byte
-	m.memory.Read(offset, buf[:])
+	m.memories[mem].Read(offset, buf[:])
 	return LE.Uint64(buf[:])
 }
 
 // memory write
-func (m *aotModule) writeU8(offset uint64, n byte) {
+func (m *aotModule) writeU8(mem uint32, offset uint64, n byte) {
 	var buf [1]byte
 	buf[0] = n
-	m.memory.Write(offset, buf[:])
+	m.memories[mem].Write(offset, buf[:])
 }
-func (m *aotModule) writeU16(offset uint64, n uint16) {
+func (m *aotModule) writeU16(mem uint32, offset uint64, n uint16) {
 	var buf [2]byte
 	LE.PutUint16(buf[:], n)
-	m.memory.Write(offset, buf[:])
+	m.memories[mem].Write(offset, buf[:])
 }
-func (m *aotModule) writeU32(offset uint64, n uint32) {
+func (m *aotModule) writeU32(mem uint32, offset uint64, n uint32) {
 	var buf [4]byte
 	LE.PutUint32(buf[:], n)
-	m.memory.Write(offset, buf[:])
+	m.memories[mem].Write(offset, buf[:])
 }
-func (m *aotModule) writeU64(offset uint64, n uint64) {
+func (m *aotModule) writeU64(mem uint32, offset uint64, n uint64) {
 	var buf [8]byte
 	LE.PutUint64(buf[:], n)
-	m.memory.Write(offset, buf[:])
+	m.memories[mem].Write(offset, buf[:])
+}
+
+// bulk memory
+var errMemOutOfBounds = errors.New("out of bounds memory access")
+
+func (m *aotModule) memoryInit(mem, x, d, s, n uint32) {
+	data := m.datas[x]
+	memSize := uint64(m.memories[mem].Size()) * binary.PageSize
+	if uint64(s)+uint64(n) > uint64(len(data)) || uint64(d)+uint64(n) > memSize {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		m.memories[mem].Write(uint64(d), data[s:s+n])
+	}
+}
+func (m *aotModule) memoryCopy(dm, sm, d, s, n uint32) {
+	dst, src := m.memories[dm], m.memories[sm]
+	if uint64(s)+uint64(n) > uint64(src.Size())*binary.PageSize ||
+		uint64(d)+uint64(n) > uint64(dst.Size())*binary.PageSize {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		buf := make([]byte, n)
+		src.Read(uint64(s), buf)
+		dst.Write(uint64(d), buf)
+	}
+}
+func (m *aotModule) memoryFill(mem, d uint32, val byte, n uint32) {
+	memSize := uint64(m.memories[mem].Size()) * binary.PageSize
+	if uint64(d)+uint64(n) > memSize {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = val
+		}
+		m.memories[mem].Write(uint64(d), buf)
+	}
+}
+
+// call_indirect
+func (m *aotModule) getElem(t uint32, idx uint64, sig string) instance.Function {
+	table := m.tables[t]
+	if uint32(idx) >= table.Size() {
+		panic(errors.New("undefined element"))
+	}
+	f, ok := table.GetElem(uint32(idx)).(instance.Function)
+	if !ok {
+		panic(errors.New("uninitialized element"))
+	}
+	if f.Type().GetSignature() != sig {
+		panic(errors.New("indirect call type mismatch"))
+	}
+	return f
+}
+
+// references
+func (m *aotModule) putFuncRef(val interface{}) uint64 {
+	if _, ok := val.(instance.Function); !ok && val != nil {
+		panic(fmt.Errorf("not a funcref: %v", val))
+	}
+	return m.refs.Put(val)
+}
+
+// tables
+var errTableOutOfBounds = errors.New("out of bounds table access")
+
+func (m *aotModule) tableFill(t, i uint32, ref uint64, n uint32) {
+	table := m.tables[t]
+	if uint64(i)+uint64(n) > uint64(table.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	elem := m.refs.Get(ref)
+	for j := uint32(0); j < n; j++ {
+		table.SetElem(i+j, elem)
+	}
+}
+func (m *aotModule) tableInit(t, x, d, s, n uint32) {
+	table, elems := m.tables[t], m.elems[x]
+	if uint64(s)+uint64(n) > uint64(len(elems)) || uint64(d)+uint64(n) > uint64(table.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	for i := uint32(0); i < n; i++ {
+		table.SetElem(d+i, elems[s+i])
+	}
+}
+func (m *aotModule) tableCopy(dt, st, d, s, n uint32) {
+	dst, src := m.tables[dt], m.tables[st]
+	if uint64(s)+uint64(n) > uint64(src.Size()) || uint64(d)+uint64(n) > uint64(dst.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	if d <= s {
+		for i := uint32(0); i < n; i++ {
+			dst.SetElem(d+i, src.GetElem(s+i))
+		}
+	} else {
+		for i := n; i > 0; i-- {
+			dst.SetElem(d+i-1, src.GetElem(s+i-1))
+		}
+	}
+}
+
+// exceptions
+type delegation struct {
+	exn  *instance.Exception
+	skip int // try blocks left to skip
+}
+
+// the exception a try block recovered, other panics go on
+func catchException(r interface{}) *instance.Exception {
+	switch x := r.(type) {
+	case *instance.Exception:
+		return x
+	case *delegation:
+		if x.skip--; x.skip > 0 {
+			panic(x)
+		}
+		panic(x.exn)
+	}
+	panic(r)
+}
+func (m *aotModule) throwRef(ref uint64) {
+	exn, _ := m.refs.Get(ref).(*instance.Exception)
+	if exn == nil {
+		panic(errors.New("null exception reference"))
+	}
+	panic(exn)
+}
+
+// traps to errors
+func safeCall(f func([]interface{}) ([]interface{}, error),
+	args []interface{}) (results []interface{}, err error) {
+
+	defer func() {
+		if _err := recover(); _err != nil {
+			switch x := _err.(type) {
+			case error:
+				err = x
+			default:
+				err = fmt.Errorf("%v", x)
+			}
+		}
+	}()
+	return f(args)
+}
+
+// numeric, same semantics as the interpreter
+var (
+	errIntOverflow  = errors.New("integer overflow")
+	errConvertToInt = errors.New("invalid conversion to integer")
+)
+func i32DivS(v1, v2 int32) uint64 {
+	if v1 == math.MinInt32 && v2 == -1 {
+		panic(errIntOverflow)
+	}
+	return uint64(uint32(v1 / v2))
+}
+func i64DivS(v1, v2 int64) uint64 {
+	if v1 == math.MinInt64 && v2 == -1 {
+		panic(errIntOverflow)
+	}
+	return uint64(v1 / v2)
+}
+// truncates f, which must be in [min, max)
+func truncF(f, min, max float64) float64 {
+	f = math.Trunc(f)
+	if f >= max || f < min {
+		panic(errIntOverflow)
+	}
+	if math.IsNaN(f) {
+		panic(errConvertToInt)
+	}
+	return f
+}
+func truncSatU(z float64, n int) uint64 {
+	if math.IsNaN(z) || math.IsInf(z, -1) {
+		return 0
+	}
+	max := (uint64(1) << n) - 1
+	if math.IsInf(z, 1) {
+		return max
+	}
+	if x := math.Trunc(z); x < 0 {
+		return 0
+	} else if x >= float64(max) {
+		return max
+	} else {
+		return uint64(x)
+	}
+}
+func truncSatS(z float64, n int) int64 {
+	if math.IsNaN(z) {
+		return 0
+	}
+	min := -(int64(1) << (n - 1))
+	max := (int64(1) << (n - 1)) - 1
+	if math.IsInf(z, -1) {
+		return min
+	}
+	if math.IsInf(z, 1) {
+		return max
+	}
+	if x := math.Trunc(z); x < float64(min) {
+		return min
+	} else if x >= float64(max) {
+		return max
+	} else {
+		return int64(x)
+	}
+}
+// a single NaN operand is returned as is
+func f32Min(v1, v2 float32) float32 {
+	if v1 != v1 && v2 == v2 {
+		return v1
+	} else if v2 != v2 && v1 == v1 {
+		return v2
+	}
+	return float32(math.Min(float64(v1), float64(v2)))
+}
+func f32Max(v1, v2 float32) float32 {
+	if v1 != v1 && v2 == v2 {
+		return v1
+	} else if v2 != v2 && v1 == v1 {
+		return v2
+	}
+	return float32(math.Max(float64(v1), float64(v2)))
+}
+func f64Min(v1, v2 float64) float64 {
+	if v1 != v1 && v2 == v2 {
+		return v1
+	} else if v2 != v2 && v1 == v1 {
+		return v2
+	}
+	return math.Min(v1, v2)
+}
+func f64Max(v1, v2 float64) float64 {
+	if v1 != v1 && v2 == v2 {
+		return v1
+	} else if v2 != v2 && v1 == v1 {
+		return v2
+	}
+	return math.Max(v1, v2)
 }
 
 // utils
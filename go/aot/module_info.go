@@ -10,8 +10,21 @@ type moduleInfo struct {
 	importedTables   []binary.Import
 	importedMemories []binary.Import
 	importedGlobals  []binary.Import
+	importedTags     []binary.Import
 	globalTypes      []binary.GlobalType
+	tagTypes         []binary.FuncType
 	maxOperandStacks []int
+	refFuncs         []uint32     // funcs which may be referenced
+	tailFuncs        map[int]bool // funcs which do tail calls
+	tailGroups       []tailGroup
+	tailRefs         []uint32 // funcs return_call_indirect may jump to
+}
+
+// tail calls between funcs returning the same types
+// are dispatched by the same trampoline
+type tailGroup struct {
+	resultTypes []binary.ValType
+	funcs       []uint32
 }
 
 func newModuleInfo(module binary.Module) moduleInfo {
@@ -26,11 +39,148 @@ func newModuleInfo(module binary.Module) moduleInfo {
 			info.importedMemories = append(info.importedMemories, imp)
 		case binary.ImportTagGlobal:
 			info.importedGlobals = append(info.importedGlobals, imp)
+			info.globalTypes = append(info.globalTypes, imp.Desc.Global)
+		case binary.ImportTagTag:
+			info.importedTags = append(info.importedTags, imp)
+			info.tagTypes = append(info.tagTypes, module.TypeSec[imp.Desc.TagType.Type])
 		}
 	}
+	for _, g := range module.GlobalSec {
+		info.globalTypes = append(info.globalTypes, g.Type)
+	}
+	for _, tt := range module.TagSec {
+		info.tagTypes = append(info.tagTypes, module.TypeSec[tt.Type])
+	}
+	info.refFuncs = collectRefFuncs(module)
+	info.collectTailCalls()
 	return info
 }
 
+func (mi *moduleInfo) collectTailCalls() {
+	mi.tailFuncs = map[int]bool{}
+	var indirectSigs []string
+	var walk func(fIdx int, expr binary.Expr)
+	walk = func(fIdx int, expr binary.Expr) {
+		for _, instr := range expr {
+			switch args := instr.Args.(type) {
+			case binary.BlockArgs:
+				walk(fIdx, args.Instrs)
+			case binary.IfArgs:
+				walk(fIdx, args.Instrs1)
+				walk(fIdx, args.Instrs2)
+			case binary.TryArgs:
+				walk(fIdx, args.Instrs)
+				for _, h := range args.Handlers {
+					walk(fIdx, h.Instrs)
+				}
+			case binary.TryTableArgs:
+				walk(fIdx, args.Instrs)
+			}
+			switch instr.Opcode {
+			case binary.ReturnCall:
+				mi.tailFuncs[fIdx] = true
+				if callee := instr.Args.(uint32); int(callee) >= len(mi.importedFuncs) {
+					mi.addTailTarget(callee)
+				}
+			case binary.ReturnCallIndirect:
+				mi.tailFuncs[fIdx] = true
+				ft := mi.module.TypeSec[instr.Args.(binary.CallIndirectArgs).Type]
+				indirectSigs = append(indirectSigs, ft.GetSignature())
+			}
+		}
+	}
+	for i, code := range mi.module.CodeSec {
+		fIdx := len(mi.importedFuncs) + i
+		walk(fIdx, code.Expr)
+		if mi.tailFuncs[fIdx] {
+			mi.getTailGroup(mi.getFuncType(fIdx).ResultTypes)
+		}
+	}
+	for _, fIdx := range mi.refFuncs {
+		if int(fIdx) < len(mi.importedFuncs) {
+			continue
+		}
+		sig := mi.getFuncType(int(fIdx)).GetSignature()
+		for _, indirectSig := range indirectSigs {
+			if sig == indirectSig {
+				mi.addTailTarget(fIdx)
+				mi.tailRefs = append(mi.tailRefs, fIdx)
+				break
+			}
+		}
+	}
+}
+
+func (mi *moduleInfo) addTailTarget(fIdx uint32) {
+	g := &mi.tailGroups[mi.getTailGroup(mi.getFuncType(int(fIdx)).ResultTypes)]
+	for _, f := range g.funcs {
+		if f == fIdx {
+			return
+		}
+	}
+	g.funcs = append(g.funcs, fIdx)
+}
+
+func (mi *moduleInfo) getTailGroup(resultTypes []binary.ValType) int {
+	sig := binary.FuncType{ResultTypes: resultTypes}.GetSignature()
+	for i, g := range mi.tailGroups {
+		if (binary.FuncType{ResultTypes: g.resultTypes}).GetSignature() == sig {
+			return i
+		}
+	}
+	mi.tailGroups = append(mi.tailGroups, tailGroup{resultTypes: resultTypes})
+	return len(mi.tailGroups) - 1
+}
+
+// funcs in element segments and operands of ref.func
+func collectRefFuncs(module binary.Module) []uint32 {
+	var refFuncs []uint32
+	found := map[uint32]bool{}
+	add := func(fIdx uint32) {
+		if !found[fIdx] {
+			found[fIdx] = true
+			refFuncs = append(refFuncs, fIdx)
+		}
+	}
+	var walk func(expr binary.Expr)
+	walk = func(expr binary.Expr) {
+		for _, instr := range expr {
+			switch args := instr.Args.(type) {
+			case binary.BlockArgs:
+				walk(args.Instrs)
+			case binary.IfArgs:
+				walk(args.Instrs1)
+				walk(args.Instrs2)
+			case binary.TryArgs:
+				walk(args.Instrs)
+				for _, h := range args.Handlers {
+					walk(h.Instrs)
+				}
+			case binary.TryTableArgs:
+				walk(args.Instrs)
+			}
+			if instr.Opcode == binary.RefFunc {
+				add(instr.Args.(uint32))
+			}
+		}
+	}
+	for _, elem := range module.ElemSec {
+		for _, fIdx := range elem.Init {
+			add(fIdx)
+		}
+		for _, expr := range elem.Exprs {
+			walk(expr)
+		}
+	}
+	for _, g := range module.GlobalSec {
+		walk(g.Init)
+	}
+	for _, code := range module.CodeSec {
+		walk(code.Expr)
+	}
+	return refFuncs
+}
+
 func (mi moduleInfo) getFuncType(funcIdx int) binary.FuncType {
 	var ftIdx uint32
 	if funcIdx < len(mi.importedFuncs) {
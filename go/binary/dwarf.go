@@ -0,0 +1,133 @@
+package binary
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SourceLoc is a position in the source a module was compiled from.
+type SourceLoc struct {
+	File   string
+	Line   int
+	Column int // 0 if unknown
+}
+
+func (loc SourceLoc) String() string {
+	if loc.Column == 0 {
+		return fmt.Sprintf("%s:%d", loc.File, loc.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", loc.File, loc.Line, loc.Column)
+}
+
+// LineTable maps the offsets of instructions in the binary to their
+// source, after the DWARF line programs of a module.
+type LineTable struct {
+	rows []lineRow // by offset
+}
+
+type lineRow struct {
+	offset uint32
+	loc    SourceLoc // zero at the end of a sequence
+}
+
+// optional sections of DWARF 5
+var dwarf5Secs = []string{
+	".debug_addr", ".debug_line_str", ".debug_str_offsets", ".debug_rnglists",
+}
+
+// LineTable decodes the DWARF custom sections, it returns nil if
+// there are none.
+func (module Module) LineTable() (*LineTable, error) {
+	secs := map[string][]byte{}
+	for _, sec := range module.CustomSecs {
+		secs[sec.Name] = sec.Bytes
+	}
+	if secs[".debug_info"] == nil || secs[".debug_line"] == nil {
+		return nil, nil
+	}
+	data, err := dwarf.New(secs[".debug_abbrev"], nil, nil, secs[".debug_info"],
+		secs[".debug_line"], nil, secs[".debug_ranges"], secs[".debug_str"])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range dwarf5Secs {
+		if secs[name] != nil {
+			if err := data.AddSection(name, secs[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	table := &LineTable{}
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagCompileUnit {
+			if err := table.addUnit(data, entry, module.CodeOffset); err != nil {
+				return nil, err
+			}
+		}
+		reader.SkipChildren()
+	}
+
+	// ends of sequences first, the next one may start there
+	sort.SliceStable(table.rows, func(i, j int) bool {
+		a, b := table.rows[i], table.rows[j]
+		if a.offset != b.offset {
+			return a.offset < b.offset
+		}
+		return a.loc.Line == 0 && b.loc.Line != 0
+	})
+	return table, nil
+}
+
+func (table *LineTable) addUnit(data *dwarf.Data, unit *dwarf.Entry, codeOffset uint32) error {
+	lr, err := data.LineReader(unit)
+	if err != nil || lr == nil {
+		return err
+	}
+	var seq []lineRow
+	var entry dwarf.LineEntry
+	for {
+		if err := lr.Next(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		row := lineRow{offset: codeOffset + uint32(entry.Address)}
+		if !entry.EndSequence && entry.File != nil {
+			row.loc = SourceLoc{entry.File.Name, entry.Line, entry.Column}
+		}
+		seq = append(seq, row)
+		if !entry.EndSequence {
+			continue
+		}
+		// linkers leave the code they dropped at address 0 or at
+		// tombstones, offset 0 is the function count
+		if start := seq[0].offset - codeOffset; start != 0 && start < 0xfffffffe {
+			table.rows = append(table.rows, seq...)
+		}
+		seq = nil
+	}
+	return nil
+}
+
+// Lookup returns the source of the instruction at offset.
+func (table *LineTable) Lookup(offset uint32) (SourceLoc, bool) {
+	i := sort.Search(len(table.rows), func(i int) bool {
+		return table.rows[i].offset > offset
+	})
+	if i == 0 {
+		return SourceLoc{}, false
+	}
+	loc := table.rows[i-1].loc
+	return loc, loc.Line > 0
+}
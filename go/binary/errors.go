@@ -3,7 +3,21 @@ package binary
 import "errors"
 
 var (
-	errUnexpectedEnd = errors.New("unexpected end of section or function")
-	errIntTooLong    = errors.New("integer representation too long")
-	errIntTooLarge   = errors.New("integer too large")
+	errUnexpectedEnd  = errors.New("unexpected end of section or function")
+	errIntTooLong     = errors.New("integer representation too long")
+	errIntTooLarge    = errors.New("integer too large")
+	errLenOutOfBounds = errors.New("length out of bounds")
 )
+
+// exceeded limits of DecodeOptions
+var (
+	ErrSectionTooLarge = errors.New("section too large")
+	ErrTooManyFuncs    = errors.New("too many functions")
+	ErrTooManyLocals   = errors.New("too many locals")
+	ErrBodyTooLarge    = errors.New("function body too large")
+	ErrNestingTooDeep  = errors.New("nesting too deep")
+	ErrDataTooLarge    = errors.New("data segment too large")
+)
+
+// use of a proposal which DecodeOptions.Features disables
+var ErrFeatureDisabled = errors.New("feature not enabled")
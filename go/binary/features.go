@@ -0,0 +1,265 @@
+package binary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Feature is a post-MVP proposal, or a set of them.
+type Feature uint32
+
+const (
+	FeatureSignExtension Feature = 1 << iota
+	FeatureSaturatingTrunc
+	FeatureMultiValue
+	FeatureBulkMemory
+	FeatureReferenceTypes
+	FeatureSIMD
+	FeatureTailCall
+	FeatureThreads
+	FeatureExceptions
+	FeatureMemory64
+	FeatureMultiMemory
+	FeatureExtendedConst
+	featureEnd
+)
+
+// AllFeatures lists the proposals in bit order.
+var AllFeatures []Feature
+
+func init() {
+	for f := Feature(1); f < featureEnd; f <<= 1 {
+		AllFeatures = append(AllFeatures, f)
+	}
+}
+
+// names of the wabt --enable-* flags
+var featureNames = []string{
+	"sign-extension",
+	"saturating-float-to-int",
+	"multi-value",
+	"bulk-memory",
+	"reference-types",
+	"simd",
+	"tail-call",
+	"threads",
+	"exceptions",
+	"memory64",
+	"multi-memory",
+	"extended-const",
+}
+
+func (f Feature) String() string {
+	var names []string
+	for i, name := range featureNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func ParseFeature(name string) (Feature, bool) {
+	for i, s := range featureNames {
+		if s == name {
+			return 1 << i, true
+		}
+	}
+	return 0, false
+}
+
+// Features is the set of enabled proposals. The zero value enables
+// all of them, so that zero DecodeOptions accept every module.
+type Features struct {
+	disabled Feature
+}
+
+// FeaturesMVP enables no proposal, FeaturesStandard those of Wasm 2.0.
+var (
+	FeaturesMVP      = NewFeatures()
+	FeaturesStandard = NewFeatures(FeatureSignExtension, FeatureSaturatingTrunc,
+		FeatureMultiValue, FeatureBulkMemory, FeatureReferenceTypes, FeatureSIMD)
+)
+
+// NewFeatures enables only fs.
+func NewFeatures(fs ...Feature) Features {
+	features := Features{disabled: featureEnd - 1}
+	for _, f := range fs {
+		features = features.Enable(f)
+	}
+	return features
+}
+
+func (fs Features) Has(f Feature) bool {
+	return fs.disabled&f == 0
+}
+
+func (fs Features) Enable(f Feature) Features {
+	fs.disabled &^= f
+	return fs
+}
+
+func (fs Features) Disable(f Feature) Features {
+	fs.disabled |= f & (featureEnd - 1)
+	return fs
+}
+
+// Check reports the proposals of f which are disabled.
+func (fs Features) Check(f Feature) error {
+	if missing := fs.disabled & f; missing != 0 {
+		return fmt.Errorf("%w: %s", ErrFeatureDisabled, missing)
+	}
+	return nil
+}
+
+func (fs Features) String() string {
+	if fs.disabled == featureEnd-1 {
+		return "mvp"
+	}
+	return ((featureEnd - 1) &^ fs.disabled).String()
+}
+
+// ValTypeFeature returns the proposals introducing vt as a value type,
+// funcref being MVP only as the element type of tables.
+func ValTypeFeature(vt ValType) Feature {
+	switch vt {
+	case ValTypeV128:
+		return FeatureSIMD
+	case ValTypeFuncRef, ValTypeExternRef:
+		return FeatureReferenceTypes
+	case ValTypeExnRef:
+		return FeatureExceptions
+	}
+	return 0
+}
+
+func BlockTypeFeature(bt BlockType) Feature {
+	if bt >= 0 {
+		return FeatureMultiValue
+	}
+	if bt == BlockTypeEmpty {
+		return 0
+	}
+	return ValTypeFeature(ValType(bt & 0x7F))
+}
+
+func (ft FuncType) Feature() (f Feature) {
+	if len(ft.ResultTypes) > 1 {
+		f |= FeatureMultiValue
+	}
+	for _, vt := range ft.ParamTypes {
+		f |= ValTypeFeature(vt)
+	}
+	for _, vt := range ft.ResultTypes {
+		f |= ValTypeFeature(vt)
+	}
+	return
+}
+
+func (limits Limits) Feature() (f Feature) {
+	if limits.Shared {
+		f |= FeatureThreads
+	}
+	if limits.Is64 {
+		f |= FeatureMemory64
+	}
+	return
+}
+
+// Feature returns the proposals needed to encode instr, nested
+// instructions excluded.
+func (instr Instruction) Feature() Feature {
+	switch instr.Opcode {
+	case Block, Loop:
+		return BlockTypeFeature(instr.Args.(BlockArgs).BT)
+	case If:
+		return BlockTypeFeature(instr.Args.(IfArgs).BT)
+	case Try:
+		return FeatureExceptions | BlockTypeFeature(instr.Args.(TryArgs).BT)
+	case TryTable:
+		return FeatureExceptions | BlockTypeFeature(instr.Args.(TryTableArgs).BT)
+	case Catch_, Throw, Rethrow, ThrowRef, Delegate_, CatchAll_:
+		return FeatureExceptions
+	case ReturnCall:
+		return FeatureTailCall
+	case ReturnCallIndirect:
+		return FeatureTailCall | callIndirectFeature(instr.Args.(CallIndirectArgs))
+	case CallIndirect:
+		return callIndirectFeature(instr.Args.(CallIndirectArgs))
+	case SelectT:
+		return FeatureReferenceTypes | ValTypeFeature(instr.Args.([]ValType)[0])
+	case TableGet, TableSet, RefIsNull, RefFunc:
+		return FeatureReferenceTypes
+	case RefNull:
+		return FeatureReferenceTypes | ValTypeFeature(instr.Args.(ValType))
+	case MemorySize, MemoryGrow:
+		return memIdxFeature(instr.Args.(uint32))
+	case NumericPrefix:
+		return numericFeature(instr.Args.(PrefixArgs))
+	case SimdPrefix:
+		f := FeatureSIMD
+		switch args := instr.Args.(PrefixArgs).Args.(type) {
+		case MemArg:
+			f |= memIdxFeature(args.Mem)
+		case MemLaneArgs:
+			f |= memIdxFeature(args.MemArg.Mem)
+		}
+		return f
+	case AtomicPrefix:
+		f := FeatureThreads
+		if memArg, ok := instr.Args.(PrefixArgs).Args.(MemArg); ok {
+			f |= memIdxFeature(memArg.Mem)
+		}
+		return f
+	}
+	if instr.Opcode >= I32Extend8S && instr.Opcode <= I64Extend32S {
+		return FeatureSignExtension
+	}
+	if memArg, ok := instr.Args.(MemArg); ok {
+		return memIdxFeature(memArg.Mem)
+	}
+	return 0
+}
+
+func callIndirectFeature(args CallIndirectArgs) Feature {
+	if args.Table != 0 {
+		return FeatureReferenceTypes
+	}
+	return 0
+}
+
+func memIdxFeature(memIdx MemIdx) Feature {
+	if memIdx != 0 {
+		return FeatureMultiMemory
+	}
+	return 0
+}
+
+func numericFeature(args PrefixArgs) Feature {
+	switch args.SubOp {
+	case MemoryInit:
+		return FeatureBulkMemory | memIdxFeature(args.Args.(MemoryInitArgs).Mem)
+	case MemoryCopy:
+		copyArgs := args.Args.(MemoryCopyArgs)
+		return FeatureBulkMemory | memIdxFeature(copyArgs.Dst|copyArgs.Src)
+	case MemoryFill:
+		return FeatureBulkMemory | memIdxFeature(args.Args.(uint32))
+	case DataDrop, ElemDrop:
+		return FeatureBulkMemory
+	case TableInit:
+		return FeatureBulkMemory | tableIdxFeature(args.Args.(TableInitArgs).Table)
+	case TableCopy:
+		copyArgs := args.Args.(TableCopyArgs)
+		return FeatureBulkMemory | tableIdxFeature(copyArgs.Dst|copyArgs.Src)
+	case TableGrow, TableSize, TableFill:
+		return FeatureReferenceTypes
+	}
+	return FeatureSaturatingTrunc
+}
+
+func tableIdxFeature(tableIdx TableIdx) Feature {
+	if tableIdx != 0 {
+		return FeatureReferenceTypes
+	}
+	return 0
+}
@@ -4,6 +4,7 @@ type Expr = []Instruction
 
 type Instruction struct {
 	Opcode byte
+	Offset uint32 // in the binary, 0 if not decoded
 	Args   interface{}
 }
 
@@ -19,20 +20,107 @@ type IfArgs struct {
 	Instrs2 []Instruction
 }
 
+// try with catch & catch_all handlers, or delegate
+type TryArgs struct {
+	BT       BlockType
+	Instrs   []Instruction
+	Handlers []Handler
+	Delegate *LabelIdx
+}
+
+type Handler struct {
+	CatchAll bool
+	Tag      TagIdx // catch only
+	Instrs   []Instruction
+}
+
+// try_table catch clauses
+const (
+	ClauseCatch       = 0x00 // catch x l
+	ClauseCatchRef    = 0x01 // catch_ref x l
+	ClauseCatchAll    = 0x02 // catch_all l
+	ClauseCatchAllRef = 0x03 // catch_all_ref l
+)
+
+type TryTableArgs struct {
+	BT      BlockType
+	Catches []Catch
+	Instrs  []Instruction
+}
+
+type Catch struct {
+	Kind  byte
+	Tag   TagIdx // catch & catch_ref only
+	Label LabelIdx
+}
+
+type CallIndirectArgs struct {
+	Type  TypeIdx
+	Table TableIdx
+}
+
+type TableInitArgs struct {
+	Elem  ElemIdx
+	Table TableIdx
+}
+
+type TableCopyArgs struct {
+	Dst TableIdx
+	Src TableIdx
+}
+
 type BrTableArgs struct {
 	Labels  []LabelIdx
 	Default LabelIdx
 }
 
+// instructions with a prefix opcode
+type PrefixArgs struct {
+	SubOp uint32
+	Args  interface{}
+}
+
 type MemArg struct {
 	Align  uint32
-	Offset uint32
+	Offset uint64
+	Mem    MemIdx
+}
+
+// v128.loadN_lane & v128.storeN_lane
+type MemLaneArgs struct {
+	MemArg MemArg
+	Lane   byte
+}
+
+type MemoryInitArgs struct {
+	Data DataIdx
+	Mem  MemIdx
+}
+
+type MemoryCopyArgs struct {
+	Dst MemIdx
+	Src MemIdx
 }
 
 func (instr Instruction) GetOpname() string {
+	if instr.Opcode == NumericPrefix {
+		if args, ok := instr.Args.(PrefixArgs); ok {
+			return numericOpnames[args.SubOp]
+		}
+	}
+	if instr.Opcode == SimdPrefix {
+		if args, ok := instr.Args.(PrefixArgs); ok {
+			return simdOpnames[args.SubOp]
+		}
+	}
+	if instr.Opcode == AtomicPrefix {
+		if args, ok := instr.Args.(PrefixArgs); ok {
+			return atomicOpnames[args.SubOp]
+		}
+	}
 	return opnames[instr.Opcode]
 }
 
 func (instr Instruction) String() string {
-	return opnames[instr.Opcode]
+	return instr.GetOpname()
 }
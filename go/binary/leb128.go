@@ -41,3 +41,22 @@ func decodeVarInt(data []byte, size int) (int64, int) {
 	}
 	panic(errUnexpectedEnd)
 }
+
+func appendVarUint(data []byte, n uint64) []byte {
+	for n >= 0x80 {
+		data = append(data, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(data, byte(n))
+}
+
+func appendVarInt(data []byte, n int64) []byte {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 && b&0x40 == 0 || n == -1 && b&0x40 != 0 {
+			return append(data, b)
+		}
+		data = append(data, b|0x80)
+	}
+}
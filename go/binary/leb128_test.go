@@ -37,3 +37,18 @@ func testDecodeVarInt32(t *testing.T, data []byte, n int32, w int) {
 	require.Equal(t, n, int32(_n))
 	require.Equal(t, w, _w)
 }
+
+func TestAppendVarInt(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 63, 64, -64, -65, -123456, 1 << 40, -1 << 63} {
+		data := appendVarInt(nil, n)
+		_n, w := decodeVarInt(data, 64)
+		require.Equal(t, n, _n)
+		require.Equal(t, len(data), w)
+	}
+	for _, n := range []uint64{0, 127, 128, 1 << 35, 1<<64 - 1} {
+		data := appendVarUint(nil, n)
+		_n, w := decodeVarUint(data, 64)
+		require.Equal(t, n, _n)
+		require.Equal(t, len(data), w)
+	}
+}
@@ -18,6 +18,15 @@ const (
 	SecElemID
 	SecCodeID
 	SecDataID
+	SecDataCountID
+	SecTagID
+)
+
+// data & elem segment modes
+const (
+	SegModeActive      = 0
+	SegModePassive     = 1
+	SegModeDeclarative = 2 // elem only
 )
 
 const (
@@ -25,6 +34,7 @@ const (
 	ImportTagTable  = 1
 	ImportTagMem    = 2
 	ImportTagGlobal = 3
+	ImportTagTag    = 4
 )
 
 const (
@@ -32,39 +42,47 @@ const (
 	ExportTagTable  = 1
 	ExportTagMem    = 2
 	ExportTagGlobal = 3
+	ExportTagTag    = 4
 )
 
 const (
-	PageSize     = 65536 // 64KB
-	MaxPageCount = 65536 // 2 ^ 16
+	PageSize       = 65536   // 64KB
+	MaxPageCount   = 65536   // 2 ^ 16
+	MaxPageCount64 = 1 << 48 // memory64
 )
 
 
 type (
 	TypeIdx   = uint32 // 类型索引 类型段
 	FuncIdx   = uint32 // 函数索引 导入段(内部函数) 代码段(外部函数)
-	TableIdx  = uint32 // 表索引  0
-	MemIdx    = uint32 // 内存索引  0
+	TableIdx  = uint32 // 表索引
+	ElemIdx   = uint32 // 元素段索引
+	MemIdx    = uint32 // 内存索引
+	DataIdx   = uint32 // 数据段索引
 	GlobalIdx = uint32 // 全局变量索引 外包全局变量 内部全局变量
 	LocalIdx  = uint32 // 局部变量索引 参数 + 局部变量
 	LabelIdx  = uint32 // 跳转标签索引
+	TagIdx    = uint32 // 异常标签索引 导入段 标签段
 )
 
 type Module struct {
-	Magic      uint32
-	Version    uint32
-	CustomSecs []CustomSec
-	TypeSec    []FuncType
-	ImportSec  []Import
-	FuncSec    []TypeIdx
-	TableSec   []TableType
-	MemSec     []MemType
-	GlobalSec  []Global
-	ExportSec  []Export
-	StartSec   *FuncIdx
-	ElemSec    []Elem
-	CodeSec    []Code
-	DataSec    []Data
+	Magic        uint32
+	Version      uint32
+	CustomSecs   []CustomSec
+	TypeSec      []FuncType
+	ImportSec    []Import
+	FuncSec      []TypeIdx
+	TableSec     []TableType
+	MemSec       []MemType
+	TagSec       []TagType
+	GlobalSec    []Global
+	ExportSec    []Export
+	StartSec     *FuncIdx
+	ElemSec      []Elem
+	DataCountSec *uint32
+	CodeSec      []Code
+	DataSec      []Data
+	CodeOffset   uint32 // of the code section content, DWARF addresses are relative to it
 }
 
 type CustomSec struct {
@@ -85,6 +103,7 @@ type ImportDesc struct {
 	Table    TableType  // tag=1
 	Mem      MemType    // tag=2
 	Global   GlobalType // tag=3
+	TagType  TagType    // tag=4
 }
 
 type Global struct {
@@ -103,9 +122,19 @@ type ExportDesc struct {
 }
 
 type Elem struct {
-	Table  TableIdx
-	Offset Expr
-	Init   []FuncIdx
+	Mode   byte
+	Table  TableIdx // active only
+	Offset Expr     // active only
+	Type   ValType
+	Init   []FuncIdx // either func indices
+	Exprs  []Expr    // or constant expressions
+}
+
+func (elem Elem) Len() int {
+	if elem.Exprs != nil {
+		return len(elem.Exprs)
+	}
+	return len(elem.Init)
 }
 
 type Code struct {
@@ -119,8 +148,9 @@ type Locals struct {
 }
 
 type Data struct {
-	Mem    MemIdx
-	Offset Expr
+	Mode   byte
+	Mem    MemIdx // active only
+	Offset Expr   // active only
 	Init   []byte
 }
 
@@ -136,6 +166,14 @@ func (module Module) GetBlockType(bt BlockType) FuncType {
 		return FuncType{ResultTypes: []ValType{ValTypeF32}}
 	case BlockTypeF64:
 		return FuncType{ResultTypes: []ValType{ValTypeF64}}
+	case BlockTypeV128:
+		return FuncType{ResultTypes: []ValType{ValTypeV128}}
+	case BlockTypeFuncRef:
+		return FuncType{ResultTypes: []ValType{ValTypeFuncRef}}
+	case BlockTypeExternRef:
+		return FuncType{ResultTypes: []ValType{ValTypeExternRef}}
+	case BlockTypeExnRef:
+		return FuncType{ResultTypes: []ValType{ValTypeExnRef}}
 	case BlockTypeEmpty:
 		return FuncType{}
 	default:
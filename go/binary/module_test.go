@@ -1,6 +1,8 @@
 package binary
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -24,3 +26,106 @@ func TestDecode(t *testing.T) {
 	require.Equal(t, 171, len(module.CodeSec))
 	require.Equal(t, 4, len(module.DataSec))
 }
+
+func TestFuncNames(t *testing.T) {
+	module, err := DecodeFile("./testdata/hw_rust.wasm")
+	require.NoError(t, err)
+	names := module.FuncNames()
+	require.Equal(t, 171, len(names))
+	require.Equal(t, "memcmp", names[170])
+
+	module.CustomSecs = []CustomSec{{Name: "name", Bytes: []byte{NameSubsecFunc, 3, 1, 0}}}
+	require.Nil(t, module.FuncNames())
+}
+
+func TestLineTable(t *testing.T) {
+	module, err := DecodeFile("../../rust/examples/target/wasm32-unknown-unknown/release/rust_examples.wasm")
+	require.NoError(t, err)
+	table, err := module.LineTable()
+	require.NoError(t, err)
+	require.NotNil(t, table)
+
+	// core::fmt::Write::write_fmt
+	offset := module.CodeSec[20].Expr[0].Offset
+	require.Equal(t, uint32(0x7f3), offset-module.CodeOffset)
+	loc, ok := table.Lookup(offset)
+	require.True(t, ok)
+	require.Equal(t, 208, loc.Line)
+	require.Equal(t, 17, loc.Column)
+	require.True(t, strings.HasSuffix(loc.String(), "library/core/src/fmt/mod.rs:208:17"))
+	_, ok = table.Lookup(module.CodeOffset)
+	require.False(t, ok)
+
+	module, err = DecodeFile("./testdata/hw_rust.wasm")
+	require.NoError(t, err)
+	table, err = module.LineTable()
+	require.NoError(t, err)
+	require.Nil(t, table)
+}
+
+func TestDecodeHugeVec(t *testing.T) {
+	header := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+	// type section with 2^32-1 func types
+	data := append(header, SecTypeID, 0x05, 0xff, 0xff, 0xff, 0xff, 0x0f)
+	_, err := Decode(data)
+	require.Equal(t, errLenOutOfBounds, err)
+}
+
+func TestDecodeDeepNesting(t *testing.T) {
+	var expr []byte
+	for i := 0; i <= int(DefaultDecodeOptions.MaxNestingDepth); i++ {
+		expr = append(expr, Block, 0x40)
+	}
+	reader := &wasmReader{data: expr}
+	reader.opts = DefaultDecodeOptions
+	require.PanicsWithValue(t, ErrNestingTooDeep, func() { reader.readExpr() })
+}
+
+func TestDecodeWithOptions(t *testing.T) {
+	data, err := os.ReadFile("./testdata/hw_rust.wasm")
+	require.NoError(t, err)
+
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxSectionSize: 100})
+	require.ErrorIs(t, err, ErrSectionTooLarge)
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxFuncs: 100})
+	require.ErrorIs(t, err, ErrTooManyFuncs)
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxLocals: 1})
+	require.ErrorIs(t, err, ErrTooManyLocals)
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxBodySize: 100})
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxNestingDepth: 1})
+	require.ErrorIs(t, err, ErrNestingTooDeep)
+	_, err = DecodeWithOptions(data, DecodeOptions{MaxDataSize: 1})
+	require.ErrorIs(t, err, ErrDataTooLarge)
+}
+
+func TestDecodeTry(t *testing.T) {
+	reader := &wasmReader{data: []byte{
+		Try, 0x40, Nop, Catch_, 0x00, Nop, CatchAll_, End_,
+		Try, 0x40, Delegate_, 0x01,
+		TryTable, 0x40, 0x02, ClauseCatch, 0x01, 0x00, ClauseCatchAllRef, 0x02, End_,
+		End_,
+	}}
+	reader.size = len(reader.data)
+	reader.opts = DefaultDecodeOptions
+	delegate := uint32(1)
+	nop := Instruction{Opcode: Nop, Offset: 2}
+	require.Equal(t, Expr{
+		{Opcode: Try, Args: TryArgs{BT: BlockTypeEmpty, Instrs: Expr{nop},
+			Handlers: []Handler{
+				{Tag: 0, Instrs: Expr{{Opcode: Nop, Offset: 5}}},
+				{CatchAll: true},
+			}}},
+		{Opcode: Try, Offset: 8, Args: TryArgs{BT: BlockTypeEmpty, Delegate: &delegate}},
+		{Opcode: TryTable, Offset: 12, Args: TryTableArgs{BT: BlockTypeEmpty,
+			Catches: []Catch{
+				{Kind: ClauseCatch, Tag: 1, Label: 0},
+				{Kind: ClauseCatchAllRef, Label: 2},
+			}}},
+	}, reader.readExpr())
+
+	// catch after catch_all
+	reader = &wasmReader{data: []byte{Try, 0x40, CatchAll_, Catch_, 0x00, End_, End_}}
+	reader.opts = DefaultDecodeOptions
+	require.PanicsWithError(t, "invalid try end: 7", func() { reader.readExpr() })
+}
@@ -0,0 +1,65 @@
+package binary
+
+import "fmt"
+
+// subsections of the name section
+const (
+	NameSubsecModule = 0
+	NameSubsecFunc   = 1
+	NameSubsecLocal  = 2
+)
+
+// FuncNames returns the function names of the name section, nil if
+// there are none. A malformed name section is ignored, like any
+// custom section.
+func (module Module) FuncNames() (names map[FuncIdx]string) {
+	defer func() {
+		if r := recover(); r != nil {
+			names = nil
+		}
+	}()
+
+	for _, sec := range module.CustomSecs {
+		if sec.Name != "name" {
+			continue
+		}
+		reader := &wasmReader{data: sec.Bytes}
+		for reader.remaining() > 0 {
+			id := reader.readByte()
+			subReader := &wasmReader{data: reader.readBytes()}
+			if id != NameSubsecFunc {
+				continue
+			}
+			names = map[FuncIdx]string{}
+			n := subReader.readVecLen()
+			for i := uint32(0); i < n; i++ {
+				idx := subReader.readVarU32()
+				names[idx] = subReader.readName()
+			}
+		}
+	}
+	return
+}
+
+// FuncName prefers the names of the name section, as returned by
+// FuncNames, then those of imports and exports.
+func (module Module) FuncName(idx FuncIdx, names map[FuncIdx]string) string {
+	if name, ok := names[idx]; ok {
+		return name
+	}
+	importedCount := uint32(0)
+	for _, imp := range module.ImportSec {
+		if imp.Desc.Tag == ImportTagFunc {
+			if importedCount == idx {
+				return imp.Module + "." + imp.Name
+			}
+			importedCount++
+		}
+	}
+	for _, exp := range module.ExportSec {
+		if exp.Desc.Tag == ExportTagFunc && exp.Desc.Idx == idx {
+			return exp.Name
+		}
+	}
+	return fmt.Sprintf("func[%d]", idx)
+}
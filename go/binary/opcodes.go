@@ -2,182 +2,550 @@ package binary
 
 // Opcodes
 const (
-	Unreachable       = 0x00 // unreachable
-	Nop               = 0x01 // nop
-	Block             = 0x02 // block rt in* end
-	Loop              = 0x03 // loop rt in* end
-	If                = 0x04 // if rt in* else in* end
-	Else_             = 0x05 // else
-	End_              = 0x0B // end
-	Br                = 0x0C // br l
-	BrIf              = 0x0D // br_if l
-	BrTable           = 0x0E // br_table l* lN
-	Return            = 0x0F // return
-	Call              = 0x10 // call x
-	CallIndirect      = 0x11 // call_indirect x
-	Drop              = 0x1A // drop
-	Select            = 0x1B // select
-	LocalGet          = 0x20 // local.get x
-	LocalSet          = 0x21 // local.set x
-	LocalTee          = 0x22 // local.tee x
-	GlobalGet         = 0x23 // global.get x
-	GlobalSet         = 0x24 // global.set x
-	I32Load           = 0x28 // i32.load m
-	I64Load           = 0x29 // i64.load m
-	F32Load           = 0x2A // f32.load m
-	F64Load           = 0x2B // f64.load m
-	I32Load8S         = 0x2C // i32.load8_s m
-	I32Load8U         = 0x2D // i32.load8_u m
-	I32Load16S        = 0x2E // i32.load16_s m
-	I32Load16U        = 0x2F // i32.load16_u m
-	I64Load8S         = 0x30 // i64.load8_s m
-	I64Load8U         = 0x31 // i64.load8_u m
-	I64Load16S        = 0x32 // i64.load16_s m
-	I64Load16U        = 0x33 // i64.load16_u m
-	I64Load32S        = 0x34 // i64.load32_s m
-	I64Load32U        = 0x35 // i64.load32_u m
-	I32Store          = 0x36 // i32.store m
-	I64Store          = 0x37 // i64.store m
-	F32Store          = 0x38 // f32.store m
-	F64Store          = 0x39 // f64.store m
-	I32Store8         = 0x3A // i32.store8 m
-	I32Store16        = 0x3B // i32.store16 m
-	I64Store8         = 0x3C // i64.store8 m
-	I64Store16        = 0x3D // i64.store16 m
-	I64Store32        = 0x3E // i64.store32 m
-	MemorySize        = 0x3F // memory.size
-	MemoryGrow        = 0x40 // memory.grow
-	I32Const          = 0x41 // i32.const n
-	I64Const          = 0x42 // i64.const n
-	F32Const          = 0x43 // f32.const z
-	F64Const          = 0x44 // f64.const z
-	I32Eqz            = 0x45 // i32.eqz
-	I32Eq             = 0x46 // i32.eq
-	I32Ne             = 0x47 // i32.ne
-	I32LtS            = 0x48 // i32.lt_s
-	I32LtU            = 0x49 // i32.lt_u
-	I32GtS            = 0x4A // i32.gt_s
-	I32GtU            = 0x4B // i32.gt_u
-	I32LeS            = 0x4C // i32.le_s
-	I32LeU            = 0x4D // i32.le_u
-	I32GeS            = 0x4E // i32.ge_s
-	I32GeU            = 0x4F // i32.ge_u
-	I64Eqz            = 0x50 // i64.eqz
-	I64Eq             = 0x51 // i64.eq
-	I64Ne             = 0x52 // i64.ne
-	I64LtS            = 0x53 // i64.lt_s
-	I64LtU            = 0x54 // i64.lt_u
-	I64GtS            = 0x55 // i64.gt_s
-	I64GtU            = 0x56 // i64.gt_u
-	I64LeS            = 0x57 // i64.le_s
-	I64LeU            = 0x58 // i64.le_u
-	I64GeS            = 0x59 // i64.ge_s
-	I64GeU            = 0x5A // i64.ge_u
-	F32Eq             = 0x5B // f32.eq
-	F32Ne             = 0x5C // f32.ne
-	F32Lt             = 0x5D // f32.lt
-	F32Gt             = 0x5E // f32.gt
-	F32Le             = 0x5F // f32.le
-	F32Ge             = 0x60 // f32.ge
-	F64Eq             = 0x61 // f64.eq
-	F64Ne             = 0x62 // f64.ne
-	F64Lt             = 0x63 // f64.lt
-	F64Gt             = 0x64 // f64.gt
-	F64Le             = 0x65 // f64.le
-	F64Ge             = 0x66 // f64.ge
-	I32Clz            = 0x67 // i32.clz
-	I32Ctz            = 0x68 // i32.ctz
-	I32PopCnt         = 0x69 // i32.popcnt
-	I32Add            = 0x6A // i32.add
-	I32Sub            = 0x6B // i32.sub
-	I32Mul            = 0x6C // i32.mul
-	I32DivS           = 0x6D // i32.div_s
-	I32DivU           = 0x6E // i32.div_u
-	I32RemS           = 0x6F // i32.rem_s
-	I32RemU           = 0x70 // i32.rem_u
-	I32And            = 0x71 // i32.and
-	I32Or             = 0x72 // i32.or
-	I32Xor            = 0x73 // i32.xor
-	I32Shl            = 0x74 // i32.shl
-	I32ShrS           = 0x75 // i32.shr_s
-	I32ShrU           = 0x76 // i32.shr_u
-	I32Rotl           = 0x77 // i32.rotl
-	I32Rotr           = 0x78 // i32.rotr
-	I64Clz            = 0x79 // i64.clz
-	I64Ctz            = 0x7A // i64.ctz
-	I64PopCnt         = 0x7B // i64.popcnt
-	I64Add            = 0x7C // i64.add
-	I64Sub            = 0x7D // i64.sub
-	I64Mul            = 0x7E // i64.mul
-	I64DivS           = 0x7F // i64.div_s
-	I64DivU           = 0x80 // i64.div_u
-	I64RemS           = 0x81 // i64.rem_s
-	I64RemU           = 0x82 // i64.rem_u
-	I64And            = 0x83 // i64.and
-	I64Or             = 0x84 // i64.or
-	I64Xor            = 0x85 // i64.xor
-	I64Shl            = 0x86 // i64.shl
-	I64ShrS           = 0x87 // i64.shr_s
-	I64ShrU           = 0x88 // i64.shr_u
-	I64Rotl           = 0x89 // i64.rotl
-	I64Rotr           = 0x8A // i64.rotr
-	F32Abs            = 0x8B // f32.abs
-	F32Neg            = 0x8C // f32.neg
-	F32Ceil           = 0x8D // f32.ceil
-	F32Floor          = 0x8E // f32.floor
-	F32Trunc          = 0x8F // f32.trunc
-	F32Nearest        = 0x90 // f32.nearest
-	F32Sqrt           = 0x91 // f32.sqrt
-	F32Add            = 0x92 // f32.add
-	F32Sub            = 0x93 // f32.sub
-	F32Mul            = 0x94 // f32.mul
-	F32Div            = 0x95 // f32.div
-	F32Min            = 0x96 // f32.min
-	F32Max            = 0x97 // f32.max
-	F32CopySign       = 0x98 // f32.copysign
-	F64Abs            = 0x99 // f64.abs
-	F64Neg            = 0x9A // f64.neg
-	F64Ceil           = 0x9B // f64.ceil
-	F64Floor          = 0x9C // f64.floor
-	F64Trunc          = 0x9D // f64.trunc
-	F64Nearest        = 0x9E // f64.nearest
-	F64Sqrt           = 0x9F // f64.sqrt
-	F64Add            = 0xA0 // f64.add
-	F64Sub            = 0xA1 // f64.sub
-	F64Mul            = 0xA2 // f64.mul
-	F64Div            = 0xA3 // f64.div
-	F64Min            = 0xA4 // f64.min
-	F64Max            = 0xA5 // f64.max
-	F64CopySign       = 0xA6 // f64.copysign
-	I32WrapI64        = 0xA7 // i32.wrap_i64
-	I32TruncF32S      = 0xA8 // i32.trunc_f32_s
-	I32TruncF32U      = 0xA9 // i32.trunc_f32_u
-	I32TruncF64S      = 0xAA // i32.trunc_f64_s
-	I32TruncF64U      = 0xAB // i32.trunc_f64_u
-	I64ExtendI32S     = 0xAC // i64.extend_i32_s
-	I64ExtendI32U     = 0xAD // i64.extend_i32_u
-	I64TruncF32S      = 0xAE // i64.trunc_f32_s
-	I64TruncF32U      = 0xAF // i64.trunc_f32_u
-	I64TruncF64S      = 0xB0 // i64.trunc_f64_s
-	I64TruncF64U      = 0xB1 // i64.trunc_f64_u
-	F32ConvertI32S    = 0xB2 // f32.convert_i32_s
-	F32ConvertI32U    = 0xB3 // f32.convert_i32_u
-	F32ConvertI64S    = 0xB4 // f32.convert_i64_s
-	F32ConvertI64U    = 0xB5 // f32.convert_i64_u
-	F32DemoteF64      = 0xB6 // f32.demote_f64
-	F64ConvertI32S    = 0xB7 // f64.convert_i32_s
-	F64ConvertI32U    = 0xB8 // f64.convert_i32_u
-	F64ConvertI64S    = 0xB9 // f64.convert_i64_s
-	F64ConvertI64U    = 0xBA // f64.convert_i64_u
-	F64PromoteF32     = 0xBB // f64.promote_f32
-	I32ReinterpretF32 = 0xBC // i32.reinterpret_f32
-	I64ReinterpretF64 = 0xBD // i64.reinterpret_f64
-	F32ReinterpretI32 = 0xBE // f32.reinterpret_i32
-	F64ReinterpretI64 = 0xBF // f64.reinterpret_i64
-	I32Extend8S       = 0xC0 // i32.extend8_s
-	I32Extend16S      = 0xC1 // i32.extend16_s
-	I64Extend8S       = 0xC2 // i64.extend8_s
-	I64Extend16S      = 0xC3 // i64.extend16_s
-	I64Extend32S      = 0xC4 // i64.extend32_s
-	TruncSat          = 0xFC // <i32|64>.trunc_sat_<f32|64>_<s|u>
+	Unreachable        = 0x00 // unreachable
+	Nop                = 0x01 // nop
+	Block              = 0x02 // block rt in* end
+	Loop               = 0x03 // loop rt in* end
+	If                 = 0x04 // if rt in* else in* end
+	Else_              = 0x05 // else
+	Try                = 0x06 // try rt in* (catch x in*)* (catch_all in*)? end
+	Catch_             = 0x07 // catch x
+	Throw              = 0x08 // throw x
+	Rethrow            = 0x09 // rethrow l
+	ThrowRef           = 0x0A // throw_ref
+	End_               = 0x0B // end
+	Br                 = 0x0C // br l
+	BrIf               = 0x0D // br_if l
+	BrTable            = 0x0E // br_table l* lN
+	Return             = 0x0F // return
+	Call               = 0x10 // call x
+	CallIndirect       = 0x11 // call_indirect x
+	ReturnCall         = 0x12 // return_call x
+	ReturnCallIndirect = 0x13 // return_call_indirect x
+	Delegate_          = 0x18 // delegate l
+	CatchAll_          = 0x19 // catch_all
+	Drop               = 0x1A // drop
+	Select             = 0x1B // select
+	SelectT            = 0x1C // select t*
+	TryTable           = 0x1F // try_table rt catch* in* end
+	LocalGet           = 0x20 // local.get x
+	LocalSet           = 0x21 // local.set x
+	LocalTee           = 0x22 // local.tee x
+	GlobalGet          = 0x23 // global.get x
+	GlobalSet          = 0x24 // global.set x
+	TableGet           = 0x25 // table.get x
+	TableSet           = 0x26 // table.set x
+	I32Load            = 0x28 // i32.load m
+	I64Load            = 0x29 // i64.load m
+	F32Load            = 0x2A // f32.load m
+	F64Load            = 0x2B // f64.load m
+	I32Load8S          = 0x2C // i32.load8_s m
+	I32Load8U          = 0x2D // i32.load8_u m
+	I32Load16S         = 0x2E // i32.load16_s m
+	I32Load16U         = 0x2F // i32.load16_u m
+	I64Load8S          = 0x30 // i64.load8_s m
+	I64Load8U          = 0x31 // i64.load8_u m
+	I64Load16S         = 0x32 // i64.load16_s m
+	I64Load16U         = 0x33 // i64.load16_u m
+	I64Load32S         = 0x34 // i64.load32_s m
+	I64Load32U         = 0x35 // i64.load32_u m
+	I32Store           = 0x36 // i32.store m
+	I64Store           = 0x37 // i64.store m
+	F32Store           = 0x38 // f32.store m
+	F64Store           = 0x39 // f64.store m
+	I32Store8          = 0x3A // i32.store8 m
+	I32Store16         = 0x3B // i32.store16 m
+	I64Store8          = 0x3C // i64.store8 m
+	I64Store16         = 0x3D // i64.store16 m
+	I64Store32         = 0x3E // i64.store32 m
+	MemorySize         = 0x3F // memory.size
+	MemoryGrow         = 0x40 // memory.grow
+	I32Const           = 0x41 // i32.const n
+	I64Const           = 0x42 // i64.const n
+	F32Const           = 0x43 // f32.const z
+	F64Const           = 0x44 // f64.const z
+	I32Eqz             = 0x45 // i32.eqz
+	I32Eq              = 0x46 // i32.eq
+	I32Ne              = 0x47 // i32.ne
+	I32LtS             = 0x48 // i32.lt_s
+	I32LtU             = 0x49 // i32.lt_u
+	I32GtS             = 0x4A // i32.gt_s
+	I32GtU             = 0x4B // i32.gt_u
+	I32LeS             = 0x4C // i32.le_s
+	I32LeU             = 0x4D // i32.le_u
+	I32GeS             = 0x4E // i32.ge_s
+	I32GeU             = 0x4F // i32.ge_u
+	I64Eqz             = 0x50 // i64.eqz
+	I64Eq              = 0x51 // i64.eq
+	I64Ne              = 0x52 // i64.ne
+	I64LtS             = 0x53 // i64.lt_s
+	I64LtU             = 0x54 // i64.lt_u
+	I64GtS             = 0x55 // i64.gt_s
+	I64GtU             = 0x56 // i64.gt_u
+	I64LeS             = 0x57 // i64.le_s
+	I64LeU             = 0x58 // i64.le_u
+	I64GeS             = 0x59 // i64.ge_s
+	I64GeU             = 0x5A // i64.ge_u
+	F32Eq              = 0x5B // f32.eq
+	F32Ne              = 0x5C // f32.ne
+	F32Lt              = 0x5D // f32.lt
+	F32Gt              = 0x5E // f32.gt
+	F32Le              = 0x5F // f32.le
+	F32Ge              = 0x60 // f32.ge
+	F64Eq              = 0x61 // f64.eq
+	F64Ne              = 0x62 // f64.ne
+	F64Lt              = 0x63 // f64.lt
+	F64Gt              = 0x64 // f64.gt
+	F64Le              = 0x65 // f64.le
+	F64Ge              = 0x66 // f64.ge
+	I32Clz             = 0x67 // i32.clz
+	I32Ctz             = 0x68 // i32.ctz
+	I32PopCnt          = 0x69 // i32.popcnt
+	I32Add             = 0x6A // i32.add
+	I32Sub             = 0x6B // i32.sub
+	I32Mul             = 0x6C // i32.mul
+	I32DivS            = 0x6D // i32.div_s
+	I32DivU            = 0x6E // i32.div_u
+	I32RemS            = 0x6F // i32.rem_s
+	I32RemU            = 0x70 // i32.rem_u
+	I32And             = 0x71 // i32.and
+	I32Or              = 0x72 // i32.or
+	I32Xor             = 0x73 // i32.xor
+	I32Shl             = 0x74 // i32.shl
+	I32ShrS            = 0x75 // i32.shr_s
+	I32ShrU            = 0x76 // i32.shr_u
+	I32Rotl            = 0x77 // i32.rotl
+	I32Rotr            = 0x78 // i32.rotr
+	I64Clz             = 0x79 // i64.clz
+	I64Ctz             = 0x7A // i64.ctz
+	I64PopCnt          = 0x7B // i64.popcnt
+	I64Add             = 0x7C // i64.add
+	I64Sub             = 0x7D // i64.sub
+	I64Mul             = 0x7E // i64.mul
+	I64DivS            = 0x7F // i64.div_s
+	I64DivU            = 0x80 // i64.div_u
+	I64RemS            = 0x81 // i64.rem_s
+	I64RemU            = 0x82 // i64.rem_u
+	I64And             = 0x83 // i64.and
+	I64Or              = 0x84 // i64.or
+	I64Xor             = 0x85 // i64.xor
+	I64Shl             = 0x86 // i64.shl
+	I64ShrS            = 0x87 // i64.shr_s
+	I64ShrU            = 0x88 // i64.shr_u
+	I64Rotl            = 0x89 // i64.rotl
+	I64Rotr            = 0x8A // i64.rotr
+	F32Abs             = 0x8B // f32.abs
+	F32Neg             = 0x8C // f32.neg
+	F32Ceil            = 0x8D // f32.ceil
+	F32Floor           = 0x8E // f32.floor
+	F32Trunc           = 0x8F // f32.trunc
+	F32Nearest         = 0x90 // f32.nearest
+	F32Sqrt            = 0x91 // f32.sqrt
+	F32Add             = 0x92 // f32.add
+	F32Sub             = 0x93 // f32.sub
+	F32Mul             = 0x94 // f32.mul
+	F32Div             = 0x95 // f32.div
+	F32Min             = 0x96 // f32.min
+	F32Max             = 0x97 // f32.max
+	F32CopySign        = 0x98 // f32.copysign
+	F64Abs             = 0x99 // f64.abs
+	F64Neg             = 0x9A // f64.neg
+	F64Ceil            = 0x9B // f64.ceil
+	F64Floor           = 0x9C // f64.floor
+	F64Trunc           = 0x9D // f64.trunc
+	F64Nearest         = 0x9E // f64.nearest
+	F64Sqrt            = 0x9F // f64.sqrt
+	F64Add             = 0xA0 // f64.add
+	F64Sub             = 0xA1 // f64.sub
+	F64Mul             = 0xA2 // f64.mul
+	F64Div             = 0xA3 // f64.div
+	F64Min             = 0xA4 // f64.min
+	F64Max             = 0xA5 // f64.max
+	F64CopySign        = 0xA6 // f64.copysign
+	I32WrapI64         = 0xA7 // i32.wrap_i64
+	I32TruncF32S       = 0xA8 // i32.trunc_f32_s
+	I32TruncF32U       = 0xA9 // i32.trunc_f32_u
+	I32TruncF64S       = 0xAA // i32.trunc_f64_s
+	I32TruncF64U       = 0xAB // i32.trunc_f64_u
+	I64ExtendI32S      = 0xAC // i64.extend_i32_s
+	I64ExtendI32U      = 0xAD // i64.extend_i32_u
+	I64TruncF32S       = 0xAE // i64.trunc_f32_s
+	I64TruncF32U       = 0xAF // i64.trunc_f32_u
+	I64TruncF64S       = 0xB0 // i64.trunc_f64_s
+	I64TruncF64U       = 0xB1 // i64.trunc_f64_u
+	F32ConvertI32S     = 0xB2 // f32.convert_i32_s
+	F32ConvertI32U     = 0xB3 // f32.convert_i32_u
+	F32ConvertI64S     = 0xB4 // f32.convert_i64_s
+	F32ConvertI64U     = 0xB5 // f32.convert_i64_u
+	F32DemoteF64       = 0xB6 // f32.demote_f64
+	F64ConvertI32S     = 0xB7 // f64.convert_i32_s
+	F64ConvertI32U     = 0xB8 // f64.convert_i32_u
+	F64ConvertI64S     = 0xB9 // f64.convert_i64_s
+	F64ConvertI64U     = 0xBA // f64.convert_i64_u
+	F64PromoteF32      = 0xBB // f64.promote_f32
+	I32ReinterpretF32  = 0xBC // i32.reinterpret_f32
+	I64ReinterpretF64  = 0xBD // i64.reinterpret_f64
+	F32ReinterpretI32  = 0xBE // f32.reinterpret_i32
+	F64ReinterpretI64  = 0xBF // f64.reinterpret_i64
+	I32Extend8S        = 0xC0 // i32.extend8_s
+	I32Extend16S       = 0xC1 // i32.extend16_s
+	I64Extend8S        = 0xC2 // i64.extend8_s
+	I64Extend16S       = 0xC3 // i64.extend16_s
+	I64Extend32S       = 0xC4 // i64.extend32_s
+	RefNull            = 0xD0 // ref.null t
+	RefIsNull          = 0xD1 // ref.is_null
+	RefFunc            = 0xD2 // ref.func x
+	NumericPrefix      = 0xFC // followed by a u32 sub-opcode
+	SimdPrefix         = 0xFD // followed by a u32 sub-opcode
+	AtomicPrefix       = 0xFE // followed by a u32 sub-opcode
 )
+
+// Sub-opcodes of NumericPrefix
+const (
+	I32TruncSatF32S = 0x00 // i32.trunc_sat_f32_s
+	I32TruncSatF32U = 0x01 // i32.trunc_sat_f32_u
+	I32TruncSatF64S = 0x02 // i32.trunc_sat_f64_s
+	I32TruncSatF64U = 0x03 // i32.trunc_sat_f64_u
+	I64TruncSatF32S = 0x04 // i64.trunc_sat_f32_s
+	I64TruncSatF32U = 0x05 // i64.trunc_sat_f32_u
+	I64TruncSatF64S = 0x06 // i64.trunc_sat_f64_s
+	I64TruncSatF64U = 0x07 // i64.trunc_sat_f64_u
+	MemoryInit      = 0x08 // memory.init x
+	DataDrop        = 0x09 // data.drop x
+	MemoryCopy      = 0x0A // memory.copy
+	MemoryFill      = 0x0B // memory.fill
+	TableInit       = 0x0C // table.init x y
+	ElemDrop        = 0x0D // elem.drop x
+	TableCopy       = 0x0E // table.copy x y
+	TableGrow       = 0x0F // table.grow x
+	TableSize       = 0x10 // table.size x
+	TableFill       = 0x11 // table.fill x
+)
+
+// Sub-opcodes of SimdPrefix
+const (
+	V128Load                  = 0x00 // v128.load m
+	V128Load8x8S              = 0x01 // v128.load8x8_s m
+	V128Load8x8U              = 0x02 // v128.load8x8_u m
+	V128Load16x4S             = 0x03 // v128.load16x4_s m
+	V128Load16x4U             = 0x04 // v128.load16x4_u m
+	V128Load32x2S             = 0x05 // v128.load32x2_s m
+	V128Load32x2U             = 0x06 // v128.load32x2_u m
+	V128Load8Splat            = 0x07 // v128.load8_splat m
+	V128Load16Splat           = 0x08 // v128.load16_splat m
+	V128Load32Splat           = 0x09 // v128.load32_splat m
+	V128Load64Splat           = 0x0A // v128.load64_splat m
+	V128Store                 = 0x0B // v128.store m
+	V128Const                 = 0x0C // v128.const i128
+	I8x16Shuffle              = 0x0D // i8x16.shuffle l*16
+	I8x16Swizzle              = 0x0E // i8x16.swizzle
+	I8x16Splat                = 0x0F // i8x16.splat
+	I16x8Splat                = 0x10 // i16x8.splat
+	I32x4Splat                = 0x11 // i32x4.splat
+	I64x2Splat                = 0x12 // i64x2.splat
+	F32x4Splat                = 0x13 // f32x4.splat
+	F64x2Splat                = 0x14 // f64x2.splat
+	I8x16ExtractLaneS         = 0x15 // i8x16.extract_lane_s l
+	I8x16ExtractLaneU         = 0x16 // i8x16.extract_lane_u l
+	I8x16ReplaceLane          = 0x17 // i8x16.replace_lane l
+	I16x8ExtractLaneS         = 0x18 // i16x8.extract_lane_s l
+	I16x8ExtractLaneU         = 0x19 // i16x8.extract_lane_u l
+	I16x8ReplaceLane          = 0x1A // i16x8.replace_lane l
+	I32x4ExtractLane          = 0x1B // i32x4.extract_lane l
+	I32x4ReplaceLane          = 0x1C // i32x4.replace_lane l
+	I64x2ExtractLane          = 0x1D // i64x2.extract_lane l
+	I64x2ReplaceLane          = 0x1E // i64x2.replace_lane l
+	F32x4ExtractLane          = 0x1F // f32x4.extract_lane l
+	F32x4ReplaceLane          = 0x20 // f32x4.replace_lane l
+	F64x2ExtractLane          = 0x21 // f64x2.extract_lane l
+	F64x2ReplaceLane          = 0x22 // f64x2.replace_lane l
+	I8x16Eq                   = 0x23 // i8x16.eq
+	I8x16Ne                   = 0x24 // i8x16.ne
+	I8x16LtS                  = 0x25 // i8x16.lt_s
+	I8x16LtU                  = 0x26 // i8x16.lt_u
+	I8x16GtS                  = 0x27 // i8x16.gt_s
+	I8x16GtU                  = 0x28 // i8x16.gt_u
+	I8x16LeS                  = 0x29 // i8x16.le_s
+	I8x16LeU                  = 0x2A // i8x16.le_u
+	I8x16GeS                  = 0x2B // i8x16.ge_s
+	I8x16GeU                  = 0x2C // i8x16.ge_u
+	I16x8Eq                   = 0x2D // i16x8.eq
+	I16x8Ne                   = 0x2E // i16x8.ne
+	I16x8LtS                  = 0x2F // i16x8.lt_s
+	I16x8LtU                  = 0x30 // i16x8.lt_u
+	I16x8GtS                  = 0x31 // i16x8.gt_s
+	I16x8GtU                  = 0x32 // i16x8.gt_u
+	I16x8LeS                  = 0x33 // i16x8.le_s
+	I16x8LeU                  = 0x34 // i16x8.le_u
+	I16x8GeS                  = 0x35 // i16x8.ge_s
+	I16x8GeU                  = 0x36 // i16x8.ge_u
+	I32x4Eq                   = 0x37 // i32x4.eq
+	I32x4Ne                   = 0x38 // i32x4.ne
+	I32x4LtS                  = 0x39 // i32x4.lt_s
+	I32x4LtU                  = 0x3A // i32x4.lt_u
+	I32x4GtS                  = 0x3B // i32x4.gt_s
+	I32x4GtU                  = 0x3C // i32x4.gt_u
+	I32x4LeS                  = 0x3D // i32x4.le_s
+	I32x4LeU                  = 0x3E // i32x4.le_u
+	I32x4GeS                  = 0x3F // i32x4.ge_s
+	I32x4GeU                  = 0x40 // i32x4.ge_u
+	F32x4Eq                   = 0x41 // f32x4.eq
+	F32x4Ne                   = 0x42 // f32x4.ne
+	F32x4Lt                   = 0x43 // f32x4.lt
+	F32x4Gt                   = 0x44 // f32x4.gt
+	F32x4Le                   = 0x45 // f32x4.le
+	F32x4Ge                   = 0x46 // f32x4.ge
+	F64x2Eq                   = 0x47 // f64x2.eq
+	F64x2Ne                   = 0x48 // f64x2.ne
+	F64x2Lt                   = 0x49 // f64x2.lt
+	F64x2Gt                   = 0x4A // f64x2.gt
+	F64x2Le                   = 0x4B // f64x2.le
+	F64x2Ge                   = 0x4C // f64x2.ge
+	V128Not                   = 0x4D // v128.not
+	V128And                   = 0x4E // v128.and
+	V128Andnot                = 0x4F // v128.andnot
+	V128Or                    = 0x50 // v128.or
+	V128Xor                   = 0x51 // v128.xor
+	V128Bitselect             = 0x52 // v128.bitselect
+	V128AnyTrue               = 0x53 // v128.any_true
+	V128Load8Lane             = 0x54 // v128.load8_lane m l
+	V128Load16Lane            = 0x55 // v128.load16_lane m l
+	V128Load32Lane            = 0x56 // v128.load32_lane m l
+	V128Load64Lane            = 0x57 // v128.load64_lane m l
+	V128Store8Lane            = 0x58 // v128.store8_lane m l
+	V128Store16Lane           = 0x59 // v128.store16_lane m l
+	V128Store32Lane           = 0x5A // v128.store32_lane m l
+	V128Store64Lane           = 0x5B // v128.store64_lane m l
+	V128Load32Zero            = 0x5C // v128.load32_zero m
+	V128Load64Zero            = 0x5D // v128.load64_zero m
+	F32x4DemoteF64x2Zero      = 0x5E // f32x4.demote_f64x2_zero
+	F64x2PromoteLowF32x4      = 0x5F // f64x2.promote_low_f32x4
+	I8x16Abs                  = 0x60 // i8x16.abs
+	I8x16Neg                  = 0x61 // i8x16.neg
+	I8x16Popcnt               = 0x62 // i8x16.popcnt
+	I8x16AllTrue              = 0x63 // i8x16.all_true
+	I8x16Bitmask              = 0x64 // i8x16.bitmask
+	I8x16NarrowI16x8S         = 0x65 // i8x16.narrow_i16x8_s
+	I8x16NarrowI16x8U         = 0x66 // i8x16.narrow_i16x8_u
+	F32x4Ceil                 = 0x67 // f32x4.ceil
+	F32x4Floor                = 0x68 // f32x4.floor
+	F32x4Trunc                = 0x69 // f32x4.trunc
+	F32x4Nearest              = 0x6A // f32x4.nearest
+	I8x16Shl                  = 0x6B // i8x16.shl
+	I8x16ShrS                 = 0x6C // i8x16.shr_s
+	I8x16ShrU                 = 0x6D // i8x16.shr_u
+	I8x16Add                  = 0x6E // i8x16.add
+	I8x16AddSatS              = 0x6F // i8x16.add_sat_s
+	I8x16AddSatU              = 0x70 // i8x16.add_sat_u
+	I8x16Sub                  = 0x71 // i8x16.sub
+	I8x16SubSatS              = 0x72 // i8x16.sub_sat_s
+	I8x16SubSatU              = 0x73 // i8x16.sub_sat_u
+	F64x2Ceil                 = 0x74 // f64x2.ceil
+	F64x2Floor                = 0x75 // f64x2.floor
+	I8x16MinS                 = 0x76 // i8x16.min_s
+	I8x16MinU                 = 0x77 // i8x16.min_u
+	I8x16MaxS                 = 0x78 // i8x16.max_s
+	I8x16MaxU                 = 0x79 // i8x16.max_u
+	F64x2Trunc                = 0x7A // f64x2.trunc
+	I8x16AvgrU                = 0x7B // i8x16.avgr_u
+	I16x8ExtaddPairwiseI8x16S = 0x7C // i16x8.extadd_pairwise_i8x16_s
+	I16x8ExtaddPairwiseI8x16U = 0x7D // i16x8.extadd_pairwise_i8x16_u
+	I32x4ExtaddPairwiseI16x8S = 0x7E // i32x4.extadd_pairwise_i16x8_s
+	I32x4ExtaddPairwiseI16x8U = 0x7F // i32x4.extadd_pairwise_i16x8_u
+	I16x8Abs                  = 0x80 // i16x8.abs
+	I16x8Neg                  = 0x81 // i16x8.neg
+	I16x8Q15mulrSatS          = 0x82 // i16x8.q15mulr_sat_s
+	I16x8AllTrue              = 0x83 // i16x8.all_true
+	I16x8Bitmask              = 0x84 // i16x8.bitmask
+	I16x8NarrowI32x4S         = 0x85 // i16x8.narrow_i32x4_s
+	I16x8NarrowI32x4U         = 0x86 // i16x8.narrow_i32x4_u
+	I16x8ExtendLowI8x16S      = 0x87 // i16x8.extend_low_i8x16_s
+	I16x8ExtendHighI8x16S     = 0x88 // i16x8.extend_high_i8x16_s
+	I16x8ExtendLowI8x16U      = 0x89 // i16x8.extend_low_i8x16_u
+	I16x8ExtendHighI8x16U     = 0x8A // i16x8.extend_high_i8x16_u
+	I16x8Shl                  = 0x8B // i16x8.shl
+	I16x8ShrS                 = 0x8C // i16x8.shr_s
+	I16x8ShrU                 = 0x8D // i16x8.shr_u
+	I16x8Add                  = 0x8E // i16x8.add
+	I16x8AddSatS              = 0x8F // i16x8.add_sat_s
+	I16x8AddSatU              = 0x90 // i16x8.add_sat_u
+	I16x8Sub                  = 0x91 // i16x8.sub
+	I16x8SubSatS              = 0x92 // i16x8.sub_sat_s
+	I16x8SubSatU              = 0x93 // i16x8.sub_sat_u
+	F64x2Nearest              = 0x94 // f64x2.nearest
+	I16x8Mul                  = 0x95 // i16x8.mul
+	I16x8MinS                 = 0x96 // i16x8.min_s
+	I16x8MinU                 = 0x97 // i16x8.min_u
+	I16x8MaxS                 = 0x98 // i16x8.max_s
+	I16x8MaxU                 = 0x99 // i16x8.max_u
+	I16x8AvgrU                = 0x9B // i16x8.avgr_u
+	I16x8ExtmulLowI8x16S      = 0x9C // i16x8.extmul_low_i8x16_s
+	I16x8ExtmulHighI8x16S     = 0x9D // i16x8.extmul_high_i8x16_s
+	I16x8ExtmulLowI8x16U      = 0x9E // i16x8.extmul_low_i8x16_u
+	I16x8ExtmulHighI8x16U     = 0x9F // i16x8.extmul_high_i8x16_u
+	I32x4Abs                  = 0xA0 // i32x4.abs
+	I32x4Neg                  = 0xA1 // i32x4.neg
+	I32x4AllTrue              = 0xA3 // i32x4.all_true
+	I32x4Bitmask              = 0xA4 // i32x4.bitmask
+	I32x4ExtendLowI16x8S      = 0xA7 // i32x4.extend_low_i16x8_s
+	I32x4ExtendHighI16x8S     = 0xA8 // i32x4.extend_high_i16x8_s
+	I32x4ExtendLowI16x8U      = 0xA9 // i32x4.extend_low_i16x8_u
+	I32x4ExtendHighI16x8U     = 0xAA // i32x4.extend_high_i16x8_u
+	I32x4Shl                  = 0xAB // i32x4.shl
+	I32x4ShrS                 = 0xAC // i32x4.shr_s
+	I32x4ShrU                 = 0xAD // i32x4.shr_u
+	I32x4Add                  = 0xAE // i32x4.add
+	I32x4Sub                  = 0xB1 // i32x4.sub
+	I32x4Mul                  = 0xB5 // i32x4.mul
+	I32x4MinS                 = 0xB6 // i32x4.min_s
+	I32x4MinU                 = 0xB7 // i32x4.min_u
+	I32x4MaxS                 = 0xB8 // i32x4.max_s
+	I32x4MaxU                 = 0xB9 // i32x4.max_u
+	I32x4DotI16x8S            = 0xBA // i32x4.dot_i16x8_s
+	I32x4ExtmulLowI16x8S      = 0xBC // i32x4.extmul_low_i16x8_s
+	I32x4ExtmulHighI16x8S     = 0xBD // i32x4.extmul_high_i16x8_s
+	I32x4ExtmulLowI16x8U      = 0xBE // i32x4.extmul_low_i16x8_u
+	I32x4ExtmulHighI16x8U     = 0xBF // i32x4.extmul_high_i16x8_u
+	I64x2Abs                  = 0xC0 // i64x2.abs
+	I64x2Neg                  = 0xC1 // i64x2.neg
+	I64x2AllTrue              = 0xC3 // i64x2.all_true
+	I64x2Bitmask              = 0xC4 // i64x2.bitmask
+	I64x2ExtendLowI32x4S      = 0xC7 // i64x2.extend_low_i32x4_s
+	I64x2ExtendHighI32x4S     = 0xC8 // i64x2.extend_high_i32x4_s
+	I64x2ExtendLowI32x4U      = 0xC9 // i64x2.extend_low_i32x4_u
+	I64x2ExtendHighI32x4U     = 0xCA // i64x2.extend_high_i32x4_u
+	I64x2Shl                  = 0xCB // i64x2.shl
+	I64x2ShrS                 = 0xCC // i64x2.shr_s
+	I64x2ShrU                 = 0xCD // i64x2.shr_u
+	I64x2Add                  = 0xCE // i64x2.add
+	I64x2Sub                  = 0xD1 // i64x2.sub
+	I64x2Mul                  = 0xD5 // i64x2.mul
+	I64x2Eq                   = 0xD6 // i64x2.eq
+	I64x2Ne                   = 0xD7 // i64x2.ne
+	I64x2LtS                  = 0xD8 // i64x2.lt_s
+	I64x2GtS                  = 0xD9 // i64x2.gt_s
+	I64x2LeS                  = 0xDA // i64x2.le_s
+	I64x2GeS                  = 0xDB // i64x2.ge_s
+	I64x2ExtmulLowI32x4S      = 0xDC // i64x2.extmul_low_i32x4_s
+	I64x2ExtmulHighI32x4S     = 0xDD // i64x2.extmul_high_i32x4_s
+	I64x2ExtmulLowI32x4U      = 0xDE // i64x2.extmul_low_i32x4_u
+	I64x2ExtmulHighI32x4U     = 0xDF // i64x2.extmul_high_i32x4_u
+	F32x4Abs                  = 0xE0 // f32x4.abs
+	F32x4Neg                  = 0xE1 // f32x4.neg
+	F32x4Sqrt                 = 0xE3 // f32x4.sqrt
+	F32x4Add                  = 0xE4 // f32x4.add
+	F32x4Sub                  = 0xE5 // f32x4.sub
+	F32x4Mul                  = 0xE6 // f32x4.mul
+	F32x4Div                  = 0xE7 // f32x4.div
+	F32x4Min                  = 0xE8 // f32x4.min
+	F32x4Max                  = 0xE9 // f32x4.max
+	F32x4Pmin                 = 0xEA // f32x4.pmin
+	F32x4Pmax                 = 0xEB // f32x4.pmax
+	F64x2Abs                  = 0xEC // f64x2.abs
+	F64x2Neg                  = 0xED // f64x2.neg
+	F64x2Sqrt                 = 0xEF // f64x2.sqrt
+	F64x2Add                  = 0xF0 // f64x2.add
+	F64x2Sub                  = 0xF1 // f64x2.sub
+	F64x2Mul                  = 0xF2 // f64x2.mul
+	F64x2Div                  = 0xF3 // f64x2.div
+	F64x2Min                  = 0xF4 // f64x2.min
+	F64x2Max                  = 0xF5 // f64x2.max
+	F64x2Pmin                 = 0xF6 // f64x2.pmin
+	F64x2Pmax                 = 0xF7 // f64x2.pmax
+	I32x4TruncSatF32x4S       = 0xF8 // i32x4.trunc_sat_f32x4_s
+	I32x4TruncSatF32x4U       = 0xF9 // i32x4.trunc_sat_f32x4_u
+	F32x4ConvertI32x4S        = 0xFA // f32x4.convert_i32x4_s
+	F32x4ConvertI32x4U        = 0xFB // f32x4.convert_i32x4_u
+	I32x4TruncSatF64x2SZero   = 0xFC // i32x4.trunc_sat_f64x2_s_zero
+	I32x4TruncSatF64x2UZero   = 0xFD // i32x4.trunc_sat_f64x2_u_zero
+	F64x2ConvertLowI32x4S     = 0xFE // f64x2.convert_low_i32x4_s
+	F64x2ConvertLowI32x4U     = 0xFF // f64x2.convert_low_i32x4_u
+)
+
+// Sub-opcodes of AtomicPrefix
+const (
+	MemoryAtomicNotify     = 0x00 // memory.atomic.notify m
+	MemoryAtomicWait32     = 0x01 // memory.atomic.wait32 m
+	MemoryAtomicWait64     = 0x02 // memory.atomic.wait64 m
+	AtomicFence            = 0x03 // atomic.fence
+	I32AtomicLoad          = 0x10 // i32.atomic.load m
+	I64AtomicLoad          = 0x11 // i64.atomic.load m
+	I32AtomicLoad8U        = 0x12 // i32.atomic.load8_u m
+	I32AtomicLoad16U       = 0x13 // i32.atomic.load16_u m
+	I64AtomicLoad8U        = 0x14 // i64.atomic.load8_u m
+	I64AtomicLoad16U       = 0x15 // i64.atomic.load16_u m
+	I64AtomicLoad32U       = 0x16 // i64.atomic.load32_u m
+	I32AtomicStore         = 0x17 // i32.atomic.store m
+	I64AtomicStore         = 0x18 // i64.atomic.store m
+	I32AtomicStore8        = 0x19 // i32.atomic.store8 m
+	I32AtomicStore16       = 0x1A // i32.atomic.store16 m
+	I64AtomicStore8        = 0x1B // i64.atomic.store8 m
+	I64AtomicStore16       = 0x1C // i64.atomic.store16 m
+	I64AtomicStore32       = 0x1D // i64.atomic.store32 m
+	I32AtomicRmwAdd        = 0x1E // i32.atomic.rmw.add m
+	I64AtomicRmwAdd        = 0x1F // i64.atomic.rmw.add m
+	I32AtomicRmw8AddU      = 0x20 // i32.atomic.rmw8.add_u m
+	I32AtomicRmw16AddU     = 0x21 // i32.atomic.rmw16.add_u m
+	I64AtomicRmw8AddU      = 0x22 // i64.atomic.rmw8.add_u m
+	I64AtomicRmw16AddU     = 0x23 // i64.atomic.rmw16.add_u m
+	I64AtomicRmw32AddU     = 0x24 // i64.atomic.rmw32.add_u m
+	I32AtomicRmwSub        = 0x25 // i32.atomic.rmw.sub m
+	I64AtomicRmwSub        = 0x26 // i64.atomic.rmw.sub m
+	I32AtomicRmw8SubU      = 0x27 // i32.atomic.rmw8.sub_u m
+	I32AtomicRmw16SubU     = 0x28 // i32.atomic.rmw16.sub_u m
+	I64AtomicRmw8SubU      = 0x29 // i64.atomic.rmw8.sub_u m
+	I64AtomicRmw16SubU     = 0x2A // i64.atomic.rmw16.sub_u m
+	I64AtomicRmw32SubU     = 0x2B // i64.atomic.rmw32.sub_u m
+	I32AtomicRmwAnd        = 0x2C // i32.atomic.rmw.and m
+	I64AtomicRmwAnd        = 0x2D // i64.atomic.rmw.and m
+	I32AtomicRmw8AndU      = 0x2E // i32.atomic.rmw8.and_u m
+	I32AtomicRmw16AndU     = 0x2F // i32.atomic.rmw16.and_u m
+	I64AtomicRmw8AndU      = 0x30 // i64.atomic.rmw8.and_u m
+	I64AtomicRmw16AndU     = 0x31 // i64.atomic.rmw16.and_u m
+	I64AtomicRmw32AndU     = 0x32 // i64.atomic.rmw32.and_u m
+	I32AtomicRmwOr         = 0x33 // i32.atomic.rmw.or m
+	I64AtomicRmwOr         = 0x34 // i64.atomic.rmw.or m
+	I32AtomicRmw8OrU       = 0x35 // i32.atomic.rmw8.or_u m
+	I32AtomicRmw16OrU      = 0x36 // i32.atomic.rmw16.or_u m
+	I64AtomicRmw8OrU       = 0x37 // i64.atomic.rmw8.or_u m
+	I64AtomicRmw16OrU      = 0x38 // i64.atomic.rmw16.or_u m
+	I64AtomicRmw32OrU      = 0x39 // i64.atomic.rmw32.or_u m
+	I32AtomicRmwXor        = 0x3A // i32.atomic.rmw.xor m
+	I64AtomicRmwXor        = 0x3B // i64.atomic.rmw.xor m
+	I32AtomicRmw8XorU      = 0x3C // i32.atomic.rmw8.xor_u m
+	I32AtomicRmw16XorU     = 0x3D // i32.atomic.rmw16.xor_u m
+	I64AtomicRmw8XorU      = 0x3E // i64.atomic.rmw8.xor_u m
+	I64AtomicRmw16XorU     = 0x3F // i64.atomic.rmw16.xor_u m
+	I64AtomicRmw32XorU     = 0x40 // i64.atomic.rmw32.xor_u m
+	I32AtomicRmwXchg       = 0x41 // i32.atomic.rmw.xchg m
+	I64AtomicRmwXchg       = 0x42 // i64.atomic.rmw.xchg m
+	I32AtomicRmw8XchgU     = 0x43 // i32.atomic.rmw8.xchg_u m
+	I32AtomicRmw16XchgU    = 0x44 // i32.atomic.rmw16.xchg_u m
+	I64AtomicRmw8XchgU     = 0x45 // i64.atomic.rmw8.xchg_u m
+	I64AtomicRmw16XchgU    = 0x46 // i64.atomic.rmw16.xchg_u m
+	I64AtomicRmw32XchgU    = 0x47 // i64.atomic.rmw32.xchg_u m
+	I32AtomicRmwCmpxchg    = 0x48 // i32.atomic.rmw.cmpxchg m
+	I64AtomicRmwCmpxchg    = 0x49 // i64.atomic.rmw.cmpxchg m
+	I32AtomicRmw8CmpxchgU  = 0x4A // i32.atomic.rmw8.cmpxchg_u m
+	I32AtomicRmw16CmpxchgU = 0x4B // i32.atomic.rmw16.cmpxchg_u m
+	I64AtomicRmw8CmpxchgU  = 0x4C // i64.atomic.rmw8.cmpxchg_u m
+	I64AtomicRmw16CmpxchgU = 0x4D // i64.atomic.rmw16.cmpxchg_u m
+	I64AtomicRmw32CmpxchgU = 0x4E // i64.atomic.rmw32.cmpxchg_u m
+)
+
+// The atomic loads, stores and rmw ops come in groups of seven
+// sharing the same value types and access widths
+var atomicAccesses = [7]struct {
+	vt       ValType
+	bitWidth int
+}{
+	{ValTypeI32, 32}, {ValTypeI64, 64}, {ValTypeI32, 8}, {ValTypeI32, 16},
+	{ValTypeI64, 8}, {ValTypeI64, 16}, {ValTypeI64, 32},
+}
+
+// AtomicAccess returns the value type and the access width in bits of
+// an atomic load, store or rmw sub-opcode
+func AtomicAccess(subOp uint32) (ValType, int) {
+	a := atomicAccesses[(subOp-I32AtomicLoad)%7]
+	return a.vt, a.bitWidth
+}
@@ -0,0 +1,56 @@
+package binary
+
+// DecodeOptions bounds the resources an untrusted binary can make the
+// decoder, validator and runtimes allocate, and the proposals it may
+// use. Zero fields take the value from DefaultDecodeOptions.
+type DecodeOptions struct {
+	MaxSectionSize  uint32 // in bytes, custom sections included
+	MaxFuncs        uint32 // imported & internal
+	MaxLocals       uint32 // per function, params excluded
+	MaxBodySize     uint32 // in bytes, per function
+	MaxNestingDepth uint32 // of blocks, loops and ifs
+	MaxDataSize     uint32 // in bytes, per data segment
+	MaxMemoryPages  uint64 // in all the memories an instance defines, i32 ones stay within MaxPageCount
+	MaxTableElems   uint64 // in all the tables an instance defines
+	Features        Features
+}
+
+// DefaultDecodeOptions mostly follows the limits of the JS API.
+var DefaultDecodeOptions = DecodeOptions{
+	MaxSectionSize:  1 << 30,
+	MaxFuncs:        1000000,
+	MaxLocals:       50000,
+	MaxBodySize:     7654321,
+	MaxNestingDepth: 1 << 14,
+	MaxDataSize:     1 << 30,
+	MaxMemoryPages:  1 << 14, // 1GiB
+	MaxTableElems:   10000000,
+}
+
+func (opts DecodeOptions) WithDefaults() DecodeOptions {
+	if opts.MaxSectionSize == 0 {
+		opts.MaxSectionSize = DefaultDecodeOptions.MaxSectionSize
+	}
+	if opts.MaxFuncs == 0 {
+		opts.MaxFuncs = DefaultDecodeOptions.MaxFuncs
+	}
+	if opts.MaxLocals == 0 {
+		opts.MaxLocals = DefaultDecodeOptions.MaxLocals
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = DefaultDecodeOptions.MaxBodySize
+	}
+	if opts.MaxNestingDepth == 0 {
+		opts.MaxNestingDepth = DefaultDecodeOptions.MaxNestingDepth
+	}
+	if opts.MaxDataSize == 0 {
+		opts.MaxDataSize = DefaultDecodeOptions.MaxDataSize
+	}
+	if opts.MaxMemoryPages == 0 {
+		opts.MaxMemoryPages = DefaultDecodeOptions.MaxMemoryPages
+	}
+	if opts.MaxTableElems == 0 {
+		opts.MaxTableElems = DefaultDecodeOptions.MaxTableElems
+	}
+	return opts
+}
@@ -10,18 +10,47 @@ import (
 )
 
 type wasmReader struct {
-	data []byte
+	data  []byte
+	size  int // of the whole binary
+	opts  DecodeOptions
+	depth uint32 // of nested blocks
+
+	usesDataIdx bool // requires the data count section
+}
+
+// non-custom sections must appear in this order
+var secOrder = []byte{
+	SecTypeID, SecImportID, SecFuncID, SecTableID, SecMemID,
+	SecTagID, SecGlobalID, SecExportID, SecStartID, SecElemID,
+	SecDataCountID, SecCodeID, SecDataID,
+}
+
+func getSecOrder(secID byte) int {
+	for i, id := range secOrder {
+		if id == secID {
+			return i
+		}
+	}
+	return -1
 }
 
 func DecodeFile(filename string) (Module, error) {
+	return DecodeFileWithOptions(filename, DecodeOptions{})
+}
+
+func DecodeFileWithOptions(filename string, opts DecodeOptions) (Module, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return Module{}, err
 	}
-	return Decode(data)
+	return DecodeWithOptions(data, opts)
+}
+
+func Decode(data []byte) (Module, error) {
+	return DecodeWithOptions(data, DecodeOptions{})
 }
 
-func Decode(data []byte) (module Module, err error) {
+func DecodeWithOptions(data []byte, opts DecodeOptions) (module Module, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			switch x := r.(type) {
@@ -33,15 +62,25 @@ func Decode(data []byte) (module Module, err error) {
 		}
 	}()
 
-	reader := &wasmReader{data: data}
+	reader := &wasmReader{data: data, size: len(data), opts: opts.WithDefaults()}
 	reader.readModule(&module)
 	return
 }
 
+func (reader *wasmReader) require(f Feature) {
+	if err := reader.opts.Features.Check(f); err != nil {
+		panic(err)
+	}
+}
+
 func (reader *wasmReader) remaining() int {
 	return len(reader.data)
 }
 
+func (reader *wasmReader) offset() int {
+	return reader.size - len(reader.data)
+}
+
 func (reader *wasmReader) readByte() byte {
 	if len(reader.data) < 1 {
 		panic(errUnexpectedEnd)
@@ -84,6 +123,17 @@ func (reader *wasmReader) readVarU32() uint32 {
 	return uint32(n)
 }
 
+func (reader *wasmReader) readVarU64() uint64 {
+	n, w := decodeVarUint(reader.data, 64)
+	reader.data = reader.data[w:]
+	return n
+}
+
+func (reader *wasmReader) peekVarU32() uint32 {
+	n, _ := decodeVarUint(reader.data, 32)
+	return uint32(n)
+}
+
 func (reader *wasmReader) readVarS32() int32 {
 	n, w := decodeVarInt(reader.data, 32)
 	reader.data = reader.data[w:]
@@ -96,6 +146,16 @@ func (reader *wasmReader) readVarS64() int64 {
 	return n
 }
 
+// every element takes at least one byte, so a vector can't be
+// longer than the remaining data
+func (reader *wasmReader) readVecLen() uint32 {
+	n := reader.readVarU32()
+	if uint64(n) > uint64(len(reader.data)) {
+		panic(errLenOutOfBounds)
+	}
+	return n
+}
+
 func (reader *wasmReader) readBytes() []byte {
 	n := reader.readVarU32()
 	if len(reader.data) < int(n) {
@@ -132,30 +192,49 @@ func (reader *wasmReader) readModule(module *Module) {
 	}
 
 	reader.readSections(module)
+	funcCount := len(module.FuncSec)
+	for _, imp := range module.ImportSec {
+		if imp.Desc.Tag == ImportTagFunc {
+			funcCount++
+		}
+	}
+	if funcCount > int(reader.opts.MaxFuncs) {
+		panic(ErrTooManyFuncs)
+	}
 	if len(module.FuncSec) != len(module.CodeSec) {
 		panic(errors.New("function and code section have inconsistent lengths"))
 	}
+	if module.DataCountSec != nil && int(*module.DataCountSec) != len(module.DataSec) {
+		panic(errors.New("data count and data section have inconsistent lengths"))
+	}
+	if reader.usesDataIdx && module.DataCountSec == nil {
+		panic(errors.New("data count section required"))
+	}
 	if reader.remaining() > 0 {
 		panic(errors.New("junk after last section"))
 	}
 }
 
 func (reader *wasmReader) readSections(module *Module) {
-	prevSecID := byte(0)
+	prevOrder := -1
 	for reader.remaining() > 0 {
 		secID := reader.readByte()
+		if reader.peekVarU32() > reader.opts.MaxSectionSize {
+			panic(fmt.Errorf("%w, id: %d", ErrSectionTooLarge, secID))
+		}
 		if secID == SecCustomID {
 			module.CustomSecs = append(module.CustomSecs, reader.readCustomSec())
 			continue
 		}
 
-		if secID > SecDataID {
+		order := getSecOrder(secID)
+		if order < 0 {
 			panic(fmt.Errorf("malformed section id: %d", secID))
 		}
-		if secID <= prevSecID {
+		if order <= prevOrder {
 			panic(fmt.Errorf("junk after last section, id: %d", secID))
 		}
-		prevSecID = secID
+		prevOrder = order
 
 		n := reader.readVarU32()
 		remainingBeforeRead := reader.remaining()
@@ -186,6 +265,9 @@ func (reader *wasmReader) readNonCustomSec(secID byte, module *Module) {
 		module.TableSec = reader.readTableSec()
 	case SecMemID:
 		module.MemSec = reader.readMemSec()
+	case SecTagID:
+		reader.require(FeatureExceptions)
+		module.TagSec = reader.readTagSec()
 	case SecGlobalID:
 		module.GlobalSec = reader.readGlobalSec()
 	case SecExportID:
@@ -195,14 +277,19 @@ func (reader *wasmReader) readNonCustomSec(secID byte, module *Module) {
 	case SecElemID:
 		module.ElemSec = reader.readElemSec()
 	case SecCodeID:
+		module.CodeOffset = uint32(reader.offset())
 		module.CodeSec = reader.readCodeSec()
 	case SecDataID:
 		module.DataSec = reader.readDataSec()
+	case SecDataCountID:
+		reader.require(FeatureBulkMemory)
+		n := reader.readVarU32()
+		module.DataCountSec = &n
 	}
 }
 
 func (reader *wasmReader) readTypeSec() []FuncType {
-	vec := make([]FuncType, reader.readVarU32())
+	vec := make([]FuncType, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readFuncType()
 	}
@@ -210,7 +297,7 @@ func (reader *wasmReader) readTypeSec() []FuncType {
 }
 
 func (reader *wasmReader) readImportSec() []Import {
-	vec := make([]Import, reader.readVarU32())
+	vec := make([]Import, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readImport()
 	}
@@ -236,6 +323,9 @@ func (reader *wasmReader) readImportDesc() ImportDesc {
 		desc.Mem = reader.readLimits()
 	case ImportTagGlobal:
 		desc.Global = reader.readGlobalType()
+	case ImportTagTag:
+		reader.require(FeatureExceptions)
+		desc.TagType = reader.readTagType()
 	default:
 		panic(fmt.Errorf("invalid import desc tag: %d", desc.Tag))
 	}
@@ -243,7 +333,7 @@ func (reader *wasmReader) readImportDesc() ImportDesc {
 }
 
 func (reader *wasmReader) readTableSec() []TableType {
-	vec := make([]TableType, reader.readVarU32())
+	vec := make([]TableType, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readTableType()
 	}
@@ -251,15 +341,23 @@ func (reader *wasmReader) readTableSec() []TableType {
 }
 
 func (reader *wasmReader) readMemSec() []MemType {
-	vec := make([]MemType, reader.readVarU32())
+	vec := make([]MemType, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readLimits()
 	}
 	return vec
 }
 
+func (reader *wasmReader) readTagSec() []TagType {
+	vec := make([]TagType, reader.readVecLen())
+	for i := range vec {
+		vec[i] = reader.readTagType()
+	}
+	return vec
+}
+
 func (reader *wasmReader) readGlobalSec() []Global {
-	vec := make([]Global, reader.readVarU32())
+	vec := make([]Global, reader.readVecLen())
 	for i := range vec {
 		vec[i] = Global{
 			Type: reader.readGlobalType(),
@@ -270,7 +368,7 @@ func (reader *wasmReader) readGlobalSec() []Global {
 }
 
 func (reader *wasmReader) readExportSec() []Export {
-	vec := make([]Export, reader.readVarU32())
+	vec := make([]Export, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readExport()
 	}
@@ -294,6 +392,8 @@ func (reader *wasmReader) readExportDesc() ExportDesc {
 	case ExportTagTable: // table_idx
 	case ExportTagMem: // mem_idx
 	case ExportTagGlobal: // global_idx
+	case ExportTagTag: // tag_idx
+		reader.require(FeatureExceptions)
 	default:
 		panic(fmt.Errorf("invalid export desc tag: %d", desc.Tag))
 	}
@@ -306,23 +406,54 @@ func (reader *wasmReader) readStartSec() *uint32 {
 }
 
 func (reader *wasmReader) readElemSec() []Elem {
-	vec := make([]Elem, reader.readVarU32())
+	vec := make([]Elem, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readElem()
 	}
 	return vec
 }
 
-func (reader *wasmReader) readElem() Elem {
-	return Elem{
-		Table:  reader.readVarU32(),
-		Offset: reader.readExpr(),
-		Init:   reader.readIndices(),
+// bit 0: passive or declarative, bit 1: explicit table index
+// or declarative, bit 2: expressions instead of func indices
+func (reader *wasmReader) readElem() (elem Elem) {
+	flags := reader.readVarU32()
+	if flags > 7 {
+		panic(fmt.Errorf("malformed elements segment kind: %d", flags))
+	}
+	if flags != 0 {
+		reader.require(FeatureBulkMemory)
+	}
+	if flags&1 == 0 {
+		if flags&2 != 0 {
+			elem.Table = reader.readVarU32()
+		}
+		elem.Offset = reader.readExpr()
+	} else if flags&2 == 0 {
+		elem.Mode = SegModePassive
+	} else {
+		elem.Mode = SegModeDeclarative
+	}
+	elem.Type = ValTypeFuncRef
+	if flags&3 != 0 {
+		if flags&4 != 0 {
+			elem.Type = reader.readRefType()
+		} else if kind := reader.readByte(); kind != 0 {
+			panic(fmt.Errorf("malformed element kind: %d", kind))
+		}
+	}
+	if flags&4 != 0 {
+		elem.Exprs = make([]Expr, reader.readVecLen())
+		for i := range elem.Exprs {
+			elem.Exprs[i] = reader.readExpr()
+		}
+	} else {
+		elem.Init = reader.readIndices()
 	}
+	return
 }
 
 func (reader *wasmReader) readCodeSec() []Code {
-	vec := make([]Code, reader.readVarU32())
+	vec := make([]Code, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readCode(i)
 	}
@@ -331,6 +462,9 @@ func (reader *wasmReader) readCodeSec() []Code {
 
 func (reader *wasmReader) readCode(idx int) Code {
 	n := reader.readVarU32()
+	if n > reader.opts.MaxBodySize {
+		panic(fmt.Errorf("code[%d]: %w", idx, ErrBodyTooLarge))
+	}
 	remainingBeforeRead := reader.remaining()
 	code := Code{
 		Locals: reader.readLocalsVec(),
@@ -339,15 +473,15 @@ func (reader *wasmReader) readCode(idx int) Code {
 	if reader.remaining()+int(n) != remainingBeforeRead {
 		panic(fmt.Errorf("invalid code[%d]", idx))
 	}
-	if code.GetLocalCount() >= math.MaxUint32 {
-		panic(fmt.Errorf("too many locals: %d",
-			code.GetLocalCount()))
+	if code.GetLocalCount() > uint64(reader.opts.MaxLocals) {
+		panic(fmt.Errorf("code[%d]: %w: %d",
+			idx, ErrTooManyLocals, code.GetLocalCount()))
 	}
 	return code
 }
 
 func (reader *wasmReader) readLocalsVec() []Locals {
-	vec := make([]Locals, reader.readVarU32())
+	vec := make([]Locals, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readLocals()
 	}
@@ -362,7 +496,7 @@ func (reader *wasmReader) readLocals() Locals {
 }
 
 func (reader *wasmReader) readDataSec() []Data {
-	vec := make([]Data, reader.readVarU32())
+	vec := make([]Data, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readData()
 	}
@@ -370,15 +504,30 @@ func (reader *wasmReader) readDataSec() []Data {
 }
 
 func (reader *wasmReader) readData() (data Data) {
-	return Data{
-		Mem:    reader.readVarU32(),
-		Offset: reader.readExpr(),
-		Init:   reader.readBytes(),
+	switch flags := reader.readVarU32(); flags {
+	case 0:
+		data.Offset = reader.readExpr()
+	case 1:
+		data.Mode = SegModePassive
+	case 2:
+		data.Mem = reader.readVarU32()
+		data.Offset = reader.readExpr()
+	default:
+		panic(fmt.Errorf("malformed data segment flags: %d", flags))
 	}
+	if data.Mode == SegModePassive {
+		reader.require(FeatureBulkMemory)
+	}
+	reader.require(memIdxFeature(data.Mem))
+	if reader.peekVarU32() > reader.opts.MaxDataSize {
+		panic(ErrDataTooLarge)
+	}
+	data.Init = reader.readBytes()
+	return
 }
 
 func (reader *wasmReader) readValTypes() []ValType {
-	vec := make([]ValType, reader.readVarU32())
+	vec := make([]ValType, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readValType()
 	}
@@ -388,10 +537,12 @@ func (reader *wasmReader) readValTypes() []ValType {
 func (reader *wasmReader) readValType() ValType {
 	vt := reader.readByte()
 	switch vt {
-	case ValTypeI32, ValTypeI64, ValTypeF32, ValTypeF64:
+	case ValTypeI32, ValTypeI64, ValTypeF32, ValTypeF64, ValTypeV128,
+		ValTypeFuncRef, ValTypeExternRef, ValTypeExnRef:
 	default:
 		panic(fmt.Errorf("malformed value type: %d", vt))
 	}
+	reader.require(ValTypeFeature(vt))
 	return vt
 }
 
@@ -399,8 +550,8 @@ func (reader *wasmReader) readBlockType() int32 {
 	bt := reader.readVarS32()
 	if bt < 0 {
 		switch bt {
-		case BlockTypeI32, BlockTypeI64, BlockTypeF32, BlockTypeF64,
-			BlockTypeEmpty:
+		case BlockTypeI32, BlockTypeI64, BlockTypeF32, BlockTypeF64, BlockTypeV128,
+			BlockTypeFuncRef, BlockTypeExternRef, BlockTypeExnRef, BlockTypeEmpty:
 		default:
 			panic(fmt.Errorf("malformed block type: %d", bt))
 		}
@@ -417,16 +568,35 @@ func (reader *wasmReader) readFuncType() FuncType {
 	if ft.Tag != FtTag {
 		panic(fmt.Errorf("invalid functype tag: %d", ft.Tag))
 	}
+	reader.require(ft.Feature())
 	return ft
 }
 
 func (reader *wasmReader) readTableType() TableType {
-	tt := TableType{
-		ElemType: reader.readByte(),
+	return TableType{
+		ElemType: reader.readRefType(),
 		Limits:   reader.readLimits(),
 	}
-	if tt.ElemType != FuncRef {
-		panic(fmt.Errorf("invalid elemtype: %d", tt.ElemType))
+}
+
+func (reader *wasmReader) readRefType() ValType {
+	rt := reader.readByte()
+	if !IsRefType(rt) {
+		panic(fmt.Errorf("malformed reference type: %d", rt))
+	}
+	if rt != ValTypeFuncRef {
+		reader.require(ValTypeFeature(rt))
+	}
+	return rt
+}
+
+func (reader *wasmReader) readTagType() TagType {
+	tt := TagType{
+		Attribute: reader.readByte(),
+		Type:      reader.readVarU32(),
+	}
+	if tt.Attribute != TagAttrException {
+		panic(fmt.Errorf("malformed tag attribute: %d", tt.Attribute))
 	}
 	return tt
 }
@@ -446,18 +616,32 @@ func (reader *wasmReader) readGlobalType() GlobalType {
 }
 
 func (reader *wasmReader) readLimits() Limits {
+	flags := reader.readByte()
+	if flags > 7 {
+		panic(fmt.Errorf("malformed limits flags: %d", flags))
+	}
 	limits := Limits{
-		Tag: reader.readByte(),
-		Min: reader.readVarU32(),
+		Tag:    flags & 1,
+		Shared: flags&2 != 0,
+		Is64:   flags&4 != 0,
 	}
+	reader.require(limits.Feature())
+	limits.Min = reader.readLimit(limits.Is64)
 	if limits.Tag == 1 {
-		limits.Max = reader.readVarU32()
+		limits.Max = reader.readLimit(limits.Is64)
 	}
 	return limits
 }
 
+func (reader *wasmReader) readLimit(is64 bool) uint64 {
+	if is64 {
+		return reader.readVarU64()
+	}
+	return uint64(reader.readVarU32())
+}
+
 func (reader *wasmReader) readIndices() []uint32 {
-	vec := make([]uint32, reader.readVarU32())
+	vec := make([]uint32, reader.readVecLen())
 	for i := range vec {
 		vec[i] = reader.readVarU32()
 	}
@@ -466,17 +650,19 @@ func (reader *wasmReader) readIndices() []uint32 {
 
 func (reader *wasmReader) readExpr() Expr {
 	instrs, end := reader.readInstructions()
-	if end != End_ {
-		panic(fmt.Errorf("invalid expr end: %d", end))
+	if end.Opcode != End_ {
+		panic(fmt.Errorf("invalid expr end: %d", end.Opcode))
 	}
 	return instrs
 }
 
-func (reader *wasmReader) readInstructions() (instrs []Instruction, end byte) {
+// reads until end, else, catch, catch_all or delegate
+func (reader *wasmReader) readInstructions() (instrs []Instruction, end Instruction) {
 	for {
 		instr := reader.readInstruction()
-		if instr.Opcode == Else_ || instr.Opcode == End_ {
-			end = instr.Opcode
+		switch instr.Opcode {
+		case Else_, End_, Catch_, CatchAll_, Delegate_:
+			end = instr
 			return
 		}
 		instrs = append(instrs, instr)
@@ -484,11 +670,13 @@ func (reader *wasmReader) readInstructions() (instrs []Instruction, end byte) {
 }
 
 func (reader *wasmReader) readInstruction() (instr Instruction) {
+	instr.Offset = uint32(reader.offset())
 	instr.Opcode = reader.readByte()
 	if opnames[instr.Opcode] == "" {
 		panic(fmt.Errorf("undefined opcode: 0x%02x", instr.Opcode))
 	}
 	instr.Args = reader.readArgs(instr.Opcode)
+	reader.require(instr.Feature())
 	return
 }
 
@@ -499,20 +687,34 @@ func (reader *wasmReader) readArgs(opcode byte) interface{} {
 		return reader.readBlockArgs()
 	case If:
 		return reader.readIfArgs()
-	case Br, BrIf:
+	case Try:
+		return reader.readTryArgs()
+	case TryTable:
+		return reader.readTryTableArgs()
+	case Throw, Catch_:
+		return reader.readVarU32() // tag_idx
+	case Br, BrIf, Rethrow, Delegate_:
 		return reader.readVarU32() // label_idx
 	case BrTable:
 		return reader.readBrTableArgs()
-	case Call:
+	case Call, ReturnCall:
 		return reader.readVarU32() // func_idx
-	case CallIndirect:
+	case CallIndirect, ReturnCallIndirect:
 		return reader.readCallIndirectArgs()
 	case LocalGet, LocalSet, LocalTee:
 		return reader.readVarU32() // local_idx
 	case GlobalGet, GlobalSet:
 		return reader.readVarU32() // global_idx
+	case TableGet, TableSet:
+		return reader.readVarU32() // table_idx
+	case SelectT:
+		return reader.readSelectTypes()
+	case RefNull:
+		return reader.readRefType()
+	case RefFunc:
+		return reader.readVarU32() // func_idx
 	case MemorySize, MemoryGrow:
-		return reader.readZero()
+		return reader.readVarU32() // mem_idx
 	case I32Const:
 		return reader.readVarS32()
 	case I64Const:
@@ -521,8 +723,12 @@ func (reader *wasmReader) readArgs(opcode byte) interface{} {
 		return reader.readF32()
 	case F64Const:
 		return reader.readF64()
-	case TruncSat:
-		return reader.readByte()
+	case NumericPrefix:
+		return reader.readNumericArgs()
+	case SimdPrefix:
+		return reader.readSimdArgs()
+	case AtomicPrefix:
+		return reader.readAtomicArgs()
 	default:
 		if opcode >= I32Load && opcode <= I64Store32 {
 			return reader.readMemArg()
@@ -531,26 +737,95 @@ func (reader *wasmReader) readArgs(opcode byte) interface{} {
 	}
 }
 
+func (reader *wasmReader) enterBlock() {
+	if reader.depth++; reader.depth > reader.opts.MaxNestingDepth {
+		panic(ErrNestingTooDeep)
+	}
+}
+
 func (reader *wasmReader) readBlockArgs() (args BlockArgs) {
-	var end byte
+	var end Instruction
 	args.BT = reader.readBlockType()
+	reader.enterBlock()
 	args.Instrs, end = reader.readInstructions()
-	if end != End_ {
-		panic(fmt.Errorf("invalid block end: %d", end))
+	reader.depth--
+	if end.Opcode != End_ {
+		panic(fmt.Errorf("invalid block end: %d", end.Opcode))
 	}
 	return
 }
 
 func (reader *wasmReader) readIfArgs() (args IfArgs) {
-	var end byte
+	var end Instruction
 	args.BT = reader.readBlockType()
+	reader.enterBlock()
 	args.Instrs1, end = reader.readInstructions()
-	if end == Else_ {
+	if end.Opcode == Else_ {
 		args.Instrs2, end = reader.readInstructions()
-		if end != End_ {
-			panic(fmt.Errorf("invalid block end: %d", end))
+	}
+	if end.Opcode != End_ {
+		panic(fmt.Errorf("invalid block end: %d", end.Opcode))
+	}
+	reader.depth--
+	return
+}
+
+// catch_all must be the last handler, delegate replaces them all
+func (reader *wasmReader) readTryArgs() (args TryArgs) {
+	var end Instruction
+	args.BT = reader.readBlockType()
+	reader.enterBlock()
+	args.Instrs, end = reader.readInstructions()
+	if end.Opcode == Delegate_ {
+		l := end.Args.(uint32)
+		args.Delegate = &l
+		reader.depth--
+		return
+	}
+	for end.Opcode == Catch_ || end.Opcode == CatchAll_ {
+		handler := Handler{CatchAll: end.Opcode == CatchAll_}
+		if !handler.CatchAll {
+			handler.Tag = end.Args.(uint32)
+		}
+		handler.Instrs, end = reader.readInstructions()
+		args.Handlers = append(args.Handlers, handler)
+		if handler.CatchAll {
+			break
 		}
 	}
+	if end.Opcode != End_ {
+		panic(fmt.Errorf("invalid try end: %d", end.Opcode))
+	}
+	reader.depth--
+	return
+}
+
+func (reader *wasmReader) readTryTableArgs() (args TryTableArgs) {
+	var end Instruction
+	args.BT = reader.readBlockType()
+	args.Catches = make([]Catch, reader.readVecLen())
+	for i := range args.Catches {
+		args.Catches[i] = reader.readCatch()
+	}
+	reader.enterBlock()
+	args.Instrs, end = reader.readInstructions()
+	reader.depth--
+	if end.Opcode != End_ {
+		panic(fmt.Errorf("invalid block end: %d", end.Opcode))
+	}
+	return
+}
+
+func (reader *wasmReader) readCatch() (catch Catch) {
+	catch.Kind = reader.readByte()
+	switch catch.Kind {
+	case ClauseCatch, ClauseCatchRef:
+		catch.Tag = reader.readVarU32()
+	case ClauseCatchAll, ClauseCatchAllRef:
+	default:
+		panic(fmt.Errorf("malformed catch clause: %d", catch.Kind))
+	}
+	catch.Label = reader.readVarU32()
 	return
 }
 
@@ -561,23 +836,109 @@ func (reader *wasmReader) readBrTableArgs() BrTableArgs {
 	}
 }
 
-func (reader *wasmReader) readCallIndirectArgs() uint32 {
-	typeIdx := reader.readVarU32()
-	reader.readZero()
-	return typeIdx
+func (reader *wasmReader) readSelectTypes() []ValType {
+	vts := reader.readValTypes()
+	if len(vts) != 1 {
+		panic(fmt.Errorf("invalid result arity"))
+	}
+	return vts
 }
 
-func (reader *wasmReader) readMemArg() MemArg {
-	return MemArg{
-		Align:  reader.readVarU32(),
-		Offset: reader.readVarU32(),
+func (reader *wasmReader) readCallIndirectArgs() CallIndirectArgs {
+	return CallIndirectArgs{
+		Type:  reader.readVarU32(),
+		Table: reader.readVarU32(),
 	}
 }
 
-func (reader *wasmReader) readZero() byte {
-	b := reader.readByte()
-	if b != 0 {
-		panic(fmt.Errorf("zero flag expected, got %d", b))
+// bit 6 of the alignment flags the presence of a memory index
+func (reader *wasmReader) readMemArg() (memArg MemArg) {
+	memArg.Align = reader.readVarU32()
+	if memArg.Align&0x40 != 0 {
+		memArg.Align &^= 0x40
+		memArg.Mem = reader.readVarU32()
 	}
-	return 0
-}
\ No newline at end of file
+	memArg.Offset = reader.readVarU64() // checked against the index type by validator
+	return
+}
+
+func (reader *wasmReader) readNumericArgs() PrefixArgs {
+	args := PrefixArgs{SubOp: reader.readVarU32()}
+	switch args.SubOp {
+	case I32TruncSatF32S, I32TruncSatF32U, I32TruncSatF64S, I32TruncSatF64U,
+		I64TruncSatF32S, I64TruncSatF32U, I64TruncSatF64S, I64TruncSatF64U:
+	case MemoryInit:
+		args.Args = MemoryInitArgs{
+			Data: reader.readVarU32(),
+			Mem:  reader.readVarU32(),
+		}
+		reader.usesDataIdx = true
+	case DataDrop:
+		args.Args = reader.readVarU32() // data_idx
+		reader.usesDataIdx = true
+	case MemoryCopy:
+		args.Args = MemoryCopyArgs{
+			Dst: reader.readVarU32(),
+			Src: reader.readVarU32(),
+		}
+	case MemoryFill:
+		args.Args = reader.readVarU32() // mem_idx
+	case TableInit:
+		args.Args = TableInitArgs{
+			Elem:  reader.readVarU32(),
+			Table: reader.readVarU32(),
+		}
+	case ElemDrop:
+		args.Args = reader.readVarU32() // elem_idx
+	case TableCopy:
+		args.Args = TableCopyArgs{
+			Dst: reader.readVarU32(),
+			Src: reader.readVarU32(),
+		}
+	case TableGrow, TableSize, TableFill:
+		args.Args = reader.readVarU32() // table_idx
+	default:
+		panic(fmt.Errorf("undefined opcode: 0xfc 0x%02x", args.SubOp))
+	}
+	return args
+}
+
+func (reader *wasmReader) readSimdArgs() PrefixArgs {
+	args := PrefixArgs{SubOp: reader.readVarU32()}
+	switch op := args.SubOp; {
+	case op <= V128Store, op == V128Load32Zero, op == V128Load64Zero:
+		args.Args = reader.readMemArg()
+	case op >= V128Load8Lane && op <= V128Store64Lane:
+		args.Args = MemLaneArgs{
+			MemArg: reader.readMemArg(),
+			Lane:   reader.readByte(),
+		}
+	case op == V128Const, op == I8x16Shuffle:
+		var bytes [16]byte
+		for i := range bytes {
+			bytes[i] = reader.readByte()
+		}
+		args.Args = bytes
+	case op >= I8x16ExtractLaneS && op <= F64x2ReplaceLane:
+		args.Args = reader.readByte() // lane_idx
+	case simdOpnames[op] != "":
+	default:
+		panic(fmt.Errorf("undefined opcode: 0xfd 0x%02x", op))
+	}
+	return args
+}
+
+func (reader *wasmReader) readAtomicArgs() PrefixArgs {
+	args := PrefixArgs{SubOp: reader.readVarU32()}
+	switch {
+	case args.SubOp == AtomicFence:
+		if b := reader.readByte(); b != 0 {
+			panic(fmt.Errorf("malformed atomic.fence flags: %d", b))
+		}
+	case atomicOpnames[args.SubOp] != "":
+		args.Args = reader.readMemArg()
+	default:
+		panic(fmt.Errorf("undefined opcode: 0xfe 0x%02x", args.SubOp))
+	}
+	return args
+}
@@ -1,9 +1,12 @@
 package binary
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"wasm.go/internal/fuzzseed"
 )
 
 func TestReads(t *testing.T) {
@@ -29,3 +32,63 @@ func TestReads(t *testing.T) {
 	require.Equal(t, "foo", reader.readName())
 	require.Equal(t, 0, reader.remaining())
 }
+
+func TestReadLimits(t *testing.T) {
+	reader := wasmReader{data: []byte{0x00, 0x01, 0x01, 0x01, 0x02, 0x03, 0x01, 0x08,
+		0x05, 0x01, 0x80, 0x80, 0x80, 0x80, 0x20, 0x08}}
+	require.Equal(t, Limits{Min: 1}, reader.readLimits())
+	require.Equal(t, Limits{Tag: 1, Min: 1, Max: 2}, reader.readLimits())
+	require.Equal(t, Limits{Tag: 1, Shared: true, Min: 1, Max: 8}, reader.readLimits())
+	require.Equal(t, Limits{Tag: 1, Is64: true, Min: 1, Max: 1 << 33}, reader.readLimits())
+	require.PanicsWithError(t, "malformed limits flags: 8", func() { reader.readLimits() })
+}
+
+func TestDecodeFeatures(t *testing.T) {
+	data, err := os.ReadFile("../../wat/ch14_tail_call.wasm")
+	require.NoError(t, err)
+	_, err = Decode(data)
+	require.NoError(t, err)
+	_, err = DecodeWithOptions(data, DecodeOptions{Features: FeaturesStandard})
+	require.ErrorIs(t, err, ErrFeatureDisabled)
+	require.EqualError(t, err, "feature not enabled: tail-call")
+	opts := DecodeOptions{Features: FeaturesMVP.Enable(FeatureTailCall)}
+	_, err = DecodeWithOptions(data, opts)
+	require.NoError(t, err)
+
+	reader := wasmReader{data: []byte{0x03, 0x01}, opts: DecodeOptions{Features: FeaturesMVP}}
+	require.PanicsWithError(t, "feature not enabled: threads", func() { reader.readLimits() })
+	reader = wasmReader{data: []byte{0x7B}, opts: DecodeOptions{Features: FeaturesMVP}}
+	require.PanicsWithError(t, "feature not enabled: simd", func() { reader.readValType() })
+
+	files, _ := filepath.Glob("../../wat/ch07_*.wasm")
+	for _, file := range files {
+		_, err := DecodeFileWithOptions(file, DecodeOptions{Features: FeaturesMVP})
+		require.NoError(t, err, file)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	require.Len(t, AllFeatures, 12)
+	for _, f := range AllFeatures {
+		parsed, ok := ParseFeature(f.String())
+		require.True(t, ok)
+		require.Equal(t, f, parsed)
+		require.True(t, Features{}.Has(f))
+		require.False(t, FeaturesMVP.Has(f))
+	}
+	require.Equal(t, "mvp", FeaturesMVP.String())
+	require.Equal(t, "simd, threads",
+		FeaturesMVP.Enable(FeatureSIMD|FeatureThreads).String())
+	require.True(t, FeaturesStandard.Has(FeatureBulkMemory|FeatureSIMD))
+	require.False(t, FeaturesStandard.Has(FeatureBulkMemory|FeatureTailCall))
+	require.NoError(t, FeaturesStandard.Check(FeatureMultiValue))
+	require.EqualError(t, FeaturesStandard.Check(FeatureSIMD|FeatureMemory64|FeatureThreads),
+		"feature not enabled: threads, memory64")
+}
+
+func FuzzDecode(f *testing.F) {
+	fuzzseed.Add(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = Decode(data)
+	})
+}
@@ -6,28 +6,46 @@ import (
 )
 
 const (
-	ValTypeI32 ValType = 0x7F // i32
-	ValTypeI64 ValType = 0x7E // i64
-	ValTypeF32 ValType = 0x7D // f32
-	ValTypeF64 ValType = 0x7C // f64
-
-	BlockTypeI32   BlockType = -1  // ()->(i32)
-	BlockTypeI64   BlockType = -2  // ()->(i64)
-	BlockTypeF32   BlockType = -3  // ()->(f32)
-	BlockTypeF64   BlockType = -4  // ()->(f64)
-	BlockTypeEmpty BlockType = -64 // ()->()
-
-	FtTag   = 0x60
-	FuncRef = 0x70
+	ValTypeI32  ValType = 0x7F // i32
+	ValTypeI64  ValType = 0x7E // i64
+	ValTypeF32  ValType = 0x7D // f32
+	ValTypeF64  ValType = 0x7C // f64
+	ValTypeV128 ValType = 0x7B // v128
+
+	ValTypeFuncRef   ValType = 0x70 // funcref
+	ValTypeExternRef ValType = 0x6F // externref
+	ValTypeExnRef    ValType = 0x69 // exnref
+
+	BlockTypeI32       BlockType = -1  // ()->(i32)
+	BlockTypeI64       BlockType = -2  // ()->(i64)
+	BlockTypeF32       BlockType = -3  // ()->(f32)
+	BlockTypeF64       BlockType = -4  // ()->(f64)
+	BlockTypeV128      BlockType = -5  // ()->(v128)
+	BlockTypeFuncRef   BlockType = -16 // ()->(funcref)
+	BlockTypeExternRef BlockType = -17 // ()->(externref)
+	BlockTypeExnRef    BlockType = -23 // ()->(exnref)
+	BlockTypeEmpty     BlockType = -64 // ()->()
+
+	FtTag     = 0x60
+	FuncRef   = 0x70
+	ExternRef = 0x6F
+	ExnRef    = 0x69
 
 	MutConst byte = 0
 	MutVar   byte = 1
+
+	TagAttrException byte = 0
 )
 
 type ValType = byte
 type BlockType = int32
 type MemType = Limits
 
+type TagType struct {
+	Attribute byte
+	Type      TypeIdx // params are the payload, no results
+}
+
 type FuncType struct {
 	Tag         byte
 	ParamTypes  []ValType
@@ -45,9 +63,11 @@ type GlobalType struct {
 }
 
 type Limits struct {
-	Tag byte
-	Min uint32
-	Max uint32
+	Tag    byte
+	Shared bool
+	Is64   bool // memory64, addressed by i64
+	Min    uint64
+	Max    uint64
 }
 
 func ValTypeToStr(vt ValType) string {
@@ -60,11 +80,23 @@ func ValTypeToStr(vt ValType) string {
 		return "f32"
 	case ValTypeF64:
 		return "f64"
+	case ValTypeV128:
+		return "v128"
+	case ValTypeFuncRef:
+		return "funcref"
+	case ValTypeExternRef:
+		return "externref"
+	case ValTypeExnRef:
+		return "exnref"
 	default:
 		panic(fmt.Errorf("invalid valtype: %d", vt))
 	}
 }
 
+func IsRefType(vt ValType) bool {
+	return vt == ValTypeFuncRef || vt == ValTypeExternRef || vt == ValTypeExnRef
+}
+
 func (ft FuncType) Equal(ft2 FuncType) bool {
 	//return reflect.DeepEqual(ft, ft2)
 	if len(ft.ParamTypes) != len(ft2.ParamTypes) {
@@ -117,6 +149,12 @@ func (gt GlobalType) String() string {
 }
 
 func (limits Limits) String() string {
-	return fmt.Sprintf("{min: %d, max: %d}",
-		limits.Min, limits.Max)
+	s := fmt.Sprintf("{min: %d, max: %d", limits.Min, limits.Max)
+	if limits.Is64 {
+		s += ", i64"
+	}
+	if limits.Shared {
+		s += ", shared"
+	}
+	return s + "}"
 }
@@ -0,0 +1,220 @@
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Patch returns data, the binary module was decoded from, with the
+// sections ids encoded again from module, which may have changed.
+// Empty sections are left out, the others are copied as they are.
+// Only memory, global, export, start, data count and data sections
+// can be encoded.
+func Patch(data []byte, module Module, ids ...byte) (patched []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch x := r.(type) {
+			case error:
+				err = x
+			default:
+				err = errors.New("unknown error")
+			}
+		}
+	}()
+
+	if len(data) < 8 {
+		panic(errUnexpectedEnd)
+	}
+	patched = append(patched, data[:8]...) // magic & version
+	pending := map[byte]bool{}
+	for _, id := range ids {
+		pending[id] = true
+	}
+	// encodes the pending sections which come before order
+	flush := func(order int) {
+		for _, id := range secOrder[:order] {
+			if pending[id] {
+				patched = appendSec(patched, id, module)
+				delete(pending, id)
+			}
+		}
+	}
+
+	reader := &wasmReader{data: data[8:], size: len(data)}
+	for reader.remaining() > 0 {
+		start := reader.offset()
+		id := reader.readByte()
+		reader.readBytes()
+		if id == SecCustomID {
+			patched = append(patched, data[start:reader.offset()]...)
+			continue
+		}
+		order := getSecOrder(id)
+		if order < 0 {
+			panic(fmt.Errorf("malformed section id: %d", id))
+		}
+		flush(order)
+		if pending[id] {
+			flush(order + 1)
+		} else {
+			patched = append(patched, data[start:reader.offset()]...)
+		}
+	}
+	flush(len(secOrder))
+	return
+}
+
+func appendSec(data []byte, id byte, module Module) []byte {
+	writer := &wasmWriter{}
+	switch id {
+	case SecMemID:
+		if len(module.MemSec) == 0 {
+			return data
+		}
+		writer.writeVarU32(uint32(len(module.MemSec)))
+		for _, mt := range module.MemSec {
+			writer.writeLimits(mt)
+		}
+	case SecGlobalID:
+		if len(module.GlobalSec) == 0 {
+			return data
+		}
+		writer.writeVarU32(uint32(len(module.GlobalSec)))
+		for _, g := range module.GlobalSec {
+			writer.writeByte(g.Type.ValType)
+			writer.writeByte(g.Type.Mut)
+			writer.writeExpr(g.Init)
+		}
+	case SecExportID:
+		if len(module.ExportSec) == 0 {
+			return data
+		}
+		writer.writeVarU32(uint32(len(module.ExportSec)))
+		for _, exp := range module.ExportSec {
+			writer.writeName(exp.Name)
+			writer.writeByte(exp.Desc.Tag)
+			writer.writeVarU32(exp.Desc.Idx)
+		}
+	case SecStartID:
+		if module.StartSec == nil {
+			return data
+		}
+		writer.writeVarU32(*module.StartSec)
+	case SecDataCountID:
+		if module.DataCountSec == nil {
+			return data
+		}
+		writer.writeVarU32(*module.DataCountSec)
+	case SecDataID:
+		if len(module.DataSec) == 0 {
+			return data
+		}
+		writer.writeVarU32(uint32(len(module.DataSec)))
+		for _, d := range module.DataSec {
+			writer.writeData(d)
+		}
+	default:
+		panic(fmt.Errorf("can't encode section, id: %d", id))
+	}
+
+	data = append(data, id)
+	data = appendVarUint(data, uint64(len(writer.data)))
+	return append(data, writer.data...)
+}
+
+// wasmWriter encodes what Patch needs
+type wasmWriter struct {
+	data []byte
+}
+
+func (writer *wasmWriter) writeByte(b byte) {
+	writer.data = append(writer.data, b)
+}
+
+func (writer *wasmWriter) writeVarU32(n uint32) {
+	writer.data = appendVarUint(writer.data, uint64(n))
+}
+
+func (writer *wasmWriter) writeVarU64(n uint64) {
+	writer.data = appendVarUint(writer.data, n)
+}
+
+func (writer *wasmWriter) writeVarS64(n int64) {
+	writer.data = appendVarInt(writer.data, n)
+}
+
+func (writer *wasmWriter) writeBytes(b []byte) {
+	writer.writeVarU32(uint32(len(b)))
+	writer.data = append(writer.data, b...)
+}
+
+func (writer *wasmWriter) writeName(name string) {
+	writer.writeBytes([]byte(name))
+}
+
+func (writer *wasmWriter) writeLimits(limits Limits) {
+	flags := limits.Tag
+	if limits.Shared {
+		flags |= 2
+	}
+	if limits.Is64 {
+		flags |= 4
+	}
+	writer.writeByte(flags)
+	writer.writeVarU64(limits.Min)
+	if limits.Tag == 1 {
+		writer.writeVarU64(limits.Max)
+	}
+}
+
+func (writer *wasmWriter) writeData(d Data) {
+	switch {
+	case d.Mode == SegModePassive:
+		writer.writeVarU32(1)
+	case d.Mem == 0:
+		writer.writeVarU32(0)
+		writer.writeExpr(d.Offset)
+	default:
+		writer.writeVarU32(2)
+		writer.writeVarU32(d.Mem)
+		writer.writeExpr(d.Offset)
+	}
+	writer.writeBytes(d.Init)
+}
+
+// constant expressions only
+func (writer *wasmWriter) writeExpr(expr Expr) {
+	for _, instr := range expr {
+		writer.writeByte(instr.Opcode)
+		switch instr.Opcode {
+		case I32Const:
+			writer.writeVarS64(int64(instr.Args.(int32)))
+		case I64Const:
+			writer.writeVarS64(instr.Args.(int64))
+		case F32Const:
+			writer.data = binary.LittleEndian.AppendUint32(writer.data,
+				math.Float32bits(instr.Args.(float32)))
+		case F64Const:
+			writer.data = binary.LittleEndian.AppendUint64(writer.data,
+				math.Float64bits(instr.Args.(float64)))
+		case GlobalGet, RefFunc:
+			writer.writeVarU32(instr.Args.(uint32))
+		case RefNull:
+			writer.writeByte(instr.Args.(ValType))
+		case I32Add, I32Sub, I32Mul, I64Add, I64Sub, I64Mul:
+		case SimdPrefix:
+			args := instr.Args.(PrefixArgs)
+			if args.SubOp != V128Const {
+				panic(fmt.Errorf("can't encode %s", instr.GetOpname()))
+			}
+			writer.writeVarU32(args.SubOp)
+			bytes := args.Args.([16]byte)
+			writer.data = append(writer.data, bytes[:]...)
+		default:
+			panic(fmt.Errorf("can't encode %s", instr.GetOpname()))
+		}
+	}
+	writer.writeByte(End_)
+}
@@ -0,0 +1,59 @@
+package binary
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var patchableSecs = []byte{
+	SecMemID, SecGlobalID, SecExportID, SecStartID, SecDataCountID, SecDataID,
+}
+
+func TestPatchUnchanged(t *testing.T) {
+	for _, filename := range []string{
+		"./testdata/hw_rust.wasm",
+		"../interpreter/testdata/memory64.wasm",
+		"../interpreter/testdata/multi_mem.wasm",
+		"../interpreter/testdata/bulk_table.wasm",
+		"../interpreter/testdata/extended_const.wasm",
+	} {
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		module, err := Decode(data)
+		require.NoError(t, err)
+		patched, err := Patch(data, module, patchableSecs...)
+		require.NoError(t, err, filename)
+		require.Equal(t, data, patched, filename)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	data, err := os.ReadFile("./testdata/hw_rust.wasm")
+	require.NoError(t, err)
+	module, err := Decode(data)
+	require.NoError(t, err)
+
+	start := uint32(3)
+	module.StartSec = &start
+	module.MemSec[0].Min = 20
+	module.ExportSec = module.ExportSec[1:]
+	module.GlobalSec[0].Init = Expr{{Opcode: I32Const, Args: int32(-5)}}
+	module.DataSec = []Data{{Offset: Expr{{Opcode: I32Const, Args: int32(16)}}, Init: []byte("hi")}}
+	patched, err := Patch(data, module, patchableSecs...)
+	require.NoError(t, err)
+
+	module2, err := Decode(patched)
+	require.NoError(t, err)
+	require.Equal(t, start, *module2.StartSec)
+	require.Equal(t, uint64(20), module2.MemSec[0].Min)
+	require.Equal(t, len(module.ExportSec), len(module2.ExportSec))
+	require.Equal(t, int32(-5), module2.GlobalSec[0].Init[0].Args)
+	require.Equal(t, []byte("hi"), module2.DataSec[0].Init)
+	require.Equal(t, len(module.CodeSec), len(module2.CodeSec))
+	require.Equal(t, module.CustomSecs, module2.CustomSecs)
+
+	_, err = Patch(data, module, SecCodeID)
+	require.EqualError(t, err, "can't encode section, id: 10")
+}
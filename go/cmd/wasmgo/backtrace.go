@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+// printBacktrace prints where m trapped, in the source if the module
+// has DWARF line info
+func printBacktrace(module binary.Module, m instance.Module) {
+	names := module.FuncNames()
+	lines, _ := module.LineTable()
+	for i, frame := range interpreter.Backtrace(m) {
+		msg := fmt.Sprintf("  #%d %06x: func[%d] <%s>", i,
+			frame.Offset, frame.FuncIdx, module.FuncName(frame.FuncIdx, names))
+		if loc, ok := lookupLine(lines, frame.Offset); ok {
+			msg += " at " + loc.String()
+		}
+		fmt.Println(msg)
+	}
+}
+
+func lookupLine(lines *binary.LineTable, offset uint32) (binary.SourceLoc, bool) {
+	if lines == nil {
+		return binary.SourceLoc{}, false
+	}
+	return lines.Lookup(offset)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+// startCoverage adds the counts of previous runs kept in filename
+func startCoverage(m instance.Module, filename string) (*interpreter.Coverage, error) {
+	c, err := interpreter.NewCoverage(m)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c, c.ReadCounts(f)
+}
+
+// finishCoverage saves the counts, and an lcov tracefile next to them
+// if the module has DWARF line info
+func finishCoverage(module binary.Module, c *interpreter.Coverage, filename string) error {
+	c.Detach()
+	if err := writeFile(filename, c.WriteCounts); err != nil {
+		return err
+	}
+	if lines, _ := module.LineTable(); lines != nil {
+		err := writeFile(filename+".lcov", func(w io.Writer) error {
+			return c.WriteLcov(w, func(offset uint32) (string, int, bool) {
+				loc, ok := lines.Lookup(offset)
+				return loc.File, loc.Line, ok
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var funcs, funcsHit, blocks, blocksHit, branches, branchesHit int
+	for _, fc := range c.Summary() {
+		fmt.Printf("func[%d] <%s>: calls %d, blocks %d/%d, branches %d/%d\n",
+			fc.Idx, fc.Name, fc.Calls, fc.BlocksHit, fc.Blocks, fc.BranchesHit, fc.Branches)
+		funcs++
+		if fc.Calls > 0 {
+			funcsHit++
+		}
+		blocks, blocksHit = blocks+fc.Blocks, blocksHit+fc.BlocksHit
+		branches, branchesHit = branches+fc.Branches, branchesHit+fc.BranchesHit
+	}
+	fmt.Printf("total: functions %s, blocks %s, branches %s\n",
+		percent(funcsHit, funcs), percent(blocksHit, blocks), percent(branchesHit, branches))
+	return nil
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", hit, total, float64(hit)*100/float64(total))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+const debugHelp = `Commands:
+  b, break FUNC[@OFFSET]   set a breakpoint, FUNC is an index or a name,
+                           OFFSET is in hex and defaults to the entry
+  d, delete FUNC[@OFFSET]  clear a breakpoint
+  c, continue              run to the next breakpoint
+  s, step                  run to the next instruction
+  n, next                  step over calls
+  o, out                   run until the function returns
+  w, where                 print the instruction and the call stack
+  l, locals                print params and locals
+  st, stack                print the operand stack, top last
+  g, globals               print globals
+  m, mem ADDR [N [MEM]]    dump N bytes of memory MEM at ADDR
+  q, quit                  exit
+`
+
+type debugger struct {
+	*interpreter.Debugger
+	in    *bufio.Scanner
+	lines *binary.LineTable // nil without DWARF
+}
+
+// debug runs main under the debugger, paused at its first instruction
+func debug(module binary.Module) {
+	mm := map[string]instance.Module{"env": newEnv()}
+	m, err := interpreter.NewWithOptions(module, mm, opts)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	d, _ := interpreter.NewDebugger(m)
+	dbg := &debugger{Debugger: d, in: bufio.NewScanner(os.Stdin)}
+	dbg.lines, _ = module.LineTable()
+	d.OnPause = dbg.onPause
+	d.Pause()
+
+	if _, err = m.InvokeFunc("main"); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func (dbg *debugger) onPause(*interpreter.Debugger) interpreter.StepMode {
+	dbg.printInstr()
+	for {
+		fmt.Print("(wdb) ")
+		if !dbg.in.Scan() {
+			os.Exit(0)
+		}
+		fields := strings.Fields(dbg.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch cmd, args := fields[0], fields[1:]; cmd {
+		case "c", "continue":
+			return interpreter.Continue
+		case "s", "step":
+			return interpreter.StepInto
+		case "n", "next":
+			return interpreter.StepOver
+		case "o", "out":
+			return interpreter.StepOut
+		case "b", "break", "d", "delete":
+			bp, err := dbg.parseBreakpoint(args)
+			if err != nil {
+				fmt.Println(err.Error())
+			} else if cmd == "b" || cmd == "break" {
+				dbg.SetBreakpoint(bp)
+			} else {
+				dbg.ClearBreakpoint(bp)
+			}
+		case "w", "where":
+			dbg.printInstr()
+			stack := dbg.CallStack()
+			for i := len(stack) - 1; i >= 0; i-- {
+				fmt.Printf("  #%d %s\n", len(stack)-1-i, dbg.funcName(stack[i]))
+			}
+		case "l", "locals":
+			for i, val := range dbg.Locals() {
+				fmt.Printf("  local[%d]: %v\n", i, val)
+			}
+		case "st", "stack":
+			for _, val := range dbg.Operands() {
+				fmt.Printf("  0x%016x\n", val)
+			}
+		case "g", "globals":
+			for i, val := range dbg.Globals() {
+				fmt.Printf("  global[%d]: %v\n", i, val)
+			}
+		case "m", "mem":
+			dbg.dumpMemory(args)
+		case "q", "quit":
+			os.Exit(0)
+		case "h", "help":
+			fmt.Print(debugHelp)
+		default:
+			fmt.Printf("unknown command: %s, try help\n", cmd)
+		}
+	}
+}
+
+func (dbg *debugger) printInstr() {
+	instr := dbg.Instr()
+	msg := dbg.InstrPath()
+	if args := instr.Args; args != nil && !isBlock(instr.Opcode) {
+		msg += fmt.Sprintf(" %v", args)
+	}
+	if loc, ok := lookupLine(dbg.lines, instr.Offset); ok {
+		msg += " at " + loc.String()
+	}
+	fmt.Printf("%06x: %s %s\n", instr.Offset, dbg.funcName(dbg.FuncIdx()), msg)
+}
+
+func isBlock(opcode byte) bool {
+	switch opcode {
+	case binary.Block, binary.Loop, binary.If, binary.Try, binary.TryTable:
+		return true
+	}
+	return false
+}
+
+func (dbg *debugger) funcName(idx uint32) string {
+	id := fmt.Sprintf("func[%d]", idx)
+	if name := dbg.FuncName(idx); name != id {
+		return fmt.Sprintf("%s <%s>", id, name)
+	}
+	return id
+}
+
+func (dbg *debugger) parseBreakpoint(args []string) (bp interpreter.Breakpoint, err error) {
+	if len(args) != 1 {
+		return bp, fmt.Errorf("usage: break FUNC[@OFFSET]")
+	}
+	name, offset, _ := strings.Cut(args[0], "@")
+	if idx, err := strconv.ParseUint(name, 10, 32); err == nil {
+		bp.FuncIdx = uint32(idx)
+	} else if idx, ok := dbg.LookupFunc(name); ok {
+		bp.FuncIdx = idx
+	} else {
+		return bp, fmt.Errorf("function not found: %s", name)
+	}
+	if offset != "" {
+		n, err := strconv.ParseUint(strings.TrimPrefix(offset, "0x"), 16, 32)
+		if err != nil {
+			return bp, fmt.Errorf("invalid offset: %s", offset)
+		}
+		bp.Offset = uint32(n)
+	}
+	return bp, nil
+}
+
+func (dbg *debugger) dumpMemory(args []string) {
+	if len(args) == 0 || len(args) > 3 {
+		fmt.Println("usage: mem ADDR [N [MEM]]")
+		return
+	}
+	nums := []uint64{0, 16, 0}
+	for i, arg := range args {
+		n, err := strconv.ParseUint(arg, 0, 64)
+		if err != nil {
+			fmt.Printf("invalid number: %s\n", arg)
+			return
+		}
+		nums[i] = n
+	}
+	data, err := dbg.ReadMemory(uint32(nums[2]), nums[0], int(nums[1]))
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	for i := 0; i < len(data); i += 16 {
+		line := data[i:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		fmt.Printf("  %08x: % x\n", nums[0]+uint64(i), line)
+	}
+}
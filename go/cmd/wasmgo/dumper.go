@@ -12,6 +12,7 @@ type dumper struct {
 	importedTableCount  int
 	importedMemCount    int
 	importedGlobalCount int
+	importedTagCount    int
 }
 
 func dump(module binary.Module) {
@@ -23,10 +24,12 @@ func dump(module binary.Module) {
 	d.dumpFuncSec()
 	d.dumpTableSec()
 	d.dumpMemSec()
+	d.dumpTagSec()
 	d.dumpGlobalSec()
 	d.dumpExportSec()
 	d.dumpStartSec()
 	d.dumpElemSec()
+	d.dumpDataCountSec()
 	d.dumpCodeSec()
 	d.dumpDataSec()
 	d.dumpCustomSec()
@@ -59,6 +62,10 @@ func (d *dumper) dumpImportSec() {
 			fmt.Printf("  global[%d]: %s.%s, %s\n",
 				d.importedGlobalCount, imp.Module, imp.Name, imp.Desc.Global)
 			d.importedGlobalCount++
+		case binary.ImportTagTag:
+			fmt.Printf("  tag[%d]: %s.%s, sig=%d\n",
+				d.importedTagCount, imp.Module, imp.Name, imp.Desc.TagType.Type)
+			d.importedTagCount++
 		}
 	}
 	return
@@ -88,6 +95,16 @@ func (d *dumper) dumpMemSec() {
 	}
 }
 
+func (d *dumper) dumpTagSec() {
+	if len(d.module.TagSec) > 0 {
+		fmt.Printf("Tag[%d]:\n", len(d.module.TagSec))
+		for i, tt := range d.module.TagSec {
+			fmt.Printf("  tag[%d]: sig=%d\n",
+				d.importedTagCount+i, tt.Type)
+		}
+	}
+}
+
 func (d *dumper) dumpGlobalSec() {
 	fmt.Printf("Global[%d]:\n", len(d.module.GlobalSec))
 	for i, g := range d.module.GlobalSec {
@@ -108,6 +125,8 @@ func (d *dumper) dumpExportSec() {
 			fmt.Printf("  memory[%d]: name=%s\n", int(exp.Desc.Idx), exp.Name)
 		case binary.ExportTagGlobal:
 			fmt.Printf("  global[%d]: name=%s\n", int(exp.Desc.Idx), exp.Name)
+		case binary.ExportTagTag:
+			fmt.Printf("  tag[%d]: name=%s\n", int(exp.Desc.Idx), exp.Name)
 		}
 	}
 }
@@ -122,7 +141,23 @@ func (d *dumper) dumpStartSec() {
 func (d *dumper) dumpElemSec() {
 	fmt.Printf("Element[%d]:\n", len(d.module.ElemSec))
 	for i, elem := range d.module.ElemSec {
-		fmt.Printf("  elem[%d]: table=%d\n", i, elem.Table) // TODO
+		switch elem.Mode {
+		case binary.SegModePassive:
+			fmt.Printf("  elem[%d]: passive, %s x %d\n",
+				i, binary.ValTypeToStr(elem.Type), elem.Len())
+		case binary.SegModeDeclarative:
+			fmt.Printf("  elem[%d]: declarative, %s x %d\n",
+				i, binary.ValTypeToStr(elem.Type), elem.Len())
+		default:
+			fmt.Printf("  elem[%d]: table=%d, %s x %d\n",
+				i, elem.Table, binary.ValTypeToStr(elem.Type), elem.Len())
+		}
+	}
+}
+
+func (d *dumper) dumpDataCountSec() {
+	if d.module.DataCountSec != nil {
+		fmt.Printf("DataCount:\n  count=%d\n", *d.module.DataCountSec)
 	}
 }
 
@@ -147,7 +182,11 @@ func (d *dumper) dumpCodeSec() {
 func (d *dumper) dumpDataSec() {
 	fmt.Printf("Data[%d]:\n", len(d.module.DataSec))
 	for i, data := range d.module.DataSec {
-		fmt.Printf("  data[%d]: mem=%d\n", i, data.Mem) // TODO
+		if data.Mode == binary.SegModePassive {
+			fmt.Printf("  data[%d]: passive, size=%d\n", i, len(data.Init))
+		} else {
+			fmt.Printf("  data[%d]: mem=%d\n", i, data.Mem) // TODO
+		}
 	}
 }
 
@@ -175,6 +214,55 @@ func (d *dumper) dumpExpr(indentation string, expr binary.Expr) {
 			fmt.Printf("%s%s\n", indentation, "else")
 			d.dumpExpr(indentation+"  ", args.Instrs2)
 			fmt.Printf("%s%s\n", indentation, "end")
+		case binary.Try:
+			args := instr.Args.(binary.TryArgs)
+			bt := d.module.GetBlockType(args.BT)
+			fmt.Printf("%s%s %s\n", indentation, "try", bt)
+			d.dumpExpr(indentation+"  ", args.Instrs)
+			for _, h := range args.Handlers {
+				if h.CatchAll {
+					fmt.Printf("%s%s\n", indentation, "catch_all")
+				} else {
+					fmt.Printf("%s%s %d\n", indentation, "catch", h.Tag)
+				}
+				d.dumpExpr(indentation+"  ", h.Instrs)
+			}
+			if args.Delegate != nil {
+				fmt.Printf("%s%s %d\n", indentation, "delegate", *args.Delegate)
+			} else {
+				fmt.Printf("%s%s\n", indentation, "end")
+			}
+		case binary.TryTable:
+			args := instr.Args.(binary.TryTableArgs)
+			bt := d.module.GetBlockType(args.BT)
+			fmt.Printf("%s%s %s\n", indentation, "try_table", bt)
+			for _, c := range args.Catches {
+				switch c.Kind {
+				case binary.ClauseCatch:
+					fmt.Printf("%s  (catch %d %d)\n", indentation, c.Tag, c.Label)
+				case binary.ClauseCatchRef:
+					fmt.Printf("%s  (catch_ref %d %d)\n", indentation, c.Tag, c.Label)
+				case binary.ClauseCatchAll:
+					fmt.Printf("%s  (catch_all %d)\n", indentation, c.Label)
+				case binary.ClauseCatchAllRef:
+					fmt.Printf("%s  (catch_all_ref %d)\n", indentation, c.Label)
+				}
+			}
+			d.dumpExpr(indentation+"  ", args.Instrs)
+			fmt.Printf("%s%s\n", indentation, "end")
+		case binary.RefNull:
+			vt := instr.Args.(binary.ValType)
+			fmt.Printf("%s%s %s\n", indentation, instr.GetOpname(), binary.ValTypeToStr(vt))
+		case binary.SelectT:
+			vt := instr.Args.([]binary.ValType)[0]
+			fmt.Printf("%s%s %s\n", indentation, instr.GetOpname(), binary.ValTypeToStr(vt))
+		case binary.NumericPrefix, binary.AtomicPrefix, binary.SimdPrefix:
+			args := instr.Args.(binary.PrefixArgs)
+			if args.Args != nil {
+				fmt.Printf("%s%s %v\n", indentation, instr.GetOpname(), args.Args)
+			} else {
+				fmt.Printf("%s%s\n", indentation, instr.GetOpname())
+			}
 		default:
 			if instr.Args != nil {
 				fmt.Printf("%s%s %v\n", indentation, instr.GetOpname(), instr.Args)
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -11,20 +12,62 @@ import (
 	"wasm.go/instance"
 	"wasm.go/interpreter"
 	"wasm.go/validator"
+	"wasm.go/wizer"
 )
 
 func main() {
 	dumpFlag := flag.Bool("d", false, "dump Wasm file")
 	checkFlag := flag.Bool("c", false, "check Wasm file")
 	aotFlag := flag.Bool("a", false, "compile Wasm file to Go plugin")
+	var execFlags execFlags
+	flag.BoolVar(&execFlags.trace, "trace", false, "print an execution trace to stderr")
+	flag.StringVar(&execFlags.pprof, "pprof", "", "write a pprof profile of the guest to `file`")
+	flag.StringVar(&execFlags.coverage, "coverage", "", "add coverage counts to `file`, print a summary and write file.lcov with DWARF")
+	flag.StringVar(&execFlags.record, "record", "", "record the host interactions of the run to `file`")
+	replayFlag := flag.String("replay", "", "replay the run recorded in `file` without the host")
+	initFlag := flag.String("init", wizer.DefaultInit, "export `name` wizer runs")
+	enableAll := flag.Bool("enable-all", false, "enable all features")
+	enables := map[binary.Feature]*bool{}
+	disables := map[binary.Feature]*bool{}
+	for _, f := range binary.AllFeatures {
+		enables[f] = flag.Bool("enable-"+f.String(), false, "enable "+f.String())
+		disables[f] = flag.Bool("disable-"+f.String(), false, "disable "+f.String())
+	}
 
 	flag.Parse()
+	if *enableAll {
+		opts.Features = binary.Features{}
+	}
+	for _, f := range binary.AllFeatures {
+		if *enables[f] {
+			opts.Features = opts.Features.Enable(f)
+		}
+		if *disables[f] {
+			opts.Features = opts.Features.Disable(f)
+		}
+	}
+	if flag.NArg() == 2 && flag.Arg(0) == "debug" {
+		debug(decode(flag.Arg(1)))
+		return
+	}
+	if flag.NArg() == 3 && flag.Arg(0) == "wizer" {
+		initialize(flag.Arg(1), flag.Arg(2), *initFlag)
+		return
+	}
 	if flag.NArg() != 1 {
 		fmt.Printf(`Usage: 
 	wasmgo    filename
 	wasmgo -d filename
 	wasmgo -c filename
 	wasmgo -a filename
+	wasmgo -trace filename
+	wasmgo -pprof file filename
+	wasmgo -coverage file filename
+	wasmgo -record file filename
+	wasmgo -replay file filename
+	wasmgo debug filename
+	wasmgo [-init name] wizer filename output
+Features default to Wasm 2.0, see -enable-* and -disable-*.
 `)
 		os.Exit(1)
 	}
@@ -36,15 +79,21 @@ func main() {
 		check(decode(filename))
 	} else if *aotFlag {
 		aot.Compile(decode(filename))
+	} else if *replayFlag != "" {
+		replay(decode(filename), *replayFlag, execFlags.trace)
 	} else if strings.HasSuffix(filename, ".so") {
 		execSO(filename)
 	} else {
-		instantiateAndExecMainFunc(decode(filename))
+		instantiateAndExecMainFunc(decode(filename), execFlags)
 	}
 }
 
+// decoding, validation and the interpreter are restricted to the
+// features enabled by the command line
+var opts = binary.DecodeOptions{Features: binary.FeaturesStandard}
+
 func decode(filename string) binary.Module {
-	module, err := binary.DecodeFile(filename)
+	module, err := binary.DecodeFileWithOptions(filename, opts)
 	if err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
@@ -53,25 +102,107 @@ func decode(filename string) binary.Module {
 }
 
 func check(module binary.Module) {
-	if err := validator.Validate(module); err != nil {
-		fmt.Println(err.Error())
+	diags := validator.ValidateAllWithOptions(module, opts)
+	for _, d := range diags {
+		printDiagnostic(d)
+	}
+	if len(diags) > 0 {
 		os.Exit(1)
 	}
 	fmt.Println("OK!")
 }
 
-func instantiateAndExecMainFunc(module binary.Module) {
+func printDiagnostic(d *validator.Diagnostic) {
+	msg := d.Error()
+	if d.Offset > 0 {
+		msg = fmt.Sprintf("%06x: %s", d.Offset, msg)
+	}
+	if d.Expected != nil || d.Actual != nil {
+		msg += fmt.Sprintf(", expected %s, got %s",
+			valTypesToStr(d.Expected), valTypesToStr(d.Actual))
+	}
+	fmt.Println(msg)
+}
+
+func valTypesToStr(vts []binary.ValType) string {
+	strs := make([]string, len(vts))
+	for i, vt := range vts {
+		if vt == 0 {
+			strs[i] = "unknown" // polymorphic stack
+		} else {
+			strs[i] = binary.ValTypeToStr(vt)
+		}
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// instrumentation of the interpreter
+type execFlags struct {
+	trace    bool
+	pprof    string // file
+	coverage string // file
+	record   string // file
+}
+
+func instantiateAndExecMainFunc(module binary.Module, flags execFlags) {
 	mm := map[string]instance.Module{"env": newEnv()}
-	m, err := interpreter.New(module, mm)
+	m, err := interpreter.NewWithOptions(module, mm, opts)
+	if err == nil && flags.trace {
+		err = interpreter.SetHooks(m, &tracer{module: module})
+	}
+	var p *interpreter.Profiler
+	if err == nil && flags.pprof != "" {
+		p, err = interpreter.NewProfiler(m)
+	}
+	var c *interpreter.Coverage
+	if err == nil && flags.coverage != "" {
+		c, err = startCoverage(m, flags.coverage)
+	}
+	var r *interpreter.Recorder
+	if err == nil && flags.record != "" {
+		r, err = interpreter.NewRecorder(m)
+	}
 	if err == nil {
 		_, err = m.InvokeFunc("main")
 	}
+	if r != nil {
+		r.Stop()
+		if _err := writeFile(flags.record, r.Recording().Encode); _err != nil && err == nil {
+			err = _err
+		}
+	}
+	if p != nil {
+		p.Stop()
+		if _err := writeFile(flags.pprof, p.WriteProfile); _err != nil && err == nil {
+			err = _err
+		}
+	}
+	if c != nil {
+		if _err := finishCoverage(module, c, flags.coverage); _err != nil && err == nil {
+			err = _err
+		}
+	}
 	if err != nil {
 		fmt.Println(err.Error())
+		if m != nil {
+			printBacktrace(module, m)
+		}
 		os.Exit(1)
 	}
 }
 
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func execSO(filename string) {
 	mm := map[string]instance.Module{
 		"env": newEnv(),
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"wasm.go/binary"
+	"wasm.go/interpreter"
+)
+
+// replay runs module as recorded in file, a divergence from the
+// recording fails like the run itself would
+func replay(module binary.Module, file string, trace bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	rec, err := interpreter.DecodeRecording(f)
+	f.Close()
+	var r *interpreter.Replayer
+	if err == nil {
+		r, err = interpreter.NewReplayer(module, rec, opts)
+	}
+	if err == nil && trace {
+		err = interpreter.SetHooks(r.Instance(), &tracer{module: module})
+	}
+	if err == nil {
+		err = r.Run()
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		if r != nil {
+			printBacktrace(module, r.Instance())
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"wasm.go/binary"
+	"wasm.go/interpreter"
+)
+
+// tracer prints every hook of the interpreter to stderr, indented
+// by call depth
+type tracer struct {
+	module binary.Module
+	depth  int
+}
+
+func (t *tracer) printf(format string, a ...interface{}) {
+	indent := strings.Repeat("  ", t.depth)
+	fmt.Fprintf(os.Stderr, indent+format+"\n", a...)
+}
+
+func (t *tracer) EnterFunc(idx uint32, args []interpreter.WasmVal) {
+	t.printf("-> %s(%s)", t.funcName(idx), valsToStr(args))
+	t.depth++
+}
+
+func (t *tracer) ExitFunc(idx uint32, results []interpreter.WasmVal) {
+	t.depth--
+	t.printf("<- %s%s", t.funcName(idx), resultsToStr(results))
+}
+
+func (t *tracer) BeforeInstr(instr binary.Instruction, stack []uint64) {
+	s := instr.GetOpname()
+	if instr.Args != nil && !isBlock(instr.Opcode) {
+		s += fmt.Sprintf(" %v", instr.Args)
+	}
+	if len(stack) > 0 {
+		s = fmt.Sprintf("%-24s ; top: 0x%x", s, stack[len(stack)-1])
+	}
+	t.printf("%06x: %s", instr.Offset, s)
+}
+
+func (t *tracer) MemRead(memIdx uint32, addr uint64, data []byte) {
+	t.printf("load  mem[%d][0x%x]: % x", memIdx, addr, data)
+}
+
+func (t *tracer) MemWrite(memIdx uint32, addr uint64, data []byte) {
+	t.printf("store mem[%d][0x%x]: % x", memIdx, addr, data)
+}
+
+func (t *tracer) HostCall(idx uint32, args, results []interpreter.WasmVal) {
+	t.printf("host %s(%s)%s", t.funcName(idx), valsToStr(args), resultsToStr(results))
+}
+
+// imported functions are named after their import, others after
+// their export if any
+func (t *tracer) funcName(idx uint32) string {
+	importedCount := uint32(0)
+	for _, imp := range t.module.ImportSec {
+		if imp.Desc.Tag != binary.ImportTagFunc {
+			continue
+		}
+		if importedCount == idx {
+			return imp.Module + "." + imp.Name
+		}
+		importedCount++
+	}
+	for _, exp := range t.module.ExportSec {
+		if exp.Desc.Tag == binary.ExportTagFunc && exp.Desc.Idx == idx {
+			return fmt.Sprintf("func[%d] <%s>", idx, exp.Name)
+		}
+	}
+	return fmt.Sprintf("func[%d]", idx)
+}
+
+func valsToStr(vals []interpreter.WasmVal) string {
+	strs := make([]string, len(vals))
+	for i, val := range vals {
+		strs[i] = fmt.Sprint(val)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func resultsToStr(results []interpreter.WasmVal) string {
+	if len(results) == 0 {
+		return ""
+	}
+	return ": " + valsToStr(results)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"wasm.go/instance"
+	"wasm.go/wizer"
+)
+
+// initialize writes filename, pre-initialized by its export init, to
+// output
+func initialize(filename, output, init string) {
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		mm := map[string]instance.Module{"env": newEnv()}
+		data, err = wizer.Initialize(data, mm, opts, init)
+	}
+	if err == nil {
+		err = os.WriteFile(output, data, 0644)
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
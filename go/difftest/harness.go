@@ -0,0 +1,242 @@
+package difftest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+const (
+	memExportPrefix    = "__difftest_mem"
+	globalExportPrefix = "__difftest_global"
+)
+
+type Invocation struct {
+	Name string
+	Args []instance.WasmVal
+}
+
+func (inv Invocation) String() string {
+	return fmt.Sprintf("%s%v", inv.Name, inv.Args)
+}
+
+// Divergence describes the first observable difference
+// between the interpreter and the AOT-compiled module.
+type Divergence struct {
+	Invocation Invocation
+	Index      int    // index of the invocation, -1 for instantiation
+	What       string // results, trap, memory[i], global[i]
+	Interp     interface{}
+	AOT        interface{}
+}
+
+func (d *Divergence) Error() string {
+	if d.Index < 0 {
+		return fmt.Sprintf("instantiation: %s diverged: interpreter=%v, aot=%v",
+			d.What, d.Interp, d.AOT)
+	}
+	return fmt.Sprintf("invocation[%d] %s: %s diverged: interpreter=%v, aot=%v",
+		d.Index, d.Invocation, d.What, d.Interp, d.AOT)
+}
+
+// Run instantiates module with the interpreter and with the AOT plugin
+// built into dir, performs invocations on both and compares results,
+// traps, memories and globals after each of them. imports is called
+// once per engine so that host modules do not share state.
+func Run(module binary.Module, imports func() instance.Map,
+	invocations []Invocation, dir string) error {
+
+	module = exportAll(module)
+	so, err := BuildPlugin(module, dir)
+	if err != nil {
+		return err
+	}
+
+	interp, interpErr := interpreter.New(module, imports())
+	compiled, aotErr := loadPlugin(so, imports())
+	if d := compareErrs(interpErr, aotErr); d != nil {
+		d.Index = -1
+		return d
+	}
+	if interpErr != nil {
+		return nil // both failed the same way
+	}
+	if d := compareState(module, interp, compiled); d != nil {
+		d.Index = -1
+		return d
+	}
+
+	for i, inv := range invocations {
+		r1, err1 := interp.InvokeFunc(inv.Name, inv.Args...)
+		r2, err2 := safeInvoke(compiled, inv)
+		d := compareErrs(err1, err2)
+		if d == nil && err1 == nil && !isResultsEq(r1, r2) {
+			d = &Divergence{What: "results", Interp: r1, AOT: r2}
+		}
+		if d == nil {
+			d = compareState(module, interp, compiled)
+		}
+		if d != nil {
+			d.Index = i
+			d.Invocation = inv
+			return d
+		}
+	}
+	return nil
+}
+
+// exportAll makes every memory and global reachable through GetMember
+func exportAll(module binary.Module) binary.Module {
+	exported := map[[2]uint32]bool{}
+	for _, exp := range module.ExportSec {
+		exported[[2]uint32{uint32(exp.Desc.Tag), exp.Desc.Idx}] = true
+	}
+
+	memCount, globalCount := 0, 0
+	for _, imp := range module.ImportSec {
+		switch imp.Desc.Tag {
+		case binary.ImportTagMem:
+			memCount++
+		case binary.ImportTagGlobal:
+			globalCount++
+		}
+	}
+	memCount += len(module.MemSec)
+	globalCount += len(module.GlobalSec)
+
+	exports := append([]binary.Export{}, module.ExportSec...)
+	for i := 0; i < memCount; i++ {
+		exports = append(exports, binary.Export{
+			Name: fmt.Sprintf("%s%d", memExportPrefix, i),
+			Desc: binary.ExportDesc{Tag: binary.ExportTagMem, Idx: uint32(i)},
+		})
+	}
+	for i := 0; i < globalCount; i++ {
+		exports = append(exports, binary.Export{
+			Name: fmt.Sprintf("%s%d", globalExportPrefix, i),
+			Desc: binary.ExportDesc{Tag: binary.ExportTagGlobal, Idx: uint32(i)},
+		})
+	}
+	module.ExportSec = exports
+	return module
+}
+
+func compareErrs(err1, err2 error) *Divergence {
+	if err1 == nil && err2 == nil {
+		return nil
+	}
+	if err1 != nil && err2 != nil && err1.Error() == err2.Error() {
+		return nil
+	}
+	return &Divergence{What: "trap", Interp: err1, AOT: err2}
+}
+
+func compareState(module binary.Module,
+	interp, compiled instance.Module) *Divergence {
+
+	for _, exp := range module.ExportSec {
+		switch exp.Desc.Tag {
+		case binary.ExportTagMem:
+			m1, _ := interp.GetMember(exp.Name).(instance.Memory)
+			m2, _ := compiled.GetMember(exp.Name).(instance.Memory)
+			if d := compareMem(m1, m2); d != nil {
+				d.What = fmt.Sprintf("memory[%d] %s", exp.Desc.Idx, d.What)
+				return d
+			}
+		case binary.ExportTagGlobal:
+			v1, _ := interp.GetGlobalVal(exp.Name)
+			v2, _ := compiled.GetGlobalVal(exp.Name)
+			if !isValEq(v1, v2) {
+				return &Divergence{
+					What:   fmt.Sprintf("global[%d]", exp.Desc.Idx),
+					Interp: v1, AOT: v2,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func compareMem(m1, m2 instance.Memory) *Divergence {
+	if m1 == nil || m2 == nil {
+		if m1 != m2 {
+			return &Divergence{What: "presence", Interp: m1, AOT: m2}
+		}
+		return nil
+	}
+	if m1.Size() != m2.Size() {
+		return &Divergence{What: "size", Interp: m1.Size(), AOT: m2.Size()}
+	}
+
+	buf1 := make([]byte, binary.PageSize)
+	buf2 := make([]byte, binary.PageSize)
+	for page := uint64(0); page < m1.Size(); page++ {
+		offset := page * binary.PageSize
+		m1.Read(offset, buf1)
+		m2.Read(offset, buf2)
+		if !bytes.Equal(buf1, buf2) {
+			i := 0
+			for buf1[i] == buf2[i] {
+				i++
+			}
+			return &Divergence{
+				What:   fmt.Sprintf("byte@%d", offset+uint64(i)),
+				Interp: buf1[i], AOT: buf2[i],
+			}
+		}
+	}
+	return nil
+}
+
+func isResultsEq(r1, r2 []instance.WasmVal) bool {
+	if len(r1) != len(r2) {
+		return false
+	}
+	for i := range r1 {
+		if !isValEq(r1[i], r2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// floats are compared bitwise so that NaNs and signed zeros count
+func isValEq(v1, v2 instance.WasmVal) bool {
+	switch x := v1.(type) {
+	case float32:
+		y, ok := v2.(float32)
+		return ok && math.Float32bits(x) == math.Float32bits(y)
+	case float64:
+		y, ok := v2.(float64)
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	case instance.Function:
+		// funcs of different engines can only be told apart by type
+		y, ok := v2.(instance.Function)
+		return ok && x.Type().GetSignature() == y.Type().GetSignature()
+	case *instance.Exception:
+		// and exceptions by their tag type and payload
+		y, ok := v2.(*instance.Exception)
+		return ok && x.Error() == y.Error()
+	default:
+		return v1 == v2
+	}
+}
+
+// the generated code may panic with non-error values
+func safeInvoke(m instance.Module, inv Invocation) (results []instance.WasmVal, err error) {
+	defer func() {
+		if _err := recover(); _err != nil {
+			switch x := _err.(type) {
+			case error:
+				err = x
+			default:
+				err = fmt.Errorf("%v", x)
+			}
+		}
+	}()
+	return m.InvokeFunc(inv.Name, inv.Args...)
+}
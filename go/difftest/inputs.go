@@ -0,0 +1,98 @@
+package difftest
+
+import (
+	"math"
+	"math/rand"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// boundary values are much more likely to expose differences
+var (
+	edgeI32 = []int32{0, 1, -1, 2, 31, 32, math.MinInt32, math.MaxInt32}
+	edgeI64 = []int64{0, 1, -1, 63, 64, math.MinInt64, math.MaxInt64}
+	edgeF32 = []float32{0, -1, 0.5, -0.5, math.MaxFloat32,
+		math.SmallestNonzeroFloat32, float32(math.Inf(1)),
+		float32(math.Inf(-1)), float32(math.NaN()), 2147483648}
+	edgeF64 = []float64{0, -1, 0.5, -0.5, math.MaxFloat64,
+		math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1),
+		math.NaN(), 9223372036854775808}
+)
+
+// RandomInvocations calls each exported function n times
+// with arguments of the right types drawn from r.
+func RandomInvocations(module binary.Module, r *rand.Rand,
+	n int) []Invocation {
+
+	var invocations []Invocation
+	for i := 0; i < n; i++ {
+		for _, exp := range module.ExportSec {
+			if exp.Desc.Tag == binary.ExportTagFunc {
+				ft := getFuncType(module, exp.Desc.Idx)
+				invocations = append(invocations, Invocation{
+					Name: exp.Name,
+					Args: RandomArgs(ft, r),
+				})
+			}
+		}
+	}
+	return invocations
+}
+
+func RandomArgs(ft binary.FuncType, r *rand.Rand) []instance.WasmVal {
+	args := make([]instance.WasmVal, len(ft.ParamTypes))
+	for i, vt := range ft.ParamTypes {
+		args[i] = randomVal(vt, r)
+	}
+	return args
+}
+
+func randomVal(vt binary.ValType, r *rand.Rand) instance.WasmVal {
+	edge := r.Intn(4) == 0
+	switch vt {
+	case binary.ValTypeI32:
+		if edge {
+			return edgeI32[r.Intn(len(edgeI32))]
+		}
+		return int32(r.Uint32())
+	case binary.ValTypeI64:
+		if edge {
+			return edgeI64[r.Intn(len(edgeI64))]
+		}
+		return int64(r.Uint64())
+	case binary.ValTypeF32:
+		if edge {
+			return edgeF32[r.Intn(len(edgeF32))]
+		}
+		return float32(r.NormFloat64() * 1e6)
+	case binary.ValTypeF64:
+		if edge {
+			return edgeF64[r.Intn(len(edgeF64))]
+		}
+		return r.NormFloat64() * 1e12
+	case binary.ValTypeFuncRef:
+		return nil // engines can't share funcs
+	case binary.ValTypeExnRef:
+		return nil // nor exceptions
+	case binary.ValTypeExternRef:
+		if edge {
+			return nil
+		}
+		return r.Intn(8)
+	default:
+		panic("unreachable")
+	}
+}
+
+func getFuncType(module binary.Module, fIdx uint32) binary.FuncType {
+	for _, imp := range module.ImportSec {
+		if imp.Desc.Tag == binary.ImportTagFunc {
+			if fIdx == 0 {
+				return module.TypeSec[imp.Desc.FuncType]
+			}
+			fIdx--
+		}
+	}
+	return module.TypeSec[module.FuncSec[fIdx]]
+}
@@ -0,0 +1,71 @@
+package difftest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"wasm.go/aot"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// BuildPlugin compiles module to Go and builds it as a plugin in dir.
+// Plugins are named after the hash of their source, so a module that
+// was already built is reused (the Go runtime refuses to load two
+// different files of the same plugin anyway).
+func BuildPlugin(module binary.Module, dir string) (string, error) {
+	src := aot.Generate(module)
+	sum := sha256.Sum256([]byte(src))
+	name := "m" + hex.EncodeToString(sum[:8])
+	goFile := filepath.Join(dir, name+".go")
+	soFile := filepath.Join(dir, name+".so")
+	if _, err := os.Stat(soFile); err == nil {
+		return soFile, nil
+	}
+
+	if err := os.WriteFile(goFile, []byte(src), 0644); err != nil {
+		return "", err
+	}
+	root, err := moduleRoot()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", soFile, goFile)
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("build %s: %v\n%s", goFile, err, out)
+	}
+	return soFile, nil
+}
+
+// the plugin imports wasm.go packages, so it is built inside this module
+func moduleRoot() (string, error) {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return "", err
+	}
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == os.DevNull {
+		return "", fmt.Errorf("go.mod not found")
+	}
+	return filepath.Dir(gomod), nil
+}
+
+func loadPlugin(so string, mm instance.Map) (m instance.Module, err error) {
+	defer func() {
+		if _err := recover(); _err != nil {
+			switch x := _err.(type) {
+			case error:
+				err = x
+			default:
+				err = fmt.Errorf("%v", x)
+			}
+		}
+	}()
+	return aot.Load(so, mm)
+}
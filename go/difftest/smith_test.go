@@ -0,0 +1,26 @@
+package difftest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+	"wasm.go/smith"
+)
+
+func TestSmithModules(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building plugins is slow")
+	}
+	r := rand.New(rand.NewSource(1))
+	dir := t.TempDir()
+	for i := 0; i < 30; i++ {
+		seed := make([]byte, 4096)
+		r.Read(seed)
+		module := smith.Generate(seed)
+		imports := func() instance.Map { return smith.StubImports(module) }
+		invocations := RandomInvocations(module, r, 2)
+		require.NoError(t, Run(module, imports, invocations, dir), "seed %d", i)
+	}
+}
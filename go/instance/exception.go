@@ -0,0 +1,15 @@
+package instance
+
+import "fmt"
+
+// Exception is a wasm exception. Funcs return it as the error when it
+// is not caught, and host funcs may return one to throw it into wasm.
+type Exception struct {
+	Tag  Tag
+	Args []WasmVal // payload, typed by the params of the tag
+}
+
+func (e *Exception) Error() string {
+	return fmt.Sprintf("uncaught exception: %s %v",
+		e.Tag.Type().GetSignature(), e.Args)
+}
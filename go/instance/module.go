@@ -2,9 +2,19 @@ package instance
 
 import "wasm.go/binary"
 
+// int32, int64, float32 or float64 for numbers, V128 for vectors,
+// instance.Function for funcrefs and any Go value for externrefs, nil
+// if the ref is null
 type WasmVal = interface{}
 type Map = map[string]Module
 
+// v128 values, lanes are little-endian
+type V128 [16]byte
+
+// Instances aren't safe for concurrent use: calls of InvokeFunc,
+// GetGlobalVal, SetGlobalVal and of the members' methods must not
+// overlap, except those of shared memories. GetMember only reads what
+// instantiation set up and may run concurrently with anything.
 type Module interface {
 	GetMember(name string) interface{}
 	InvokeFunc(name string, args ...WasmVal) ([]WasmVal, error)
@@ -20,19 +30,33 @@ type Function interface {
 type Table interface {
 	Type() binary.TableType
 	Size() uint32
-	Grow(n uint32)
-	GetElem(idx uint32) Function
-	SetElem(idx uint32, elem Function)
+	Grow(n uint32, init WasmVal) uint32 // old size or 0xFFFFFFFF
+	GetElem(idx uint32) WasmVal
+	SetElem(idx uint32, elem WasmVal)
 }
 
 type Memory interface {
 	Type() binary.MemType
-	Size() uint32 // page count
-	Grow(n uint32) uint32
+	Size() uint64         // page count
+	Grow(n uint64) uint64 // old size or math.MaxUint64
 	Read(offset uint64, buf []byte)
 	Write(offset uint64, buf []byte)
 }
 
+// memories supporting the atomic instructions, shared ones may be
+// imported by instances running on several goroutines
+type AtomicMemory interface {
+	Memory
+	RMW(offset uint64, n int, f func(old uint64) uint64) uint64 // returns old
+	Wait(offset uint64, n int, expected uint64, timeout int64) uint32
+	Notify(offset uint64, count uint32) uint32
+}
+
+// tags are compared by identity, exceptions only match their own tag
+type Tag interface {
+	Type() binary.FuncType
+}
+
 type Global interface {
 	Type() binary.GlobalType
 	GetAsU64() uint64
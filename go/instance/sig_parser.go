@@ -37,6 +37,14 @@ func parseValTypes(list string) []binary.ValType {
 			valTypes = append(valTypes, binary.ValTypeF32)
 		case "f64":
 			valTypes = append(valTypes, binary.ValTypeF64)
+		case "v128":
+			valTypes = append(valTypes, binary.ValTypeV128)
+		case "funcref":
+			valTypes = append(valTypes, binary.ValTypeFuncRef)
+		case "externref":
+			valTypes = append(valTypes, binary.ValTypeExternRef)
+		case "exnref":
+			valTypes = append(valTypes, binary.ValTypeExnRef)
 		}
 	}
 	return valTypes
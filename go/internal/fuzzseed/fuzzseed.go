@@ -0,0 +1,21 @@
+// Package fuzzseed seeds the fuzz targets of the module.
+package fuzzseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Add adds the compiled wat samples and hw_rust.wasm to the seed
+// corpus of f, which runs in a package directory of the module.
+func Add(f *testing.F) {
+	files, _ := filepath.Glob("../../wat/*.wasm")
+	for _, file := range append(files, "../binary/testdata/hw_rust.wasm") {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+}
@@ -0,0 +1,337 @@
+package interpreter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// Coverage counts the calls of the functions of an instance, the
+// entries of its blocks and the directions its branches take. Counts
+// of successive runs add up, Merge and ReadCounts add those of other
+// instances of the same module.
+type Coverage struct {
+	module   binary.Module
+	vm       *vm
+	Calls    map[uint32]uint64 // by function index
+	Blocks   map[uint32]uint64 // by offset of block, loop, if, try and try_table
+	Branches map[Branch]uint64
+}
+
+// Branch is a direction of the if, br_if or br_table at Offset. Arm 0
+// is the then arm of if and the taken br_if, 1 the else arm and the
+// fallthrough. Arms of br_table are numbered after its labels, the
+// default one last.
+type Branch struct {
+	Offset uint32
+	Arm    uint32
+}
+
+// FuncCoverage sums up the coverage of an internal function.
+type FuncCoverage struct {
+	Idx         uint32
+	Name        string
+	Calls       uint64
+	Blocks      int
+	BlocksHit   int
+	Branches    int
+	BranchesHit int
+}
+
+// LineFunc maps the offset of an instruction to its source location.
+type LineFunc func(offset uint32) (file string, line int, ok bool)
+
+func NewCoverage(m instance.Module) (*Coverage, error) {
+	vm, ok := m.(*vm)
+	if !ok {
+		return nil, errors.New("not an interpreter instance")
+	}
+	c := &Coverage{
+		module:   vm.module,
+		vm:       vm,
+		Calls:    map[uint32]uint64{},
+		Blocks:   map[uint32]uint64{},
+		Branches: map[Branch]uint64{},
+	}
+	vm.coverage = c
+	vm.observe()
+	return c, nil
+}
+
+// Detach stops counting, the counts remain.
+func (c *Coverage) Detach() {
+	c.vm.coverage = nil
+	c.vm.observe()
+}
+
+func (c *Coverage) beforeInstr(instr binary.Instruction) {
+	switch instr.Opcode {
+	case binary.Block, binary.Loop, binary.Try, binary.TryTable:
+		c.Blocks[instr.Offset]++
+	case binary.If:
+		c.Blocks[instr.Offset]++
+		c.Branches[Branch{instr.Offset, boolToU32(c.stackTop() == 0)}]++
+	case binary.BrIf:
+		c.Branches[Branch{instr.Offset, boolToU32(c.stackTop() == 0)}]++
+	case binary.BrTable:
+		n := uint32(len(instr.Args.(binary.BrTableArgs).Labels))
+		if arm := c.stackTop(); arm < n {
+			c.Branches[Branch{instr.Offset, arm}]++
+		} else {
+			c.Branches[Branch{instr.Offset, n}]++
+		}
+	}
+}
+
+// the condition or index of branches
+func (c *Coverage) stackTop() uint32 {
+	return uint32(c.vm.slots[len(c.vm.slots)-1])
+}
+
+func boolToU32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Merge adds the counts of o, which covers the same module.
+func (c *Coverage) Merge(o *Coverage) {
+	for idx, n := range o.Calls {
+		c.Calls[idx] += n
+	}
+	for offset, n := range o.Blocks {
+		c.Blocks[offset] += n
+	}
+	for br, n := range o.Branches {
+		c.Branches[br] += n
+	}
+}
+
+// WriteCounts writes the counts as lines of text, ReadCounts adds
+// them back.
+func (c *Coverage) WriteCounts(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, idx := range sortedKeys(c.Calls) {
+		fmt.Fprintf(bw, "call %d %d\n", idx, c.Calls[idx])
+	}
+	for _, offset := range sortedKeys(c.Blocks) {
+		fmt.Fprintf(bw, "block %d %d\n", offset, c.Blocks[offset])
+	}
+	brs := make([]Branch, 0, len(c.Branches))
+	for br := range c.Branches {
+		brs = append(brs, br)
+	}
+	sort.Slice(brs, func(i, j int) bool {
+		if brs[i].Offset != brs[j].Offset {
+			return brs[i].Offset < brs[j].Offset
+		}
+		return brs[i].Arm < brs[j].Arm
+	})
+	for _, br := range brs {
+		fmt.Fprintf(bw, "branch %d %d %d\n", br.Offset, br.Arm, c.Branches[br])
+	}
+	return bw.Flush()
+}
+
+func (c *Coverage) ReadCounts(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		nums := make([]uint64, len(fields)-1)
+		for i, field := range fields[1:] {
+			n, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid coverage line: %s", scanner.Text())
+			}
+			nums[i] = n
+		}
+		switch {
+		case fields[0] == "call" && len(nums) == 2:
+			c.Calls[uint32(nums[0])] += nums[1]
+		case fields[0] == "block" && len(nums) == 2:
+			c.Blocks[uint32(nums[0])] += nums[1]
+		case fields[0] == "branch" && len(nums) == 3:
+			c.Branches[Branch{uint32(nums[0]), uint32(nums[1])}] += nums[2]
+		default:
+			return fmt.Errorf("invalid coverage line: %s", scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+func sortedKeys(m map[uint32]uint64) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Summary returns the coverage of each internal function.
+func (c *Coverage) Summary() []FuncCoverage {
+	names := c.module.FuncNames()
+	importedFuncCount := len(c.vm.funcs) - len(c.module.CodeSec)
+	fcs := make([]FuncCoverage, len(c.module.CodeSec))
+	for i, code := range c.module.CodeSec {
+		fc := &fcs[i]
+		fc.Idx = uint32(importedFuncCount + i)
+		fc.Name = c.module.FuncName(fc.Idx, names)
+		fc.Calls = c.Calls[fc.Idx]
+		c.walk(code.Expr, fc.Calls, func(instr binary.Instruction, count uint64) {
+			if isBlockInstr(instr.Opcode) {
+				fc.Blocks++
+				if c.Blocks[instr.Offset] > 0 {
+					fc.BlocksHit++
+				}
+			}
+			for arm := uint32(0); arm < branchArms(instr); arm++ {
+				fc.Branches++
+				if c.Branches[Branch{instr.Offset, arm}] > 0 {
+					fc.BranchesHit++
+				}
+			}
+		})
+	}
+	return fcs
+}
+
+// WriteLcov writes an lcov tracefile, lines maps instructions to
+// their source.
+func (c *Coverage) WriteLcov(w io.Writer, lines LineFunc) error {
+	type fileCoverage struct {
+		funcs, funcsHit       int
+		branches, branchesHit int
+		records               []string // FN, FNDA and BRDA
+		lines                 map[int]uint64
+	}
+	files := map[string]*fileCoverage{}
+	getFile := func(name string) *fileCoverage {
+		if files[name] == nil {
+			files[name] = &fileCoverage{lines: map[int]uint64{}}
+		}
+		return files[name]
+	}
+
+	names := c.module.FuncNames()
+	importedFuncCount := len(c.vm.funcs) - len(c.module.CodeSec)
+	for i, code := range c.module.CodeSec {
+		idx := uint32(importedFuncCount + i)
+		calls := c.Calls[idx]
+		entry := true
+		c.walk(code.Expr, calls, func(instr binary.Instruction, count uint64) {
+			file, line, ok := lines(instr.Offset)
+			if !ok {
+				return
+			}
+			fc := getFile(file)
+			if entry {
+				name := c.module.FuncName(idx, names)
+				fc.records = append(fc.records,
+					fmt.Sprintf("FN:%d,%s\nFNDA:%d,%s\n", line, name, calls, name))
+				fc.funcs++
+				if calls > 0 {
+					fc.funcsHit++
+				}
+				entry = false
+			}
+			if n, ok := fc.lines[line]; !ok || count > n {
+				fc.lines[line] = count
+			}
+			for arm := uint32(0); arm < branchArms(instr); arm++ {
+				n := c.Branches[Branch{instr.Offset, arm}]
+				taken := "-"
+				if count > 0 {
+					taken = fmt.Sprint(n)
+				}
+				fc.records = append(fc.records,
+					fmt.Sprintf("BRDA:%d,%d,%d,%s\n", line, instr.Offset, arm, taken))
+				fc.branches++
+				if n > 0 {
+					fc.branchesHit++
+				}
+			}
+		})
+	}
+
+	fileNames := make([]string, 0, len(files))
+	for name := range files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+	bw := bufio.NewWriter(w)
+	for _, name := range fileNames {
+		fc := files[name]
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", name)
+		for _, record := range fc.records {
+			bw.WriteString(record)
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\nBRF:%d\nBRH:%d\n",
+			fc.funcs, fc.funcsHit, fc.branches, fc.branchesHit)
+		lineNums := make([]int, 0, len(fc.lines))
+		for line := range fc.lines {
+			lineNums = append(lineNums, line)
+		}
+		sort.Ints(lineNums)
+		linesHit := 0
+		for _, line := range lineNums {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, fc.lines[line])
+			if fc.lines[line] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lineNums), linesHit)
+	}
+	return bw.Flush()
+}
+
+// walk calls f with the instructions of expr, nested ones included,
+// and the number of times their innermost block or arm was entered.
+// Catch handlers aren't counted and are skipped.
+func (c *Coverage) walk(expr []binary.Instruction, count uint64,
+	f func(instr binary.Instruction, count uint64)) {
+
+	for _, instr := range expr {
+		f(instr, count)
+		switch args := instr.Args.(type) {
+		case binary.BlockArgs:
+			c.walk(args.Instrs, c.Blocks[instr.Offset], f)
+		case binary.IfArgs:
+			c.walk(args.Instrs1, c.Branches[Branch{instr.Offset, 0}], f)
+			c.walk(args.Instrs2, c.Branches[Branch{instr.Offset, 1}], f)
+		case binary.TryArgs:
+			c.walk(args.Instrs, c.Blocks[instr.Offset], f)
+		case binary.TryTableArgs:
+			c.walk(args.Instrs, c.Blocks[instr.Offset], f)
+		}
+	}
+}
+
+func isBlockInstr(opcode byte) bool {
+	switch opcode {
+	case binary.Block, binary.Loop, binary.If, binary.Try, binary.TryTable:
+		return true
+	}
+	return false
+}
+
+func branchArms(instr binary.Instruction) uint32 {
+	switch instr.Opcode {
+	case binary.If, binary.BrIf:
+		return 2
+	case binary.BrTable:
+		return uint32(len(instr.Args.(binary.BrTableArgs).Labels)) + 1
+	}
+	return 0
+}
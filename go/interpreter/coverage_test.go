@@ -0,0 +1,78 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	module := decodeTestdata(t, "coverage")
+	m, err := New(module, nil)
+	require.NoError(t, err)
+	c, err := NewCoverage(m)
+	require.NoError(t, err)
+
+	for _, arg := range []int32{0, 5} {
+		_, err = m.InvokeFunc("classify", arg)
+		require.NoError(t, err)
+	}
+	results, err := m.InvokeFunc("abs", int32(-3))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(3)}, results)
+
+	require.Equal(t, []FuncCoverage{
+		{Idx: 0, Name: "classify", Calls: 2, Blocks: 3, BlocksHit: 3, Branches: 3, BranchesHit: 2},
+		{Idx: 1, Name: "abs", Calls: 1, Blocks: 1, BlocksHit: 1, Branches: 2, BranchesHit: 1},
+		{Idx: 2, Name: "unused", Calls: 0, Blocks: 1, BlocksHit: 0, Branches: 2, BranchesHit: 0},
+	}, c.Summary())
+
+	// merged through counts
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteCounts(&buf))
+	m2, err := New(module, nil)
+	require.NoError(t, err)
+	c2, err := NewCoverage(m2)
+	require.NoError(t, err)
+	_, err = m2.InvokeFunc("unused")
+	require.NoError(t, err)
+	require.NoError(t, c2.ReadCounts(&buf))
+	require.Equal(t, uint64(1), c2.Calls[2])
+	require.Equal(t, c.Blocks, mapWithout(c2.Blocks, module.CodeSec[2].Expr[0].Offset))
+	require.Equal(t, 1, c2.Summary()[2].BranchesHit)
+	require.Error(t, c2.ReadCounts(bytes.NewBufferString("call 1\n")))
+
+	c.Merge(c2)
+	require.Equal(t, uint64(4), c.Calls[0])
+
+	// one line per function
+	buf.Reset()
+	require.NoError(t, c.WriteLcov(&buf, func(offset uint32) (string, int, bool) {
+		for i := len(module.CodeSec) - 1; i >= 0; i-- {
+			if offset >= module.CodeSec[i].Expr[0].Offset {
+				return "coverage.wat", i + 1, true
+			}
+		}
+		return "", 0, false
+	}))
+	lcov := buf.String()
+	require.Contains(t, lcov, "SF:coverage.wat\nFN:1,classify\nFNDA:4,classify\n")
+	require.Contains(t, lcov, "FNF:3\nFNH:3\nBRF:7\nBRH:4\n")
+	require.Contains(t, lcov, "DA:1,4\nDA:2,2\nDA:3,1\nLF:3\nLH:3\nend_of_record\n")
+
+	c.Detach()
+	_, err = m.InvokeFunc("abs", int32(1))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), c.Calls[1])
+}
+
+func mapWithout(m map[uint32]uint64, key uint32) map[uint32]uint64 {
+	m2 := map[uint32]uint64{}
+	for k, v := range m {
+		if k != key {
+			m2[k] = v
+		}
+	}
+	return m2
+}
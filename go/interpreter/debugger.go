@@ -0,0 +1,208 @@
+package interpreter
+
+import (
+	"errors"
+	"strings"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// StepMode says how far a paused Debugger lets the guest run.
+type StepMode int
+
+const (
+	Continue StepMode = iota // to the next breakpoint
+	StepInto                 // to the next instruction
+	StepOver                 // to the next one of the function or a caller
+	StepOut                  // to the next one of a caller
+)
+
+// Breakpoint pauses before the instruction at Offset in the binary,
+// or before the first instruction of the function if Offset is 0.
+type Breakpoint struct {
+	FuncIdx uint32
+	Offset  uint32
+}
+
+// Debugger pauses an instance of this package at breakpoints and
+// after steps. OnPause runs on the goroutine executing the guest, the
+// state can be inspected until it returns how to go on.
+type Debugger struct {
+	OnPause     func(d *Debugger) StepMode
+	vm          *vm
+	breakpoints map[Breakpoint]bool
+	names       map[binary.FuncIdx]string // of the name section
+	mode        StepMode
+	callPos     int           // of the call frame the step started in
+	cf          *controlFrame // innermost frame while paused
+	call        *controlFrame
+}
+
+func NewDebugger(m instance.Module) (*Debugger, error) {
+	vm, ok := m.(*vm)
+	if !ok {
+		return nil, errors.New("not an interpreter instance")
+	}
+	d := &Debugger{
+		vm:          vm,
+		breakpoints: map[Breakpoint]bool{},
+		names:       vm.module.FuncNames(),
+	}
+	vm.debugger = d
+	vm.observe()
+	return d, nil
+}
+
+func (d *Debugger) Detach() {
+	d.vm.debugger = nil
+	d.vm.observe()
+}
+
+// Pause stops before the next instruction.
+func (d *Debugger) Pause() {
+	d.mode = StepInto
+}
+
+func (d *Debugger) SetBreakpoint(bp Breakpoint) {
+	d.breakpoints[bp] = true
+}
+
+func (d *Debugger) ClearBreakpoint(bp Breakpoint) {
+	delete(d.breakpoints, bp)
+}
+
+func (d *Debugger) Breakpoints() []Breakpoint {
+	bps := make([]Breakpoint, 0, len(d.breakpoints))
+	for bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	return bps
+}
+
+// LookupFunc returns the index of the function FuncName calls name,
+// or else of the one exported as name.
+func (d *Debugger) LookupFunc(name string) (uint32, bool) {
+	for idx := range d.vm.funcs {
+		if d.FuncName(uint32(idx)) == name {
+			return uint32(idx), true
+		}
+	}
+	for _, exp := range d.vm.module.ExportSec {
+		if exp.Name == name && exp.Desc.Tag == binary.ExportTagFunc {
+			return exp.Desc.Idx, true
+		}
+	}
+	return 0, false
+}
+
+// FuncName names function idx like profiles and coverage do, see
+// binary.Module.FuncName.
+func (d *Debugger) FuncName(idx uint32) string {
+	return d.vm.module.FuncName(idx, d.names)
+}
+
+func (d *Debugger) beforeInstr(cf *controlFrame) {
+	callPos := d.vm.callFramePos()
+	call := d.vm.frames[callPos]
+	pause := false
+	switch d.mode {
+	case StepInto:
+		pause = true
+	case StepOver:
+		pause = callPos <= d.callPos
+	case StepOut:
+		pause = callPos < d.callPos
+	}
+	if !pause && len(d.breakpoints) > 0 {
+		idx := call.fn.idx
+		offset := cf.instrs[cf.pc].Offset
+		pause = offset != 0 && d.breakpoints[Breakpoint{idx, offset}] ||
+			cf == call && cf.pc == 0 && d.breakpoints[Breakpoint{FuncIdx: idx}]
+	}
+	if !pause || d.OnPause == nil {
+		return
+	}
+
+	d.cf, d.call = cf, call
+	d.mode = d.OnPause(d)
+	d.callPos = callPos
+	d.cf, d.call = nil, nil
+}
+
+// the following may only be called while paused
+
+func (d *Debugger) FuncIdx() uint32 {
+	return d.call.fn.idx
+}
+
+// Instr returns the instruction about to be executed.
+func (d *Debugger) Instr() binary.Instruction {
+	return d.cf.instrs[d.cf.pc]
+}
+
+// InstrPath returns the enclosing blocks and the instruction about to
+// be executed, e.g. "block/loop/i32.add".
+func (d *Debugger) InstrPath() string {
+	var names []string
+	for i := d.vm.callFramePos() + 1; i < len(d.vm.frames); i++ {
+		names = append(names, binary.Instruction{Opcode: d.vm.frames[i].opcode}.GetOpname())
+	}
+	return strings.Join(append(names, d.Instr().GetOpname()), "/")
+}
+
+// CallStack returns the indices of the called functions, innermost last.
+func (d *Debugger) CallStack() []uint32 {
+	var idxs []uint32
+	for _, cf := range d.vm.frames {
+		if cf.opcode == binary.Call {
+			idxs = append(idxs, cf.fn.idx)
+		}
+	}
+	return idxs
+}
+
+// Locals returns the params and locals of the current function.
+func (d *Debugger) Locals() []WasmVal {
+	ft := d.call.fn._type
+	vts := append([]binary.ValType{}, ft.ParamTypes...)
+	for _, locals := range d.call.fn.code.Locals {
+		for i := uint32(0); i < locals.N; i++ {
+			vts = append(vts, locals.Type)
+		}
+	}
+	vals := make([]WasmVal, len(vts))
+	for i, vt := range vts {
+		vals[i] = d.vm.getVal(d.call.bp+i, vt)
+	}
+	return vals
+}
+
+// Operands returns the raw operand stack of the current function,
+// locals excluded, the top last. Vectors take their low halves only.
+func (d *Debugger) Operands() []uint64 {
+	bp := d.call.bp + len(d.call.fn._type.ParamTypes) +
+		int(d.call.fn.code.GetLocalCount())
+	return append([]uint64{}, d.vm.slots[bp:]...)
+}
+
+func (d *Debugger) Globals() []WasmVal {
+	vals := make([]WasmVal, len(d.vm.globals))
+	for i, g := range d.vm.globals {
+		vals[i] = g.Get()
+	}
+	return vals
+}
+
+func (d *Debugger) ReadMemory(memIdx uint32, offset uint64, n int) ([]byte, error) {
+	if int(memIdx) >= len(d.vm.memories) {
+		return nil, errors.New("unknown memory")
+	}
+	mem := d.vm.memories[memIdx]
+	if outOfBounds(offset, uint64(n), mem.Size()*binary.PageSize) {
+		return nil, errMemOutOfBounds
+	}
+	buf := make([]byte, n)
+	mem.Read(offset, buf)
+	return buf, nil
+}
@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+func TestDebugger(t *testing.T) {
+	module := decodeTestdata(t, "debug")
+	m, err := New(module, nil)
+	require.NoError(t, err)
+	d, err := NewDebugger(m)
+	require.NoError(t, err)
+
+	add, ok := d.LookupFunc("add")
+	require.True(t, ok)
+	require.Equal(t, uint32(0), add)
+	require.Equal(t, "main", d.FuncName(1))
+	sub, ok := d.LookupFunc("sub") // of the name section only
+	require.True(t, ok)
+	require.Equal(t, uint32(2), sub)
+	require.Equal(t, "sub", d.FuncName(sub))
+	_, ok = d.LookupFunc("mul")
+	require.False(t, ok)
+
+	var paths []string
+	d.OnPause = func(d *Debugger) StepMode {
+		paths = append(paths, d.InstrPath())
+		switch len(paths) {
+		case 1:
+			require.Equal(t, "block", d.InstrPath())
+			require.Equal(t, uint32(1), d.FuncIdx())
+			require.Empty(t, d.Locals())
+			return StepInto
+		case 2:
+			require.Equal(t, []uint64{}, d.Operands())
+			return StepInto
+		case 4:
+			require.Equal(t, []uint64{1, 2}, d.Operands())
+			return StepOver
+		case 5:
+			require.Equal(t, "block/i32.const", d.InstrPath())
+			require.Equal(t, []uint64{3}, d.Operands())
+			require.Equal(t, []instance.WasmVal{int32(7)}, d.Globals())
+			mem, err := d.ReadMemory(0, 0, 4)
+			require.NoError(t, err)
+			require.Equal(t, []byte{3, 0, 0, 0}, mem)
+			_, err = d.ReadMemory(0, binary.PageSize-2, 4)
+			require.Error(t, err)
+			return StepInto
+		case 6:
+			return StepOut
+		}
+		return StepInto
+	}
+	d.Pause()
+	results, err := m.InvokeFunc("main")
+	require.NoError(t, err)
+	require.Equal(t, []instance.WasmVal{int32(3)}, results)
+	require.Equal(t, []string{"block", "block/i32.const", "block/i32.const",
+		"block/call", "block/i32.const", "block/global.set"}, paths)
+
+	// i32.store of add
+	store := module.CodeSec[0].Expr[6]
+	require.Equal(t, "i32.store", store.GetOpname())
+	d.SetBreakpoint(Breakpoint{FuncIdx: add, Offset: store.Offset})
+	paths = nil
+	d.OnPause = func(d *Debugger) StepMode {
+		paths = append(paths, d.InstrPath())
+		require.Equal(t, []uint32{1, 0}, d.CallStack())
+		require.Equal(t, []instance.WasmVal{int32(1), int32(2), int32(3)}, d.Locals())
+		require.Equal(t, []uint64{0, 3}, d.Operands())
+		return Continue
+	}
+	_, err = m.InvokeFunc("main")
+	require.NoError(t, err)
+	require.Equal(t, []string{"i32.store"}, paths)
+
+	// function entry
+	paths = nil
+	d.ClearBreakpoint(Breakpoint{FuncIdx: add, Offset: store.Offset})
+	d.SetBreakpoint(Breakpoint{FuncIdx: add})
+	d.OnPause = func(d *Debugger) StepMode {
+		paths = append(paths, d.InstrPath())
+		return Continue
+	}
+	_, err = m.InvokeFunc("add", int32(1), int32(1))
+	require.NoError(t, err)
+	require.Equal(t, []string{"local.get"}, paths)
+
+	d.Detach()
+	_, err = m.InvokeFunc("main")
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+}
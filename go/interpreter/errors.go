@@ -8,8 +8,15 @@ var (
 	errTypeMismatch      = errors.New("indirect call type mismatch")
 	errUndefinedElem     = errors.New("undefined element")
 	errUninitializedElem = errors.New("uninitialized element")
+	errTableOutOfBounds  = errors.New("out of bounds table access")
 	errMemOutOfBounds    = errors.New("out of bounds memory access")
 	errImmutableGlobal   = errors.New("immutable global")
 	errIntOverflow       = errors.New("integer overflow")
 	errConvertToInt      = errors.New("invalid conversion to integer")
+	errTableTooLarge     = errors.New("table size exceeds implementation limit")
+	errMemTooLarge       = errors.New("memory size exceeds implementation limit")
+	errConstExprRequired = errors.New("constant expression required")
+	errNullExnRef        = errors.New("null exception reference")
+	errUnalignedAtomic   = errors.New("unaligned atomic")
+	errExpectedShared    = errors.New("expected shared memory")
 )
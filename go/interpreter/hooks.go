@@ -0,0 +1,87 @@
+package interpreter
+
+import (
+	"errors"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// The hook interfaces observe the execution of an instance, see
+// SetHooks. Slices passed to them are only valid during the call.
+
+// FuncHook sees internal functions being entered and left, so calls
+// stay balanced. Functions left by traps or exceptions, or replaced
+// by tail calls, exit with nil results.
+type FuncHook interface {
+	EnterFunc(idx uint32, args []WasmVal)
+	ExitFunc(idx uint32, results []WasmVal)
+}
+
+// InstrHook sees each instruction before it is executed, along with
+// the raw slots of the whole operand stack, top last. References are
+// RefStore indices and v128 operands only show their low halves.
+type InstrHook interface {
+	BeforeInstr(instr binary.Instruction, stack []uint64)
+}
+
+// MemHook sees the little endian bytes loaded and stored by memory
+// instructions, bulk ones included.
+type MemHook interface {
+	MemRead(memIdx uint32, addr uint64, data []byte)
+	MemWrite(memIdx uint32, addr uint64, data []byte)
+}
+
+// HostHook sees the calls to imported functions which return.
+type HostHook interface {
+	HostCall(idx uint32, args, results []WasmVal)
+}
+
+type hooks struct {
+	funcs FuncHook
+	instr InstrHook
+	mem   MemHook
+	host  HostHook
+}
+
+// SetHooks installs on m the hook interfaces implemented by h, nil
+// removes them all. Instances only pay for the installed ones.
+func SetHooks(m instance.Module, h interface{}) error {
+	vm, ok := m.(*vm)
+	if !ok {
+		return errors.New("not an interpreter instance")
+	}
+	vm.hooks = hooks{}
+	vm.hooks.funcs, _ = h.(FuncHook)
+	vm.hooks.instr, _ = h.(InstrHook)
+	vm.hooks.mem, _ = h.(MemHook)
+	vm.hooks.host, _ = h.(HostHook)
+	vm.observe()
+	return nil
+}
+
+func (vm *vm) enterFuncHook(f *vmFunc) {
+	bp := vm.stackSize() - len(f._type.ParamTypes)
+	args := make([]WasmVal, len(f._type.ParamTypes))
+	for i, vt := range f._type.ParamTypes {
+		args[i] = vm.getVal(bp+i, vt)
+	}
+	vm.hooks.funcs.EnterFunc(f.idx, args)
+}
+
+func (vm *vm) exitFuncHook(cf *controlFrame) {
+	top := vm.stackSize() - len(cf.bt.ResultTypes)
+	results := make([]WasmVal, len(cf.bt.ResultTypes))
+	for i, vt := range cf.bt.ResultTypes {
+		results[i] = vm.getVal(top+i, vt)
+	}
+	vm.hooks.funcs.ExitFunc(cf.fn.idx, results)
+}
+
+// whether f is imported by vm, functions of other instances may be
+// called through tables
+func (vm *vm) isImportedFunc(f instance.Function) (*vmFunc, bool) {
+	_f, ok := f.(*vmFunc)
+	return _f, ok && _f._func != nil &&
+		int(_f.idx) < len(vm.funcs) && vm.funcs[_f.idx] == _f
+}
@@ -0,0 +1,100 @@
+package interpreter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+type recorder struct {
+	events []string
+	instrs int
+}
+
+func (r *recorder) EnterFunc(idx uint32, args []WasmVal) {
+	r.events = append(r.events, fmt.Sprintf("enter %d %v", idx, args))
+}
+func (r *recorder) ExitFunc(idx uint32, results []WasmVal) {
+	r.events = append(r.events, fmt.Sprintf("exit %d %v", idx, results))
+}
+func (r *recorder) BeforeInstr(instr binary.Instruction, stack []uint64) {
+	r.instrs++
+}
+func (r *recorder) MemRead(memIdx uint32, addr uint64, data []byte) {
+	r.events = append(r.events, fmt.Sprintf("read %d %d %v", memIdx, addr, data))
+}
+func (r *recorder) MemWrite(memIdx uint32, addr uint64, data []byte) {
+	r.events = append(r.events, fmt.Sprintf("write %d %d %v", memIdx, addr, data))
+}
+func (r *recorder) HostCall(idx uint32, args, results []WasmVal) {
+	r.events = append(r.events, fmt.Sprintf("host %d %v %v", idx, args, results))
+}
+
+func TestHooks(t *testing.T) {
+	env := instance.NewNativeInstance()
+	env.RegisterFunc("log(i32)->()", func(args []WasmVal) ([]WasmVal, error) {
+		return nil, nil
+	})
+	m := newTestInstance(t, "hooks", instance.Map{"env": env})
+
+	r := &recorder{}
+	require.NoError(t, SetHooks(m, r))
+	results, err := m.InvokeFunc("main", int32(3))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(9)}, results)
+	require.Equal(t, []string{
+		"enter 2 [3]",
+		"enter 1 [3]",
+		"exit 1 [9]",
+		"write 0 8 [9 0 0 0]",
+		"read 0 8 [9 0 0 0]",
+		"host 0 [9] []",
+		"host 0 [7] []",
+		"read 0 8 [9]",
+		"exit 2 [9]",
+	}, r.events)
+	require.Equal(t, 15, r.instrs)
+	require.True(t, m.(*vm).observed)
+
+	require.NoError(t, SetHooks(m, nil))
+	require.False(t, m.(*vm).observed)
+	_, err = m.InvokeFunc("main", int32(3))
+	require.NoError(t, err)
+	require.Len(t, r.events, 9)
+	require.Equal(t, 15, r.instrs)
+}
+
+func TestHooksUnwind(t *testing.T) {
+	env := instance.NewNativeInstance()
+	env.RegisterFunc("log(i32)->()", func(args []WasmVal) ([]WasmVal, error) {
+		return nil, nil
+	})
+	m := newTestInstance(t, "hooks", instance.Map{"env": env})
+
+	// calls replaced by return_call and left by the exception exit
+	r := &recorder{}
+	require.NoError(t, SetHooks(m, r))
+	results, err := m.InvokeFunc("unwind", int32(2))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(0)}, results)
+	require.Equal(t, []string{
+		"enter 5 [2]",
+		"enter 3 [2]",
+		"exit 3 []",
+		"enter 3 [1]",
+		"exit 3 []",
+		"enter 3 [0]",
+		"exit 3 [0]",
+		"enter 4 [0]",
+		"exit 4 []",
+		"exit 5 [0]",
+	}, r.events)
+
+	r.events = nil
+	_, err = m.InvokeFunc("throw", int32(1))
+	require.Error(t, err)
+	require.Equal(t, []string{"enter 4 [1]", "exit 4 []"}, r.events)
+}
@@ -0,0 +1,90 @@
+package interpreter
+
+import "wasm.go/binary"
+
+func atomicPrefix(vm *vm, args interface{}) {
+	prefixArgs := args.(binary.PrefixArgs)
+	switch op := prefixArgs.SubOp; op {
+	case binary.AtomicFence: // every atomic access is already ordered
+	case binary.MemoryAtomicNotify:
+		count := vm.popU32()
+		offset := getOffset(vm, prefixArgs.Args)
+		vm.pushU32(AtomicNotify(getMem(vm, prefixArgs.Args), offset, count))
+	case binary.MemoryAtomicWait32:
+		timeout := vm.popS64()
+		expected := uint64(vm.popU32())
+		offset := getOffset(vm, prefixArgs.Args)
+		vm.pushU32(AtomicWait(getMem(vm, prefixArgs.Args), offset, 4, expected, timeout))
+	case binary.MemoryAtomicWait64:
+		timeout := vm.popS64()
+		expected := vm.popU64()
+		offset := getOffset(vm, prefixArgs.Args)
+		vm.pushU32(AtomicWait(getMem(vm, prefixArgs.Args), offset, 8, expected, timeout))
+	default:
+		atomicAccess(vm, op, prefixArgs.Args)
+	}
+}
+
+// loads, stores and rmw ops, narrow results are zero-extended
+func atomicAccess(vm *vm, op uint32, memArg interface{}) {
+	_, bitWidth := binary.AtomicAccess(op)
+	var f func(old uint64) uint64
+	switch (op - binary.I32AtomicLoad) / 7 {
+	case 0: // load
+	case 1: // store
+		v := vm.popU64()
+		f = func(uint64) uint64 { return v }
+	case 2: // add
+		v := vm.popU64()
+		f = func(old uint64) uint64 { return old + v }
+	case 3: // sub
+		v := vm.popU64()
+		f = func(old uint64) uint64 { return old - v }
+	case 4: // and
+		v := vm.popU64()
+		f = func(old uint64) uint64 { return old & v }
+	case 5: // or
+		v := vm.popU64()
+		f = func(old uint64) uint64 { return old | v }
+	case 6: // xor
+		v := vm.popU64()
+		f = func(old uint64) uint64 { return old ^ v }
+	case 7: // xchg
+		v := vm.popU64()
+		f = func(uint64) uint64 { return v }
+	case 8: // cmpxchg, the expected value is wrapped first
+		replacement := vm.popU64()
+		expected := vm.popU64() & (1<<bitWidth - 1)
+		f = func(old uint64) uint64 {
+			if old == expected {
+				return replacement
+			}
+			return old
+		}
+	}
+	offset := getOffset(vm, memArg)
+	old := AtomicRMW(getMem(vm, memArg), offset, bitWidth/8, f)
+	isStore := op >= binary.I32AtomicStore && op <= binary.I64AtomicStore32
+	if vm.hooks.mem != nil {
+		atomicHook(vm, memArg, offset, bitWidth/8, old, f, isStore)
+	}
+	if !isStore {
+		vm.pushU64(old)
+	}
+}
+
+// reports the value read unless storing, and the one written if any
+func atomicHook(vm *vm, memArg interface{}, offset uint64, n int,
+	old uint64, f func(old uint64) uint64, isStore bool) {
+
+	memIdx := memArg.(binary.MemArg).Mem
+	var buf [8]byte
+	if !isStore {
+		byteOrder.PutUint64(buf[:], old)
+		vm.hooks.mem.MemRead(memIdx, offset, buf[:n])
+	}
+	if f != nil {
+		byteOrder.PutUint64(buf[:], f(old))
+		vm.hooks.mem.MemWrite(memIdx, offset, buf[:n])
+	}
+}
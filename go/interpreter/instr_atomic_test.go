@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestAtomics(t *testing.T) {
+	module := decodeTestdata(t, "threads")
+	mem := NewSharedMemory(1, 1)
+	env := instance.NewNativeInstance()
+	env.Register("shared_mem", mem)
+	newInstance := func() instance.Module {
+		m, err := New(module, instance.Map{"env": env})
+		require.NoError(t, err)
+		return m
+	}
+	m := newInstance()
+	expect := func(expected instance.WasmVal, name string, args ...instance.WasmVal) {
+		results, err := m.InvokeFunc(name, args...)
+		require.NoError(t, err, name)
+		require.Equal(t, []instance.WasmVal{expected}, results, name)
+	}
+
+	// instances on separate goroutines sharing one memory
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(m instance.Module) {
+			defer wg.Done()
+			_, err := m.InvokeFunc("count", int32(0), int32(1000))
+			require.NoError(t, err)
+			_, err = m.InvokeFunc("locked_count", int32(1000))
+			require.NoError(t, err)
+		}(newInstance())
+	}
+	wg.Wait()
+	expect(int32(4000), "load", int32(0))
+	expect(int32(4000), "load", int32(12))
+
+	// narrow accesses zero-extend and wrap
+	_, err := m.InvokeFunc("store8", int32(16), int32(0x1FF))
+	require.NoError(t, err)
+	expect(int32(0xFF), "add8", int32(16), int32(2))
+	expect(int32(1), "cmpxchg8", int32(16), int32(0x101), int32(7))
+	expect(int32(7), "load8", int32(16))
+	_, err = m.InvokeFunc("store64", int32(24), int64(-1))
+	require.NoError(t, err)
+	expect(int64(0xFFFF), "sub16", int32(24), int64(0x10001))
+	expect(int64(0xFFFFFFFE), "and32", int32(24), int64(0xF0))
+	expect(int64(-0xFFFFFF10), "xor64", int32(24), int64(-1))
+	expect(int64(0x0F), "xchg8", int32(24), int64(0x102))
+	expect(int64(0xFFFFFF02), "load64", int32(24))
+	expect(int32(0), "or", int32(32), int32(5))
+
+	// alignment is checked after bounds
+	_, err = m.InvokeFunc("load", int32(2))
+	require.EqualError(t, err, "unaligned atomic")
+	_, err = m.InvokeFunc("load", int32(65535))
+	require.EqualError(t, err, "out of bounds memory access")
+
+	// wait and notify
+	expect(int32(1), "wait32", int32(0), int32(1), int64(-1))
+	expect(int32(2), "wait32", int32(0), int32(4000), int64(1000))
+	expect(int32(2), "wait64", int32(40), int64(0), int64(0))
+	expect(int32(0), "notify", int32(48), int32(1))
+	woken := make(chan []instance.WasmVal)
+	go func(m instance.Module) {
+		results, err := m.InvokeFunc("wait32", int32(48), int32(0), int64(-1))
+		require.NoError(t, err)
+		woken <- results
+	}(newInstance())
+	for {
+		results, err := m.InvokeFunc("notify", int32(48), int32(2))
+		require.NoError(t, err)
+		if results[0] == int32(1) {
+			break
+		}
+	}
+	require.Equal(t, []instance.WasmVal{int32(0)}, <-woken)
+
+	// unshared memories cannot be waited on nor imported as shared
+	require.PanicsWithError(t, "expected shared memory", func() {
+		AtomicWait(NewMemory(1, 1), 0, 4, 0, 0)
+	})
+	require.Equal(t, uint32(0), AtomicNotify(NewMemory(1, 1), 0, 1))
+	env.Register("shared_mem", NewMemory(1, 1))
+	_, err = New(module, instance.Map{"env": env})
+	require.EqualError(t, err, "incompatible import type: env.shared_mem")
+}
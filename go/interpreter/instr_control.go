@@ -1,6 +1,17 @@
 package interpreter
 
-import "wasm.go/binary"
+import (
+	"fmt"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// calls beyond these trap with errCallStackOverflow
+const (
+	maxControlDepth = 1 << 16 // frames, blocks included
+	maxStackSize    = 1 << 20 // operand slots, locals included
+)
 
 func unreachable(vm *vm, _ interface{}) {
 	panic(errTrap)
@@ -71,7 +82,7 @@ func call(vm *vm, args interface{}) {
 	callFunc(vm, f)
 }
 
-func callFunc(vm *vm, f vmFunc) {
+func callFunc(vm *vm, f *vmFunc) {
 	if f._func != nil {
 		callExternalFunc(vm, f)
 	} else {
@@ -79,30 +90,26 @@ func callFunc(vm *vm, f vmFunc) {
 	}
 }
 
-func callExternalFunc(vm *vm, f vmFunc) {
-	args := popArgs(vm, f._type)
-	results, err := f._func.Call(args...)
-	if err != nil {
-		panic(err)
-	}
-	pushResults(vm, f._type, results)
+func callExternalFunc(vm *vm, f *vmFunc) {
+	vm.callHost(hostCall{f: f._func, imported: f, ft: f._type})
 }
 
 func popArgs(vm *vm, ft binary.FuncType) []interface{} {
 	paramCount := len(ft.ParamTypes)
 	args := make([]interface{}, paramCount)
 	for i := paramCount - 1; i >= 0; i-- {
-		args[i] = wrapU64(ft.ParamTypes[i], vm.popU64())
+		args[i] = vm.popVal(ft.ParamTypes[i])
 	}
 	return args
 }
 
 func pushResults(vm *vm, ft binary.FuncType, results []interface{}) {
 	if len(ft.ResultTypes) != len(results) {
-		panic("TODO")
+		panic(fmt.Errorf("result count: %d, expected: %d",
+			len(results), len(ft.ResultTypes)))
 	}
-	for _, result := range results {
-		vm.pushU64(unwrapU64(ft.ResultTypes[0], result))
+	for i, result := range results {
+		vm.pushVal(ft.ResultTypes[i], result)
 	}
 }
 
@@ -120,42 +127,98 @@ operand stack:
 +---------------+
 |  ............ |
 */
-func callInternalFunc(vm *vm, f vmFunc) {
+func callInternalFunc(vm *vm, f *vmFunc) {
+	localCount := int(f.code.GetLocalCount())
+	if vm.controlDepth() >= maxControlDepth ||
+		vm.stackSize()+localCount > maxStackSize {
+		panic(errCallStackOverflow)
+	}
 	vm.enterBlock(binary.Call, f._type, f.code.Expr)
+	vm.topControlFrame().fn = f
+	if vm.hooks.funcs != nil {
+		vm.enterFuncHook(f)
+	}
+	if vm.coverage != nil {
+		vm.coverage.Calls[f.idx]++
+	}
 
 	// alloc locals
-	localCount := int(f.code.GetLocalCount())
 	for i := 0; i < localCount; i++ {
 		vm.pushU64(0)
 	}
 }
 
 func callIndirect(vm *vm, args interface{}) {
-	typeIdx := args.(uint32)
-	ft := vm.module.TypeSec[typeIdx]
+	f, ft := getIndirectFunc(vm, args)
+
+	// optimize internal func call
+	if _f, ok := f.(*vmFunc); ok {
+		if _f._func == nil && _f.vm == vm {
+			callInternalFunc(vm, _f)
+			return
+		}
+	}
+	callIndirectExternal(vm, f, ft, false)
+}
+
+func getIndirectFunc(vm *vm, args interface{}) (instance.Function, binary.FuncType) {
+	callArgs := args.(binary.CallIndirectArgs)
+	ft := vm.module.TypeSec[callArgs.Type]
+	table := vm.tables[callArgs.Table]
 
 	i := vm.popU32()
-	if i >= vm.table.Size() {
+	if i >= table.Size() {
 		panic(errUndefinedElem)
 	}
 
-	f := vm.table.GetElem(i)
+	f, ok := table.GetElem(i).(instance.Function)
+	if !ok {
+		panic(errUninitializedElem)
+	}
 	if f.Type().GetSignature() != ft.GetSignature() {
 		panic(errTypeMismatch)
 	}
+	return f, ft
+}
 
-	// optimize internal func call
-	if _f, ok := f.(vmFunc); ok {
+func callIndirectExternal(vm *vm, f instance.Function, ft binary.FuncType, tail bool) {
+	imported, ok := vm.isImportedFunc(f)
+	if !ok {
+		imported = nil
+	}
+	vm.callHost(hostCall{f: f, imported: imported, ft: ft, tail: tail})
+}
+
+// tail calls replace the caller's frame, so that tail recursion
+// runs in constant control and operand stack space
+func returnCall(vm *vm, args interface{}) {
+	f := vm.funcs[args.(uint32)]
+	if f._func != nil {
+		vm.callHost(hostCall{f: f._func, imported: f, ft: f._type, tail: true})
+	} else {
+		tailCallInternalFunc(vm, f)
+	}
+}
+
+func returnCallIndirect(vm *vm, args interface{}) {
+	f, ft := getIndirectFunc(vm, args)
+	if _f, ok := f.(*vmFunc); ok {
 		if _f._func == nil && _f.vm == vm {
-			callInternalFunc(vm, _f)
+			tailCallInternalFunc(vm, _f)
 			return
 		}
 	}
+	callIndirectExternal(vm, f, ft, true)
+}
 
-	fcArgs := popArgs(vm, ft)
-	results, err := f.Call(fcArgs...)
-	if err != nil {
-		panic(err)
+func tailCallInternalFunc(vm *vm, f *vmFunc) {
+	cf, labelIdx := vm.topCallFrame()
+	if vm.hooks.funcs != nil {
+		vm.hooks.funcs.ExitFunc(cf.fn.idx, nil)
+	}
+	for i := 0; i <= labelIdx; i++ {
+		vm.popControlFrame()
 	}
-	pushResults(vm, ft, results)
+	vm.moveTop(len(f._type.ParamTypes), cf.bp)
+	callInternalFunc(vm, f)
 }
@@ -0,0 +1,26 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestTailCalls(t *testing.T) {
+	m := newTestInstance(t, "tail_call", nil)
+	invoke := invoker(t, m)
+	require.Equal(t, []instance.WasmVal{int64(7034535277573963776)}, invoke("fac", int64(25)))
+
+	// non-tail calls this deep would overflow both stacks
+	n := int64(maxStackSize)
+	require.Equal(t, []instance.WasmVal{int64(0)}, invoke("fac", n))
+	require.Equal(t, []instance.WasmVal{int32(1)}, invoke("even", n))
+	require.Equal(t, []instance.WasmVal{int32(0)}, invoke("odd", n))
+	require.Equal(t, []instance.WasmVal{int32(1)}, invoke("even_ind", n))
+	require.Equal(t, []instance.WasmVal{int32(0)}, invoke("even_ind", n+1))
+
+	vm := m.(*vm)
+	require.Equal(t, 0, vm.controlDepth())
+	require.Equal(t, 0, vm.stackSize())
+}
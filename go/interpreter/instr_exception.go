@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// throw, rethrow and throw_ref panic with the exception,
+// vm.run() recovers it and unwinds to the matching handler
+
+func try(vm *vm, args interface{}) {
+	tryArgs := args.(binary.TryArgs)
+	bt := vm.module.GetBlockType(tryArgs.BT)
+	vm.enterBlock(binary.Try, bt, tryArgs.Instrs)
+	vm.topControlFrame().args = tryArgs
+}
+
+func tryTable(vm *vm, args interface{}) {
+	tryArgs := args.(binary.TryTableArgs)
+	bt := vm.module.GetBlockType(tryArgs.BT)
+	vm.enterBlock(binary.TryTable, bt, tryArgs.Instrs)
+	vm.topControlFrame().args = tryArgs
+}
+
+func throw(vm *vm, args interface{}) {
+	tag := vm.tags[args.(uint32)]
+	panic(&instance.Exception{
+		Tag:  tag,
+		Args: popResults(vm, binary.FuncType{ResultTypes: tag.Type().ParamTypes}),
+	})
+}
+
+func rethrow(vm *vm, args interface{}) {
+	cf := vm.frames[vm.controlDepth()-1-int(args.(uint32))]
+	panic(cf.exn)
+}
+
+func throwRef(vm *vm, _ interface{}) {
+	exn := vm.refs.Get(vm.popU64())
+	if exn == nil {
+		panic(errNullExnRef)
+	}
+	panic(exn.(*instance.Exception))
+}
+
+// looks for a handler of exn in the frames above base,
+// returns false if the exception is not caught there
+func (vm *vm) catchException(exn *instance.Exception, base int) bool {
+	for n := vm.controlDepth() - 1; n >= base; n-- {
+		cf := vm.frames[n]
+		switch args := cf.args.(type) {
+		case binary.TryArgs:
+			if args.Delegate != nil {
+				n -= int(*args.Delegate) // the search goes on at label l
+				continue
+			}
+			for _, h := range args.Handlers {
+				if h.CatchAll || vm.tags[h.Tag] == exn.Tag {
+					vm.catchByHandler(n, h, exn)
+					return true
+				}
+			}
+		case binary.TryTableArgs:
+			for _, c := range args.Catches {
+				if c.Kind == binary.ClauseCatch || c.Kind == binary.ClauseCatchRef {
+					if vm.tags[c.Tag] != exn.Tag {
+						continue
+					}
+				}
+				vm.unwind(n, cf.bp)
+				if c.Kind == binary.ClauseCatch || c.Kind == binary.ClauseCatchRef {
+					pushArgs(vm, exn.Tag.Type(), exn.Args)
+				}
+				if c.Kind == binary.ClauseCatchRef || c.Kind == binary.ClauseCatchAllRef {
+					vm.pushU64(vm.refs.Put(exn))
+				}
+				br(vm, c.Label)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaces the try frame n with a catch frame running h
+func (vm *vm) catchByHandler(n int, h binary.Handler, exn *instance.Exception) {
+	cf := vm.frames[n]
+	vm.unwind(n, cf.bp)
+	opcode := byte(binary.Catch_)
+	if h.CatchAll {
+		opcode = binary.CatchAll_
+	}
+	vm.enterBlock(opcode, binary.FuncType{ResultTypes: cf.bt.ResultTypes}, h.Instrs)
+	vm.topControlFrame().exn = exn
+	if !h.CatchAll {
+		pushArgs(vm, exn.Tag.Type(), exn.Args)
+	}
+}
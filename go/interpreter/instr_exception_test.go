@@ -0,0 +1,73 @@
+package interpreter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+func TestExceptions(t *testing.T) {
+	tag := NewTag(binary.ValTypeI32)
+	env := instance.NewNativeInstance()
+	env.Register("exn_i32", tag)
+	env.RegisterFunc("throw_i32(i32)->()",
+		func(args []instance.WasmVal) ([]instance.WasmVal, error) {
+			return nil, &instance.Exception{Tag: tag, Args: args}
+		})
+	m := newTestInstance(t, "exceptions", instance.Map{"env": env})
+
+	call := func(name string, args ...instance.WasmVal) ([]instance.WasmVal, error) {
+		return m.InvokeFunc(name, args...)
+	}
+	i32 := func(n int32) []instance.WasmVal {
+		return []instance.WasmVal{n}
+	}
+	expect := func(expected []instance.WasmVal, name string, args ...instance.WasmVal) {
+		results, err := call(name, args...)
+		require.NoError(t, err, name)
+		require.Equal(t, expected, results, name)
+	}
+
+	// try, catch, catch_all, delegate and rethrow
+	expect(i32(1), "try_catch", int32(0))
+	expect(i32(142), "try_catch", int32(1))
+	expect(i32(7), "catch_all")
+	expect(i32(5), "delegate")
+	expect(i32(6), "rethrow")
+	expect(i32(11), "catch_br")
+	expect(i32(4), "delegate_skip")
+
+	// try_table and throw_ref
+	expect(i32(3), "try_table", int32(3))
+	expect(i32(9), "throw_ref")
+	expect(i32(0), "catch_all_ref")
+	_, err := call("throw_null")
+	require.EqualError(t, err, "null exception reference")
+
+	// the frames and operands between throw and catch are dropped
+	expect(i32(1080), "unwind", int32(100))
+
+	// branches, returns and tail calls out of try blocks
+	expect(i32(110), "br_out", int32(1))
+	expect(i32(-2), "br_out", int32(0))
+	expect(i32(5), "tail_in_try", int32(5))
+
+	// exceptions thrown by the host carry their payload
+	expect(i32(12), "host", int32(12))
+
+	// uncaught exceptions reach the caller with their tag and payload
+	_, err = call("uncaught", int32(8))
+	var exn *instance.Exception
+	require.True(t, errors.As(err, &exn))
+	require.Equal(t, m.GetMember("e"), exn.Tag)
+	require.Equal(t, i32(8), exn.Args)
+	_, err = call("uncaught_e2")
+	require.EqualError(t, err, "uncaught exception: (i64,f64)->() [1 2.5]")
+
+	vm := m.(*vm)
+	require.Equal(t, 0, vm.controlDepth())
+	require.Equal(t, 0, vm.stackSize())
+}
@@ -3,6 +3,7 @@ package interpreter
 import (
 	gobin "encoding/binary"
 	"wasm.go/binary"
+	"wasm.go/instance"
 )
 
 var byteOrder = gobin.LittleEndian
@@ -10,28 +11,28 @@ var byteOrder = gobin.LittleEndian
 func readU8(vm *vm, memArg interface{}) byte {
 	var buf [1]byte
 	offset := getOffset(vm, memArg)
-	vm.memory.Read(offset, buf[:])
+	readMem(vm, memArg, offset, buf[:])
 	return buf[0]
 }
 
 func readU16(vm *vm, memArg interface{}) uint16 {
 	var buf [2]byte
 	offset := getOffset(vm, memArg)
-	vm.memory.Read(offset, buf[:])
+	readMem(vm, memArg, offset, buf[:])
 	return byteOrder.Uint16(buf[:])
 }
 
 func readU32(vm *vm, memArg interface{}) uint32 {
 	var buf [4]byte
 	offset := getOffset(vm, memArg)
-	vm.memory.Read(offset, buf[:])
+	readMem(vm, memArg, offset, buf[:])
 	return byteOrder.Uint32(buf[:])
 }
 
 func readU64(vm *vm, memArg interface{}) uint64 {
 	var buf [8]byte
 	offset := getOffset(vm, memArg)
-	vm.memory.Read(offset, buf[:])
+	readMem(vm, memArg, offset, buf[:])
 	return byteOrder.Uint64(buf[:])
 }
 
@@ -39,42 +40,144 @@ func writeU8(vm *vm, memArg interface{}, n byte) {
 	var buf [1]byte
 	buf[0] = n
 	offset := getOffset(vm, memArg)
-	vm.memory.Write(offset, buf[:])
+	writeMem(vm, memArg, offset, buf[:])
 }
 
 func writeU16(vm *vm, memArg interface{}, n uint16) {
 	var buf [2]byte
 	byteOrder.PutUint16(buf[:], n)
 	offset := getOffset(vm, memArg)
-	vm.memory.Write(offset, buf[:])
+	writeMem(vm, memArg, offset, buf[:])
 }
 
 func writeU32(vm *vm, memArg interface{}, n uint32) {
 	var buf [4]byte
 	byteOrder.PutUint32(buf[:], n)
 	offset := getOffset(vm, memArg)
-	vm.memory.Write(offset, buf[:])
+	writeMem(vm, memArg, offset, buf[:])
 }
 
 func writeU64(vm *vm, memArg interface{}, n uint64) {
 	var buf [8]byte
 	byteOrder.PutUint64(buf[:], n)
 	offset := getOffset(vm, memArg)
-	vm.memory.Write(offset, buf[:])
+	writeMem(vm, memArg, offset, buf[:])
 }
 
 func getOffset(vm *vm, memArg interface{}) uint64 {
 	offset := memArg.(binary.MemArg).Offset
-	return uint64(vm.popU32()) + uint64(offset)
+	addr := popAddr(vm, getMem(vm, memArg))
+	if addr+offset < addr {
+		panic(errMemOutOfBounds) // i64 addresses may overflow
+	}
+	return addr + offset
 }
 
-func memorySize(vm *vm, _ interface{}) {
-	vm.pushU32(vm.memory.Size())
+// memory64 memories take i64 addresses and sizes
+func popAddr(vm *vm, mem instance.Memory) uint64 {
+	if mem.Type().Is64 {
+		return vm.popU64()
+	}
+	return uint64(vm.popU32())
+}
+func pushAddr(vm *vm, mem instance.Memory, val uint64) {
+	if mem.Type().Is64 {
+		vm.pushU64(val)
+	} else {
+		vm.pushU32(uint32(val))
+	}
 }
 
-func memoryGrow(vm *vm, _ interface{}) {
-	oldSize := vm.memory.Grow(vm.popU32())
-	vm.pushU32(oldSize)
+// whether [offset, offset+n) exceeds size, without overflowing
+func outOfBounds(offset, n, size uint64) bool {
+	return offset > size || n > size-offset
+}
+
+func getMem(vm *vm, memArg interface{}) instance.Memory {
+	return vm.memories[memArg.(binary.MemArg).Mem]
+}
+
+// loads and stores go through these for the MemHook
+func readMem(vm *vm, memArg interface{}, offset uint64, buf []byte) {
+	getMem(vm, memArg).Read(offset, buf)
+	if vm.hooks.mem != nil {
+		vm.hooks.mem.MemRead(memArg.(binary.MemArg).Mem, offset, buf)
+	}
+}
+func writeMem(vm *vm, memArg interface{}, offset uint64, data []byte) {
+	getMem(vm, memArg).Write(offset, data)
+	if vm.hooks.mem != nil {
+		vm.hooks.mem.MemWrite(memArg.(binary.MemArg).Mem, offset, data)
+	}
+}
+
+func memorySize(vm *vm, memIdx interface{}) {
+	mem := vm.memories[memIdx.(uint32)]
+	pushAddr(vm, mem, mem.Size())
+}
+
+func memoryGrow(vm *vm, memIdx interface{}) {
+	mem := vm.memories[memIdx.(uint32)]
+	pushAddr(vm, mem, mem.Grow(popAddr(vm, mem)))
+}
+
+// bulk memory
+func memoryInit(vm *vm, args interface{}) {
+	initArgs := args.(binary.MemoryInitArgs)
+	n := uint64(vm.popU32())
+	s := uint64(vm.popU32())
+	d := popAddr(vm, vm.memories[initArgs.Mem])
+	vm.initMemory(initArgs.Mem, initArgs.Data, d, s, n)
+}
+
+func dataDrop(vm *vm, dataIdx interface{}) {
+	vm.datas[dataIdx.(uint32)] = nil
+}
+
+func memoryCopy(vm *vm, args interface{}) {
+	copyArgs := args.(binary.MemoryCopyArgs)
+	dst, src := vm.memories[copyArgs.Dst], vm.memories[copyArgs.Src]
+	var n uint64
+	if dst.Type().Is64 && src.Type().Is64 {
+		n = vm.popU64()
+	} else {
+		n = uint64(vm.popU32())
+	}
+	s := popAddr(vm, src)
+	d := popAddr(vm, dst)
+	if outOfBounds(s, n, src.Size()*binary.PageSize) ||
+		outOfBounds(d, n, dst.Size()*binary.PageSize) {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		buf := make([]byte, n)
+		src.Read(s, buf)
+		dst.Write(d, buf)
+		if vm.hooks.mem != nil {
+			vm.hooks.mem.MemRead(copyArgs.Src, s, buf)
+			vm.hooks.mem.MemWrite(copyArgs.Dst, d, buf)
+		}
+	}
+}
+
+func memoryFill(vm *vm, memIdx interface{}) {
+	mem := vm.memories[memIdx.(uint32)]
+	n := popAddr(vm, mem)
+	val := byte(vm.popU32())
+	d := popAddr(vm, mem)
+	if outOfBounds(d, n, mem.Size()*binary.PageSize) {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = val
+		}
+		mem.Write(d, buf)
+		if vm.hooks.mem != nil {
+			vm.hooks.mem.MemWrite(memIdx.(uint32), d, buf)
+		}
+	}
 }
 
 // store
@@ -1,27 +1,29 @@
 package interpreter
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"wasm.go/binary"
+	"wasm.go/instance"
 )
 
 func TestMemSizeAndGrow(t *testing.T) {
-	vm := &vm{memory: newMemory(binary.MemType{Min: 2})}
-	instrTable[binary.MemorySize](vm, nil)
+	vm := &vm{memories: []instance.Memory{newMemory(binary.MemType{Min: 2}, binary.MaxPageCount)}}
+	instrTable[binary.MemorySize](vm, uint32(0))
 	require.Equal(t, uint64(2), vm.popU64())
 
 	vm.pushU32(3)
-	instrTable[binary.MemoryGrow](vm, nil)
+	instrTable[binary.MemoryGrow](vm, uint32(0))
 	require.Equal(t, uint64(2), vm.popU64())
 
-	instrTable[binary.MemorySize](vm, nil)
+	instrTable[binary.MemorySize](vm, uint32(0))
 	require.Equal(t, uint64(5), vm.popU64())
 }
 
 func TestMemOps(t *testing.T) {
-	vm := &vm{memory: newMemory(binary.MemType{Min: 1})}
+	vm := &vm{memories: []instance.Memory{newMemory(binary.MemType{Min: 1}, binary.MaxPageCount)}}
 	testMemOp(t, vm, binary.I32Store, binary.I32Load, 0x10, 0x01, int32(100))
 	testMemOp(t, vm, binary.I64Store, binary.I64Load, 0x20, 0x02, int64(123))
 	testMemOp(t, vm, binary.F32Store, binary.F32Load, 0x30, 0x03, float32(1.5))
@@ -41,7 +43,7 @@ func TestMemOps(t *testing.T) {
 func testMemOp(t *testing.T, vm *vm, storeOp, loadOp byte,
 	offset, i uint32, val interface{}) {
 
-	memArg := binary.MemArg{Offset: offset}
+	memArg := binary.MemArg{Offset: uint64(offset)}
 
 	// store
 	vm.pushU32(i)
@@ -55,3 +57,152 @@ func testMemOp(t *testing.T, vm *vm, storeOp, loadOp byte,
 	// check
 	require.Equal(t, val, popVal(vm, val))
 }
+
+func TestBulkMemOps(t *testing.T) {
+	vm := &vm{memories: []instance.Memory{newMemory(binary.MemType{Min: 1}, binary.MaxPageCount)}}
+	vm.datas = [][]byte{[]byte("Goodbye!")}
+	buf := make([]byte, 8)
+
+	// memory.init
+	vm.pushU32(0x10)
+	vm.pushU32(4)
+	vm.pushU32(3)
+	memoryInit(vm, binary.MemoryInitArgs{})
+	vm.memories[0].Read(0x10, buf[:3])
+	require.Equal(t, "bye", string(buf[:3]))
+
+	// memory.copy
+	vm.pushU32(0x20)
+	vm.pushU32(0x10)
+	vm.pushU32(3)
+	memoryCopy(vm, binary.MemoryCopyArgs{})
+	vm.memories[0].Read(0x20, buf[:3])
+	require.Equal(t, "bye", string(buf[:3]))
+
+	// memory.fill
+	vm.pushU32(0x21)
+	vm.pushU32('!')
+	vm.pushU32(2)
+	memoryFill(vm, uint32(0))
+	vm.memories[0].Read(0x20, buf[:3])
+	require.Equal(t, "b!!", string(buf[:3]))
+
+	// data.drop
+	dataDrop(vm, uint32(0))
+	vm.pushU32(0)
+	vm.pushU32(0)
+	vm.pushU32(1)
+	require.PanicsWithValue(t, errMemOutOfBounds, func() { memoryInit(vm, binary.MemoryInitArgs{}) })
+
+	// out of bounds
+	vm.pushU32(binary.PageSize - 1)
+	vm.pushU32(0)
+	vm.pushU32(2)
+	require.PanicsWithValue(t, errMemOutOfBounds, func() { memoryFill(vm, uint32(0)) })
+}
+
+func TestMultiMemory(t *testing.T) {
+	m := newTestInstance(t, "multi_mem", nil)
+	m0 := m.GetMember("m0").(instance.Memory)
+	m1 := m.GetMember("m1").(instance.Memory)
+	require.NotSame(t, m0, m1)
+	invoke := invoker(t, m)
+	buf := make([]byte, 5)
+
+	// active segment with an explicit memory
+	require.Equal(t, []instance.WasmVal{int32('H')}, invoke("load1", int32(8)))
+	m0.Read(8, buf)
+	require.Equal(t, make([]byte, 5), buf)
+
+	// memory.copy between memories
+	invoke("copy", int32(0), int32(8), int32(5))
+	m0.Read(0, buf)
+	require.Equal(t, "Hello", string(buf))
+	invoke("store0", int32(0), int32('J'))
+	m0.Read(0, buf)
+	require.Equal(t, "Jello", string(buf))
+	require.Equal(t, []instance.WasmVal{int32('H')}, invoke("load1", int32(8)))
+
+	// memory.fill, size and grow
+	invoke("fill1", int32(9), int32('!'), int32(2))
+	m1.Read(8, buf)
+	require.Equal(t, "H!!lo", string(buf))
+	require.Equal(t, []instance.WasmVal{int32(1)}, invoke("grow1", int32(1)))
+	require.Equal(t, []instance.WasmVal{int32(-1)}, invoke("grow1", int32(1)))
+	require.Equal(t, []instance.WasmVal{int32(2)}, invoke("size1"))
+	require.Equal(t, uint64(1), m0.Size())
+}
+
+func TestMemory64(t *testing.T) {
+	m := newTestInstance(t, "memory64", nil)
+	invoke := invoker(t, m)
+	require.Equal(t, []instance.WasmVal{int32(0x04030201)}, invoke("load", int64(4)))
+	invoke("store", int64(16), int64(0x1122334455667788))
+	require.Equal(t, []instance.WasmVal{int32(0x55667788)}, invoke("load", int64(12)))
+
+	// addresses beyond 4GiB and address + offset overflows trap
+	for _, addr := range []int64{1 << 32, -4, -1} {
+		_, err := m.InvokeFunc("load", addr)
+		require.Equal(t, errMemOutOfBounds, err, addr)
+	}
+
+	// bulk ops take i64 operands, copies to an i32 memory an i32 length
+	invoke("copy_to_32", int32(0), int64(8), int32(4))
+	require.Equal(t, []instance.WasmVal{int32(0x04030201)}, invoke("load32", int32(0)))
+	invoke("fill", int64(4), int32(0xAB), int64(4))
+	require.Equal(t, []instance.WasmVal{int32(-0x54545455)}, invoke("load", int64(0)))
+	_, err := m.InvokeFunc("fill", int64(1), int32(0), int64(-1))
+	require.Equal(t, errMemOutOfBounds, err)
+
+	require.Equal(t, []instance.WasmVal{int64(1)}, invoke("size"))
+	require.Equal(t, []instance.WasmVal{int64(1)}, invoke("grow", int64(1)))
+	require.Equal(t, []instance.WasmVal{int64(-1)}, invoke("grow", int64(1<<40)))
+	require.Equal(t, []instance.WasmVal{int64(2)}, invoke("size"))
+}
+
+func TestMaxMemoryPages(t *testing.T) {
+	// a higher limit doesn't let i32 memories exceed 4GiB
+	mem := newMemory(binary.MemType{}, binary.MaxPageCount64)
+	require.Equal(t, uint64(math.MaxUint64), mem.Grow(binary.MaxPageCount+1))
+	mem = newMemory(binary.MemType{Is64: true, Min: 1}, 2)
+	require.Equal(t, uint64(1), mem.Grow(1))
+	require.Equal(t, uint64(math.MaxUint64), mem.Grow(1))
+	require.Equal(t, uint64(math.MaxUint64), NewMemory64(1, 0).Grow(binary.MaxPageCount))
+	require.PanicsWithValue(t, errMemTooLarge, func() { NewMemory(binary.MaxPageCount+1, 0) })
+
+	module := decodeTestdata(t, "memory64")
+	module.MemSec[0].Tag = 0 // no max
+
+	// the limit covers its 2 memories
+	opts := binary.DecodeOptions{MaxMemoryPages: 3}
+	m, err := NewWithOptions(module, nil, opts)
+	require.NoError(t, err)
+	results, err := m.InvokeFunc("grow", int64(1))
+	require.NoError(t, err)
+	require.Equal(t, []instance.WasmVal{int64(1)}, results)
+	results, err = m.InvokeFunc("grow", int64(1))
+	require.NoError(t, err)
+	require.Equal(t, []instance.WasmVal{int64(-1)}, results)
+
+	// other instances keep the default
+	m, err = New(module, nil)
+	require.NoError(t, err)
+	results, err = m.InvokeFunc("grow", int64(2))
+	require.NoError(t, err)
+	require.Equal(t, []instance.WasmVal{int64(1)}, results)
+
+	module.MemSec[0].Min = 3
+	_, err = NewWithOptions(module, nil, opts)
+	require.Equal(t, errMemTooLarge, err)
+
+	// the limit covers all the memories, of 1 page each here
+	module = decodeTestdata(t, "multi_mem")
+	_, err = NewWithOptions(module, nil, binary.DecodeOptions{MaxMemoryPages: 1})
+	require.Equal(t, errMemTooLarge, err)
+	m, err = NewWithOptions(module, nil, binary.DecodeOptions{MaxMemoryPages: 2})
+	require.NoError(t, err)
+	m1 := m.GetMember("m1").(instance.Memory)
+	require.Equal(t, uint64(math.MaxUint64), m1.Grow(1))
+	m1.(*memory).reset(0, nil)
+	require.Equal(t, uint64(0), m1.Grow(1))
+}
@@ -3,6 +3,8 @@ package interpreter
 import (
 	"math"
 	"math/bits"
+
+	"wasm.go/binary"
 )
 
 // const
@@ -596,30 +598,30 @@ func i64Extend32S(vm *vm, _ interface{}) {
 	vm.pushS64(int64(int32(vm.popS64())))
 }
 
-func truncSat(vm *vm, args interface{}) {
-	switch args.(byte) {
-	case 0: // i32.trunc_sat_f32_s
+func truncSat(vm *vm, subOp uint32) {
+	switch subOp {
+	case binary.I32TruncSatF32S:
 		v := truncSatS(float64(vm.popF32()), 32)
 		vm.pushS32(int32(v))
-	case 1: // i32.trunc_sat_f32_u
+	case binary.I32TruncSatF32U:
 		v := truncSatU(float64(vm.popF32()), 32)
 		vm.pushU32(uint32(v))
-	case 2: // i32.trunc_sat_f64_s
+	case binary.I32TruncSatF64S:
 		v := truncSatS(vm.popF64(), 32)
 		vm.pushS32(int32(v))
-	case 3: // i32.trunc_sat_f64_u
+	case binary.I32TruncSatF64U:
 		v := truncSatU(vm.popF64(), 32)
 		vm.pushU32(uint32(v))
-	case 4: // i64.trunc_sat_f32_s
+	case binary.I64TruncSatF32S:
 		v := truncSatS(float64(vm.popF32()), 64)
 		vm.pushS64(v)
-	case 5: // i64.trunc_sat_f32_u
+	case binary.I64TruncSatF32U:
 		v := truncSatU(float64(vm.popF32()), 64)
 		vm.pushU64(v)
-	case 6: // i64.trunc_sat_f64_s
+	case binary.I64TruncSatF64S:
 		v := truncSatS(vm.popF64(), 64)
 		vm.pushS64(v)
-	case 7: // i64.trunc_sat_f64_u
+	case binary.I64TruncSatF64U:
 		v := truncSatU(vm.popF64(), 64)
 		vm.pushU64(v)
 	default:
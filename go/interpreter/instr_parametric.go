@@ -4,14 +4,11 @@ func drop(vm *vm, _ interface{}) {
 	vm.popU64()
 }
 
+// moves v2 over v1 unless v3, so that vectors keep their high halves
 func _select(vm *vm, _ interface{}) {
-	v3 := vm.popBool()
-	v2 := vm.popU64()
-	v1 := vm.popU64()
-
-	if v3 {
-		vm.pushU64(v1)
+	if v3 := vm.popBool(); v3 {
+		vm.popU64()
 	} else {
-		vm.pushU64(v2)
+		vm.moveTop(1, vm.stackSize()-2)
 	}
 }
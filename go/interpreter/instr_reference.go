@@ -0,0 +1,14 @@
+package interpreter
+
+func refNull(vm *vm, _ interface{}) {
+	vm.pushU64(0)
+}
+
+func refIsNull(vm *vm, _ interface{}) {
+	vm.pushBool(vm.popU64() == 0)
+}
+
+func refFunc(vm *vm, args interface{}) {
+	f := vm.funcs[args.(uint32)]
+	vm.pushU64(vm.refs.Put(f))
+}
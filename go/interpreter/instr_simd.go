@@ -0,0 +1,791 @@
+package interpreter
+
+import (
+	"math"
+	"math/bits"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+type v128 = instance.V128
+
+// indexed by sub-opcode like instrTable
+var simdTable = make([]instrFn, 256)
+
+func simdPrefix(vm *vm, args interface{}) {
+	prefixArgs := args.(binary.PrefixArgs)
+	simdTable[prefixArgs.SubOp](vm, prefixArgs.Args)
+}
+
+/* lanes, size is in bytes */
+
+// lanes are read zero-extended and written truncated
+func getLane(v *v128, size, i int) uint64 {
+	switch size {
+	case 1:
+		return uint64(v[i])
+	case 2:
+		return uint64(byteOrder.Uint16(v[2*i:]))
+	case 4:
+		return uint64(byteOrder.Uint32(v[4*i:]))
+	default:
+		return byteOrder.Uint64(v[8*i:])
+	}
+}
+
+func setLane(v *v128, size, i int, x uint64) {
+	switch size {
+	case 1:
+		v[i] = byte(x)
+	case 2:
+		byteOrder.PutUint16(v[2*i:], uint16(x))
+	case 4:
+		byteOrder.PutUint32(v[4*i:], uint32(x))
+	default:
+		byteOrder.PutUint64(v[8*i:], x)
+	}
+}
+
+func sext(x uint64, size int) int64 {
+	shift := 64 - 8*size
+	return int64(x<<shift) >> shift
+}
+
+func saturateS(x int64, size int) uint64 {
+	max := int64(1)<<(8*size-1) - 1
+	if x > max {
+		return uint64(max)
+	} else if x < -max-1 {
+		return uint64(-max - 1)
+	}
+	return uint64(x)
+}
+
+func saturateU(x int64, size int) uint64 {
+	max := int64(1)<<(8*size) - 1
+	if x > max {
+		return uint64(max)
+	} else if x < 0 {
+		return 0
+	}
+	return uint64(x)
+}
+
+func b2mask(b bool) uint64 {
+	if b {
+		return math.MaxUint64
+	}
+	return 0
+}
+
+/* lane-wise op builders */
+
+func unop(size int, f func(a uint64) uint64) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var r v128
+		for i := 0; i < 16/size; i++ {
+			setLane(&r, size, i, f(getLane(&a, size, i)))
+		}
+		vm.pushV128(r)
+	}
+}
+
+func binop(size int, f func(a, b uint64) uint64) instrFn {
+	return func(vm *vm, _ interface{}) {
+		b, a := vm.popV128(), vm.popV128()
+		var r v128
+		for i := 0; i < 16/size; i++ {
+			setLane(&r, size, i, f(getLane(&a, size, i), getLane(&b, size, i)))
+		}
+		vm.pushV128(r)
+	}
+}
+
+func sunop(size int, f func(a int64) int64) instrFn {
+	return unop(size, func(a uint64) uint64 {
+		return uint64(f(sext(a, size)))
+	})
+}
+
+func sbinop(size int, f func(a, b int64) int64) instrFn {
+	return binop(size, func(a, b uint64) uint64 {
+		return uint64(f(sext(a, size), sext(b, size)))
+	})
+}
+
+func cmpop(size int, f func(a, b uint64) bool) instrFn {
+	return binop(size, func(a, b uint64) uint64 {
+		return b2mask(f(a, b))
+	})
+}
+
+func scmpop(size int, f func(a, b int64) bool) instrFn {
+	return binop(size, func(a, b uint64) uint64 {
+		return b2mask(f(sext(a, size), sext(b, size)))
+	})
+}
+
+// f32 lanes are computed in float64 like the scalar ops
+func f32unop(f func(a float64) float64) instrFn {
+	return unop(4, func(a uint64) uint64 {
+		return f32bits(float32(f(float64(math.Float32frombits(uint32(a))))))
+	})
+}
+
+func f32binop(f func(a, b float64) float64) instrFn {
+	return binop(4, func(a, b uint64) uint64 {
+		x := float64(math.Float32frombits(uint32(a)))
+		y := float64(math.Float32frombits(uint32(b)))
+		return f32bits(float32(f(x, y)))
+	})
+}
+
+func f32cmpop(f func(a, b float32) bool) instrFn {
+	return binop(4, func(a, b uint64) uint64 {
+		return b2mask(f(math.Float32frombits(uint32(a)), math.Float32frombits(uint32(b))))
+	})
+}
+
+// pseudo-min & max pick one operand as is, so NaN bits are preserved
+func f32pminmax(max bool) instrFn {
+	return binop(4, func(a, b uint64) uint64 {
+		x, y := math.Float32frombits(uint32(a)), math.Float32frombits(uint32(b))
+		if max && x < y || !max && y < x {
+			return b
+		}
+		return a
+	})
+}
+
+func f64pminmax(max bool) instrFn {
+	return binop(8, func(a, b uint64) uint64 {
+		x, y := math.Float64frombits(a), math.Float64frombits(b)
+		if max && x < y || !max && y < x {
+			return b
+		}
+		return a
+	})
+}
+
+// NaN results must be quiet, but Go passes signaling NaNs through
+func f32bits(f float32) uint64 {
+	if f != f {
+		return uint64(math.Float32bits(f) | 1<<22)
+	}
+	return uint64(math.Float32bits(f))
+}
+
+func f64bits(f float64) uint64 {
+	if math.IsNaN(f) {
+		return math.Float64bits(f) | 1<<51
+	}
+	return math.Float64bits(f)
+}
+
+func f64unop(f func(a float64) float64) instrFn {
+	return unop(8, func(a uint64) uint64 {
+		return f64bits(f(math.Float64frombits(a)))
+	})
+}
+
+func f64binop(f func(a, b float64) float64) instrFn {
+	return binop(8, func(a, b uint64) uint64 {
+		return f64bits(f(math.Float64frombits(a), math.Float64frombits(b)))
+	})
+}
+
+func f64cmpop(f func(a, b float64) bool) instrFn {
+	return binop(8, func(a, b uint64) uint64 {
+		return b2mask(f(math.Float64frombits(a), math.Float64frombits(b)))
+	})
+}
+
+// the shift count is taken modulo the lane width
+func shiftop(size int, f func(a uint64, n uint) uint64) instrFn {
+	return func(vm *vm, _ interface{}) {
+		n := uint(vm.popU32()) % uint(8*size)
+		a := vm.popV128()
+		var r v128
+		for i := 0; i < 16/size; i++ {
+			setLane(&r, size, i, f(getLane(&a, size, i), n))
+		}
+		vm.pushV128(r)
+	}
+}
+
+// result lanes are twice as wide as the lanes of the operands
+func extendop(size int, high, signed bool) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var r v128
+		n := 8 / size
+		for i := 0; i < n; i++ {
+			x := getLane(&a, size, i)
+			if high {
+				x = getLane(&a, size, i+n)
+			}
+			if signed {
+				x = uint64(sext(x, size))
+			}
+			setLane(&r, 2*size, i, x)
+		}
+		vm.pushV128(r)
+	}
+}
+
+func extmulop(size int, high, signed bool) instrFn {
+	return func(vm *vm, _ interface{}) {
+		b, a := vm.popV128(), vm.popV128()
+		var r v128
+		n := 8 / size
+		for i := 0; i < n; i++ {
+			j := i
+			if high {
+				j += n
+			}
+			x, y := getLane(&a, size, j), getLane(&b, size, j)
+			if signed {
+				x, y = uint64(sext(x, size)), uint64(sext(y, size))
+			}
+			setLane(&r, 2*size, i, x*y)
+		}
+		vm.pushV128(r)
+	}
+}
+
+func extaddPairwiseop(size int, signed bool) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var r v128
+		for i := 0; i < 8/size; i++ {
+			x, y := getLane(&a, size, 2*i), getLane(&a, size, 2*i+1)
+			if signed {
+				x, y = uint64(sext(x, size)), uint64(sext(y, size))
+			}
+			setLane(&r, 2*size, i, x+y)
+		}
+		vm.pushV128(r)
+	}
+}
+
+// the lanes of both operands are narrowed with signed saturation
+func narrowop(size int, saturate func(x int64, size int) uint64) instrFn {
+	return func(vm *vm, _ interface{}) {
+		b, a := vm.popV128(), vm.popV128()
+		var r v128
+		n := 16 / size
+		for i := 0; i < n; i++ {
+			setLane(&r, size/2, i, saturate(sext(getLane(&a, size, i), size), size/2))
+			setLane(&r, size/2, i+n, saturate(sext(getLane(&b, size, i), size), size/2))
+		}
+		vm.pushV128(r)
+	}
+}
+
+func allTrue(size int) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		for i := 0; i < 16/size; i++ {
+			if getLane(&a, size, i) == 0 {
+				vm.pushBool(false)
+				return
+			}
+		}
+		vm.pushBool(true)
+	}
+}
+
+func bitmask(size int) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var mask uint32
+		for i := 0; i < 16/size; i++ {
+			if sext(getLane(&a, size, i), size) < 0 {
+				mask |= 1 << i
+			}
+		}
+		vm.pushU32(mask)
+	}
+}
+
+// splats pop the scalar as is, setLane truncates it
+func splat(size int) instrFn {
+	return func(vm *vm, _ interface{}) {
+		x := vm.popU64()
+		var r v128
+		for i := 0; i < 16/size; i++ {
+			setLane(&r, size, i, x)
+		}
+		vm.pushV128(r)
+	}
+}
+
+func extractLane(size int, signed bool) instrFn {
+	return func(vm *vm, args interface{}) {
+		a := vm.popV128()
+		x := getLane(&a, size, int(args.(byte)))
+		if signed {
+			x = uint64(uint32(sext(x, size)))
+		}
+		vm.pushU64(x)
+	}
+}
+
+func replaceLane(size int) instrFn {
+	return func(vm *vm, args interface{}) {
+		x := vm.popU64()
+		a := vm.popV128()
+		setLane(&a, size, int(args.(byte)), x)
+		vm.pushV128(a)
+	}
+}
+
+/* loads & stores */
+
+func v128Load(vm *vm, memArg interface{}) {
+	var r v128
+	offset := getOffset(vm, memArg)
+	readMem(vm, memArg, offset, r[:])
+	vm.pushV128(r)
+}
+
+func v128Store(vm *vm, memArg interface{}) {
+	a := vm.popV128()
+	offset := getOffset(vm, memArg)
+	writeMem(vm, memArg, offset, a[:])
+}
+
+// loads 8 bytes and extends each lane to twice its size
+func loadExtend(size int, signed bool) instrFn {
+	return func(vm *vm, memArg interface{}) {
+		var a, r v128
+		offset := getOffset(vm, memArg)
+		readMem(vm, memArg, offset, a[:8])
+		for i := 0; i < 8/size; i++ {
+			x := getLane(&a, size, i)
+			if signed {
+				x = uint64(sext(x, size))
+			}
+			setLane(&r, 2*size, i, x)
+		}
+		vm.pushV128(r)
+	}
+}
+
+func loadSplat(size int) instrFn {
+	return func(vm *vm, memArg interface{}) {
+		var a, r v128
+		offset := getOffset(vm, memArg)
+		readMem(vm, memArg, offset, a[:size])
+		for i := 0; i < 16/size; i++ {
+			copy(r[i*size:], a[:size])
+		}
+		vm.pushV128(r)
+	}
+}
+
+func loadZero(size int) instrFn {
+	return func(vm *vm, memArg interface{}) {
+		var r v128
+		offset := getOffset(vm, memArg)
+		readMem(vm, memArg, offset, r[:size])
+		vm.pushV128(r)
+	}
+}
+
+func loadLane(size int) instrFn {
+	return func(vm *vm, args interface{}) {
+		laneArgs := args.(binary.MemLaneArgs)
+		a := vm.popV128()
+		offset := getOffset(vm, laneArgs.MemArg)
+		lane := int(laneArgs.Lane)
+		readMem(vm, laneArgs.MemArg, offset, a[lane*size:(lane+1)*size])
+		vm.pushV128(a)
+	}
+}
+
+func storeLane(size int) instrFn {
+	return func(vm *vm, args interface{}) {
+		laneArgs := args.(binary.MemLaneArgs)
+		a := vm.popV128()
+		offset := getOffset(vm, laneArgs.MemArg)
+		lane := int(laneArgs.Lane)
+		writeMem(vm, laneArgs.MemArg, offset, a[lane*size:(lane+1)*size])
+	}
+}
+
+/* the rest */
+
+func v128Const(vm *vm, args interface{}) {
+	vm.pushV128(args.([16]byte))
+}
+
+func i8x16Shuffle(vm *vm, args interface{}) {
+	b, a := vm.popV128(), vm.popV128()
+	var r v128
+	for i, lane := range args.([16]byte) {
+		if lane < 16 {
+			r[i] = a[lane]
+		} else {
+			r[i] = b[lane-16]
+		}
+	}
+	vm.pushV128(r)
+}
+
+func i8x16Swizzle(vm *vm, _ interface{}) {
+	b, a := vm.popV128(), vm.popV128()
+	var r v128
+	for i, lane := range b {
+		if lane < 16 {
+			r[i] = a[lane]
+		}
+	}
+	vm.pushV128(r)
+}
+
+func v128Bitselect(vm *vm, _ interface{}) {
+	c, b, a := vm.popV128(), vm.popV128(), vm.popV128()
+	var r v128
+	for i := range r {
+		r[i] = a[i]&c[i] | b[i]&^c[i]
+	}
+	vm.pushV128(r)
+}
+
+func v128AnyTrue(vm *vm, _ interface{}) {
+	a := vm.popV128()
+	vm.pushBool(a != v128{})
+}
+
+// float lanes converted to half as many int32 lanes, the rest is zero
+func truncSatZero(signed bool) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var r v128
+		for i := 0; i < 2; i++ {
+			z := math.Float64frombits(getLane(&a, 8, i))
+			if signed {
+				setLane(&r, 4, i, uint64(truncSatS(z, 32)))
+			} else {
+				setLane(&r, 4, i, truncSatU(z, 32))
+			}
+		}
+		vm.pushV128(r)
+	}
+}
+
+func f64x2ConvertLow(signed bool) instrFn {
+	return func(vm *vm, _ interface{}) {
+		a := vm.popV128()
+		var r v128
+		for i := 0; i < 2; i++ {
+			x := getLane(&a, 4, i)
+			f := float64(uint32(x))
+			if signed {
+				f = float64(int32(x))
+			}
+			setLane(&r, 8, i, math.Float64bits(f))
+		}
+		vm.pushV128(r)
+	}
+}
+
+func f32x4DemoteF64x2Zero(vm *vm, _ interface{}) {
+	a := vm.popV128()
+	var r v128
+	for i := 0; i < 2; i++ {
+		f := float32(math.Float64frombits(getLane(&a, 8, i)))
+		setLane(&r, 4, i, f32bits(f))
+	}
+	vm.pushV128(r)
+}
+
+func f64x2PromoteLowF32x4(vm *vm, _ interface{}) {
+	a := vm.popV128()
+	var r v128
+	for i := 0; i < 2; i++ {
+		f := float64(math.Float32frombits(uint32(getLane(&a, 4, i))))
+		setLane(&r, 8, i, math.Float64bits(f))
+	}
+	vm.pushV128(r)
+}
+
+// NaNs propagate, -0 is less than +0
+func fmin(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return a + b
+	}
+	return math.Min(a, b)
+}
+
+func fmax(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return a + b
+	}
+	return math.Max(a, b)
+}
+
+func init() {
+	simdTable[binary.V128Load] = v128Load
+	simdTable[binary.V128Load8x8S] = loadExtend(1, true)
+	simdTable[binary.V128Load8x8U] = loadExtend(1, false)
+	simdTable[binary.V128Load16x4S] = loadExtend(2, true)
+	simdTable[binary.V128Load16x4U] = loadExtend(2, false)
+	simdTable[binary.V128Load32x2S] = loadExtend(4, true)
+	simdTable[binary.V128Load32x2U] = loadExtend(4, false)
+	simdTable[binary.V128Load8Splat] = loadSplat(1)
+	simdTable[binary.V128Load16Splat] = loadSplat(2)
+	simdTable[binary.V128Load32Splat] = loadSplat(4)
+	simdTable[binary.V128Load64Splat] = loadSplat(8)
+	simdTable[binary.V128Store] = v128Store
+	simdTable[binary.V128Const] = v128Const
+	simdTable[binary.I8x16Shuffle] = i8x16Shuffle
+	simdTable[binary.I8x16Swizzle] = i8x16Swizzle
+	simdTable[binary.I8x16Splat] = splat(1)
+	simdTable[binary.I16x8Splat] = splat(2)
+	simdTable[binary.I32x4Splat] = splat(4)
+	simdTable[binary.I64x2Splat] = splat(8)
+	simdTable[binary.F32x4Splat] = splat(4)
+	simdTable[binary.F64x2Splat] = splat(8)
+	simdTable[binary.I8x16ExtractLaneS] = extractLane(1, true)
+	simdTable[binary.I8x16ExtractLaneU] = extractLane(1, false)
+	simdTable[binary.I8x16ReplaceLane] = replaceLane(1)
+	simdTable[binary.I16x8ExtractLaneS] = extractLane(2, true)
+	simdTable[binary.I16x8ExtractLaneU] = extractLane(2, false)
+	simdTable[binary.I16x8ReplaceLane] = replaceLane(2)
+	simdTable[binary.I32x4ExtractLane] = extractLane(4, false)
+	simdTable[binary.I32x4ReplaceLane] = replaceLane(4)
+	simdTable[binary.I64x2ExtractLane] = extractLane(8, false)
+	simdTable[binary.I64x2ReplaceLane] = replaceLane(8)
+	simdTable[binary.F32x4ExtractLane] = extractLane(4, false)
+	simdTable[binary.F32x4ReplaceLane] = replaceLane(4)
+	simdTable[binary.F64x2ExtractLane] = extractLane(8, false)
+	simdTable[binary.F64x2ReplaceLane] = replaceLane(8)
+
+	// comparisons
+	for size, op := range map[int]uint32{1: binary.I8x16Eq, 2: binary.I16x8Eq, 4: binary.I32x4Eq} {
+		simdTable[op] = cmpop(size, func(a, b uint64) bool { return a == b })
+		simdTable[op+1] = cmpop(size, func(a, b uint64) bool { return a != b })
+		simdTable[op+2] = scmpop(size, func(a, b int64) bool { return a < b })
+		simdTable[op+3] = cmpop(size, func(a, b uint64) bool { return a < b })
+		simdTable[op+4] = scmpop(size, func(a, b int64) bool { return a > b })
+		simdTable[op+5] = cmpop(size, func(a, b uint64) bool { return a > b })
+		simdTable[op+6] = scmpop(size, func(a, b int64) bool { return a <= b })
+		simdTable[op+7] = cmpop(size, func(a, b uint64) bool { return a <= b })
+		simdTable[op+8] = scmpop(size, func(a, b int64) bool { return a >= b })
+		simdTable[op+9] = cmpop(size, func(a, b uint64) bool { return a >= b })
+	}
+	simdTable[binary.I64x2Eq] = cmpop(8, func(a, b uint64) bool { return a == b })
+	simdTable[binary.I64x2Ne] = cmpop(8, func(a, b uint64) bool { return a != b })
+	simdTable[binary.I64x2LtS] = scmpop(8, func(a, b int64) bool { return a < b })
+	simdTable[binary.I64x2GtS] = scmpop(8, func(a, b int64) bool { return a > b })
+	simdTable[binary.I64x2LeS] = scmpop(8, func(a, b int64) bool { return a <= b })
+	simdTable[binary.I64x2GeS] = scmpop(8, func(a, b int64) bool { return a >= b })
+	simdTable[binary.F32x4Eq] = f32cmpop(func(a, b float32) bool { return a == b })
+	simdTable[binary.F32x4Ne] = f32cmpop(func(a, b float32) bool { return a != b })
+	simdTable[binary.F32x4Lt] = f32cmpop(func(a, b float32) bool { return a < b })
+	simdTable[binary.F32x4Gt] = f32cmpop(func(a, b float32) bool { return a > b })
+	simdTable[binary.F32x4Le] = f32cmpop(func(a, b float32) bool { return a <= b })
+	simdTable[binary.F32x4Ge] = f32cmpop(func(a, b float32) bool { return a >= b })
+	simdTable[binary.F64x2Eq] = f64cmpop(func(a, b float64) bool { return a == b })
+	simdTable[binary.F64x2Ne] = f64cmpop(func(a, b float64) bool { return a != b })
+	simdTable[binary.F64x2Lt] = f64cmpop(func(a, b float64) bool { return a < b })
+	simdTable[binary.F64x2Gt] = f64cmpop(func(a, b float64) bool { return a > b })
+	simdTable[binary.F64x2Le] = f64cmpop(func(a, b float64) bool { return a <= b })
+	simdTable[binary.F64x2Ge] = f64cmpop(func(a, b float64) bool { return a >= b })
+
+	// bitwise
+	simdTable[binary.V128Not] = unop(8, func(a uint64) uint64 { return ^a })
+	simdTable[binary.V128And] = binop(8, func(a, b uint64) uint64 { return a & b })
+	simdTable[binary.V128Andnot] = binop(8, func(a, b uint64) uint64 { return a &^ b })
+	simdTable[binary.V128Or] = binop(8, func(a, b uint64) uint64 { return a | b })
+	simdTable[binary.V128Xor] = binop(8, func(a, b uint64) uint64 { return a ^ b })
+	simdTable[binary.V128Bitselect] = v128Bitselect
+	simdTable[binary.V128AnyTrue] = v128AnyTrue
+
+	simdTable[binary.V128Load8Lane] = loadLane(1)
+	simdTable[binary.V128Load16Lane] = loadLane(2)
+	simdTable[binary.V128Load32Lane] = loadLane(4)
+	simdTable[binary.V128Load64Lane] = loadLane(8)
+	simdTable[binary.V128Store8Lane] = storeLane(1)
+	simdTable[binary.V128Store16Lane] = storeLane(2)
+	simdTable[binary.V128Store32Lane] = storeLane(4)
+	simdTable[binary.V128Store64Lane] = storeLane(8)
+	simdTable[binary.V128Load32Zero] = loadZero(4)
+	simdTable[binary.V128Load64Zero] = loadZero(8)
+
+	// integer arithmetic shared by all shapes
+	for size, ops := range map[int][]uint32{
+		1: {binary.I8x16Abs, binary.I8x16Neg, binary.I8x16AllTrue, binary.I8x16Bitmask,
+			binary.I8x16Shl, binary.I8x16ShrS, binary.I8x16ShrU, binary.I8x16Add, binary.I8x16Sub},
+		2: {binary.I16x8Abs, binary.I16x8Neg, binary.I16x8AllTrue, binary.I16x8Bitmask,
+			binary.I16x8Shl, binary.I16x8ShrS, binary.I16x8ShrU, binary.I16x8Add, binary.I16x8Sub},
+		4: {binary.I32x4Abs, binary.I32x4Neg, binary.I32x4AllTrue, binary.I32x4Bitmask,
+			binary.I32x4Shl, binary.I32x4ShrS, binary.I32x4ShrU, binary.I32x4Add, binary.I32x4Sub},
+		8: {binary.I64x2Abs, binary.I64x2Neg, binary.I64x2AllTrue, binary.I64x2Bitmask,
+			binary.I64x2Shl, binary.I64x2ShrS, binary.I64x2ShrU, binary.I64x2Add, binary.I64x2Sub},
+	} {
+		size := size
+		simdTable[ops[0]] = sunop(size, func(a int64) int64 {
+			if a < 0 {
+				return -a
+			}
+			return a
+		})
+		simdTable[ops[1]] = unop(size, func(a uint64) uint64 { return -a })
+		simdTable[ops[2]] = allTrue(size)
+		simdTable[ops[3]] = bitmask(size)
+		simdTable[ops[4]] = shiftop(size, func(a uint64, n uint) uint64 { return a << n })
+		simdTable[ops[5]] = shiftop(size, func(a uint64, n uint) uint64 { return uint64(sext(a, size) >> n) })
+		simdTable[ops[6]] = shiftop(size, func(a uint64, n uint) uint64 { return a >> n })
+		simdTable[ops[7]] = binop(size, func(a, b uint64) uint64 { return a + b })
+		simdTable[ops[8]] = binop(size, func(a, b uint64) uint64 { return a - b })
+	}
+	simdTable[binary.I16x8Mul] = binop(2, func(a, b uint64) uint64 { return a * b })
+	simdTable[binary.I32x4Mul] = binop(4, func(a, b uint64) uint64 { return a * b })
+	simdTable[binary.I64x2Mul] = binop(8, func(a, b uint64) uint64 { return a * b })
+
+	// saturating arithmetic, min, max & avgr of i8x16 & i16x8, i32x4 has no
+	// saturating ops nor avgr_u
+	for size, ops := range map[int][]uint32{
+		1: {binary.I8x16AddSatS, binary.I8x16AddSatU, binary.I8x16SubSatS, binary.I8x16SubSatU,
+			binary.I8x16MinS, binary.I8x16MinU, binary.I8x16MaxS, binary.I8x16MaxU, binary.I8x16AvgrU},
+		2: {binary.I16x8AddSatS, binary.I16x8AddSatU, binary.I16x8SubSatS, binary.I16x8SubSatU,
+			binary.I16x8MinS, binary.I16x8MinU, binary.I16x8MaxS, binary.I16x8MaxU, binary.I16x8AvgrU},
+		4: {0, 0, 0, 0, binary.I32x4MinS, binary.I32x4MinU, binary.I32x4MaxS, binary.I32x4MaxU, 0},
+	} {
+		size := size
+		if size < 4 {
+			simdTable[ops[0]] = sbinop(size, func(a, b int64) int64 { return int64(saturateS(a+b, size)) })
+			simdTable[ops[1]] = binop(size, func(a, b uint64) uint64 { return saturateU(int64(a+b), size) })
+			simdTable[ops[2]] = sbinop(size, func(a, b int64) int64 { return int64(saturateS(a-b, size)) })
+			simdTable[ops[3]] = binop(size, func(a, b uint64) uint64 { return saturateU(int64(a)-int64(b), size) })
+			simdTable[ops[8]] = binop(size, func(a, b uint64) uint64 { return (a + b + 1) / 2 })
+		}
+		simdTable[ops[4]] = sbinop(size, func(a, b int64) int64 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		simdTable[ops[5]] = binop(size, func(a, b uint64) uint64 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		simdTable[ops[6]] = sbinop(size, func(a, b int64) int64 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+		simdTable[ops[7]] = binop(size, func(a, b uint64) uint64 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+	}
+	simdTable[binary.I8x16Popcnt] = unop(1, func(a uint64) uint64 {
+		return uint64(bits.OnesCount8(uint8(a)))
+	})
+	simdTable[binary.I16x8Q15mulrSatS] = sbinop(2, func(a, b int64) int64 {
+		return int64(saturateS((a*b+0x4000)>>15, 2))
+	})
+	simdTable[binary.I32x4DotI16x8S] = func(vm *vm, _ interface{}) {
+		b, a := vm.popV128(), vm.popV128()
+		var r v128
+		for i := 0; i < 4; i++ {
+			x := sext(getLane(&a, 2, 2*i), 2) * sext(getLane(&b, 2, 2*i), 2)
+			y := sext(getLane(&a, 2, 2*i+1), 2) * sext(getLane(&b, 2, 2*i+1), 2)
+			setLane(&r, 4, i, uint64(x+y))
+		}
+		vm.pushV128(r)
+	}
+
+	// narrowing & widening
+	simdTable[binary.I8x16NarrowI16x8S] = narrowop(2, saturateS)
+	simdTable[binary.I8x16NarrowI16x8U] = narrowop(2, saturateU)
+	simdTable[binary.I16x8NarrowI32x4S] = narrowop(4, saturateS)
+	simdTable[binary.I16x8NarrowI32x4U] = narrowop(4, saturateU)
+	for size, op := range map[int]uint32{
+		1: binary.I16x8ExtendLowI8x16S,
+		2: binary.I32x4ExtendLowI16x8S,
+		4: binary.I64x2ExtendLowI32x4S,
+	} {
+		simdTable[op] = extendop(size, false, true)
+		simdTable[op+1] = extendop(size, true, true)
+		simdTable[op+2] = extendop(size, false, false)
+		simdTable[op+3] = extendop(size, true, false)
+	}
+	for size, op := range map[int]uint32{
+		1: binary.I16x8ExtmulLowI8x16S,
+		2: binary.I32x4ExtmulLowI16x8S,
+		4: binary.I64x2ExtmulLowI32x4S,
+	} {
+		simdTable[op] = extmulop(size, false, true)
+		simdTable[op+1] = extmulop(size, true, true)
+		simdTable[op+2] = extmulop(size, false, false)
+		simdTable[op+3] = extmulop(size, true, false)
+	}
+	simdTable[binary.I16x8ExtaddPairwiseI8x16S] = extaddPairwiseop(1, true)
+	simdTable[binary.I16x8ExtaddPairwiseI8x16U] = extaddPairwiseop(1, false)
+	simdTable[binary.I32x4ExtaddPairwiseI16x8S] = extaddPairwiseop(2, true)
+	simdTable[binary.I32x4ExtaddPairwiseI16x8U] = extaddPairwiseop(2, false)
+
+	// float arithmetic
+	simdTable[binary.F32x4Abs] = unop(4, func(a uint64) uint64 { return a &^ (1 << 31) })
+	simdTable[binary.F32x4Neg] = unop(4, func(a uint64) uint64 { return a ^ 1<<31 })
+	simdTable[binary.F32x4Sqrt] = f32unop(math.Sqrt)
+	simdTable[binary.F32x4Ceil] = f32unop(math.Ceil)
+	simdTable[binary.F32x4Floor] = f32unop(math.Floor)
+	simdTable[binary.F32x4Trunc] = f32unop(math.Trunc)
+	simdTable[binary.F32x4Nearest] = f32unop(math.RoundToEven)
+	simdTable[binary.F32x4Add] = f32binop(func(a, b float64) float64 { return a + b })
+	simdTable[binary.F32x4Sub] = f32binop(func(a, b float64) float64 { return a - b })
+	simdTable[binary.F32x4Mul] = f32binop(func(a, b float64) float64 { return a * b })
+	simdTable[binary.F32x4Div] = f32binop(func(a, b float64) float64 { return a / b })
+	simdTable[binary.F32x4Min] = f32binop(fmin)
+	simdTable[binary.F32x4Max] = f32binop(fmax)
+	simdTable[binary.F32x4Pmin] = f32pminmax(false)
+	simdTable[binary.F32x4Pmax] = f32pminmax(true)
+	simdTable[binary.F64x2Abs] = unop(8, func(a uint64) uint64 { return a &^ (1 << 63) })
+	simdTable[binary.F64x2Neg] = unop(8, func(a uint64) uint64 { return a ^ 1<<63 })
+	simdTable[binary.F64x2Sqrt] = f64unop(math.Sqrt)
+	simdTable[binary.F64x2Ceil] = f64unop(math.Ceil)
+	simdTable[binary.F64x2Floor] = f64unop(math.Floor)
+	simdTable[binary.F64x2Trunc] = f64unop(math.Trunc)
+	simdTable[binary.F64x2Nearest] = f64unop(math.RoundToEven)
+	simdTable[binary.F64x2Add] = f64binop(func(a, b float64) float64 { return a + b })
+	simdTable[binary.F64x2Sub] = f64binop(func(a, b float64) float64 { return a - b })
+	simdTable[binary.F64x2Mul] = f64binop(func(a, b float64) float64 { return a * b })
+	simdTable[binary.F64x2Div] = f64binop(func(a, b float64) float64 { return a / b })
+	simdTable[binary.F64x2Min] = f64binop(fmin)
+	simdTable[binary.F64x2Max] = f64binop(fmax)
+	simdTable[binary.F64x2Pmin] = f64pminmax(false)
+	simdTable[binary.F64x2Pmax] = f64pminmax(true)
+
+	// conversions
+	simdTable[binary.I32x4TruncSatF32x4S] = unop(4, func(a uint64) uint64 {
+		return uint64(truncSatS(float64(math.Float32frombits(uint32(a))), 32))
+	})
+	simdTable[binary.I32x4TruncSatF32x4U] = unop(4, func(a uint64) uint64 {
+		return truncSatU(float64(math.Float32frombits(uint32(a))), 32)
+	})
+	simdTable[binary.F32x4ConvertI32x4S] = unop(4, func(a uint64) uint64 {
+		return uint64(math.Float32bits(float32(int32(a))))
+	})
+	simdTable[binary.F32x4ConvertI32x4U] = unop(4, func(a uint64) uint64 {
+		return uint64(math.Float32bits(float32(uint32(a))))
+	})
+	simdTable[binary.I32x4TruncSatF64x2SZero] = truncSatZero(true)
+	simdTable[binary.I32x4TruncSatF64x2UZero] = truncSatZero(false)
+	simdTable[binary.F64x2ConvertLowI32x4S] = f64x2ConvertLow(true)
+	simdTable[binary.F64x2ConvertLowI32x4U] = f64x2ConvertLow(false)
+	simdTable[binary.F32x4DemoteF64x2Zero] = f32x4DemoteF64x2Zero
+	simdTable[binary.F64x2PromoteLowF32x4] = f64x2PromoteLowF32x4
+}
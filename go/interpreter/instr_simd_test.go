@@ -0,0 +1,169 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/validator"
+)
+
+// commands of the simd_*.wast spec tests, converted by wast2json
+type specCommand struct {
+	Type   string
+	Wasm   string
+	Name   string
+	Text   string
+	Action struct {
+		Field string
+		Args  []specVal
+	}
+	Expected []specVal
+}
+
+// scalars are decimal bit patterns, vectors are decimal lanes
+type specVal struct {
+	Type     string
+	LaneType string `json:"lane_type"`
+	Value    json.RawMessage
+}
+
+func TestSimdSpec(t *testing.T) {
+	f, err := os.Open("testdata/simd.json.gz")
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	var cmds []specCommand
+	require.NoError(t, json.NewDecoder(r).Decode(&cmds))
+
+	var m instance.Module
+	for _, cmd := range cmds {
+		if cmd.Type == "module" {
+			data, err := base64.StdEncoding.DecodeString(cmd.Wasm)
+			require.NoError(t, err)
+			module, err := binary.Decode(data)
+			require.NoError(t, err, cmd.Name)
+			require.NoError(t, validator.Validate(module), cmd.Name)
+			m, err = New(module, nil)
+			require.NoError(t, err, cmd.Name)
+			continue
+		}
+
+		args := make([]instance.WasmVal, len(cmd.Action.Args))
+		for i, arg := range cmd.Action.Args {
+			args[i] = specArg(t, arg)
+		}
+		results, err := m.InvokeFunc(cmd.Action.Field, args...)
+		if cmd.Type == "assert_trap" {
+			require.EqualError(t, err, cmd.Text, cmd.Action.Field)
+			continue
+		}
+		require.NoError(t, err, cmd.Action.Field)
+		require.Len(t, results, len(cmd.Expected), cmd.Action.Field)
+		for i, expected := range cmd.Expected {
+			checkSpecResult(t, cmd.Action.Field, expected, results[i])
+		}
+	}
+}
+
+func specBits(t *testing.T, s string) uint64 {
+	if strings.HasPrefix(s, "-") {
+		n, err := strconv.ParseInt(s, 10, 64)
+		require.NoError(t, err)
+		return uint64(n)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	require.NoError(t, err)
+	return n
+}
+
+func specLaneSize(laneType string) int {
+	switch laneType {
+	case "i8":
+		return 1
+	case "i16":
+		return 2
+	case "i32", "f32":
+		return 4
+	default:
+		return 8
+	}
+}
+
+func specArg(t *testing.T, arg specVal) instance.WasmVal {
+	if arg.Type == "v128" {
+		var lanes []string
+		require.NoError(t, json.Unmarshal(arg.Value, &lanes))
+		var v instance.V128
+		for i, lane := range lanes {
+			setLane(&v, specLaneSize(arg.LaneType), i, specBits(t, lane))
+		}
+		return v
+	}
+	var s string
+	require.NoError(t, json.Unmarshal(arg.Value, &s))
+	bits := specBits(t, s)
+	switch arg.Type {
+	case "i32":
+		return int32(bits)
+	case "i64":
+		return int64(bits)
+	case "f32":
+		return math.Float32frombits(uint32(bits))
+	default:
+		return math.Float64frombits(bits)
+	}
+}
+
+func checkSpecResult(t *testing.T, name string, expected specVal, result instance.WasmVal) {
+	if expected.Type != "v128" {
+		var s string
+		require.NoError(t, json.Unmarshal(expected.Value, &s))
+		switch x := result.(type) {
+		case float32:
+			checkSpecLane(t, name, s, 4, uint64(math.Float32bits(x)))
+		case float64:
+			checkSpecLane(t, name, s, 8, math.Float64bits(x))
+		default:
+			require.Equal(t, specArg(t, expected), result, name)
+		}
+		return
+	}
+
+	var lanes []string
+	require.NoError(t, json.Unmarshal(expected.Value, &lanes))
+	v, ok := result.(instance.V128)
+	require.True(t, ok, name)
+	size := specLaneSize(expected.LaneType)
+	for i, lane := range lanes {
+		checkSpecLane(t, name, lane, size, getLane(&v, size, i))
+	}
+}
+
+// NaNs are only checked for being canonical or arithmetic
+func checkSpecLane(t *testing.T, name, expected string, size int, bits uint64) {
+	quiet := uint64(1) << 22
+	if size == 8 {
+		quiet = 1 << 51
+	}
+	mantissa := quiet<<1 - 1
+	sign := uint64(1) << (8*size - 1)
+	exponent := sign - 1 - mantissa
+	switch expected {
+	case "nan:canonical":
+		require.Equal(t, exponent|quiet, bits&^sign, name)
+	case "nan:arithmetic":
+		require.Equal(t, exponent|quiet, bits&(exponent|quiet), name)
+	default:
+		require.Equal(t, specBits(t, expected)&(sign|(sign-1)), bits, name)
+	}
+}
@@ -0,0 +1,72 @@
+package interpreter
+
+import "wasm.go/binary"
+
+func tableGet(vm *vm, tableIdx interface{}) {
+	i := vm.popU32()
+	elem := vm.tables[tableIdx.(uint32)].GetElem(i)
+	vm.pushU64(vm.refs.Put(elem))
+}
+
+func tableSet(vm *vm, tableIdx interface{}) {
+	ref := vm.popU64()
+	i := vm.popU32()
+	vm.tables[tableIdx.(uint32)].SetElem(i, vm.refs.Get(ref))
+}
+
+func tableSize(vm *vm, tableIdx interface{}) {
+	vm.pushU32(vm.tables[tableIdx.(uint32)].Size())
+}
+
+func tableGrow(vm *vm, tableIdx interface{}) {
+	n := vm.popU32()
+	ref := vm.popU64()
+	vm.pushU32(vm.tables[tableIdx.(uint32)].Grow(n, vm.refs.Get(ref)))
+}
+
+func tableFill(vm *vm, tableIdx interface{}) {
+	table := vm.tables[tableIdx.(uint32)]
+	n := vm.popU32()
+	ref := vm.popU64()
+	i := vm.popU32()
+	if uint64(i)+uint64(n) > uint64(table.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	elem := vm.refs.Get(ref)
+	for j := uint32(0); j < n; j++ {
+		table.SetElem(i+j, elem)
+	}
+}
+
+func tableInit(vm *vm, args interface{}) {
+	initArgs := args.(binary.TableInitArgs)
+	n := vm.popU32()
+	s := vm.popU32()
+	d := vm.popU32()
+	vm.initTableElems(initArgs.Table, initArgs.Elem, d, s, n)
+}
+
+func elemDrop(vm *vm, elemIdx interface{}) {
+	vm.elems[elemIdx.(uint32)] = nil
+}
+
+func tableCopy(vm *vm, args interface{}) {
+	copyArgs := args.(binary.TableCopyArgs)
+	dst, src := vm.tables[copyArgs.Dst], vm.tables[copyArgs.Src]
+	n := vm.popU32()
+	s := vm.popU32()
+	d := vm.popU32()
+	if uint64(s)+uint64(n) > uint64(src.Size()) ||
+		uint64(d)+uint64(n) > uint64(dst.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	if d <= s {
+		for i := uint32(0); i < n; i++ {
+			dst.SetElem(d+i, src.GetElem(s+i))
+		}
+	} else {
+		for i := n; i > 0; i-- {
+			dst.SetElem(d+i-1, src.GetElem(s+i-1))
+		}
+	}
+}
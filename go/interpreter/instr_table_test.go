@@ -0,0 +1,74 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+func TestTableBulkOps(t *testing.T) {
+	m := newTestInstance(t, "bulk_table", nil)
+
+	call := func(name string, args ...instance.WasmVal) ([]instance.WasmVal, error) {
+		return m.InvokeFunc(name, args...)
+	}
+	i32 := func(n int32) []instance.WasmVal {
+		return []instance.WasmVal{n}
+	}
+
+	// active segment with an explicit table
+	results, err := call("call1", int32(2))
+	require.NoError(t, err)
+	require.Equal(t, i32(2), results)
+	_, err = call("call1", int32(0))
+	require.EqualError(t, err, "uninitialized element")
+	_, err = call("call0", int32(1))
+	require.EqualError(t, err, "uninitialized element")
+
+	// table.init from a passive segment of expressions
+	_, err = call("init", int32(0), int32(0), int32(2))
+	require.NoError(t, err)
+	results, err = call("call0", int32(0))
+	require.NoError(t, err)
+	require.Equal(t, i32(3), results)
+	_, err = call("call0", int32(1))
+	require.EqualError(t, err, "uninitialized element")
+	_, err = call("init", int32(3), int32(1), int32(2))
+	require.EqualError(t, err, "out of bounds table access")
+
+	// table.copy between tables
+	_, err = call("copy", int32(2), int32(1), int32(2))
+	require.NoError(t, err)
+	results, err = call("call0", int32(3))
+	require.NoError(t, err)
+	require.Equal(t, i32(2), results)
+	_, err = call("copy", int32(3), int32(0), int32(2))
+	require.EqualError(t, err, "out of bounds table access")
+
+	// elem.drop
+	_, err = call("drop")
+	require.NoError(t, err)
+	_, err = call("init", int32(0), int32(0), int32(0))
+	require.NoError(t, err)
+	_, err = call("init", int32(0), int32(0), int32(1))
+	require.EqualError(t, err, "out of bounds table access")
+}
+
+func TestMaxTableElems(t *testing.T) {
+	// the two tables have 4 elements each
+	module := decodeTestdata(t, "bulk_table")
+	_, err := NewWithOptions(module, nil, binary.DecodeOptions{MaxTableElems: 7})
+	require.Equal(t, errTableTooLarge, err)
+	_, err = NewWithOptions(module, nil, binary.DecodeOptions{MaxTableElems: 8})
+	require.NoError(t, err)
+
+	vm := emptyVM(module, binary.DecodeOptions{MaxTableElems: 3})
+	tt := binary.TableType{ElemType: binary.FuncRef, Limits: binary.Limits{Min: 1}}
+	t0, t1 := vm.newTable(tt), vm.newTable(tt)
+	require.Equal(t, uint32(1), t0.Grow(1, nil))
+	require.Equal(t, uint32(0xFFFFFFFF), t1.Grow(1, nil))
+	t0.truncate(1)
+	require.Equal(t, uint32(1), t1.Grow(1, nil))
+}
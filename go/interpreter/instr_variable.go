@@ -1,32 +1,47 @@
 package interpreter
 
+import (
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
 func localGet(vm *vm, args interface{}) {
 	idx := args.(uint32)
-	val := vm.getOperand(vm.local0Idx + idx)
-	vm.pushU64(val)
+	vm.pushOperand(vm.local0Idx + idx)
 }
 
 func localSet(vm *vm, args interface{}) {
 	idx := args.(uint32)
-	val := vm.popU64()
-	vm.setOperand(vm.local0Idx+idx, val)
+	vm.popOperand(vm.local0Idx + idx)
 }
 
 func localTee(vm *vm, args interface{}) {
 	idx := args.(uint32)
-	val := vm.popU64()
-	vm.pushU64(val)
-	vm.setOperand(vm.local0Idx+idx, val)
+	vm.popOperand(vm.local0Idx + idx)
+	vm.pushOperand(vm.local0Idx + idx)
 }
 
 func globalGet(vm *vm, args interface{}) {
-	idx := args.(uint32)
-	val := vm.globals[idx].GetAsU64()
-	vm.pushU64(val)
+	g := vm.globals[args.(uint32)]
+	if binary.IsRefType(g.Type().ValType) {
+		vm.pushU64(vm.refs.Put(g.Get()))
+	} else if g.Type().ValType == binary.ValTypeV128 {
+		vm.pushV128(g.Get().(instance.V128))
+	} else {
+		vm.pushU64(g.GetAsU64())
+	}
 }
 
 func globalSet(vm *vm, args interface{}) {
-	idx := args.(uint32)
+	g := vm.globals[args.(uint32)]
+	if g.Type().ValType == binary.ValTypeV128 {
+		g.Set(vm.popV128())
+		return
+	}
 	val := vm.popU64()
-	vm.globals[idx].SetAsU64(val)
+	if binary.IsRefType(g.Type().ValType) {
+		g.Set(vm.refs.Get(val))
+	} else {
+		g.SetAsU64(val)
+	}
 }
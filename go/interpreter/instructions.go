@@ -13,19 +13,29 @@ func init() {
 	instrTable[binary.Block] = block
 	instrTable[binary.Loop] = loop
 	instrTable[binary.If] = _if
+	instrTable[binary.Try] = try
+	instrTable[binary.TryTable] = tryTable
+	instrTable[binary.Throw] = throw
+	instrTable[binary.Rethrow] = rethrow
+	instrTable[binary.ThrowRef] = throwRef
 	instrTable[binary.Br] = br
 	instrTable[binary.BrIf] = brIf
 	instrTable[binary.BrTable] = brTable
 	instrTable[binary.Return] = _return
 	instrTable[binary.Call] = call
 	instrTable[binary.CallIndirect] = callIndirect
+	instrTable[binary.ReturnCall] = returnCall
+	instrTable[binary.ReturnCallIndirect] = returnCallIndirect
 	instrTable[binary.Drop] = drop
 	instrTable[binary.Select] = _select
+	instrTable[binary.SelectT] = _select
 	instrTable[binary.LocalGet] = localGet
 	instrTable[binary.LocalSet] = localSet
 	instrTable[binary.LocalTee] = localTee
 	instrTable[binary.GlobalGet] = globalGet
 	instrTable[binary.GlobalSet] = globalSet
+	instrTable[binary.TableGet] = tableGet
+	instrTable[binary.TableSet] = tableSet
 	instrTable[binary.I32Load] = i32Load
 	instrTable[binary.I64Load] = i64Load
 	instrTable[binary.F32Load] = f32Load
@@ -183,5 +193,38 @@ func init() {
 	instrTable[binary.I64Extend8S] = i64Extend8S
 	instrTable[binary.I64Extend16S] = i64Extend16S
 	instrTable[binary.I64Extend32S] = i64Extend32S
-	instrTable[binary.TruncSat] = truncSat
+	instrTable[binary.RefNull] = refNull
+	instrTable[binary.RefIsNull] = refIsNull
+	instrTable[binary.RefFunc] = refFunc
+	instrTable[binary.NumericPrefix] = numericPrefix
+	instrTable[binary.AtomicPrefix] = atomicPrefix
+	instrTable[binary.SimdPrefix] = simdPrefix
+}
+
+func numericPrefix(vm *vm, args interface{}) {
+	prefixArgs := args.(binary.PrefixArgs)
+	switch prefixArgs.SubOp {
+	case binary.MemoryInit:
+		memoryInit(vm, prefixArgs.Args)
+	case binary.DataDrop:
+		dataDrop(vm, prefixArgs.Args)
+	case binary.MemoryCopy:
+		memoryCopy(vm, prefixArgs.Args)
+	case binary.MemoryFill:
+		memoryFill(vm, prefixArgs.Args)
+	case binary.TableInit:
+		tableInit(vm, prefixArgs.Args)
+	case binary.ElemDrop:
+		elemDrop(vm, prefixArgs.Args)
+	case binary.TableCopy:
+		tableCopy(vm, prefixArgs.Args)
+	case binary.TableGrow:
+		tableGrow(vm, prefixArgs.Args)
+	case binary.TableSize:
+		tableSize(vm, prefixArgs.Args)
+	case binary.TableFill:
+		tableFill(vm, prefixArgs.Args)
+	default:
+		truncSat(vm, prefixArgs.SubOp)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,128 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/validator"
+)
+
+// Pool hands out instances of a module to goroutines, at most size
+// of them at once. Instances given back are reset to the state they
+// had after instantiation, keeping the allocations of their memories
+// and stacks, unless the state can't be snapshotted.
+//
+// The methods of a Pool are safe for concurrent use, instances aren't,
+// see instance.Module: each belongs to the goroutine which got it
+// until it is put back. The imports are shared by all instances and
+// must be safe for concurrent use themselves, as shared memories are.
+type Pool struct {
+	module  binary.Module
+	imports map[string]instance.Module
+	opts    binary.DecodeOptions
+	initial *Snapshot     // nil if instances aren't reused
+	tokens  chan struct{} // one per instance which may be handed out
+	mu      sync.Mutex
+	idle    []*vm
+	out     map[*vm]bool
+}
+
+// NewPool validates m once, its first instance is created right away
+// and gives the state instances are reset to.
+func NewPool(m binary.Module, mm map[string]instance.Module,
+	opts binary.DecodeOptions, size int) (*Pool, error) {
+
+	if size < 1 {
+		return nil, errors.New("pool size must be positive")
+	}
+	if err := validator.ValidateWithOptions(m, opts); err != nil {
+		return nil, err
+	}
+	first, err := instantiate(m, mm, opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pool{
+		module:  m,
+		imports: mm,
+		opts:    opts,
+		tokens:  make(chan struct{}, size),
+		out:     map[*vm]bool{},
+	}
+	for i := 0; i < size; i++ {
+		p.tokens <- struct{}{}
+	}
+	if p.initial, err = TakeSnapshot(first); err == nil {
+		p.idle = append(p.idle, first)
+	}
+	return p, nil
+}
+
+// Get returns an idle instance, or a new one, waiting while size
+// instances are out.
+func (p *Pool) Get(ctx context.Context) (instance.Module, error) {
+	select {
+	case <-p.tokens:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		vm := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.out[vm] = true
+		p.mu.Unlock()
+		return vm, nil
+	}
+	p.mu.Unlock()
+
+	vm, err := instantiate(p.module, p.imports, p.opts)
+	if err != nil {
+		p.tokens <- struct{}{}
+		return nil, err
+	}
+	p.mu.Lock()
+	p.out[vm] = true
+	p.mu.Unlock()
+	return vm, nil
+}
+
+// Put gives back an instance returned by Get, which must not be used
+// anymore. Its suspensions are canceled and its instrumentation, like
+// hooks and profilers, detached.
+func (p *Pool) Put(m instance.Module) {
+	vm, ok := m.(*vm)
+	p.mu.Lock()
+	if !ok || !p.out[vm] {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.out, vm)
+	p.mu.Unlock()
+
+	if p.initial != nil && p.reset(vm) == nil {
+		p.mu.Lock()
+		p.idle = append(p.idle, vm)
+		p.mu.Unlock()
+	}
+	p.tokens <- struct{}{}
+}
+
+func (p *Pool) reset(vm *vm) error {
+	for len(vm.suspended) > 0 {
+		vm.suspended[len(vm.suspended)-1].Cancel()
+	}
+	if vm.recorder != nil {
+		vm.recorder.Stop()
+	}
+	vm.debugger, vm.profiler, vm.coverage = nil, nil, nil
+	vm.hooks = hooks{}
+	vm.observed = false
+	vm.backtrace = nil
+	vm.refs.reset()
+	return p.initial.restore(vm, true)
+}
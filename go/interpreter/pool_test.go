@@ -0,0 +1,79 @@
+package interpreter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+func TestPool(t *testing.T) {
+	module := decodeTestdata(t, "pool")
+	p, err := NewPool(module, nil, binary.DecodeOptions{}, 2)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	m1, err := p.Get(ctx)
+	require.NoError(t, err)
+	results, err := m1.InvokeFunc("bump")
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(1)}, results)
+	m2, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.NotSame(t, m1, m2)
+
+	// at most 2 instances are out
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(timeout)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// m1 comes back as it was instantiated, keeping its allocation
+	p.Put(m1)
+	p.Put(m1) // ignored
+	m3, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.Same(t, m1, m3)
+	mem := m3.GetMember("mem").(instance.Memory)
+	require.Equal(t, uint64(1), mem.Size())
+	require.Equal(t, 2*binary.PageSize, cap(mem.(*memory).data))
+	buf := make([]byte, 4)
+	mem.Read(0, buf)
+	require.Equal(t, []byte{0, 0, 0, 0}, buf)
+	mem.Read(100, buf)
+	require.Equal(t, []byte("abc\x00"), buf)
+	require.Equal(t, uint32(1), m3.GetMember("tab").(instance.Table).Size())
+	results, err = m3.InvokeFunc("bump")
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(1)}, results)
+	mem.Read(65536, buf)
+	require.Equal(t, []byte("xyz\x00"), buf)
+	p.Put(m2)
+	p.Put(m3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				m, err := p.Get(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results, err := m.InvokeFunc("bump")
+				if err != nil || results[0] != int32(1) {
+					t.Error(results, err)
+				}
+				p.Put(m)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 2, len(p.idle))
+}
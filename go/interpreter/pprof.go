@@ -0,0 +1,130 @@
+package interpreter
+
+import "wasm.go/binary"
+
+// pprofBuilder encodes the few messages of profile.proto a Profiler
+// needs, see https://github.com/google/pprof/blob/main/proto/profile.proto
+// Every function gets a location of its own, both are identified by
+// the function index plus one.
+type pprofBuilder struct {
+	protoBuffer
+	strings  map[string]int64
+	strTable []string
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	b.strings[s] = int64(len(b.strTable))
+	b.strTable = append(b.strTable, s)
+	return b.strings[s]
+}
+
+// Profile.sample_type
+func (b *pprofBuilder) valueType(tag int, typ, unit string) {
+	vt := &protoBuffer{}
+	vt.int64(1, b.str(typ))
+	vt.int64(2, b.str(unit))
+	b.message(tag, vt)
+}
+
+// Profile.sample, stack innermost first
+func (b *pprofBuilder) sample(stack []uint32, values ...int64) {
+	locs := make([]uint64, len(stack))
+	for i, idx := range stack {
+		locs[i] = uint64(idx) + 1
+	}
+	s := &protoBuffer{}
+	s.uint64s(1, locs)
+	s.int64s(2, values)
+	b.message(2, s)
+}
+
+// Profile.location and Profile.function, src is where the function
+// starts if known
+func (b *pprofBuilder) function(idx uint32, name string, src binary.SourceLoc) {
+	id := uint64(idx) + 1
+	line := &protoBuffer{}
+	line.uint64(1, id)
+	line.int64(2, int64(src.Line))
+	loc := &protoBuffer{}
+	loc.uint64(1, id)
+	loc.message(4, line)
+	b.message(4, loc)
+
+	f := &protoBuffer{}
+	f.uint64(1, id)
+	f.int64(2, b.str(name))
+	f.int64(3, b.str(name))
+	if src.File != "" {
+		f.int64(4, b.str(src.File))
+		f.int64(5, int64(src.Line))
+	}
+	b.message(5, f)
+}
+
+func (b *pprofBuilder) encode() []byte {
+	for _, s := range b.strTable {
+		b.string(6, s)
+	}
+	return b.data
+}
+
+// protoBuffer appends protobuf fields, zero scalars are omitted
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(tag, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) uint64(tag int, x uint64) {
+	if x != 0 {
+		b.key(tag, 0)
+		b.varint(x)
+	}
+}
+
+func (b *protoBuffer) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protoBuffer) uint64s(tag int, xs []uint64) {
+	packed := &protoBuffer{}
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(tag, packed.data)
+}
+
+func (b *protoBuffer) int64s(tag int, xs []int64) {
+	packed := &protoBuffer{}
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytes(tag, packed.data)
+}
+
+func (b *protoBuffer) string(tag int, s string) {
+	b.bytes(tag, []byte(s))
+}
+
+func (b *protoBuffer) message(tag int, m *protoBuffer) {
+	b.bytes(tag, m.data)
+}
+
+func (b *protoBuffer) bytes(tag int, data []byte) {
+	b.key(tag, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
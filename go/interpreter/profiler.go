@@ -0,0 +1,154 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// Profiler attributes the instructions executed by an instance, and
+// the wall time they take, to the call stacks of its functions. Time
+// spent in imported functions goes to them.
+type Profiler struct {
+	vm      *vm
+	samples map[string]*profSample
+	keys    []string // of samples, in order of appearance
+	start   time.Time
+	end     time.Time     // zero until stopped
+	cur     *profSample   // nil outside of the guest
+	call    *controlFrame // innermost call frame of cur, nil in imports
+	last    time.Time     // when cur was entered
+}
+
+type profSample struct {
+	stack  []uint32 // function indices, innermost first
+	instrs int64
+	wall   time.Duration
+}
+
+func NewProfiler(m instance.Module) (*Profiler, error) {
+	vm, ok := m.(*vm)
+	if !ok {
+		return nil, errors.New("not an interpreter instance")
+	}
+	p := &Profiler{
+		vm:      vm,
+		samples: map[string]*profSample{},
+		start:   time.Now(),
+	}
+	vm.profiler = p
+	vm.observe()
+	return p, nil
+}
+
+// Stop detaches the profiler, its profile can still be written.
+func (p *Profiler) Stop() {
+	p.vm.profiler = nil
+	p.vm.observe()
+	p.end = time.Now()
+}
+
+func (p *Profiler) beforeInstr() {
+	call, _ := p.vm.topCallFrame()
+	if call != p.call || p.cur == nil {
+		p.enter(p.callStack(), call)
+	}
+	p.cur.instrs++
+}
+
+func (p *Profiler) enterImport(idx uint32) {
+	p.enter(append([]uint32{idx}, p.callStack()...), nil)
+}
+
+// exitGuest stops the clock when the interpreter loop returns
+func (p *Profiler) exitGuest() {
+	if p.cur != nil {
+		p.cur.wall += time.Since(p.last)
+		p.cur, p.call = nil, nil
+	}
+}
+
+func (p *Profiler) enter(stack []uint32, call *controlFrame) {
+	now := time.Now()
+	if p.cur != nil {
+		p.cur.wall += now.Sub(p.last)
+	}
+	p.last, p.call = now, call
+
+	key := fmt.Sprint(stack)
+	if p.cur = p.samples[key]; p.cur == nil {
+		p.cur = &profSample{stack: stack}
+		p.samples[key] = p.cur
+		p.keys = append(p.keys, key)
+	}
+}
+
+func (p *Profiler) callStack() []uint32 {
+	var stack []uint32
+	for i := len(p.vm.frames) - 1; i >= 0; i-- {
+		if cf := p.vm.frames[i]; cf.opcode == binary.Call {
+			stack = append(stack, cf.fn.idx)
+		}
+	}
+	return stack
+}
+
+// where internal function idx starts
+func (p *Profiler) funcSource(idx uint32, lines *binary.LineTable) binary.SourceLoc {
+	f := p.vm.funcs[idx]
+	if lines == nil || f._func != nil {
+		return binary.SourceLoc{}
+	}
+	for _, instr := range f.code.Expr {
+		if loc, ok := lines.Lookup(instr.Offset); ok {
+			return loc
+		}
+	}
+	return binary.SourceLoc{}
+}
+
+// WriteProfile writes a gzipped pprof profile, with an instruction
+// count and a wall time per call stack.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	end := p.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	prof := &pprofBuilder{strings: map[string]int64{"": 0}, strTable: []string{""}}
+	prof.valueType(1, "instructions", "count")
+	prof.valueType(1, "wall", "nanoseconds")
+
+	var idxs []uint32
+	seen := map[uint32]bool{}
+	for _, key := range p.keys {
+		s := p.samples[key]
+		prof.sample(s.stack, s.instrs, int64(s.wall))
+		for _, idx := range s.stack {
+			if !seen[idx] {
+				seen[idx] = true
+				idxs = append(idxs, idx)
+			}
+		}
+	}
+	sort.Slice(idxs, func(i, j int) bool { return idxs[i] < idxs[j] })
+	names := p.vm.module.FuncNames()
+	lines, _ := p.vm.module.LineTable() // nil without DWARF
+	for _, idx := range idxs {
+		prof.function(idx, p.vm.module.FuncName(idx, names), p.funcSource(idx, lines))
+	}
+	prof.int64(9, p.start.UnixNano())
+	prof.int64(10, int64(end.Sub(p.start)))
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.encode()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,55 @@
+package interpreter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestProfiler(t *testing.T) {
+	env := instance.NewNativeInstance()
+	env.RegisterFunc("log(i32)->()", func(args []WasmVal) ([]WasmVal, error) {
+		return nil, nil
+	})
+	m := newTestInstance(t, "profile", instance.Map{"env": env})
+
+	p, err := NewProfiler(m)
+	require.NoError(t, err)
+	r := &recorder{}
+	require.NoError(t, SetHooks(m, r))
+	results, err := m.InvokeFunc("main", int32(4))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(3)}, results)
+	p.Stop()
+
+	instrs := int64(0)
+	for _, key := range p.keys {
+		instrs += p.samples[key].instrs
+	}
+	require.Equal(t, int64(r.instrs), instrs)
+	require.Equal(t, []uint32{2}, p.samples[p.keys[0]].stack)
+	require.Equal(t, []uint32{0, 2}, p.samples[p.keys[1]].stack)
+	require.Equal(t, int64(0), p.samples[p.keys[1]].instrs)
+	require.Equal(t, []uint32{1, 2}, p.samples[p.keys[2]].stack)
+	require.Equal(t, []uint32{1, 1, 1, 1, 2}, p.samples[p.keys[len(p.keys)-1]].stack)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.WriteProfile(&buf))
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	for _, s := range []string{"instructions", "wall", "fib", "log", "main"} {
+		require.Contains(t, string(data), s)
+	}
+
+	// detached
+	n := len(p.keys)
+	_, err = m.InvokeFunc("main", int32(4))
+	require.NoError(t, err)
+	require.Len(t, p.keys, n)
+}
@@ -0,0 +1,483 @@
+package interpreter
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/validator"
+)
+
+// Recording is the log of a Recorder: the state the instance started
+// from and its interactions with the host which followed.
+type Recording struct {
+	Start            *Snapshot
+	ImportedMemories []MemorySnapshot
+	ImportedGlobals  []SnapshotVal
+	Events           []Event
+}
+
+type EventKind byte
+
+const (
+	EventGuestCall   EventKind = iota // of Func by the host, with Vals
+	EventGuestReturn                  // with Vals or Err
+	EventHostCall                     // of imported Func, with Vals
+	EventHostReturn                   // with Vals or Err
+	EventMemWrite                     // of Data at Offset of memory Mem, by the host
+	EventMemGrow                      // of memory Mem by Pages, by the host
+)
+
+type Event struct {
+	Kind   EventKind
+	Func   uint32
+	Vals   []SnapshotVal
+	Err    string
+	Mem    uint32
+	Offset uint64
+	Data   []byte
+	Pages  uint64
+}
+
+func (e Event) String() string {
+	vals := make([]string, len(e.Vals))
+	for i, val := range e.Vals {
+		switch {
+		case val.Func > 0:
+			vals[i] = fmt.Sprintf("func[%d]", val.Func-1)
+		case val.Bits[1] > 0:
+			vals[i] = fmt.Sprintf("%#x%016x", val.Bits[1], val.Bits[0])
+		default:
+			vals[i] = fmt.Sprint(val.Bits[0])
+		}
+	}
+	results := "[" + strings.Join(vals, " ") + "]"
+	if e.Err != "" {
+		results = "error " + e.Err
+	}
+	switch e.Kind {
+	case EventGuestCall:
+		return fmt.Sprintf("call of func[%d] %s", e.Func, results)
+	case EventGuestReturn:
+		return "return " + results
+	case EventHostCall:
+		return fmt.Sprintf("host call of func[%d] %s", e.Func, results)
+	case EventHostReturn:
+		return "host return " + results
+	case EventMemWrite:
+		return fmt.Sprintf("write of %d bytes at %d to memory %d", len(e.Data), e.Offset, e.Mem)
+	case EventMemGrow:
+		return fmt.Sprintf("growth of memory %d by %d pages", e.Mem, e.Pages)
+	}
+	return fmt.Sprintf("event %d", e.Kind)
+}
+
+func (rec *Recording) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(rec)
+}
+
+func DecodeRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+	if err := gob.NewDecoder(r).Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Recorder logs the calls between an instance and its host, and the
+// changes the host makes to the memories of the instance, between
+// calls too.
+// Changes to imported globals and tables aren't logged, nor are
+// externrefs, which fail the calls passing them. Host functions can't
+// suspend the guest while it is recorded.
+type Recorder struct {
+	vm     *vm
+	rec    *Recording
+	inHost bool // writes and growth are the host's
+}
+
+var errRecordSuspend = errors.New("can't suspend a recorded guest")
+
+func NewRecorder(m instance.Module) (r *Recorder, err error) {
+	vm, ok := m.(*vm)
+	if !ok {
+		return nil, errors.New("not an interpreter instance")
+	}
+	start, err := TakeSnapshot(m)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			r, err = nil, fmt.Errorf("%v", x)
+		}
+	}()
+
+	rec := &Recording{Start: start}
+	for _, mem := range vm.memories[:len(vm.memories)-len(vm.module.MemSec)] {
+		data := make([]byte, mem.Size()*binary.PageSize)
+		mem.Read(0, data)
+		n := len(data)
+		for n > 0 && data[n-1] == 0 {
+			n--
+		}
+		rec.ImportedMemories = append(rec.ImportedMemories,
+			MemorySnapshot{mem.Size(), data[:n]})
+	}
+	for _, g := range vm.globals[:len(vm.globals)-len(vm.module.GlobalSec)] {
+		rec.ImportedGlobals = append(rec.ImportedGlobals,
+			vm.snapshotVal(g.Type().ValType, g.Get()))
+	}
+
+	r = &Recorder{vm: vm, rec: rec, inHost: true}
+	for i, mem := range vm.memories {
+		if mem, ok := mem.(*memory); ok {
+			mem.watch = recordedMem{r, uint32(i)}
+		}
+	}
+	vm.recorder = r
+	return r, nil
+}
+
+// Stop detaches the recorder, its recording remains.
+func (r *Recorder) Stop() {
+	for _, mem := range r.vm.memories {
+		if mem, ok := mem.(*memory); ok {
+			if watch, ok := mem.watch.(recordedMem); ok && watch.r == r {
+				mem.watch = nil
+			}
+		}
+	}
+	r.vm.recorder = nil
+}
+
+func (r *Recorder) Recording() *Recording {
+	return r.rec
+}
+
+func (r *Recorder) log(e Event) {
+	r.rec.Events = append(r.rec.Events, e)
+}
+
+// vals fails on references it can't record
+func (r *Recorder) vals(vts []binary.ValType, vals []WasmVal) (svs []SnapshotVal, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			svs, err = nil, fmt.Errorf("%v", x)
+		}
+	}()
+	for i, val := range vals {
+		if i < len(vts) {
+			svs = append(svs, r.vm.snapshotVal(vts[i], val))
+		}
+	}
+	return
+}
+
+func (r *Recorder) guestCall(f vmFunc, args []WasmVal) ([]WasmVal, error) {
+	vals, err := r.vals(f._type.ParamTypes, args)
+	if err != nil {
+		return nil, err
+	}
+	r.log(Event{Kind: EventGuestCall, Func: f.idx, Vals: vals})
+	inHost := r.inHost
+	r.inHost = false
+	defer func() { r.inHost = inHost }()
+
+	results, err := f.safeCall(args)
+	r.logReturn(EventGuestReturn, f._type, results, err)
+	return results, err
+}
+
+func (r *Recorder) hostCall(hc hostCall) ([]WasmVal, error) {
+	vals, err := r.vals(hc.ft.ParamTypes, hc.args)
+	if err != nil {
+		return nil, err
+	}
+	r.log(Event{Kind: EventHostCall, Func: hc.imported.idx, Vals: vals})
+	inHost := r.inHost
+	r.inHost = true
+	defer func() { r.inHost = inHost }()
+
+	results, err := hc.f.Call(hc.args...)
+	if err == ErrSuspend {
+		err = errRecordSuspend
+	}
+	return results, r.logReturn(EventHostReturn, hc.ft, results, err)
+}
+
+// logReturn returns err, or the error recording results failed with
+func (r *Recorder) logReturn(kind EventKind, ft binary.FuncType,
+	results []WasmVal, err error) error {
+
+	e := Event{Kind: kind}
+	if err == nil {
+		e.Vals, err = r.vals(ft.ResultTypes, results)
+	}
+	if err != nil {
+		e.Vals, e.Err = nil, err.Error()
+	}
+	r.log(e)
+	return err
+}
+
+type recordedMem struct {
+	r   *Recorder
+	idx uint32
+}
+
+func (m recordedMem) memWrite(offset uint64, data []byte) {
+	if m.r.inHost {
+		m.r.log(Event{Kind: EventMemWrite, Mem: m.idx, Offset: offset,
+			Data: append([]byte(nil), data...)})
+	}
+}
+
+func (m recordedMem) memGrow(n uint64) {
+	if m.r.inHost {
+		m.r.log(Event{Kind: EventMemGrow, Mem: m.idx, Pages: n})
+	}
+}
+
+// Replayer runs an instance of a module as recorded, its imports
+// replaced by the recording. The instance may be debugged, profiled
+// or hooked before Run.
+type Replayer struct {
+	vm   *vm
+	rec  *Recording
+	next int // event
+}
+
+// DivergenceError tells where a replay left its recording.
+type DivergenceError struct {
+	Event    int // index of the event expected
+	Expected string
+	Actual   string
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replay diverged at event %d: expected %s, got %s",
+		e.Event, e.Expected, e.Actual)
+}
+
+// NewReplayer instantiates module in the state its recording started
+// from, its start function doesn't run. Imported tables are empty.
+func NewReplayer(module binary.Module, rec *Recording,
+	opts binary.DecodeOptions) (r *Replayer, err error) {
+
+	if err := validator.ValidateWithOptions(module, opts); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			r, err = nil, fmt.Errorf("%v", x)
+		}
+	}()
+
+	r = &Replayer{vm: emptyVM(module, opts), rec: rec}
+	r.linkImports()
+	r.resetImportedMems() // for active data segments
+	r.vm.init()
+	r.resetImportedMems()
+	if err := rec.Start.restore(r.vm, false); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Replayer) Instance() instance.Module {
+	return r.vm
+}
+
+func (r *Replayer) linkImports() {
+	vm := r.vm
+	globals, memCount := r.rec.ImportedGlobals, 0
+	for _, imp := range vm.module.ImportSec {
+		switch imp.Desc.Tag {
+		case binary.ImportTagFunc:
+			ft := vm.module.TypeSec[imp.Desc.FuncType]
+			idx := uint32(len(vm.funcs))
+			vm.funcs = append(vm.funcs, newExternalFunc(idx, ft, replayedFunc{r, idx, ft}))
+		case binary.ImportTagTable:
+			vm.tables = append(vm.tables, vm.newTable(imp.Desc.Table))
+		case binary.ImportTagMem:
+			vm.memories = append(vm.memories, vm.newMemory(imp.Desc.Mem))
+			memCount++
+		case binary.ImportTagGlobal:
+			if len(globals) == 0 {
+				panic(errors.New("recording doesn't match module"))
+			}
+			g := newGlobal(imp.Desc.Global, 0)
+			g.Set(vm.restoreVal(imp.Desc.Global.ValType, globals[0]))
+			globals = globals[1:]
+			vm.globals = append(vm.globals, g)
+		case binary.ImportTagTag:
+			vm.tags = append(vm.tags, newTag(vm.module.TypeSec[imp.Desc.TagType.Type]))
+		}
+	}
+	if len(globals) > 0 || memCount != len(r.rec.ImportedMemories) {
+		panic(errors.New("recording doesn't match module"))
+	}
+}
+
+func (r *Replayer) resetImportedMems() {
+	for i, ms := range r.rec.ImportedMemories {
+		mem := r.vm.memories[i].(*memory)
+		if size := mem.Size(); size < ms.Pages && mem.Grow(ms.Pages-size) != size {
+			panic(errMemTooLarge)
+		}
+		mem.reset(ms.Pages, ms.Data)
+	}
+}
+
+// Run repeats the calls of the host into the guest, it stops at the
+// first divergence from the recording.
+func (r *Replayer) Run() error {
+	for r.next < len(r.rec.Events) {
+		e := r.nextEvent()
+		if ok, err := r.hostChange(e); err != nil {
+			return err
+		} else if ok {
+			continue
+		}
+		if e.Kind != EventGuestCall {
+			return r.diverged(e.String(), "end of run")
+		}
+		if err := r.guestCall(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Replayer) nextEvent() Event {
+	if r.next == len(r.rec.Events) {
+		r.next++
+		return Event{Kind: 0xff}
+	}
+	r.next++
+	return r.rec.Events[r.next-1]
+}
+
+func (r *Replayer) diverged(expected, actual string) *DivergenceError {
+	if r.next > len(r.rec.Events) {
+		expected = "end of recording"
+	}
+	return &DivergenceError{Event: r.next - 1, Expected: expected, Actual: actual}
+}
+
+// expect compares e with the next event
+func (r *Replayer) expect(e Event) error {
+	next := r.nextEvent()
+	if next.Kind != e.Kind || next.Func != e.Func || next.Err != e.Err ||
+		len(next.Vals) != len(e.Vals) {
+		return r.diverged(next.String(), e.String())
+	}
+	for i, val := range next.Vals {
+		if val != e.Vals[i] {
+			return r.diverged(next.String(), e.String())
+		}
+	}
+	return nil
+}
+
+func (r *Replayer) vals(vts []binary.ValType, vals []WasmVal) []SnapshotVal {
+	var svs []SnapshotVal
+	for i, val := range vals {
+		if i < len(vts) {
+			svs = append(svs, r.vm.snapshotVal(vts[i], val))
+		}
+	}
+	return svs
+}
+
+func (r *Replayer) restoreVals(vts []binary.ValType, svs []SnapshotVal) []WasmVal {
+	vals := make([]WasmVal, len(svs))
+	for i, sv := range svs {
+		if i < len(vts) {
+			vals[i] = r.vm.restoreVal(vts[i], sv)
+		}
+	}
+	return vals
+}
+
+func (r *Replayer) guestCall(e Event) error {
+	if e.Func >= uint32(len(r.vm.funcs)) {
+		return r.diverged(e.String(), "no such function")
+	}
+	f := r.vm.funcs[e.Func]
+	results, err := f.Call(r.restoreVals(f._type.ParamTypes, e.Vals)...)
+	var d *DivergenceError
+	if errors.As(err, &d) {
+		return d
+	}
+	ret := Event{Kind: EventGuestReturn}
+	if err != nil {
+		ret.Err = err.Error()
+	} else {
+		ret.Vals = r.vals(f._type.ResultTypes, results)
+	}
+	return r.expect(ret)
+}
+
+// hostCall replays the effects of the host and its results
+func (r *Replayer) hostCall(idx uint32, ft binary.FuncType, args []WasmVal) ([]WasmVal, error) {
+	call := Event{Kind: EventHostCall, Func: idx, Vals: r.vals(ft.ParamTypes, args)}
+	if err := r.expect(call); err != nil {
+		return nil, err
+	}
+	for {
+		e := r.nextEvent()
+		if ok, err := r.hostChange(e); err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+		switch {
+		case e.Kind == EventGuestCall:
+			if err := r.guestCall(e); err != nil {
+				return nil, err
+			}
+		case e.Kind == EventHostReturn && e.Err != "":
+			return nil, errors.New(e.Err)
+		case e.Kind == EventHostReturn:
+			return r.restoreVals(ft.ResultTypes, e.Vals), nil
+		default:
+			return nil, r.diverged(e.String(), "return of "+call.String())
+		}
+	}
+}
+
+// hostChange replays e if it is a change the host made to a memory
+func (r *Replayer) hostChange(e Event) (bool, error) {
+	if e.Kind != EventMemWrite && e.Kind != EventMemGrow {
+		return false, nil
+	}
+	if e.Mem >= uint32(len(r.vm.memories)) {
+		return true, r.diverged(e.String(), "no such memory")
+	}
+	if e.Kind == EventMemWrite {
+		r.vm.memories[e.Mem].Write(e.Offset, e.Data)
+	} else {
+		r.vm.memories[e.Mem].Grow(e.Pages)
+	}
+	return true, nil
+}
+
+type replayedFunc struct {
+	r   *Replayer
+	idx uint32
+	ft  binary.FuncType
+}
+
+func (f replayedFunc) Type() binary.FuncType {
+	return f.ft
+}
+
+func (f replayedFunc) Call(args ...WasmVal) ([]WasmVal, error) {
+	return f.r.hostCall(f.idx, f.ft, args)
+}
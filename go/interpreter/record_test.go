@@ -0,0 +1,126 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+func newRecordInstance(t *testing.T) (binary.Module, instance.Module) {
+	module := decodeTestdata(t, "record")
+	var m instance.Module
+	rand := int32(1000)
+	env := instance.NewNativeInstance()
+	env.Register("base", NewGlobal(binary.ValTypeI32, false, 100))
+	env.RegisterFunc("rand()->(i32)", func(args []WasmVal) ([]WasmVal, error) {
+		rand += 1000
+		return []WasmVal{rand}, nil
+	})
+	env.RegisterFunc("read(i32,i32)->(i32)", func(args []WasmVal) ([]WasmVal, error) {
+		mem := m.GetMember("memory").(instance.Memory)
+		mem.Write(uint64(args[0].(int32)), []byte{1, 2, 3, 4})
+		mem.Grow(1)
+		return []WasmVal{int32(4)}, nil
+	})
+	env.RegisterFunc("callback(i32)->(i32)", func(args []WasmVal) ([]WasmVal, error) {
+		results, err := m.InvokeFunc("double", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []WasmVal{results[0].(int32) + 1}, nil
+	})
+	m, err := New(module, instance.Map{"env": env})
+	require.NoError(t, err)
+	return module, m
+}
+
+func TestRecordReplay(t *testing.T) {
+	module, m := newRecordInstance(t)
+	r, err := NewRecorder(m)
+	require.NoError(t, err)
+	results, err := m.InvokeFunc("main", int32(5))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(100 + 10 + 2000 + 11)}, results)
+	results, err = m.InvokeFunc("main", int32(7))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(100 + 10 + 3000 + 15)}, results)
+	r.Stop()
+	_, err = m.InvokeFunc("double", int32(1)) // not recorded
+	require.NoError(t, err)
+
+	rec := r.Recording()
+	require.Equal(t, 24, len(rec.Events))
+	var strs []string
+	for _, e := range rec.Events[:12] {
+		strs = append(strs, e.String())
+	}
+	require.Equal(t, []string{
+		"call of func[4] [5]",
+		"host call of func[1] [16 8]",
+		"write of 4 bytes at 16 to memory 0",
+		"growth of memory 0 by 1 pages",
+		"host return [4]",
+		"host call of func[0] []",
+		"host return [2000]",
+		"host call of func[2] [5]",
+		"call of func[3] [5]",
+		"return [10]",
+		"host return [11]",
+		"return [2121]",
+	}, strs)
+
+	var buf bytes.Buffer
+	require.NoError(t, rec.Encode(&buf))
+	rec, err = DecodeRecording(&buf)
+	require.NoError(t, err)
+
+	replayer, err := NewReplayer(module, rec, binary.DecodeOptions{})
+	require.NoError(t, err)
+	require.NoError(t, replayer.Run())
+	mem := replayer.Instance().GetMember("memory").(instance.Memory)
+	require.Equal(t, uint64(3), mem.Size())
+	data := make([]byte, 4)
+	mem.Read(16, data)
+	require.Equal(t, []byte{1, 2, 3, 4}, data)
+
+	// the guest calls back with 6 instead of 5
+	rec.Events[0].Vals[0].Bits[0] = 6
+	replayer, err = NewReplayer(module, rec, binary.DecodeOptions{})
+	require.NoError(t, err)
+	err = replayer.Run()
+	var d *DivergenceError
+	require.True(t, errors.As(err, &d))
+	require.Equal(t, 7, d.Event)
+	require.EqualError(t, err, "replay diverged at event 7: "+
+		"expected host call of func[2] [5], got host call of func[2] [6]")
+
+	rec.Events[0].Vals[0].Bits[0] = 5
+	rec.Events = rec.Events[:23]
+	replayer, err = NewReplayer(module, rec, binary.DecodeOptions{})
+	require.NoError(t, err)
+	require.EqualError(t, replayer.Run(), "replay diverged at event 23: "+
+		"expected end of recording, got return [3125]")
+}
+
+func TestRecordHostWriteBeforeCall(t *testing.T) {
+	module, m := newRecordInstance(t)
+	r, err := NewRecorder(m)
+	require.NoError(t, err)
+	m.GetMember("memory").(instance.Memory).Write(100, []byte("abc"))
+	_, err = m.InvokeFunc("main", int32(5))
+	require.NoError(t, err)
+	r.Stop()
+
+	rec := r.Recording()
+	require.Equal(t, "write of 3 bytes at 100 to memory 0", rec.Events[0].String())
+	replayer, err := NewReplayer(module, rec, binary.DecodeOptions{})
+	require.NoError(t, err)
+	require.NoError(t, replayer.Run())
+	data := make([]byte, 3)
+	replayer.Instance().GetMember("memory").(instance.Memory).Read(100, data)
+	require.Equal(t, []byte("abc"), data)
+}
@@ -0,0 +1,229 @@
+package interpreter
+
+import (
+	gobin "encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// Snapshot is the state of the memories, globals and tables an
+// instance defines, imported ones are left out. It restores into
+// other instances of the same module.
+type Snapshot struct {
+	Memories     []MemorySnapshot
+	Globals      []SnapshotVal
+	Tables       [][]SnapshotVal
+	DroppedDatas []uint32 // passive data segments
+	DroppedElems []uint32 // passive elem segments
+}
+
+type MemorySnapshot struct {
+	Pages uint64
+	Data  []byte // trailing zeros trimmed
+}
+
+// SnapshotVal holds numbers and vectors as bits, funcrefs as the
+// index of a function of the instance plus one, 0 being null.
+type SnapshotVal struct {
+	Bits [2]uint64
+	Func uint32
+}
+
+func TakeSnapshot(m instance.Module) (s *Snapshot, err error) {
+	vm, ok := m.(*vm)
+	if !ok {
+		return nil, errors.New("not an interpreter instance")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	s = &Snapshot{}
+	for _, mem := range vm.definedMemories() {
+		pages := mem.Size()
+		data := make([]byte, pages*binary.PageSize)
+		mem.Read(0, data)
+		n := len(data)
+		for n > 0 && data[n-1] == 0 {
+			n--
+		}
+		s.Memories = append(s.Memories, MemorySnapshot{pages, data[:n]})
+	}
+	for _, g := range vm.definedGlobals() {
+		s.Globals = append(s.Globals, vm.snapshotVal(g._type.ValType, g.Get()))
+	}
+	for _, t := range vm.definedTables() {
+		elems := make([]SnapshotVal, t.Size())
+		for i := range elems {
+			elems[i] = vm.snapshotVal(t._type.ElemType, t.GetElem(uint32(i)))
+		}
+		s.Tables = append(s.Tables, elems)
+	}
+	for i, data := range vm.module.DataSec {
+		if data.Mode == binary.SegModePassive && vm.datas[i] == nil {
+			s.DroppedDatas = append(s.DroppedDatas, uint32(i))
+		}
+	}
+	for i, elem := range vm.module.ElemSec {
+		if elem.Mode == binary.SegModePassive && vm.elems[i] == nil {
+			s.DroppedElems = append(s.DroppedElems, uint32(i))
+		}
+	}
+	return s, nil
+}
+
+func (vm *vm) snapshotVal(vt binary.ValType, val instance.WasmVal) SnapshotVal {
+	switch {
+	case vt == binary.ValTypeV128:
+		v := val.(instance.V128)
+		return SnapshotVal{Bits: [2]uint64{
+			gobin.LittleEndian.Uint64(v[:8]),
+			gobin.LittleEndian.Uint64(v[8:]),
+		}}
+	case !binary.IsRefType(vt):
+		return SnapshotVal{Bits: [2]uint64{unwrapU64(vt, val)}}
+	case val == nil:
+		return SnapshotVal{}
+	}
+	if f, ok := val.(*vmFunc); ok && f.idx < uint32(len(vm.funcs)) && vm.funcs[f.idx] == f {
+		return SnapshotVal{Func: f.idx + 1}
+	}
+	panic(fmt.Errorf("can't snapshot reference: %T", val))
+}
+
+func (vm *vm) restoreVal(vt binary.ValType, val SnapshotVal) instance.WasmVal {
+	switch {
+	case vt == binary.ValTypeV128:
+		var v instance.V128
+		gobin.LittleEndian.PutUint64(v[:8], val.Bits[0])
+		gobin.LittleEndian.PutUint64(v[8:], val.Bits[1])
+		return v
+	case !binary.IsRefType(vt):
+		return wrapU64(vt, val.Bits[0])
+	case val.Func == 0:
+		return nil
+	case val.Func > uint32(len(vm.funcs)):
+		panic(errors.New("snapshot doesn't match module"))
+	}
+	return vm.funcs[val.Func-1]
+}
+
+// Restore puts the state of the snapshot into m, an instance of the
+// same module. Its memories and tables grow to the sizes of the
+// snapshot but can't shrink, its immutable globals are kept.
+func (s *Snapshot) Restore(m instance.Module) error {
+	vm, ok := m.(*vm)
+	if !ok {
+		return errors.New("not an interpreter instance")
+	}
+	return s.restore(vm, false)
+}
+
+// restore shrinks memories and tables as well if asked to
+func (s *Snapshot) restore(vm *vm, shrink bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	mems := vm.definedMemories()
+	globals := vm.definedGlobals()
+	tables := vm.definedTables()
+	if len(mems) != len(s.Memories) || len(globals) != len(s.Globals) ||
+		len(tables) != len(s.Tables) {
+		return errors.New("snapshot doesn't match module")
+	}
+	for i, mem := range mems {
+		ms := s.Memories[i]
+		if size := mem.Size(); size > ms.Pages && !shrink ||
+			uint64(len(ms.Data)) > ms.Pages*binary.PageSize {
+			return fmt.Errorf("can't restore memory %d of %d pages", i, ms.Pages)
+		} else if size < ms.Pages && mem.Grow(ms.Pages-size) != size {
+			return fmt.Errorf("can't grow memory %d to %d pages", i, ms.Pages)
+		}
+		mem.reset(ms.Pages, ms.Data)
+	}
+	for i, g := range globals {
+		if g._type.Mut == 1 {
+			g.Set(vm.restoreVal(g._type.ValType, s.Globals[i]))
+		}
+	}
+	for i, t := range tables {
+		elems := s.Tables[i]
+		n := uint32(len(elems))
+		if size := t.Size(); size > n && !shrink {
+			return fmt.Errorf("can't restore table %d of %d elements", i, n)
+		} else if size < n && t.Grow(n-size, nil) != size {
+			return fmt.Errorf("can't grow table %d to %d elements", i, n)
+		}
+		t.truncate(n)
+		for j, elem := range elems {
+			t.SetElem(uint32(j), vm.restoreVal(t._type.ElemType, elem))
+		}
+	}
+
+	for i, data := range vm.module.DataSec {
+		if data.Mode == binary.SegModePassive {
+			vm.datas[i] = data.Init
+		}
+	}
+	for _, i := range s.DroppedDatas {
+		vm.datas[i] = nil
+	}
+	for i, elem := range vm.module.ElemSec {
+		if elem.Mode == binary.SegModePassive && vm.elems[i] == nil {
+			vm.elems[i] = vm.evalElem(elem)
+		}
+	}
+	for _, i := range s.DroppedElems {
+		vm.elems[i] = nil
+	}
+	return nil
+}
+
+func (s *Snapshot) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := gob.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (vm *vm) definedMemories() []*memory {
+	mems := vm.memories[len(vm.memories)-len(vm.module.MemSec):]
+	defined := make([]*memory, len(mems))
+	for i, mem := range mems {
+		defined[i] = mem.(*memory)
+	}
+	return defined
+}
+
+func (vm *vm) definedGlobals() []*globalVar {
+	globals := vm.globals[len(vm.globals)-len(vm.module.GlobalSec):]
+	defined := make([]*globalVar, len(globals))
+	for i, g := range globals {
+		defined[i] = g.(*globalVar)
+	}
+	return defined
+}
+
+func (vm *vm) definedTables() []*table {
+	tables := vm.tables[len(vm.tables)-len(vm.module.TableSec):]
+	defined := make([]*table, len(tables))
+	for i, t := range tables {
+		defined[i] = t.(*table)
+	}
+	return defined
+}
@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestSnapshot(t *testing.T) {
+	module := decodeTestdata(t, "snapshot")
+	m1, err := New(module, nil)
+	require.NoError(t, err)
+	_, err = m1.InvokeFunc("init")
+	require.NoError(t, err)
+
+	s, err := TakeSnapshot(m1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), s.Memories[0].Pages)
+	require.Equal(t, 70004, len(s.Memories[0].Data))
+	require.Equal(t, []SnapshotVal{{Bits: [2]uint64{99}}, {Bits: [2]uint64{1, 1<<64 - 2}}, {Bits: [2]uint64{5}}}, s.Globals)
+	require.Equal(t, 3, len(s.Tables[0]))
+	require.Equal(t, SnapshotVal{}, s.Tables[0][0])
+	require.Equal(t, SnapshotVal{Func: 1}, s.Tables[0][1])
+	require.Equal(t, []uint32{0}, s.DroppedDatas)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.Encode(&buf))
+	s, err = DecodeSnapshot(&buf)
+	require.NoError(t, err)
+
+	m2, err := New(module, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Restore(m2))
+	require.Equal(t, uint64(2), m2.GetMember("mem").(instance.Memory).Size())
+	require.Equal(t, uint32(3), m2.GetMember("tab").(instance.Table).Size())
+	results, err := m2.InvokeFunc("load", int32(70000))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(0x12345678)}, results)
+	results, err = m2.InvokeFunc("get_g")
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(99)}, results)
+	results, err = m2.InvokeFunc("get_v")
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int64(-2)}, results)
+	results, err = m2.InvokeFunc("call_tab", int32(2))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(42)}, results)
+	_, err = m2.InvokeFunc("init_mem")
+	require.Equal(t, errMemOutOfBounds, err)
+
+	// restored memories can't shrink
+	m3, err := New(module, nil)
+	require.NoError(t, err)
+	_, err = m3.InvokeFunc("init")
+	require.NoError(t, err)
+	s.Memories[0].Pages = 1
+	require.EqualError(t, s.Restore(m3), "can't restore memory 0 of 1 pages")
+
+	m4 := newTestInstance(t, "trap", nil)
+	require.EqualError(t, s.Restore(m4), "snapshot doesn't match module")
+}
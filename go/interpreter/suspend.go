@@ -0,0 +1,120 @@
+package interpreter
+
+import (
+	"errors"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+// ErrSuspend, returned by a host function, suspends the guest which
+// called it. The call of the guest returns a *Suspension as its
+// error, which resumes the guest with the results of the host
+// function. The guest must have been called by Go, not by a host
+// function it called itself.
+var ErrSuspend = errors.New("suspend")
+
+var errNestedSuspend = errors.New("can't suspend a guest called by its host")
+
+// Suspension is a guest suspended in a call to a host function, its
+// stacks are kept in the instance. Suspensions of an instance resume
+// or cancel in the reverse order of their creation, the instance may
+// run other calls meanwhile.
+type Suspension struct {
+	vm     *vm
+	call   hostCall
+	f      vmFunc // called by Go
+	depth  int    // of the control stack before f
+	size   int    // of the operand stack before f and its args
+	closed bool
+}
+
+// a call of a function the guest doesn't run itself, imported or of
+// another instance
+type hostCall struct {
+	f        instance.Function
+	imported *vmFunc // nil if not imported
+	ft       binary.FuncType
+	args     []WasmVal
+	tail     bool // return_call
+}
+
+func (s *Suspension) Error() string {
+	return "guest suspended in a host call"
+}
+
+// Args returns the args of the host function which suspended.
+func (s *Suspension) Args() []WasmVal {
+	return s.call.args
+}
+
+// Resume continues the guest as if the host function returned
+// results, until it returns, traps or suspends again.
+func (s *Suspension) Resume(results ...WasmVal) ([]WasmVal, error) {
+	if err := s.close(); err != nil {
+		return nil, err
+	}
+	return s.f.safeRun(s.depth, s.size, func() []WasmVal {
+		s.vm.hostReturn(s.call, results)
+		s.vm.loopUntil(s.depth + 1)
+		return popResults(s.vm, s.f._type)
+	})
+}
+
+// Cancel drops the guest's frames, as if it had trapped.
+func (s *Suspension) Cancel() error {
+	if err := s.close(); err != nil {
+		return err
+	}
+	s.vm.unwind(s.depth, s.size)
+	return nil
+}
+
+func (s *Suspension) close() error {
+	vm := s.vm
+	if s.closed {
+		return errors.New("suspension already resumed or canceled")
+	}
+	if vm.suspended[len(vm.suspended)-1] != s {
+		return errors.New("suspension isn't the last one of its instance")
+	}
+	vm.suspended = vm.suspended[:len(vm.suspended)-1]
+	s.closed = true
+	return nil
+}
+
+func (vm *vm) callHost(hc hostCall) {
+	hc.args = popArgs(vm, hc.ft)
+	if vm.profiler != nil && hc.imported != nil {
+		vm.profiler.enterImport(hc.imported.idx)
+	}
+	var results []WasmVal
+	var err error
+	if vm.recorder != nil && hc.imported != nil {
+		results, err = vm.recorder.hostCall(hc)
+	} else {
+		results, err = hc.f.Call(hc.args...)
+	}
+	if err == ErrSuspend {
+		if vm.entries != 1 {
+			panic(errNestedSuspend)
+		}
+		s := &Suspension{vm: vm, call: hc}
+		vm.suspended = append(vm.suspended, s)
+		panic(s) // safeRun completes it
+	}
+	if err != nil {
+		panic(err)
+	}
+	vm.hostReturn(hc, results)
+}
+
+func (vm *vm) hostReturn(hc hostCall, results []WasmVal) {
+	if vm.hooks.host != nil && hc.imported != nil {
+		vm.hooks.host.HostCall(hc.imported.idx, hc.args, results)
+	}
+	pushResults(vm, hc.ft, results)
+	if hc.tail {
+		_return(vm, nil)
+	}
+}
@@ -0,0 +1,71 @@
+package interpreter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestSuspend(t *testing.T) {
+	var m instance.Module
+	env := instance.NewNativeInstance()
+	env.RegisterFunc("read(i32)->(i32)", func(args []WasmVal) ([]WasmVal, error) {
+		return nil, ErrSuspend
+	})
+	env.RegisterFunc("reenter()->()", func(args []WasmVal) ([]WasmVal, error) {
+		_, err := m.InvokeFunc("inner")
+		return nil, err
+	})
+	m = newTestInstance(t, "suspend", instance.Map{"env": env})
+	vm := m.(*vm)
+
+	// read suspends, by call and call_indirect
+	_, err := m.InvokeFunc("sum", int32(3))
+	var s *Suspension
+	require.True(t, errors.As(err, &s))
+	require.Equal(t, []WasmVal{int32(0)}, s.Args())
+
+	// other calls may run and suspend meanwhile
+	_, err = m.InvokeFunc("tail", int32(1))
+	var s2 *Suspension
+	require.True(t, errors.As(err, &s2))
+	require.Equal(t, []WasmVal{int32(101)}, s2.Args())
+	_, err = s.Resume(int32(10))
+	require.EqualError(t, err, "suspension isn't the last one of its instance")
+	results, err := s2.Resume(int32(5))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(5)}, results)
+	_, err = s2.Resume(int32(5))
+	require.EqualError(t, err, "suspension already resumed or canceled")
+
+	for i, result := range []int32{10, 20} {
+		_, err = s.Resume(result)
+		require.True(t, errors.As(err, &s))
+		require.Equal(t, []WasmVal{int32(i + 1)}, s.Args())
+	}
+	results, err = s.Resume(int32(30))
+	require.NoError(t, err)
+	require.Equal(t, []WasmVal{int32(60)}, results)
+	require.Equal(t, 0, vm.stackSize())
+	require.Equal(t, 0, vm.controlDepth())
+
+	// wrong results trap
+	_, err = m.InvokeFunc("sum", int32(1))
+	require.True(t, errors.As(err, &s))
+	_, err = s.Resume()
+	require.EqualError(t, err, "result count: 0, expected: 1")
+	require.Equal(t, 0, vm.stackSize())
+
+	_, err = m.InvokeFunc("sum", int32(2))
+	require.True(t, errors.As(err, &s))
+	require.NoError(t, s.Cancel())
+	require.Equal(t, 0, vm.stackSize())
+	require.Equal(t, 0, vm.controlDepth())
+
+	// the host's Go stack can't be suspended
+	_, err = m.InvokeFunc("nested")
+	require.Equal(t, errNestedSuspend, err)
+	require.Empty(t, vm.suspended)
+}
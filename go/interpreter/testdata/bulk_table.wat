@@ -0,0 +1,23 @@
+(module
+  (type $r (func (result i32)))
+  (table $t0 4 funcref)
+  (table $t1 4 funcref)
+  (elem $a (table $t1) (i32.const 1) func $one $two)
+  (elem $p funcref (ref.func $three) (ref.null func))
+  (elem $d declare func $one)
+  (func $one (result i32) (i32.const 1))
+  (func $two (result i32) (i32.const 2))
+  (func $three (result i32) (i32.const 3))
+  (func (export "call0") (param i32) (result i32)
+    (call_indirect $t0 (type $r) (local.get 0)))
+  (func (export "call1") (param i32) (result i32)
+    (call_indirect $t1 (type $r) (local.get 0)))
+  (func (export "init") (param i32 i32 i32)
+    (table.init $t0 $p (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "copy") (param i32 i32 i32)
+    (table.copy $t0 $t1 (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "drop")
+    (elem.drop $p))
+  (func (export "size1") (result i32)
+    (table.size $t1))
+)
@@ -0,0 +1,17 @@
+(module
+  (func $classify (export "classify") (param i32) (result i32)
+    (block $b2
+      (block $b1
+        (block $b0
+          (br_table $b0 $b1 $b2 (local.get 0)))
+        (return (i32.const 10)))
+      (return (i32.const 11)))
+    (i32.const 12))
+  (func $abs (export "abs") (param i32) (result i32)
+    (if (result i32) (i32.lt_s (local.get 0) (i32.const 0))
+      (then (i32.sub (i32.const 0) (local.get 0)))
+      (else (local.get 0))))
+  (func $unused (export "unused") (result i32)
+    (block (br_if 0 (i32.const 1)))
+    (i32.const 0))
+)
@@ -0,0 +1,15 @@
+(module
+  (memory (export "mem") 1)
+  (global $g (export "g") (mut i32) (i32.const 7))
+  (func $add (export "add") (param $a i32) (param $b i32) (result i32)
+    (local $t i32)
+    (local.set $t (i32.add (local.get $a) (local.get $b)))
+    (i32.store (i32.const 0) (local.get $t))
+    (local.get $t))
+  (func $main (export "main") (result i32)
+    (block (result i32)
+      (call $add (i32.const 1) (i32.const 2))
+      (global.set $g (i32.const 8))))
+  (func $sub (param $a i32) (param $b i32) (result i32)
+    (i32.sub (local.get $a) (local.get $b)))
+)
@@ -0,0 +1,134 @@
+(module
+  (import "env" "throw_i32" (func $throw_i32 (param i32)))
+  (import "env" "exn_i32" (tag $exn_i32 (param i32)))
+  (tag $e (param i32))
+  (tag $e2 (param i64 f64))
+  (tag $empty)
+  (export "e" (tag $e))
+
+  (func $thrower (param i32) (throw $e (local.get 0)))
+
+  (func (export "try_catch") (param i32) (result i32)
+    try (result i32)
+      (if (local.get 0) (then (call $thrower (i32.const 42))))
+      (i32.const 1)
+    catch $e
+      (i32.const 100)
+      (i32.add)
+    catch_all
+      (i32.const -1)
+    end)
+  (func (export "catch_all") (result i32)
+    try (result i32)
+      (throw $empty)
+    catch $e
+    catch_all
+      (i32.const 7)
+    end)
+  (func (export "delegate") (result i32)
+    try $outer (result i32)
+      try (result i32)
+        try
+          (call $thrower (i32.const 5))
+        delegate $outer
+        (i32.const 0)
+      catch_all
+        (i32.const -1)
+      end
+    catch $e
+    end)
+  (func (export "rethrow") (result i32)
+    try (result i32)
+      try
+        (call $thrower (i32.const 6))
+      catch $e
+        (drop)
+        (rethrow 0)
+      end
+      (i32.const 0)
+    catch $e
+    end)
+
+  (func (export "catch_br") (result i32)
+    try (result i32)
+      (call $thrower (i32.const 11))
+      (i32.const 0)
+    catch $e
+      (br 0)
+    end)
+  (func (export "delegate_skip") (result i32)
+    try $outer (result i32)
+      (block $h (result i32)
+        (try_table (catch $e $h)
+          try
+            (call $thrower (i32.const 4))
+          delegate $outer)
+        (i32.const -1))
+    catch $e
+    end)
+  (func (export "br_out") (param i32) (result i32)
+    (local $i i32)
+    (block $b (result i32)
+      (loop $l
+        (try_table (catch_all $l)
+          (local.set $i (i32.add (local.get $i) (i32.const 1)))
+          (br_if $l (i32.lt_u (local.get $i) (i32.const 10)))
+          (if (local.get 0) (then (br $b (i32.const 100))))
+          (return (i32.const -2))))
+      (i32.const -3))
+    (i32.add (local.get $i)))
+  (func $id (param i32) (result i32) (local.get 0))
+  (func (export "tail_in_try") (param i32) (result i32)
+    (block $h (result i32)
+      (try_table (catch $e $h)
+        (return_call $id (local.get 0)))
+      (unreachable)))
+
+  (func (export "try_table") (param i32) (result i32)
+    (block $h (result i32)
+      (try_table (catch $e $h) (call $thrower (local.get 0)))
+      (i32.const -1)))
+  (func (export "throw_ref") (result i32)
+    (local $x exnref)
+    (block $h (result i32 exnref)
+      (try_table (catch_ref $e $h) (call $thrower (i32.const 9)))
+      (unreachable))
+    (local.set $x)
+    (drop)
+    (block $h (result i32)
+      (try_table (catch $e $h) (throw_ref (local.get $x)))
+      (unreachable)))
+  (func (export "catch_all_ref") (result i32)
+    (block $h (result exnref)
+      (try_table (catch_all_ref $h)
+        (throw $e2 (i64.const 1) (f64.const 2)))
+      (unreachable))
+    (ref.is_null))
+  (func (export "throw_null")
+    (throw_ref (ref.null exn)))
+
+  (func $deep (param i32) (result i32)
+    (if (result i32) (i32.eqz (local.get 0))
+      (then (throw $e (i32.const 77)))
+      (else
+        (i32.add (i32.const 1)
+          (call $deep (i32.sub (local.get 0) (i32.const 1)))))))
+  (func (export "unwind") (param i32) (result i32)
+    (local $l i32)
+    (local.set $l (i32.const 3))
+    (i32.const 1000)
+    (block $h (result i32)
+      (try_table (catch $e $h) (drop (call $deep (local.get 0))))
+      (i32.const -1))
+    (i32.add (local.get $l))
+    (i32.add))
+
+  (func (export "uncaught") (param i32)
+    (call $thrower (local.get 0)))
+  (func (export "uncaught_e2")
+    (throw $e2 (i64.const 1) (f64.const 2.5)))
+  (func (export "host") (param i32) (result i32)
+    (block $h (result i32)
+      (try_table (catch $exn_i32 $h) (call $throw_i32 (local.get 0)))
+      (i32.const -1)))
+)
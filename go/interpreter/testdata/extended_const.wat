@@ -0,0 +1,25 @@
+(module
+  (import "env" "base" (global $base i32))
+  (import "env" "base64" (global $base64 i64))
+  (memory 1)
+  (table 8 funcref)
+  (global $g1 i32 (i32.add (global.get $base) (i32.const 4)))
+  (global $g2 i64 (i64.mul (i64.const 0x100000000) (i64.const 3)))
+  (global $g3 i32 (i32.sub (i32.const 0) (i32.const 1)))
+  (global $g4 i32 (i32.mul (i32.const 0x10000) (i32.const 0x10000)))
+  (global $g5 i64 (i64.sub (global.get $base64) (i64.mul (i64.const 2) (i64.const 3))))
+  (data (offset (i32.mul (global.get $base) (i32.const 2))) "hi")
+  (elem (offset (i32.sub (global.get $base) (i32.const 12))) func $seven)
+  (func $seven (result i32) (i32.const 7))
+  (func (export "g1") (result i32) (global.get $g1))
+  (func (export "g2") (result i64) (global.get $g2))
+  (func (export "g3") (result i32) (global.get $g3))
+  (func (export "g4") (result i32) (global.get $g4))
+  (func (export "g5") (result i64) (global.get $g5))
+  (func (export "load") (param $addr i32) (result i32)
+    (i32.load16_u (local.get $addr))
+  )
+  (func (export "call") (param $i i32) (result i32)
+    (call_indirect (result i32) (local.get $i))
+  )
+)
@@ -0,0 +1,26 @@
+(module
+  (type $t (func (param i32)))
+  (import "env" "log" (func $log (param i32)))
+  (table 1 funcref)
+  (elem (i32.const 0) $log)
+  (memory 1)
+  (func $sq (param i32) (result i32)
+    (i32.mul (local.get 0) (local.get 0)))
+  (func (export "main") (param i32) (result i32)
+    (i32.store (i32.const 8) (call $sq (local.get 0)))
+    (call $log (i32.load (i32.const 8)))
+    (call_indirect (type $t) (i32.const 7) (i32.const 0))
+    (i32.load8_u (i32.const 8)))
+  (tag $e (param i32))
+  (func $count (param i32) (result i32)
+    (if (result i32) (i32.eqz (local.get 0))
+      (then (i32.const 0))
+      (else (return_call $count (i32.sub (local.get 0) (i32.const 1))))))
+  (func $throw (export "throw") (param i32)
+    (throw $e (local.get 0)))
+  (func (export "unwind") (param i32) (result i32)
+    (block $h (result i32)
+      (try_table (catch $e $h)
+        (call $throw (call $count (local.get 0))))
+      (i32.const -1)))
+)
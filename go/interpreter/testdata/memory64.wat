@@ -0,0 +1,26 @@
+(module
+  (memory $m64 i64 1 4)
+  (memory $m32 1)
+  (data (memory $m64) (i64.const 8) "\01\02\03\04")
+  (func (export "load") (param $addr i64) (result i32)
+    (i32.load $m64 offset=4 (local.get $addr))
+  )
+  (func (export "store") (param $addr i64) (param $val i64)
+    (i64.store $m64 (local.get $addr) (local.get $val))
+  )
+  (func (export "size") (result i64)
+    (memory.size $m64)
+  )
+  (func (export "grow") (param $n i64) (result i64)
+    (memory.grow $m64 (local.get $n))
+  )
+  (func (export "fill") (param $d i64) (param $val i32) (param $n i64)
+    (memory.fill $m64 (local.get $d) (local.get $val) (local.get $n))
+  )
+  (func (export "copy_to_32") (param $d i32) (param $s i64) (param $n i32)
+    (memory.copy $m32 $m64 (local.get $d) (local.get $s) (local.get $n))
+  )
+  (func (export "load32") (param $addr i32) (result i32)
+    (i32.load $m32 (local.get $addr))
+  )
+)
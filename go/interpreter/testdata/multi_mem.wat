@@ -0,0 +1,19 @@
+(module
+  (memory $m0 1)
+  (memory $m1 1 2)
+  (export "m0" (memory $m0))
+  (export "m1" (memory $m1))
+  (data (memory $m1) (i32.const 8) "Hello")
+  (func (export "load1") (param i32) (result i32)
+    (i32.load8_u $m1 (local.get 0)))
+  (func (export "store0") (param i32 i32)
+    (i32.store8 $m0 (local.get 0) (local.get 1)))
+  (func (export "copy") (param i32 i32 i32)
+    (memory.copy $m0 $m1 (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "fill1") (param i32 i32 i32)
+    (memory.fill $m1 (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "grow1") (param i32) (result i32)
+    (memory.grow $m1 (local.get 0)))
+  (func (export "size1") (result i32)
+    (memory.size $m1))
+)
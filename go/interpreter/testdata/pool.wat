@@ -0,0 +1,17 @@
+(module
+  (memory (export "mem") 1 8)
+  (global $count (mut i32) (i32.const 0))
+  (table $t (export "tab") 1 4 funcref)
+  (data (i32.const 100) "abc")
+  (data $p "xyz")
+  (elem declare func $count)
+  (func $count (export "count") (result i32) (global.get $count))
+  ;; grows everything, memory.init traps once $p is dropped
+  (func (export "bump") (result i32)
+    (drop (memory.grow (i32.const 1)))
+    (drop (table.grow $t (ref.func $count) (i32.const 1)))
+    (i32.store (i32.const 0) (i32.const -1))
+    (memory.init $p (i32.const 65536) (i32.const 0) (i32.const 3))
+    (data.drop $p)
+    (global.set $count (i32.add (global.get $count) (i32.const 1)))
+    (global.get $count)))
@@ -0,0 +1,23 @@
+(module
+  (import "env" "base" (global $base i32))
+  (import "env" "rand" (func $rand (result i32)))
+  (import "env" "read" (func $read (param i32 i32) (result i32)))
+  (import "env" "callback" (func $callback (param i32) (result i32)))
+  (memory (export "memory") 1)
+  (func (export "double") (param i32) (result i32)
+    (i32.mul (local.get 0) (i32.const 2)))
+  ;; base + the bytes read + rand() + callback(n)
+  (func (export "main") (param $n i32) (result i32)
+    (local $end i32) (local $i i32) (local $sum i32)
+    (local.set $i (i32.const 16))
+    (local.set $end (i32.add (local.get $i)
+      (call $read (local.get $i) (i32.const 8))))
+    (local.set $sum (global.get $base))
+    (block $done
+      (loop $l
+        (br_if $done (i32.ge_u (local.get $i) (local.get $end)))
+        (local.set $sum (i32.add (local.get $sum) (i32.load8_u (local.get $i))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $l)))
+    (i32.add (i32.add (local.get $sum) (call $rand))
+      (call $callback (local.get $n)))))
@@ -0,0 +1,18 @@
+(module
+  (type $ee (func (param externref) (result externref)))
+  (table $t 2 4 funcref)
+  (global $g (mut externref) (ref.null extern))
+  (elem (i32.const 0) $id)
+  (func $id (export "id") (type $ee) (local.get 0))
+  (func (export "set_g") (param externref) (global.set $g (local.get 0)))
+  (func (export "get_g") (result externref) (global.get $g))
+  (func (export "is_null") (param externref) (result i32) (ref.is_null (local.get 0)))
+  (func (export "grow") (param i32) (result i32) (table.grow $t (ref.null func) (local.get 0)))
+  (func (export "size") (result i32) (table.size $t))
+  (func (export "get") (param i32) (result funcref) (table.get $t (local.get 0)))
+  (func (export "fill") (param i32 i32) (table.fill $t (local.get 0) (ref.func $id) (local.get 1)))
+  (func (export "call") (param i32 externref) (result externref)
+    (call_indirect $t (type $ee) (local.get 1) (local.get 0)))
+  (func (export "select") (param funcref funcref i32) (result funcref)
+    (select (result funcref) (local.get 0) (local.get 1) (local.get 2)))
+)
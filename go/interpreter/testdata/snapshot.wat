@@ -0,0 +1,26 @@
+(module
+  (type $ret (func (result i32)))
+  (memory (export "mem") 1 4)
+  (global $g (mut i32) (i32.const 0))
+  (global $v (mut v128) (v128.const i64x2 0 0))
+  (global $k i32 (i32.const 5))
+  (table $t (export "tab") 1 10 funcref)
+  (data $d "passive")
+  (elem declare func $answer)
+  (func $answer (result i32) (i32.const 42))
+  (func (export "init")
+    (drop (memory.grow (i32.const 1)))
+    (i32.store (i32.const 70000) (i32.const 0x12345678))
+    (global.set $g (i32.const 99))
+    (global.set $v (v128.const i64x2 1 -2))
+    (drop (table.grow $t (ref.func $answer) (i32.const 2)))
+    (data.drop $d))
+  (func (export "load") (param i32) (result i32)
+    (i32.load (local.get 0)))
+  (func (export "get_g") (result i32) (global.get $g))
+  (func (export "get_v") (result i64)
+    (i64x2.extract_lane 1 (global.get $v)))
+  (func (export "call_tab") (param i32) (result i32)
+    (call_indirect $t (type $ret) (local.get 0)))
+  (func (export "init_mem")
+    (memory.init $d (i32.const 0) (i32.const 0) (i32.const 7))))
@@ -0,0 +1,23 @@
+(module
+  (import "env" "read" (func $read (param i32) (result i32)))
+  (import "env" "reenter" (func $reenter))
+  (type $unop (func (param i32) (result i32)))
+  (table 1 funcref)
+  (elem (i32.const 0) $read)
+  (func (export "sum") (param $n i32) (result i32)
+    (local $i i32) (local $acc i32)
+    (block $done
+      (loop $l
+        (br_if $done (i32.ge_u (local.get $i) (local.get $n)))
+        (if (i32.and (local.get $i) (i32.const 1))
+          (then (local.set $acc (i32.add (local.get $acc)
+            (call_indirect (type $unop) (local.get $i) (i32.const 0)))))
+          (else (local.set $acc (i32.add (local.get $acc)
+            (call $read (local.get $i))))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $l)))
+    (local.get $acc))
+  (func (export "tail") (param i32) (result i32)
+    (return_call $read (i32.add (local.get 0) (i32.const 100))))
+  (func (export "nested") (call $reenter))
+  (func (export "inner") (result i32) (call $read (i32.const 7))))
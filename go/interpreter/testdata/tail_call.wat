@@ -0,0 +1,35 @@
+(module
+  (type $i64_i32 (func (param i64) (result i32)))
+  (table 2 funcref)
+  (elem (i32.const 0) $even_ind $odd_ind)
+
+  (func $fac (export "fac") (param $x i64) (result i64)
+    (return_call $fac-aux (local.get $x) (i64.const 1)))
+  (func $fac-aux (param $x i64) (param $r i64) (result i64)
+    (if (result i64) (i64.eqz (local.get $x))
+      (then (local.get $r))
+      (else
+        (return_call $fac-aux
+          (i64.sub (local.get $x) (i64.const 1))
+          (i64.mul (local.get $x) (local.get $r))))))
+
+  (func $even (export "even") (param $n i64) (result i32)
+    (if (result i32) (i64.eqz (local.get $n))
+      (then (i32.const 1))
+      (else (return_call $odd (i64.sub (local.get $n) (i64.const 1))))))
+  (func $odd (export "odd") (param $n i64) (result i32)
+    (if (result i32) (i64.eqz (local.get $n))
+      (then (i32.const 0))
+      (else (return_call $even (i64.sub (local.get $n) (i64.const 1))))))
+
+  (func $even_ind (export "even_ind") (param $n i64) (result i32)
+    (if (result i32) (i64.eqz (local.get $n))
+      (then (i32.const 1))
+      (else (return_call_indirect (type $i64_i32)
+        (i64.sub (local.get $n) (i64.const 1)) (i32.const 1)))))
+  (func $odd_ind (param $n i64) (result i32)
+    (if (result i32) (i64.eqz (local.get $n))
+      (then (i32.const 0))
+      (else (return_call_indirect (type $i64_i32)
+        (i64.sub (local.get $n) (i64.const 1)) (i32.const 0)))))
+)
@@ -0,0 +1,45 @@
+(module
+  (import "env" "shared_mem" (memory 1 1 shared))
+  ;; adds 1 to the counter at addr n times
+  (func (export "count") (param $addr i32) (param $n i32)
+    (loop $l
+      (drop (i32.atomic.rmw.add (local.get $addr) (i32.const 1)))
+      (br_if $l (local.tee $n (i32.sub (local.get $n) (i32.const 1))))))
+  ;; spin lock protected non-atomic increments
+  (func (export "locked_count") (param $n i32)
+    (loop $l
+      (block $acquired
+        (loop $spin
+          (br_if $acquired (i32.eqz
+            (i32.atomic.rmw.cmpxchg (i32.const 8) (i32.const 0) (i32.const 1))))
+          (br $spin)))
+      (i32.store (i32.const 12) (i32.add (i32.load (i32.const 12)) (i32.const 1)))
+      (i32.atomic.store (i32.const 8) (i32.const 0))
+      (br_if $l (local.tee $n (i32.sub (local.get $n) (i32.const 1))))))
+  (func (export "load") (param i32) (result i32) (i32.atomic.load (local.get 0)))
+  (func (export "load64") (param i32) (result i64) (i64.atomic.load (local.get 0)))
+  (func (export "store64") (param i32 i64) (i64.atomic.store (local.get 0) (local.get 1)))
+  (func (export "add8") (param i32 i32) (result i32)
+    (i32.atomic.rmw8.add_u (local.get 0) (local.get 1)))
+  (func (export "sub16") (param i32 i64) (result i64)
+    (i64.atomic.rmw16.sub_u (local.get 0) (local.get 1)))
+  (func (export "and32") (param i32 i64) (result i64)
+    (i64.atomic.rmw32.and_u (local.get 0) (local.get 1)))
+  (func (export "or") (param i32 i32) (result i32)
+    (i32.atomic.rmw.or (local.get 0) (local.get 1)))
+  (func (export "xor64") (param i32 i64) (result i64)
+    (i64.atomic.rmw.xor (local.get 0) (local.get 1)))
+  (func (export "xchg8") (param i32 i64) (result i64)
+    (i64.atomic.rmw8.xchg_u (local.get 0) (local.get 1)))
+  (func (export "cmpxchg8") (param i32 i32 i32) (result i32)
+    (i32.atomic.rmw8.cmpxchg_u (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "store8") (param i32 i32) (i32.atomic.store8 (local.get 0) (local.get 1)))
+  (func (export "load8") (param i32) (result i32) (i32.atomic.load8_u (local.get 0)))
+  (func (export "wait32") (param i32 i32 i64) (result i32)
+    atomic.fence
+    (memory.atomic.wait32 (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "wait64") (param i32 i64 i64) (result i32)
+    (memory.atomic.wait64 (local.get 0) (local.get 1) (local.get 2)))
+  (func (export "notify") (param i32 i32) (result i32)
+    (memory.atomic.notify (local.get 0) (local.get 1)))
+)
@@ -0,0 +1,7 @@
+(module
+  (func $div (param i32 i32) (result i32)
+    (i32.div_u (local.get 0) (local.get 1)))
+  (func (export "main") (param i32) (result i32)
+    (block (result i32)
+      (call $div (i32.const 1) (local.get 0))))
+)
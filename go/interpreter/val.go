@@ -1,9 +1,11 @@
 package interpreter
 
 import (
+	"fmt"
 	"math"
 
 	"wasm.go/binary"
+	"wasm.go/instance"
 )
 
 func wrapU64(vt binary.ValType, val uint64) interface{} {
@@ -35,3 +37,46 @@ func unwrapU64(vt binary.ValType, val interface{}) uint64 {
 		panic("unreachable") // TODO
 	}
 }
+
+// same as wrapU64 on the top operand, references are looked up in
+// the ref store and vectors take both halves
+func (vm *vm) popVal(vt binary.ValType) interface{} {
+	if vt == binary.ValTypeV128 {
+		return vm.popV128()
+	}
+	if binary.IsRefType(vt) {
+		return vm.refs.Get(vm.popU64())
+	}
+	return wrapU64(vt, vm.popU64())
+}
+
+// same as popVal on the operand at idx, which stays
+func (vm *vm) getVal(idx int, vt binary.ValType) interface{} {
+	switch {
+	case vt == binary.ValTypeV128:
+		var v instance.V128
+		byteOrder.PutUint64(v[:8], vm.slots[idx])
+		byteOrder.PutUint64(v[8:], vm.getHi(idx))
+		return v
+	case binary.IsRefType(vt):
+		return vm.refs.Get(vm.slots[idx])
+	default:
+		return wrapU64(vt, vm.slots[idx])
+	}
+}
+
+func (vm *vm) pushVal(vt binary.ValType, val interface{}) {
+	if vt == binary.ValTypeV128 {
+		vm.pushV128(val.(instance.V128))
+		return
+	}
+	if binary.IsRefType(vt) {
+		if _, ok := val.(instance.Function); !ok && val != nil &&
+			vt == binary.ValTypeFuncRef {
+			panic(fmt.Errorf("not a funcref: %v", val))
+		}
+		vm.pushU64(vm.refs.Put(val))
+		return
+	}
+	vm.pushU64(unwrapU64(vt, val))
+}
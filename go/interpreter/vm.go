@@ -12,42 +12,93 @@ type vm struct {
 	operandStack
 	controlStack
 	module    binary.Module
-	memory    instance.Memory
-	table     instance.Table
+	memories  []instance.Memory
+	tables    []instance.Table
 	globals   []instance.Global
-	funcs     []vmFunc
+	tags      []instance.Tag
+	funcs     []*vmFunc
+	elems     [][]instance.WasmVal // nil if dropped
+	datas     [][]byte             // nil if dropped
+	refs      RefStore
 	local0Idx uint32
+	pagesLeft *budget // pages left to the memories it defines
+	tableLeft *budget // elements left to the tables it defines
+	debugger  *Debugger
+	profiler  *Profiler
+	coverage  *Coverage
+	recorder  *Recorder
+	backtrace []StackFrame // of the last failed call
+	entries   int          // calls from the host running
+	suspended []*Suspension
+	hooks     hooks
+	observed  bool // something runs before each instruction
 }
 
-func New(m binary.Module, mm map[string]instance.Module) (inst instance.Module, err error) {
-	if err := validator.Validate(m); err != nil {
+func New(m binary.Module, mm map[string]instance.Module) (instance.Module, error) {
+	return NewWithOptions(m, mm, binary.DecodeOptions{})
+}
+
+// NewWithOptions validates m against the limits and features of opts.
+func NewWithOptions(m binary.Module, mm map[string]instance.Module,
+	opts binary.DecodeOptions) (instance.Module, error) {
+
+	if err := validator.ValidateWithOptions(m, opts); err != nil {
 		return nil, err
 	}
 	
+	vm, err := instantiate(m, mm, opts)
+	if err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// instantiate links, initializes and starts m, which is valid
+func instantiate(m binary.Module, mm map[string]instance.Module,
+	opts binary.DecodeOptions) (vm *vm, err error) {
+
 	defer func() {
 		if _err := recover(); _err != nil {
 			switch x := _err.(type) {
 			case error:
 				err = x
 			default:
-				panic(err)
+				err = fmt.Errorf("%v", x)
 			}
 		}
 	}()
 
-	inst = newVM(m, mm)
+	vm = newVM(m, mm, opts)
 	return
 }
 
-func newVM(m binary.Module, mm map[string]instance.Module) *vm {
-	vm := &vm{module: m}
+func newVM(m binary.Module, mm map[string]instance.Module, opts binary.DecodeOptions) *vm {
+	vm := emptyVM(m, opts)
 	vm.linkImports(mm)
+	vm.init()
+	vm.execStartFunc()
+	return vm
+}
+
+// emptyVM has the limits of opts, nothing is linked or created yet
+func emptyVM(m binary.Module, opts binary.DecodeOptions) *vm {
+	opts = opts.WithDefaults()
+	vm := &vm{
+		module:    m,
+		pagesLeft: newBudget(opts.MaxMemoryPages),
+		tableLeft: newBudget(opts.MaxTableElems),
+	}
+	vm.refs.operands = &vm.operandStack
+	return vm
+}
+
+// init creates what the module defines, once its imports are linked
+func (vm *vm) init() {
 	vm.initFuncs()
 	vm.initTable()
 	vm.initMem()
+	vm.initTags()
 	vm.initGlobals()
-	vm.execStartFunc()
-	return vm
 }
 
 func (vm *vm) linkImports(mm map[string]instance.Module) {
@@ -73,23 +124,32 @@ func (vm *vm) linkImport(m instance.Module, imp binary.Import) {
 		if imp.Desc.Tag == binary.ImportTagFunc {
 			expectedFT := vm.module.TypeSec[imp.Desc.FuncType]
 			typeMatched = isFuncTypeMatch(expectedFT, x.Type())
-			vm.funcs = append(vm.funcs, newExternalFunc(expectedFT, x))
+			idx := uint32(len(vm.funcs))
+			vm.funcs = append(vm.funcs, newExternalFunc(idx, expectedFT, x))
 		}
 	case instance.Table:
 		if imp.Desc.Tag == binary.ImportTagTable {
-			typeMatched = isLimitsMatch(imp.Desc.Table.Limits, x.Type().Limits)
-			vm.table = x
+			typeMatched = x.Type().ElemType == imp.Desc.Table.ElemType &&
+				isLimitsMatch(imp.Desc.Table.Limits, x.Type().Limits)
+			vm.tables = append(vm.tables, x)
 		}
 	case instance.Memory:
 		if imp.Desc.Tag == binary.ImportTagMem {
-			typeMatched = isLimitsMatch(imp.Desc.Mem, x.Type())
-			vm.memory = x
+			typeMatched = isLimitsMatch(imp.Desc.Mem, x.Type()) &&
+				imp.Desc.Mem.Shared == x.Type().Shared
+			vm.memories = append(vm.memories, x)
 		}
 	case instance.Global:
 		if imp.Desc.Tag == binary.ImportTagGlobal {
 			typeMatched = isGlobalTypeMatch(imp.Desc.Global, x.Type())
 			vm.globals = append(vm.globals, x)
 		}
+	case instance.Tag:
+		if imp.Desc.Tag == binary.ImportTagTag {
+			expectedFT := vm.module.TypeSec[imp.Desc.TagType.Type]
+			typeMatched = isFuncTypeMatch(expectedFT, x.Type())
+			vm.tags = append(vm.tags, x)
+		}
 	}
 
 	if !typeMatched {
@@ -99,20 +159,48 @@ func (vm *vm) linkImport(m instance.Module, imp binary.Import) {
 }
 
 func (vm *vm) initMem() {
-	if len(vm.module.MemSec) > 0 {
-		vm.memory = newMemory(vm.module.MemSec[0])
+	for _, mt := range vm.module.MemSec {
+		vm.memories = append(vm.memories, vm.newMemory(mt))
+	}
+	vm.datas = make([][]byte, len(vm.module.DataSec))
+	for i, data := range vm.module.DataSec {
+		vm.datas[i] = data.Init
+		if data.Mode == binary.SegModeActive {
+			vm.execConstExpr(data.Offset)
+			offset := popAddr(vm, vm.memories[data.Mem])
+			vm.initMemory(data.Mem, uint32(i), offset, 0, uint64(len(data.Init)))
+			vm.datas[i] = nil
+		}
 	}
-	for _, data := range vm.module.DataSec {
-		vm.execConstExpr(data.Offset)
-		vm.memory.Write(vm.popU64(), data.Init)
+}
+
+// copies n bytes of data segment x from s to d in memory m
+func (vm *vm) initMemory(m, x uint32, d, s, n uint64) {
+	mem := vm.memories[m]
+	if outOfBounds(s, n, uint64(len(vm.datas[x]))) ||
+		outOfBounds(d, n, mem.Size()*binary.PageSize) {
+		panic(errMemOutOfBounds)
+	}
+	if n > 0 {
+		mem.Write(d, vm.datas[x][s:s+n])
+		if vm.hooks.mem != nil {
+			vm.hooks.mem.MemWrite(m, d, vm.datas[x][s:s+n])
+		}
+	}
+}
+
+func (vm *vm) initTags() {
+	for _, tt := range vm.module.TagSec {
+		vm.tags = append(vm.tags, newTag(vm.module.TypeSec[tt.Type]))
 	}
 }
 
 func (vm *vm) initGlobals() {
 	for _, global := range vm.module.GlobalSec {
 		vm.execConstExpr(global.Init)
-		vm.globals = append(vm.globals,
-			newGlobal(global.Type, vm.popU64()))
+		g := newGlobal(global.Type, 0)
+		g.Set(vm.popVal(global.Type.ValType))
+		vm.globals = append(vm.globals, g)
 	}
 }
 
@@ -120,25 +208,69 @@ func (vm *vm) initFuncs() {
 	for i, ftIdx := range vm.module.FuncSec {
 		ft := vm.module.TypeSec[ftIdx]
 		code := vm.module.CodeSec[i]
-		vm.funcs = append(vm.funcs, newInternalFunc(vm, ft, code))
+		idx := uint32(len(vm.funcs))
+		vm.funcs = append(vm.funcs, newInternalFunc(vm, idx, ft, code))
 	}
 }
 
 func (vm *vm) initTable() {
-	if len(vm.module.TableSec) > 0 {
-		vm.table = newTable(vm.module.TableSec[0])
-	}
-	for _, elem := range vm.module.ElemSec {
-		vm.execConstExpr(elem.Offset)
-		offset := vm.popU32()
-		for i, funcIdx := range elem.Init {
-			vm.table.SetElem(offset+uint32(i), vm.funcs[funcIdx])
+	for _, tt := range vm.module.TableSec {
+		vm.tables = append(vm.tables, vm.newTable(tt))
+	}
+	vm.elems = make([][]instance.WasmVal, len(vm.module.ElemSec))
+	for i, elem := range vm.module.ElemSec {
+		vm.elems[i] = vm.evalElem(elem)
+		switch elem.Mode {
+		case binary.SegModeActive:
+			vm.execConstExpr(elem.Offset)
+			offset := vm.popU32()
+			vm.initTableElems(elem.Table, uint32(i), offset, 0, uint32(elem.Len()))
+			vm.elems[i] = nil
+		case binary.SegModeDeclarative:
+			vm.elems[i] = nil
 		}
 	}
 }
 
+func (vm *vm) evalElem(elem binary.Elem) []instance.WasmVal {
+	refs := make([]instance.WasmVal, 0, elem.Len())
+	for _, funcIdx := range elem.Init {
+		refs = append(refs, vm.funcs[funcIdx])
+	}
+	for _, expr := range elem.Exprs {
+		vm.execConstExpr(expr)
+		refs = append(refs, vm.refs.Get(vm.popU64()))
+	}
+	return refs
+}
+
+// copies n refs of elem segment x from s to d in table t
+func (vm *vm) initTableElems(t, x, d, s, n uint32) {
+	table := vm.tables[t]
+	if uint64(s)+uint64(n) > uint64(len(vm.elems[x])) ||
+		uint64(d)+uint64(n) > uint64(table.Size()) {
+		panic(errTableOutOfBounds)
+	}
+	for i := uint32(0); i < n; i++ {
+		table.SetElem(d+i, vm.elems[x][s+i])
+	}
+}
+
+// only the instructions allowed in constant expressions are run
 func (vm *vm) execConstExpr(expr []binary.Instruction) {
 	for _, instr := range expr {
+		switch instr.Opcode {
+		case binary.I32Const, binary.I64Const, binary.F32Const, binary.F64Const,
+			binary.I32Add, binary.I32Sub, binary.I32Mul,
+			binary.I64Add, binary.I64Sub, binary.I64Mul,
+			binary.GlobalGet, binary.RefNull, binary.RefFunc:
+		case binary.SimdPrefix:
+			if instr.Args.(binary.PrefixArgs).SubOp != binary.V128Const {
+				panic(errConstExprRequired)
+			}
+		default:
+			panic(errConstExprRequired)
+		}
 		vm.execInstr(instr)
 	}
 }
@@ -162,13 +294,14 @@ func (vm *vm) enterBlock(opcode byte, bt binary.FuncType,
 
 func (vm *vm) exitBlock() {
 	cf := vm.popControlFrame()
+	if cf.opcode == binary.Call && vm.hooks.funcs != nil {
+		vm.exitFuncHook(cf)
+	}
 	vm.clearBlock(cf)
 }
 
 func (vm *vm) clearBlock(cf *controlFrame) {
-	results := vm.popU64s(len(cf.bt.ResultTypes))
-	vm.popU64s(vm.stackSize() - cf.bp)
-	vm.pushU64s(results)
+	vm.moveTop(len(cf.bt.ResultTypes), cf.bp)
 
 	if cf.opcode == binary.Call && vm.controlDepth() > 0 {
 		lastCallFrame, _ := vm.topCallFrame()
@@ -177,23 +310,86 @@ func (vm *vm) clearBlock(cf *controlFrame) {
 }
 
 func (vm *vm) resetBlock(cf *controlFrame) {
-	results := vm.popU64s(len(cf.bt.ParamTypes))
-	vm.popU64s(vm.stackSize() - cf.bp)
-	vm.pushU64s(results)
+	vm.moveTop(len(cf.bt.ParamTypes), cf.bp)
+}
+
+// pops control frames and operands down to depth and size
+func (vm *vm) unwind(depth, size int) {
+	if vm.hooks.funcs != nil {
+		for n := vm.controlDepth() - 1; n >= depth; n-- {
+			if cf := vm.frames[n]; cf.opcode == binary.Call {
+				vm.hooks.funcs.ExitFunc(cf.fn.idx, nil)
+			}
+		}
+	}
+	vm.frames = vm.frames[:depth]
+	vm.popU64s(vm.stackSize() - size)
+	if cf, _ := vm.topCallFrame(); cf != nil {
+		vm.local0Idx = uint32(cf.bp)
+	}
 }
 
 func (vm *vm) loop() {
-	depth := vm.controlDepth()
+	vm.loopUntil(vm.controlDepth())
+}
+
+// runs until the frame at depth exits
+func (vm *vm) loopUntil(depth int) {
+	if vm.profiler != nil {
+		defer vm.profiler.exitGuest()
+	}
+	for !vm.run(depth) {
+	}
+}
+
+// returns false if an exception was caught, the loop goes on
+// from its handler then
+func (vm *vm) run(depth int) (done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			exn, ok := r.(*instance.Exception)
+			if !ok || !vm.catchException(exn, depth) {
+				panic(r)
+			}
+		}
+	}()
+
 	for vm.controlDepth() >= depth {
 		cf := vm.topControlFrame()
 		if cf.pc == len(cf.instrs) {
 			vm.exitBlock()
 		} else {
 			instr := cf.instrs[cf.pc]
+			if vm.observed {
+				vm.beforeInstr(cf, instr)
+			}
 			cf.pc++
 			vm.execInstr(instr)
 		}
 	}
+	return true
+}
+
+// observe must follow any change to what runs before each
+// instruction, so that run checks a single flag
+func (vm *vm) observe() {
+	vm.observed = vm.debugger != nil || vm.hooks.instr != nil ||
+		vm.profiler != nil || vm.coverage != nil
+}
+
+func (vm *vm) beforeInstr(cf *controlFrame, instr binary.Instruction) {
+	if vm.debugger != nil {
+		vm.debugger.beforeInstr(cf)
+	}
+	if vm.hooks.instr != nil {
+		vm.hooks.instr.BeforeInstr(instr, vm.slots)
+	}
+	if vm.profiler != nil {
+		vm.profiler.beforeInstr()
+	}
+	if vm.coverage != nil {
+		vm.coverage.beforeInstr(instr)
+	}
 }
 
 func (vm *vm) execInstr(instr binary.Instruction) {
@@ -208,11 +404,13 @@ func (vm *vm) GetMember(name string) interface{} {
 			case binary.ExportTagFunc:
 				return vm.funcs[idx]
 			case binary.ExportTagTable:
-				return vm.table
+				return vm.tables[idx]
 			case binary.ExportTagMem:
-				return vm.memory
+				return vm.memories[idx]
 			case binary.ExportTagGlobal:
 				return vm.globals[idx]
+			case binary.ExportTagTag:
+				return vm.tags[idx]
 			}
 		}
 	}
@@ -260,6 +458,6 @@ func isGlobalTypeMatch(expected, actual binary.GlobalType) bool {
 }
 
 func isLimitsMatch(expected, actual binary.Limits) bool {
-	return actual.Min >= expected.Min &&
+	return actual.Is64 == expected.Is64 && actual.Min >= expected.Min &&
 		(expected.Max == 0 || actual.Max > 0 && actual.Max <= expected.Max)
 }
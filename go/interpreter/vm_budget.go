@@ -0,0 +1,38 @@
+package interpreter
+
+import "sync/atomic"
+
+// budget is shared by the tables, or the memories, an instance
+// defines. What they are created with or grow by is taken from it,
+// a nil budget has no limit.
+type budget struct {
+	left atomic.Uint64
+}
+
+func newBudget(n uint64) *budget {
+	b := &budget{}
+	b.left.Store(n)
+	return b
+}
+
+func (b *budget) take(n uint64) bool {
+	if b == nil {
+		return true
+	}
+	for {
+		left := b.left.Load()
+		if n > left {
+			return false
+		}
+		if b.left.CompareAndSwap(left, left-n) {
+			return true
+		}
+	}
+}
+
+// give returns what was taken and is no longer used
+func (b *budget) give(n uint64) {
+	if b != nil {
+		b.left.Add(n)
+	}
+}
@@ -14,24 +14,27 @@ type vmFunc struct {
 	code  binary.Code
 	_func instance.Function
 	vm    *vm
+	idx   uint32
 }
 
-func newExternalFunc(ft binary.FuncType,
-	f instance.Function) vmFunc {
+func newExternalFunc(idx uint32, ft binary.FuncType,
+	f instance.Function) *vmFunc {
 
-	return vmFunc{
+	return &vmFunc{
 		_type: ft,
 		_func: f,
+		idx:   idx,
 	}
 }
 
-func newInternalFunc(vm *vm, ft binary.FuncType,
-	code binary.Code) vmFunc {
+func newInternalFunc(vm *vm, idx uint32, ft binary.FuncType,
+	code binary.Code) *vmFunc {
 
-	return vmFunc{
+	return &vmFunc{
 		vm:    vm,
 		_type: ft,
 		code:  code,
+		idx:   idx,
 	}
 }
 
@@ -43,28 +46,54 @@ func (f vmFunc) Call(args ...WasmVal) ([]WasmVal, error) {
 	if f._func != nil {
 		return f._func.Call(args...)
 	}
+	if f.vm.recorder != nil {
+		return f.vm.recorder.guestCall(f, args)
+	}
 	return f.safeCall(args)
 }
 
-func (f vmFunc) safeCall(args []WasmVal) (results []WasmVal, err error) {
+func (f vmFunc) safeCall(args []WasmVal) ([]WasmVal, error) {
+	depth, size := f.vm.controlDepth(), f.vm.stackSize()
+	return f.safeRun(depth, size, func() []WasmVal {
+		return f.call(args)
+	})
+}
+
+// safeRun runs f, or resumes it, until it returns, traps or suspends.
+// depth and size are those of the stacks before f and its args.
+func (f vmFunc) safeRun(depth, size int,
+	run func() []WasmVal) (results []WasmVal, err error) {
+
+	f.vm.backtrace = nil
+	f.vm.entries++
 	defer func() {
+		f.vm.entries--
 		if _err := recover(); _err != nil {
+			if s, ok := _err.(*Suspension); ok {
+				s.f, s.depth, s.size = f, depth, size
+				err = s
+				return
+			}
+			if f.vm.backtrace == nil {
+				f.vm.backtrace = f.vm.callStack()
+			}
+			f.vm.unwind(depth, size)
 			switch x := _err.(type) {
 			case error:
 				err = x
 			default:
-				panic(err)
+				err = fmt.Errorf("%v", x)
 			}
 		}
 	}()
 
-	results = f.call(args)
+	results = run()
 	return
 }
 
 func (f vmFunc) call(args []interface{}) []interface{} {
 	pushArgs(f.vm, f._type, args)
-	callFunc(f.vm, f)
+	callFunc(f.vm, &f)
 	if f._func == nil {
 		f.vm.loop()
 	}
@@ -77,14 +106,48 @@ func pushArgs(vm *vm, ft binary.FuncType, args []interface{}) {
 			len(ft.ParamTypes), len(args)))
 	}
 	for i, vt := range ft.ParamTypes {
-		vm.pushU64(unwrapU64(vt, args[i]))
+		vm.pushVal(vt, args[i])
 	}
 }
 
 func popResults(vm *vm, ft binary.FuncType) []interface{} {
 	results := make([]interface{}, len(ft.ResultTypes))
 	for n := len(ft.ResultTypes) - 1; n >= 0; n-- {
-		results[n] = wrapU64(ft.ResultTypes[n], vm.popU64())
+		results[n] = vm.popVal(ft.ResultTypes[n])
 	}
 	return results
 }
+
+// StackFrame is an active function and the offset in the binary of
+// the instruction it is running, the call for the callers.
+type StackFrame struct {
+	FuncIdx uint32
+	Offset  uint32
+}
+
+// Backtrace returns the call stack of the last trap or uncaught
+// exception of an instance of this package, innermost first.
+func Backtrace(m instance.Module) []StackFrame {
+	if vm, ok := m.(*vm); ok {
+		return vm.backtrace
+	}
+	return nil
+}
+
+func (vm *vm) callStack() []StackFrame {
+	var stack []StackFrame
+	top := len(vm.frames) - 1 // innermost frame of each function
+	for i := top; i >= 0; i-- {
+		cf := vm.frames[i]
+		if cf.opcode != binary.Call {
+			continue
+		}
+		frame := StackFrame{FuncIdx: cf.fn.idx}
+		if inner := vm.frames[top]; inner.pc > 0 {
+			frame.Offset = inner.instrs[inner.pc-1].Offset
+		}
+		stack = append(stack, frame)
+		top = i - 1
+	}
+	return stack
+}
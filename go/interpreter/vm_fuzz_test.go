@@ -0,0 +1,112 @@
+package interpreter_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/internal/fuzzseed"
+	"wasm.go/interpreter"
+	"wasm.go/smith"
+)
+
+const (
+	fuzzMaxPages      = 16
+	fuzzMaxTableElems = 1000
+)
+
+func FuzzInstantiate(f *testing.F) {
+	fuzzseed.Add(f)
+	// 4 tables of 10M elements, 4 memories of 4GiB
+	f.Add([]byte("\x00asm\x01\x00\x00\x00\x04\x19\x04" +
+		strings.Repeat("\x70\x00\x80\xad\xe2\x04", 4)))
+	f.Add([]byte("\x00asm\x01\x00\x00\x00\x05\x11\x04" +
+		strings.Repeat("\x00\x80\x80\x04", 4)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		module, err := binary.Decode(data)
+		if err != nil {
+			return
+		}
+		// the host only provides memories which can't grow large
+		memCount, importedMems, importedTables := len(module.MemSec), 0, 0
+		for _, imp := range module.ImportSec {
+			switch imp.Desc.Tag {
+			case binary.ImportTagMem:
+				if mt := imp.Desc.Mem; mt.Tag == 0 || mt.Max > fuzzMaxPages {
+					return
+				}
+				importedMems++
+			case binary.ImportTagTable:
+				importedTables++
+			}
+		}
+		memCount += importedMems
+		pages, tableElems := uint64(0), uint64(0)
+		for _, mt := range module.MemSec {
+			pages += mt.Min
+		}
+		for _, tt := range module.TableSec {
+			tableElems += tt.Limits.Min
+		}
+		// the start function may never return, it runs on fuel once
+		// instantiated, and the memories are exported to be checked
+		start := module.StartSec
+		module.StartSec = nil
+		if start != nil {
+			module.ExportSec = append(module.ExportSec, binary.Export{Name: "\x00start",
+				Desc: binary.ExportDesc{Tag: binary.ExportTagFunc, Idx: *start}})
+		}
+		for i := 0; i < memCount; i++ {
+			module.ExportSec = append(module.ExportSec, binary.Export{Name: fmt.Sprint("\x00mem", i),
+				Desc: binary.ExportDesc{Tag: binary.ExportTagMem, Idx: uint32(i)}})
+		}
+		for i := range module.TableSec {
+			module.ExportSec = append(module.ExportSec, binary.Export{Name: fmt.Sprint("\x00table", i),
+				Desc: binary.ExportDesc{Tag: binary.ExportTagTable, Idx: uint32(importedTables + i)}})
+		}
+
+		opts := binary.DecodeOptions{MaxMemoryPages: fuzzMaxPages, MaxTableElems: fuzzMaxTableElems}
+		m, err := interpreter.NewWithOptions(module, smith.StubImports(module), opts)
+		if pages > fuzzMaxPages || tableElems > fuzzMaxTableElems {
+			require.Error(t, err)
+		}
+		if err != nil {
+			return
+		}
+		if start != nil {
+			require.NoError(t, interpreter.SetHooks(m, &fuel{100000}))
+			_, _ = m.InvokeFunc("\x00start")
+		}
+		pages = 0
+		for i := 0; i < memCount; i++ {
+			mem := m.GetMember(fmt.Sprint("\x00mem", i)).(instance.Memory)
+			require.LessOrEqual(t, mem.Size(), uint64(fuzzMaxPages))
+			if i >= importedMems {
+				pages += mem.Size()
+			}
+		}
+		require.LessOrEqual(t, pages, uint64(fuzzMaxPages))
+		tableElems = 0
+		for i := range module.TableSec {
+			tableElems += uint64(m.GetMember(fmt.Sprint("\x00table", i)).(instance.Table).Size())
+		}
+		require.LessOrEqual(t, tableElems, uint64(fuzzMaxTableElems))
+	})
+}
+
+var errOutOfFuel = errors.New("out of fuel")
+
+// fuel stops the guest after a number of instructions
+type fuel struct {
+	left int
+}
+
+func (f *fuel) BeforeInstr(instr binary.Instruction, stack []uint64) {
+	if f.left--; f.left < 0 {
+		panic(errOutOfFuel)
+	}
+}
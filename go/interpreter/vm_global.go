@@ -8,8 +8,11 @@ import (
 type globalVar struct {
 	_type binary.GlobalType
 	val   uint64
+	ref   instance.WasmVal // reference and vector types only
 }
 
+// val is ignored by reference types which start as null and by
+// vectors which start as zero
 func NewGlobal(vt binary.ValType, mut bool, val uint64) instance.Global {
 	gt := binary.GlobalType{ValType: vt}
 	if mut {
@@ -19,7 +22,11 @@ func NewGlobal(vt binary.ValType, mut bool, val uint64) instance.Global {
 }
 
 func newGlobal(gt binary.GlobalType, val uint64) *globalVar {
-	return &globalVar{_type: gt, val: val}
+	g := &globalVar{_type: gt, val: val}
+	if gt.ValType == binary.ValTypeV128 {
+		g.ref = instance.V128{}
+	}
+	return g
 }
 
 func (g *globalVar) Type() binary.GlobalType {
@@ -38,8 +45,20 @@ func (g *globalVar) SetAsU64(val uint64) {
 }
 
 func (g *globalVar) Get() instance.WasmVal {
+	if isBoxed(g._type.ValType) {
+		return g.ref
+	}
 	return wrapU64(g._type.ValType, g.val)
 }
 func (g *globalVar) Set(val instance.WasmVal) {
+	if isBoxed(g._type.ValType) {
+		g.ref = val
+		return
+	}
 	g.val = unwrapU64(g._type.ValType, val)
 }
+
+// references and vectors don't fit val
+func isBoxed(vt binary.ValType) bool {
+	return binary.IsRefType(vt) || vt == binary.ValTypeV128
+}
@@ -1,68 +1,312 @@
 package interpreter
 
 import (
+	"math"
+	"sync"
+	"time"
+
 	"wasm.go/binary"
 	"wasm.go/instance"
 )
 
+// shared memories lock themselves, waiters are kept by address
 type memory struct {
-	_type binary.MemType
-	data  []byte
+	_type   binary.MemType
+	data    []byte
+	mu      sync.RWMutex
+	waiters map[uint64][]chan struct{}
+	watch   memWatch // nil unless recorded
+	max     uint64   // pages it may grow to
+	budget  *budget  // nil if created by the host
+}
+
+// memWatch sees the changes the Write, RMW and Grow methods make
+type memWatch interface {
+	memWrite(offset uint64, data []byte)
+	memGrow(n uint64)
 }
 
-func NewMemory(min, max uint32) instance.Memory {
+func NewMemory(min, max uint64) instance.Memory {
 	mt := binary.MemType{Min: min, Max: max}
-	return newMemory(mt)
+	if max > 0 {
+		mt.Tag = 1
+	}
+	return newMemory(mt, binary.MaxPageCount)
+}
+
+// NewMemory64 creates a memory addressed by i64, without max it
+// grows up to DefaultDecodeOptions.MaxMemoryPages
+func NewMemory64(min, max uint64) instance.Memory {
+	mt := binary.MemType{Is64: true, Min: min, Max: max}
+	if max > 0 {
+		mt.Tag = 1
+		return newMemory(mt, max)
+	}
+	return newMemory(mt, binary.DefaultDecodeOptions.MaxMemoryPages)
+}
+
+// NewSharedMemory creates a memory which instances on separate
+// goroutines may import and access concurrently
+func NewSharedMemory(min, max uint64) instance.Memory {
+	mt := binary.MemType{Tag: 1, Shared: true, Min: min, Max: max}
+	return newMemory(mt, binary.MaxPageCount)
 }
 
-func newMemory(mt binary.MemType) *memory {
+// newMemory caps the memory at maxPages, or at MaxPageCount if it
+// is addressed by i32
+func newMemory(mt binary.MemType, maxPages uint64) *memory {
+	if !mt.Is64 && maxPages > binary.MaxPageCount {
+		maxPages = binary.MaxPageCount
+	}
+	if mt.Min > maxPages {
+		panic(errMemTooLarge)
+	}
+	if mt.Tag == 1 && mt.Max < maxPages {
+		maxPages = mt.Max
+	}
 	return &memory{
-		_type: mt,
-		data:  make([]byte, mt.Min*binary.PageSize),
+		_type:   mt,
+		data:    make([]byte, int(mt.Min)*binary.PageSize),
+		waiters: map[uint64][]chan struct{}{},
+		max:     maxPages,
+	}
+}
+
+// newMemory creates a memory taking its pages from the budget of vm
+func (vm *vm) newMemory(mt binary.MemType) *memory {
+	if !vm.pagesLeft.take(mt.Min) {
+		panic(errMemTooLarge)
 	}
+	mem := newMemory(mt, binary.MaxPageCount64)
+	mem.budget = vm.pagesLeft
+	return mem
 }
 
 func (mem *memory) Type() binary.MemType {
 	return mem._type
 }
 
-func (mem *memory) Size() uint32 {
-	return uint32(len(mem.data) / binary.PageSize)
+func (mem *memory) Size() uint64 {
+	if mem._type.Shared {
+		mem.mu.RLock()
+		defer mem.mu.RUnlock()
+	}
+	return uint64(len(mem.data) / binary.PageSize)
 }
 
-func (mem *memory) Grow(n uint32) uint32 {
-	oldSize := mem.Size()
+func (mem *memory) Grow(n uint64) uint64 {
+	if mem._type.Shared {
+		mem.mu.Lock()
+		defer mem.mu.Unlock()
+	}
+	oldSize := uint64(len(mem.data) / binary.PageSize)
 	if n == 0 {
 		return oldSize
 	}
 
-	maxPageCount := uint32(binary.MaxPageCount)
-	if max := mem._type.Max; max > 0 {
-		maxPageCount = max
-	}
-	if oldSize+n > maxPageCount {
-		return 0xFFFFFFFF // -1
+	if n > mem.max || oldSize+n > mem.max || !mem.budget.take(n) {
+		return math.MaxUint64 // -1
 	}
 
-	newData := make([]byte, (oldSize+n)*binary.PageSize)
+	if mem.watch != nil {
+		mem.watch.memGrow(n)
+	}
+	newLen := int(oldSize+n) * binary.PageSize
+	if newLen <= cap(mem.data) { // shrunk by reset
+		zero(mem.data[len(mem.data):newLen])
+		mem.data = mem.data[:newLen]
+		return oldSize
+	}
+	newData := make([]byte, newLen)
 	copy(newData, mem.data)
 	mem.data = newData
 	return oldSize
 }
 
+// reset resizes the memory to pages, keeping its allocation, and
+// fills it with data followed by zeros. Growing it is up to the
+// caller, the pages it shrinks by are given back.
+func (mem *memory) reset(pages uint64, data []byte) {
+	if mem._type.Shared {
+		mem.mu.Lock()
+		defer mem.mu.Unlock()
+	}
+	if oldSize := uint64(len(mem.data) / binary.PageSize); pages < oldSize {
+		mem.budget.give(oldSize - pages)
+	}
+	size := int(pages) * binary.PageSize
+	if size > cap(mem.data) {
+		mem.data = make([]byte, size)
+	} else {
+		mem.data = mem.data[:size]
+	}
+	n := copy(mem.data, data)
+	zero(mem.data[n:])
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func (mem *memory) Read(offset uint64, buf []byte) {
+	if mem._type.Shared {
+		mem.mu.RLock()
+		defer mem.mu.RUnlock()
+	}
 	mem.checkOffset(offset, len(buf))
 	copy(buf, mem.data[offset:])
 }
 
 func (mem *memory) Write(offset uint64, data []byte) {
+	if mem._type.Shared {
+		mem.mu.Lock()
+		defer mem.mu.Unlock()
+	}
 	mem.checkOffset(offset, len(data))
 	copy(mem.data[offset:], data)
+	if mem.watch != nil {
+		mem.watch.memWrite(offset, data)
+	}
 }
 
 func (mem *memory) checkOffset(offset uint64, length int) {
-	if int64(len(mem.data)-length) < int64(offset) {
+	if outOfBounds(offset, uint64(length), uint64(len(mem.data))) {
 		panic(errMemOutOfBounds)
 	}
 }
 
+func (mem *memory) RMW(offset uint64, n int, f func(old uint64) uint64) uint64 {
+	if mem._type.Shared {
+		mem.mu.Lock()
+		defer mem.mu.Unlock()
+	}
+	old := mem.rmw(offset, n, f)
+	if mem.watch != nil && f != nil {
+		mem.watch.memWrite(offset, mem.data[offset:offset+uint64(n)])
+	}
+	return old
+}
+
+// reads n little-endian bytes and writes back f(old) unless f is nil
+func (mem *memory) rmw(offset uint64, n int, f func(old uint64) uint64) uint64 {
+	mem.checkOffset(offset, n)
+	var buf [8]byte
+	copy(buf[:n], mem.data[offset:])
+	old := byteOrder.Uint64(buf[:])
+	if f != nil {
+		byteOrder.PutUint64(buf[:], f(old))
+		copy(mem.data[offset:], buf[:n])
+	}
+	return old
+}
+
+// returns 0 when notified, 1 when the value differs and 2 on timeout,
+// a negative timeout waits forever
+func (mem *memory) Wait(offset uint64, n int, expected uint64, timeout int64) uint32 {
+	mem.mu.Lock()
+	if mem.rmw(offset, n, nil) != expected {
+		mem.mu.Unlock()
+		return 1
+	}
+	w := make(chan struct{})
+	mem.waiters[offset] = append(mem.waiters[offset], w)
+	mem.mu.Unlock()
+
+	if timeout < 0 {
+		<-w
+		return 0
+	}
+	timer := time.NewTimer(time.Duration(timeout))
+	defer timer.Stop()
+	select {
+	case <-w:
+		return 0
+	case <-timer.C:
+	}
+
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	select {
+	case <-w: // notified meanwhile
+		return 0
+	default:
+	}
+	ws := mem.waiters[offset]
+	for i := range ws {
+		if ws[i] == w {
+			mem.waiters[offset] = append(ws[:i:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(mem.waiters[offset]) == 0 {
+		delete(mem.waiters, offset)
+	}
+	return 2
+}
+
+// wakes up at most count waiters in arrival order
+func (mem *memory) Notify(offset uint64, count uint32) uint32 {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	ws := mem.waiters[offset]
+	if uint64(count) < uint64(len(ws)) {
+		ws = ws[:count]
+	}
+	for _, w := range ws {
+		close(w)
+	}
+	if rest := mem.waiters[offset][len(ws):]; len(rest) > 0 {
+		mem.waiters[offset] = rest
+	} else {
+		delete(mem.waiters, offset)
+	}
+	return uint32(len(ws))
+}
+
+// AtomicRMW atomically replaces the n bytes at offset with f(old) and
+// returns old, f is nil for loads
+func AtomicRMW(mem instance.Memory, offset uint64, n int, f func(old uint64) uint64) uint64 {
+	checkAtomic(mem, offset, n)
+	if am, ok := mem.(instance.AtomicMemory); ok {
+		return am.RMW(offset, n, f)
+	}
+	var buf [8]byte
+	mem.Read(offset, buf[:n])
+	old := byteOrder.Uint64(buf[:])
+	if f != nil {
+		byteOrder.PutUint64(buf[:], f(old))
+		mem.Write(offset, buf[:n])
+	}
+	return old
+}
+
+// AtomicWait implements memory.atomic.wait32/64, timeout is in ns
+func AtomicWait(mem instance.Memory, offset uint64, n int, expected uint64, timeout int64) uint32 {
+	checkAtomic(mem, offset, n)
+	am, ok := mem.(instance.AtomicMemory)
+	if !ok || !mem.Type().Shared {
+		panic(errExpectedShared)
+	}
+	return am.Wait(offset, n, expected, timeout)
+}
+
+// AtomicNotify implements memory.atomic.notify, nobody waits on
+// unshared memories
+func AtomicNotify(mem instance.Memory, offset uint64, count uint32) uint32 {
+	checkAtomic(mem, offset, 4)
+	if am, ok := mem.(instance.AtomicMemory); ok && mem.Type().Shared {
+		return am.Notify(offset, count)
+	}
+	return 0
+}
+
+func checkAtomic(mem instance.Memory, offset uint64, n int) {
+	if outOfBounds(offset, uint64(n), mem.Size()*binary.PageSize) {
+		panic(errMemOutOfBounds)
+	}
+	if offset%uint64(n) != 0 {
+		panic(errUnalignedAtomic)
+	}
+}
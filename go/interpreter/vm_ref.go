@@ -0,0 +1,107 @@
+package interpreter
+
+import "reflect"
+
+// RefStore maps references to operand stack slots and back, 0 is
+// the null reference. Funcrefs are instance.Function values and
+// externrefs are opaque Go values, both are nil if null.
+//
+// The indexes of refs no operand holds anymore are reused once the
+// store has grown enough. Operands aren't typed, so any of them
+// which looks like an index keeps its ref. Without an operand stack
+// to scan, refs are only forgotten by reset.
+type RefStore struct {
+	refs     []WasmVal          // nil if free
+	idxs     map[WasmVal]uint64 // hashable refs only
+	free     []uint64
+	operands *operandStack // nil if not scanned
+	limit    int           // len(refs) which triggers a collection
+}
+
+// no collection before the store holds minRefs refs
+const minRefs = 256
+
+func (s *RefStore) Put(ref WasmVal) uint64 {
+	if ref == nil {
+		return 0
+	}
+	hashable := isHashable(ref)
+	if hashable {
+		if idx, ok := s.idxs[ref]; ok {
+			return idx
+		}
+	}
+	if len(s.free) == 0 && s.operands != nil &&
+		len(s.refs) >= minRefs && len(s.refs) >= s.limit {
+		s.collect()
+	}
+	var idx uint64
+	if n := len(s.free); n > 0 {
+		idx = s.free[n-1]
+		s.free = s.free[:n-1]
+		s.refs[idx-1] = ref
+	} else {
+		s.refs = append(s.refs, ref)
+		idx = uint64(len(s.refs))
+	}
+	if hashable {
+		if s.idxs == nil {
+			s.idxs = map[WasmVal]uint64{}
+		}
+		s.idxs[ref] = idx
+	}
+	return idx
+}
+
+func (s *RefStore) Get(idx uint64) WasmVal {
+	if idx == 0 {
+		return nil
+	}
+	return s.refs[idx-1]
+}
+
+// map keys of these kinds compare by identity or value without
+// panicking, unlike structs and arrays which may hold slices
+func isHashable(ref WasmVal) bool {
+	switch k := reflect.TypeOf(ref).Kind(); {
+	case k <= reflect.Complex128: // bools and numbers
+		return true
+	case k == reflect.Pointer, k == reflect.Chan, k == reflect.String,
+		k == reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// collect frees the refs whose index no operand holds
+func (s *RefStore) collect() {
+	held := make([]bool, len(s.refs)+1)
+	for _, val := range s.operands.slots {
+		if val <= uint64(len(s.refs)) {
+			held[val] = true
+		}
+	}
+	for i, ref := range s.refs {
+		if idx := uint64(i + 1); ref != nil && !held[idx] {
+			if isHashable(ref) {
+				delete(s.idxs, ref)
+			}
+			s.refs[i] = nil
+			s.free = append(s.free, idx)
+		}
+	}
+	s.limit = 2 * (len(s.refs) - len(s.free))
+}
+
+// reset forgets all refs, which no slot may hold anymore
+func (s *RefStore) reset() {
+	for i := range s.refs {
+		s.refs[i] = nil
+	}
+	s.refs = s.refs[:0]
+	s.free = s.free[:0]
+	s.limit = 0
+	for ref := range s.idxs {
+		delete(s.idxs, ref)
+	}
+}
@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/instance"
+)
+
+func TestRefStore(t *testing.T) {
+	var s RefStore
+	require.Equal(t, uint64(0), s.Put(nil))
+	require.Nil(t, s.Get(0))
+
+	a, b := s.Put("a"), s.Put([]int{1})
+	require.Equal(t, a, s.Put("a"))
+	require.NotEqual(t, a, b)
+	require.Equal(t, "a", s.Get(a))
+	require.Equal(t, []int{1}, s.Get(b))
+
+	// comparable, but hashing it would panic
+	type wrapper struct{ x interface{} }
+	require.NotPanics(t, func() { s.Put(wrapper{[]int{1}}) })
+}
+
+func TestRefStoreCollect(t *testing.T) {
+	var stack operandStack
+	s := RefStore{operands: &stack}
+	held := s.Put([]int{1})
+	stack.pushU64(held)
+	for i := 0; i < 10*minRefs; i++ {
+		s.Put([]int{2})
+	}
+	require.LessOrEqual(t, len(s.refs), 2*minRefs)
+	require.Equal(t, []int{1}, s.Get(held))
+
+	m := newTestInstance(t, "ref_types", nil)
+	invoke := invoker(t, m)
+	invoke("set_g", func() {})
+	for i := 0; i < 10*minRefs; i++ {
+		invoke("get_g")
+	}
+	require.LessOrEqual(t, len(m.(*vm).refs.refs), 2*minRefs)
+}
+
+func TestRefTypes(t *testing.T) {
+	m := newTestInstance(t, "ref_types", nil)
+	invoke := invoker(t, m)
+
+	// externref
+	host := &struct{ name string }{"host"}
+	require.Equal(t, host, invoke("id", host)[0])
+	require.Nil(t, invoke("id", nil)[0])
+	require.Equal(t, []instance.WasmVal{int32(1)}, invoke("is_null", nil))
+	require.Equal(t, []instance.WasmVal{int32(0)}, invoke("is_null", host))
+	require.Nil(t, invoke("get_g")[0])
+	invoke("set_g", host)
+	require.Equal(t, host, invoke("get_g")[0])
+	require.Equal(t, host, invoke("call", int32(0), host)[0])
+
+	// table
+	require.Equal(t, []instance.WasmVal{int32(2)}, invoke("size"))
+	require.Equal(t, []instance.WasmVal{int32(2)}, invoke("grow", int32(2)))
+	require.Equal(t, []instance.WasmVal{int32(-1)}, invoke("grow", int32(1)))
+	require.Equal(t, []instance.WasmVal{int32(4)}, invoke("size"))
+	require.Nil(t, invoke("get", int32(3))[0])
+	invoke("fill", int32(2), int32(2))
+	f, ok := invoke("get", int32(3))[0].(instance.Function)
+	require.True(t, ok)
+	require.Equal(t, "(externref)->(externref)", f.Type().GetSignature())
+	require.Equal(t, host, invoke("call", int32(3), host)[0])
+
+	_, err := m.InvokeFunc("call", int32(1), host)
+	require.EqualError(t, err, "uninitialized element")
+	_, err = m.InvokeFunc("get", int32(4))
+	require.EqualError(t, err, "out of bounds table access")
+	_, err = m.InvokeFunc("fill", int32(3), int32(2))
+	require.EqualError(t, err, "out of bounds table access")
+
+	// funcref
+	require.Equal(t, f, invoke("select", f, nil, int32(1))[0])
+	require.Nil(t, invoke("select", f, nil, int32(0))[0])
+	_, err = m.InvokeFunc("select", host, nil, int32(1))
+	require.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package interpreter
 
-import "wasm.go/binary"
+import (
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
 
 type controlFrame struct {
 	opcode byte
@@ -8,11 +11,14 @@ type controlFrame struct {
 	instrs []binary.Instruction
 	bp     int
 	pc     int
+	args   interface{}         // try and try_table args, for the handlers
+	exn    *instance.Exception // caught exception, for rethrow
+	fn     *vmFunc             // called function, call frames only
 }
 
 func newControlFrame(opcode byte, bt binary.FuncType,
 	instrs []binary.Instruction, bp int) *controlFrame {
-	return &controlFrame{opcode, bt, instrs, bp, 0}
+	return &controlFrame{opcode: opcode, bt: bt, instrs: instrs, bp: bp}
 }
 
 type controlStack struct {
@@ -37,6 +43,15 @@ func (cs *controlStack) topControlFrame() *controlFrame {
 	return cs.frames[len(cs.frames)-1]
 }
 
+// index of the innermost call frame in frames, -1 if none
+func (cs *controlStack) callFramePos() int {
+	_, labelIdx := cs.topCallFrame()
+	if labelIdx < 0 {
+		return -1
+	}
+	return len(cs.frames) - 1 - labelIdx
+}
+
 func (cs *controlStack) topCallFrame() (*controlFrame, int) {
 	for n := len(cs.frames) - 1; n >= 0; n-- {
 		if cf := cs.frames[n]; cf.opcode == binary.Call {
@@ -1,12 +1,22 @@
 package interpreter
 
-import "math"
+import (
+	"math"
 
+	"wasm.go/instance"
+)
+
+// v128 operands keep their high halves in hi at the same index, it
+// only grows once vectors are used and scalar pushes clear it
 type operandStack struct {
 	slots []uint64
+	hi    []uint64
 }
 
 func (s *operandStack) pushU64(val uint64) {
+	if len(s.hi) > len(s.slots) {
+		s.hi[len(s.slots)] = 0
+	}
 	s.slots = append(s.slots, val)
 }
 
@@ -68,6 +78,34 @@ func (s *operandStack) popBool() bool {
 	return s.popU64() != 0
 }
 
+func (s *operandStack) pushV128(val instance.V128) {
+	s.pushU64(byteOrder.Uint64(val[:8]))
+	s.setHi(len(s.slots)-1, byteOrder.Uint64(val[8:]))
+}
+
+func (s *operandStack) popV128() (val instance.V128) {
+	byteOrder.PutUint64(val[8:], s.getHi(len(s.slots)-1))
+	byteOrder.PutUint64(val[:8], s.popU64())
+	return
+}
+
+func (s *operandStack) getHi(idx int) uint64 {
+	if idx < len(s.hi) {
+		return s.hi[idx]
+	}
+	return 0
+}
+
+func (s *operandStack) setHi(idx int, val uint64) {
+	if idx >= len(s.hi) {
+		if val == 0 {
+			return
+		}
+		s.hi = append(s.hi, make([]uint64, idx+1-len(s.hi))...)
+	}
+	s.hi[idx] = val
+}
+
 func (s *operandStack) stackSize() int {
 	return len(s.slots)
 }
@@ -76,10 +114,6 @@ func (s *operandStack) getOperand(idx uint32) uint64 {
 	return s.slots[idx]
 }
 
-func (s *operandStack) pushU64s(vals []uint64) {
-	s.slots = append(s.slots, vals...)
-}
-
 func (s *operandStack) popU64s(n int) []uint64 {
 	vals := s.slots[len(s.slots)-n:]
 	s.slots = s.slots[:len(s.slots)-n]
@@ -89,3 +123,32 @@ func (s *operandStack) popU64s(n int) []uint64 {
 func (s *operandStack) setOperand(idx uint32, val uint64) {
 	s.slots[idx] = val
 }
+
+// the following move whole operands, vectors included
+
+// pushes a copy of the operand at idx
+func (s *operandStack) pushOperand(idx uint32) {
+	s.pushU64(s.slots[idx])
+	s.setHi(len(s.slots)-1, s.getHi(int(idx)))
+}
+
+// pops the top operand into idx
+func (s *operandStack) popOperand(idx uint32) {
+	top := len(s.slots) - 1
+	s.slots[idx] = s.slots[top]
+	s.setHi(int(idx), s.getHi(top))
+	s.slots = s.slots[:top]
+}
+
+// moves the top n operands down to bp, dropping those in between
+func (s *operandStack) moveTop(n, bp int) {
+	top := len(s.slots) - n
+	if top == bp {
+		return
+	}
+	copy(s.slots[bp:], s.slots[top:])
+	for i := 0; i < n; i++ {
+		s.setHi(bp+i, s.getHi(top+i))
+	}
+	s.slots = s.slots[:bp+n]
+}
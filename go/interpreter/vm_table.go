@@ -5,41 +5,81 @@ import (
 	"wasm.go/instance"
 )
 
+// same as the limit of web browsers
+const maxTableSize = 10000000
+
 type table struct {
-	_type binary.TableType
-	elems []instance.Function
+	_type  binary.TableType
+	elems  []instance.WasmVal
+	budget *budget // nil if created by the host
+}
+
+func NewTable(elemType byte, min, max uint32) instance.Table {
+	tt := binary.TableType{
+		ElemType: elemType,
+		Limits:   binary.Limits{Min: uint64(min), Max: uint64(max)},
+	}
+	if max > 0 {
+		tt.Limits.Tag = 1
+	}
+	return newTable(tt)
 }
 
 func newTable(tt binary.TableType) *table {
+	if tt.Limits.Min > maxTableSize {
+		panic(errTableTooLarge)
+	}
 	return &table{
 		_type: tt,
-		elems: make([]instance.Function, tt.Limits.Min),
+		elems: make([]instance.WasmVal, tt.Limits.Min),
 	}
 }
 
-func (t *table) GetElem(idx uint32) instance.Function {
-	t.checkIdx(idx)
-	elem := t.elems[idx]
-	if elem == nil {
-		panic(errUninitializedElem)
+// newTable creates a table taking its elements from the budget of vm
+func (vm *vm) newTable(tt binary.TableType) *table {
+	if !vm.tableLeft.take(tt.Limits.Min) {
+		panic(errTableTooLarge)
 	}
-	return elem
+	t := newTable(tt)
+	t.budget = vm.tableLeft
+	return t
 }
 
-func (t *table) SetElem(idx uint32, elem instance.Function) {
+func (t *table) GetElem(idx uint32) instance.WasmVal {
+	t.checkIdx(idx)
+	return t.elems[idx]
+}
+
+func (t *table) SetElem(idx uint32, elem instance.WasmVal) {
 	t.checkIdx(idx)
 	t.elems[idx] = elem
 }
 
 func (t *table) checkIdx(idx uint32) {
 	if idx >= uint32(len(t.elems)) {
-		panic(errUndefinedElem)
+		panic(errTableOutOfBounds)
+	}
+}
+
+func (t *table) Grow(n uint32, init instance.WasmVal) uint32 {
+	oldSize := t.Size()
+	max := uint32(maxTableSize)
+	if t._type.Limits.Tag == 1 && t._type.Limits.Max < uint64(max) {
+		max = uint32(t._type.Limits.Max)
+	}
+	if uint64(oldSize)+uint64(n) > uint64(max) || !t.budget.take(uint64(n)) {
+		return 0xFFFFFFFF // -1
+	}
+	for i := uint32(0); i < n; i++ {
+		t.elems = append(t.elems, init)
 	}
+	return oldSize
 }
 
-func (t *table) Grow(n uint32) {
-	// TODO: check max
-	t.elems = append(t.elems, make([]instance.Function, n)...)
+// truncate shrinks the table to n elements, giving the others back
+func (t *table) truncate(n uint32) {
+	t.budget.give(uint64(len(t.elems)) - uint64(n))
+	t.elems = t.elems[:n]
 }
 
 func (t *table) Size() uint32 {
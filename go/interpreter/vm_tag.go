@@ -0,0 +1,22 @@
+package interpreter
+
+import (
+	"wasm.go/binary"
+	"wasm.go/instance"
+)
+
+type tag struct {
+	_type binary.FuncType
+}
+
+func NewTag(paramTypes ...binary.ValType) instance.Tag {
+	return newTag(binary.FuncType{ParamTypes: paramTypes})
+}
+
+func newTag(ft binary.FuncType) *tag {
+	return &tag{_type: ft}
+}
+
+func (t *tag) Type() binary.FuncType {
+	return t._type
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"wasm.go/binary"
+	"wasm.go/instance"
 )
 
 func TestOperandStack(t *testing.T) {
@@ -41,16 +42,16 @@ func TestLocalVar(t *testing.T) {
 }
 
 func TestMem(t *testing.T) {
-	mem := newMemory(binary.MemType{Min: 1})
+	mem := newMemory(binary.MemType{Min: 1}, binary.MaxPageCount)
 
 	buf := []byte{0x01, 0x02, 0x03}
 	mem.Write(10, buf)
 	mem.Read(11, buf)
 	require.Equal(t, []byte{0x02, 0x03, 0x00}, buf)
 
-	require.Equal(t, uint32(1), mem.Size())
-	require.Equal(t, uint32(1), mem.Grow(3))
-	require.Equal(t, uint32(4), mem.Size())
+	require.Equal(t, uint64(1), mem.Size())
+	require.Equal(t, uint64(1), mem.Grow(3))
+	require.Equal(t, uint64(4), mem.Size())
 }
 
 func TestGlobalVar(t *testing.T) {
@@ -61,3 +62,71 @@ func TestGlobalVar(t *testing.T) {
 	g.SetAsU64(100)
 	require.Equal(t, uint64(100), g.GetAsU64())
 }
+
+func TestExtendedConst(t *testing.T) {
+	env := instance.NewNativeInstance()
+	env.Register("base", NewGlobal(binary.ValTypeI32, false, 16))
+	env.Register("base64", NewGlobal(binary.ValTypeI64, false, 1<<40))
+	m := newTestInstance(t, "extended_const", instance.Map{"env": env})
+
+	expect := func(expected instance.WasmVal, name string, args ...instance.WasmVal) {
+		results, err := m.InvokeFunc(name, args...)
+		require.NoError(t, err, name)
+		require.Equal(t, []instance.WasmVal{expected}, results, name)
+	}
+	expect(int32(20), "g1")
+	expect(int64(3<<32), "g2")
+	expect(int32(-1), "g3")
+	expect(int32(0), "g4")
+	expect(int64(1<<40-6), "g5")
+	expect(int32('h'|'i'<<8), "load", int32(32))
+	expect(int32(7), "call", int32(4))
+
+	// only constant instructions are evaluated
+	vm := m.(*vm)
+	require.PanicsWithValue(t, errConstExprRequired, func() {
+		vm.execConstExpr([]binary.Instruction{{Opcode: binary.I32Const, Args: int32(1)},
+			{Opcode: binary.I32DivS}})
+	})
+}
+
+func TestBacktrace(t *testing.T) {
+	module := decodeTestdata(t, "trap")
+	m, err := New(module, nil)
+	require.NoError(t, err)
+
+	_, err = m.InvokeFunc("main", int32(0))
+	require.EqualError(t, err, "runtime error: integer divide by zero")
+	div := module.CodeSec[0].Expr[2]
+	call := module.CodeSec[1].Expr[0].Args.(binary.BlockArgs).Instrs[2]
+	require.Equal(t, "i32.div_u", div.GetOpname())
+	require.Equal(t, "call", call.GetOpname())
+	require.Equal(t, []StackFrame{{0, div.Offset}, {1, call.Offset}}, Backtrace(m))
+
+	_, err = m.InvokeFunc("main", int32(1))
+	require.NoError(t, err)
+	require.Nil(t, Backtrace(m))
+}
+
+// decodeTestdata decodes testdata/name.wasm, which is compiled from
+// the .wat next to it
+func decodeTestdata(t *testing.T, name string) binary.Module {
+	module, err := binary.DecodeFile("testdata/" + name + ".wasm")
+	require.NoError(t, err)
+	return module
+}
+
+func newTestInstance(t *testing.T, name string, mm instance.Map) instance.Module {
+	m, err := New(decodeTestdata(t, name), mm)
+	require.NoError(t, err)
+	return m
+}
+
+// invoker calls the exports of m, failing t if they fail
+func invoker(t *testing.T, m instance.Module) func(string, ...WasmVal) []WasmVal {
+	return func(name string, args ...WasmVal) []WasmVal {
+		results, err := m.InvokeFunc(name, args...)
+		require.NoError(t, err, name)
+		return results
+	}
+}
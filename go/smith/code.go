@@ -0,0 +1,556 @@
+package smith
+
+import (
+	"math"
+
+	"wasm.go/binary"
+)
+
+// same rules as the control frames of codeValidator
+type ctrlFrame struct {
+	opcode      byte
+	endTypes    []binary.ValType
+	height      int
+	unreachable bool
+}
+
+func (frame ctrlFrame) labelTypes() []binary.ValType {
+	if frame.opcode == binary.Loop {
+		return nil // block types never have params
+	}
+	return frame.endTypes
+}
+
+type codeGen struct {
+	*generator
+	ft     binary.FuncType
+	locals []binary.ValType // params & locals
+	opds   []binary.ValType
+	ctrls  []ctrlFrame
+	budget int // instructions left
+}
+
+func (g *generator) genCode(ft binary.FuncType) binary.Code {
+	cg := &codeGen{
+		generator: g,
+		ft:        ft,
+		budget:    maxInstrs,
+	}
+	cg.locals = append(cg.locals, ft.ParamTypes...)
+
+	var code binary.Code
+	for i := g.s.intn(maxLocals + 1); i > 0; i-- {
+		locals := binary.Locals{
+			N:    uint32(1 + g.s.intn(2)),
+			Type: valTypes[g.s.intn(len(valTypes))],
+		}
+		code.Locals = append(code.Locals, locals)
+		for j := uint32(0); j < locals.N; j++ {
+			cg.locals = append(cg.locals, locals.Type)
+		}
+	}
+
+	cg.pushCtrl(binary.Block, ft.ResultTypes)
+	code.Expr = append(cg.genFuelCheck(), cg.genInstrs()...)
+	cg.popCtrl()
+	return code
+}
+
+/* stack */
+
+func (cg *codeGen) push(vts ...binary.ValType) {
+	cg.opds = append(cg.opds, vts...)
+}
+
+func (cg *codeGen) pop(n int) {
+	cg.opds = cg.opds[:len(cg.opds)-n]
+}
+
+func (cg *codeGen) top() *ctrlFrame {
+	return &cg.ctrls[len(cg.ctrls)-1]
+}
+
+// whether the operands of the current frame end with vts
+func (cg *codeGen) hasTop(vts ...binary.ValType) bool {
+	n := len(cg.opds) - cg.top().height
+	if n < len(vts) {
+		return false
+	}
+	for i, vt := range vts {
+		if cg.opds[len(cg.opds)-len(vts)+i] != vt {
+			return false
+		}
+	}
+	return true
+}
+
+func (cg *codeGen) pushCtrl(opcode byte, endTypes []binary.ValType) {
+	cg.ctrls = append(cg.ctrls, ctrlFrame{
+		opcode:   opcode,
+		endTypes: endTypes,
+		height:   len(cg.opds),
+	})
+}
+
+func (cg *codeGen) popCtrl() ctrlFrame {
+	frame := *cg.top()
+	cg.opds = cg.opds[:frame.height]
+	cg.ctrls = cg.ctrls[:len(cg.ctrls)-1]
+	return frame
+}
+
+/* instructions */
+
+// generates the rest of the current frame, leaving exactly its end types
+// on the stack unless the code became unreachable
+func (cg *codeGen) genInstrs() []binary.Instruction {
+	var instrs []binary.Instruction
+	for cg.budget > 0 && cg.s.intn(16) != 0 {
+		cg.budget--
+		candidates := cg.candidates()
+		instrs = append(instrs, candidates[cg.s.intn(len(candidates))]()...)
+		if cg.top().unreachable {
+			return instrs
+		}
+	}
+	return append(instrs, cg.genFixStack()...)
+}
+
+func (cg *codeGen) genFixStack() []binary.Instruction {
+	frame := cg.top()
+	if len(cg.opds)-frame.height == len(frame.endTypes) &&
+		cg.hasTop(frame.endTypes...) {
+		return nil
+	}
+
+	var instrs []binary.Instruction
+	for len(cg.opds) > frame.height {
+		instrs = append(instrs, binary.Instruction{Opcode: binary.Drop})
+		cg.pop(1)
+	}
+	for _, vt := range frame.endTypes {
+		instrs = append(instrs, cg.genConst(vt))
+	}
+	return instrs
+}
+
+type candidate = func() []binary.Instruction
+
+func (cg *codeGen) candidates() []candidate {
+	candidates := []candidate{
+		cg.genNop,
+		func() []binary.Instruction {
+			return []binary.Instruction{cg.genConst(valTypes[cg.s.intn(4)])}
+		},
+	}
+	add := func(c candidate) { candidates = append(candidates, c) }
+
+	if len(cg.locals) > 0 {
+		add(cg.genLocalGet)
+		add(cg.genLocalSet)
+	}
+	if len(cg.globals) > 1 {
+		add(cg.genGlobalGet)
+		add(cg.genGlobalSet)
+	}
+	if len(cg.opds) > cg.top().height {
+		add(cg.genDrop)
+		add(cg.genNumeric)
+		add(cg.genSelect)
+	}
+	if cg.s.intn(8) == 0 {
+		add(cg.genUnreachable)
+	}
+	if len(cg.ctrls) < maxDepth {
+		add(func() []binary.Instruction { return cg.genBlock(binary.Block) })
+		add(func() []binary.Instruction { return cg.genBlock(binary.Loop) })
+		if cg.hasTop(i32) {
+			add(func() []binary.Instruction { return cg.genBlock(binary.If) })
+		}
+	}
+	add(cg.genBr)
+	add(cg.genCall)
+	if cg.hasTop(cg.ft.ResultTypes...) {
+		add(cg.genReturn)
+	}
+	if cg.hasTable {
+		add(cg.genCallIndirect)
+	}
+	if cg.hasMem {
+		add(cg.genLoad)
+		add(cg.genStore)
+		add(cg.genMemorySize)
+		if cg.hasTop(i32) {
+			add(cg.genMemoryGrow)
+		}
+	}
+	return candidates
+}
+
+func (cg *codeGen) genNop() []binary.Instruction {
+	return []binary.Instruction{{Opcode: binary.Nop}}
+}
+
+func (cg *codeGen) genUnreachable() []binary.Instruction {
+	cg.top().unreachable = true
+	return []binary.Instruction{{Opcode: binary.Unreachable}}
+}
+
+func (cg *codeGen) genConst(vt binary.ValType) binary.Instruction {
+	cg.push(vt)
+	switch vt {
+	case i32:
+		return binary.Instruction{Opcode: binary.I32Const, Args: cg.genI32()}
+	case i64:
+		return binary.Instruction{Opcode: binary.I64Const, Args: cg.genI64()}
+	case f32:
+		return binary.Instruction{Opcode: binary.F32Const, Args: cg.genF32()}
+	default:
+		return binary.Instruction{Opcode: binary.F64Const, Args: cg.genF64()}
+	}
+}
+
+// small values and boundaries are more interesting than random bits
+func (cg *codeGen) genI32() int32 {
+	switch cg.s.intn(4) {
+	case 0:
+		return int32(cg.s.intn(16))
+	case 1:
+		return []int32{-1, math.MinInt32, math.MaxInt32}[cg.s.intn(3)]
+	default:
+		return int32(cg.s.u32())
+	}
+}
+func (cg *codeGen) genI64() int64 {
+	switch cg.s.intn(4) {
+	case 0:
+		return int64(cg.s.intn(16))
+	case 1:
+		return []int64{-1, math.MinInt64, math.MaxInt64}[cg.s.intn(3)]
+	default:
+		return int64(cg.s.u64())
+	}
+}
+func (cg *codeGen) genF32() float32 {
+	switch cg.s.intn(4) {
+	case 0:
+		return float32(cg.s.intn(16))
+	case 1:
+		return []float32{float32(math.NaN()), float32(math.Inf(1)),
+			float32(math.Inf(-1)), float32(math.Copysign(0, -1))}[cg.s.intn(4)]
+	default:
+		return math.Float32frombits(cg.s.u32())
+	}
+}
+func (cg *codeGen) genF64() float64 {
+	switch cg.s.intn(4) {
+	case 0:
+		return float64(cg.s.intn(16))
+	case 1:
+		return []float64{math.NaN(), math.Inf(1), math.Inf(-1),
+			math.Copysign(0, -1)}[cg.s.intn(4)]
+	default:
+		return math.Float64frombits(cg.s.u64())
+	}
+}
+
+func (cg *codeGen) genLocalGet() []binary.Instruction {
+	idx := cg.s.intn(len(cg.locals))
+	cg.push(cg.locals[idx])
+	return []binary.Instruction{{Opcode: binary.LocalGet, Args: uint32(idx)}}
+}
+
+// local.set or local.tee, of a local whose type is on the stack
+func (cg *codeGen) genLocalSet() []binary.Instruction {
+	var idxs []int
+	for i, vt := range cg.locals {
+		if cg.hasTop(vt) {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) == 0 {
+		return cg.genLocalGet()
+	}
+	idx := idxs[cg.s.intn(len(idxs))]
+	if cg.s.bool() {
+		return []binary.Instruction{{Opcode: binary.LocalTee, Args: uint32(idx)}}
+	}
+	cg.pop(1)
+	return []binary.Instruction{{Opcode: binary.LocalSet, Args: uint32(idx)}}
+}
+
+// the fuel global is never read or written by generated code
+func (cg *codeGen) genGlobalGet() []binary.Instruction {
+	idx := uint32(cg.s.intn(len(cg.globals)))
+	if idx == cg.fuel {
+		idx = (idx + 1) % uint32(len(cg.globals))
+	}
+	cg.push(cg.globals[idx].ValType)
+	return []binary.Instruction{{Opcode: binary.GlobalGet, Args: idx}}
+}
+
+func (cg *codeGen) genGlobalSet() []binary.Instruction {
+	for idx, gt := range cg.globals {
+		if uint32(idx) != cg.fuel &&
+			gt.Mut == binary.MutVar && cg.hasTop(gt.ValType) {
+			cg.pop(1)
+			return []binary.Instruction{{Opcode: binary.GlobalSet, Args: uint32(idx)}}
+		}
+	}
+	return cg.genGlobalGet()
+}
+
+func (cg *codeGen) genDrop() []binary.Instruction {
+	cg.pop(1)
+	return []binary.Instruction{{Opcode: binary.Drop}}
+}
+
+func (cg *codeGen) genSelect() []binary.Instruction {
+	for _, vt := range valTypes {
+		if cg.hasTop(vt, vt, i32) {
+			cg.pop(2)
+			return []binary.Instruction{{Opcode: binary.Select}}
+		}
+	}
+	if cg.hasTop(i32) {
+		return cg.genNumeric()
+	}
+	return []binary.Instruction{cg.genConst(i32)}
+}
+
+func (cg *codeGen) genNumeric() []binary.Instruction {
+	var ops []op
+	for _, op := range numericOps {
+		if cg.hasTop(op.params...) {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		return []binary.Instruction{cg.genConst(valTypes[cg.s.intn(4)])}
+	}
+	op := ops[cg.s.intn(len(ops))]
+	cg.pop(len(op.params))
+	cg.push(op.results...)
+	return []binary.Instruction{{Opcode: op.opcode, Args: op.args}}
+}
+
+/* control */
+
+func (cg *codeGen) genBlockType() binary.BlockType {
+	switch cg.s.intn(5) {
+	case 0:
+		return binary.BlockTypeI32
+	case 1:
+		return binary.BlockTypeI64
+	case 2:
+		return binary.BlockTypeF32
+	case 3:
+		return binary.BlockTypeF64
+	default:
+		return binary.BlockTypeEmpty
+	}
+}
+
+func (cg *codeGen) genBlock(opcode byte) []binary.Instruction {
+	bt := cg.genBlockType()
+	endTypes := cg.module.GetBlockType(bt).ResultTypes
+	if opcode == binary.If {
+		cg.pop(1)
+	}
+
+	cg.pushCtrl(opcode, endTypes)
+	var instrs []binary.Instruction
+	if opcode == binary.Loop {
+		instrs = cg.genFuelCheck()
+	}
+	instrs = append(instrs, cg.genInstrs()...)
+	cg.popCtrl()
+
+	var instr binary.Instruction
+	if opcode == binary.If {
+		cg.pushCtrl(binary.Else_, endTypes)
+		instrs2 := cg.genInstrs()
+		cg.popCtrl()
+		instr = binary.Instruction{Opcode: opcode,
+			Args: binary.IfArgs{BT: bt, Instrs1: instrs, Instrs2: instrs2}}
+	} else {
+		instr = binary.Instruction{Opcode: opcode,
+			Args: binary.BlockArgs{BT: bt, Instrs: instrs}}
+	}
+	cg.push(endTypes...)
+	return []binary.Instruction{instr}
+}
+
+// br, br_if or br_table to labels whose types are on the stack
+func (cg *codeGen) genBr() []binary.Instruction {
+	conditional := cg.hasTop(i32) && cg.s.intn(3) != 0
+	if conditional {
+		cg.pop(1)
+	}
+	var labels []uint32
+	for n := range cg.ctrls {
+		if cg.hasTop(cg.ctrls[len(cg.ctrls)-1-n].labelTypes()...) {
+			labels = append(labels, uint32(n))
+		}
+	}
+	if len(labels) == 0 {
+		if conditional {
+			cg.push(i32)
+		}
+		return cg.genNop()
+	}
+	label := labels[cg.s.intn(len(labels))]
+
+	if !conditional {
+		cg.top().unreachable = true
+		return []binary.Instruction{{Opcode: binary.Br, Args: label}}
+	}
+	if cg.s.bool() {
+		return []binary.Instruction{{Opcode: binary.BrIf, Args: label}}
+	}
+
+	labelTypes := cg.ctrls[len(cg.ctrls)-1-int(label)].labelTypes()
+	args := binary.BrTableArgs{Default: label}
+	for i := cg.s.intn(4); i > 0; i-- {
+		n := labels[cg.s.intn(len(labels))]
+		frame := cg.ctrls[len(cg.ctrls)-1-int(n)]
+		if isValTypesEq(frame.labelTypes(), labelTypes) {
+			args.Labels = append(args.Labels, n)
+		}
+	}
+	cg.top().unreachable = true
+	return []binary.Instruction{{Opcode: binary.BrTable, Args: args}}
+}
+
+func (cg *codeGen) genReturn() []binary.Instruction {
+	cg.top().unreachable = true
+	return []binary.Instruction{{Opcode: binary.Return}}
+}
+
+func (cg *codeGen) genCall() []binary.Instruction {
+	var fIdxs []uint32
+	for fIdx, ft := range cg.funcTypes {
+		if cg.hasTop(ft.ParamTypes...) {
+			fIdxs = append(fIdxs, uint32(fIdx))
+		}
+	}
+	if len(fIdxs) == 0 {
+		return cg.genNop()
+	}
+	fIdx := fIdxs[cg.s.intn(len(fIdxs))]
+	ft := cg.funcTypes[fIdx]
+	cg.pop(len(ft.ParamTypes))
+	cg.push(ft.ResultTypes...)
+	return []binary.Instruction{{Opcode: binary.Call, Args: fIdx}}
+}
+
+// the element index is masked so that it mostly hits the table
+func (cg *codeGen) genCallIndirect() []binary.Instruction {
+	if !cg.hasTop(i32) {
+		return []binary.Instruction{cg.genConst(i32)}
+	}
+	cg.pop(1)
+	var ftIdxs []uint32
+	for ftIdx, ft := range cg.module.TypeSec {
+		if cg.hasTop(ft.ParamTypes...) {
+			ftIdxs = append(ftIdxs, uint32(ftIdx))
+		}
+	}
+	if len(ftIdxs) == 0 {
+		return []binary.Instruction{{Opcode: binary.Drop}}
+	}
+	ftIdx := ftIdxs[cg.s.intn(len(ftIdxs))]
+	ft := cg.module.TypeSec[ftIdx]
+	cg.pop(len(ft.ParamTypes))
+	cg.push(ft.ResultTypes...)
+	return []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(maxTableSize - 1)},
+		{Opcode: binary.I32And},
+		{Opcode: binary.CallIndirect, Args: binary.CallIndirectArgs{Type: ftIdx}},
+	}
+}
+
+// fuel -= 1, traps when it runs out
+func (cg *codeGen) genFuelCheck() []binary.Instruction {
+	fuel := cg.fuel
+	return []binary.Instruction{
+		{Opcode: binary.GlobalGet, Args: fuel},
+		{Opcode: binary.I32Eqz},
+		{Opcode: binary.If, Args: binary.IfArgs{
+			BT:      binary.BlockTypeEmpty,
+			Instrs1: []binary.Instruction{{Opcode: binary.Unreachable}},
+		}},
+		{Opcode: binary.GlobalGet, Args: fuel},
+		{Opcode: binary.I32Const, Args: int32(1)},
+		{Opcode: binary.I32Sub},
+		{Opcode: binary.GlobalSet, Args: fuel},
+	}
+}
+
+/* memory */
+
+// addresses are masked so that they mostly stay in bounds
+func (cg *codeGen) genAddr() []binary.Instruction {
+	var instrs []binary.Instruction
+	if !cg.hasTop(i32) {
+		instrs = append(instrs, cg.genConst(i32))
+	}
+	return append(instrs,
+		binary.Instruction{Opcode: binary.I32Const, Args: int32(binary.PageSize - 1)},
+		binary.Instruction{Opcode: binary.I32And},
+	)
+}
+
+func (cg *codeGen) genMemArg(op memOp) binary.MemArg {
+	return binary.MemArg{
+		Align:  uint32(cg.s.intn(int(op.maxAlign) + 1)),
+		Offset: uint64(cg.s.intn(16)),
+	}
+}
+
+func (cg *codeGen) genLoad() []binary.Instruction {
+	op := loadOps[cg.s.intn(len(loadOps))]
+	instrs := cg.genAddr()
+	cg.pop(1)
+	cg.push(op.vt)
+	return append(instrs, binary.Instruction{
+		Opcode: op.opcode, Args: cg.genMemArg(op)})
+}
+
+func (cg *codeGen) genStore() []binary.Instruction {
+	for _, op := range storeOps {
+		if cg.hasTop(i32, op.vt) && cg.s.bool() {
+			cg.pop(2)
+			return []binary.Instruction{{
+				Opcode: op.opcode, Args: cg.genMemArg(op)}}
+		}
+	}
+	// address first, the value comes later
+	return cg.genAddr()
+}
+
+func (cg *codeGen) genMemorySize() []binary.Instruction {
+	cg.push(i32)
+	return []binary.Instruction{{Opcode: binary.MemorySize, Args: uint32(0)}}
+}
+
+// grows by 0 or 1 page, the limits have a max
+func (cg *codeGen) genMemoryGrow() []binary.Instruction {
+	return []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(1)},
+		{Opcode: binary.I32And},
+		{Opcode: binary.MemoryGrow, Args: uint32(0)},
+	}
+}
+
+func isValTypesEq(a, b []binary.ValType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, vt := range a {
+		if vt != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package smith
+
+import (
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+var _ instance.Function = stubFunc{}
+
+// stubFunc returns zero values of its result types
+type stubFunc struct {
+	ft binary.FuncType
+}
+
+func (f stubFunc) Type() binary.FuncType {
+	return f.ft
+}
+
+func (f stubFunc) Call(args ...instance.WasmVal) ([]instance.WasmVal, error) {
+	results := make([]instance.WasmVal, len(f.ft.ResultTypes))
+	for i, vt := range f.ft.ResultTypes {
+		results[i] = zeroVal(vt)
+	}
+	return results, nil
+}
+
+// StubImports satisfies every import of module with a stub:
+// functions do nothing, globals, tables and memories are zeroed.
+func StubImports(module binary.Module) instance.Map {
+	mm := instance.Map{}
+	for _, imp := range module.ImportSec {
+		if mm[imp.Module] == nil {
+			mm[imp.Module] = instance.NewNativeInstance()
+		}
+		native := mm[imp.Module].(interface {
+			Register(name string, x interface{})
+		})
+		switch imp.Desc.Tag {
+		case binary.ImportTagFunc:
+			if int(imp.Desc.FuncType) < len(module.TypeSec) {
+				ft := module.TypeSec[imp.Desc.FuncType]
+				native.Register(imp.Name, stubFunc{ft})
+			}
+		case binary.ImportTagTable:
+			tt := imp.Desc.Table
+			native.Register(imp.Name, interpreter.NewTable(tt.ElemType, uint32(tt.Limits.Min), uint32(tt.Limits.Max)))
+		case binary.ImportTagMem:
+			native.Register(imp.Name, interpreter.NewMemory(imp.Desc.Mem.Min, imp.Desc.Mem.Max))
+		case binary.ImportTagGlobal:
+			gt := imp.Desc.Global
+			native.Register(imp.Name, interpreter.NewGlobal(gt.ValType, gt.Mut == binary.MutVar, 0))
+		}
+	}
+	return mm
+}
+
+// ZeroArgs returns zero values of the param types of ft.
+func ZeroArgs(ft binary.FuncType) []instance.WasmVal {
+	args := make([]instance.WasmVal, len(ft.ParamTypes))
+	for i, vt := range ft.ParamTypes {
+		args[i] = zeroVal(vt)
+	}
+	return args
+}
+
+func zeroVal(vt binary.ValType) instance.WasmVal {
+	switch vt {
+	case binary.ValTypeI32:
+		return int32(0)
+	case binary.ValTypeI64:
+		return int64(0)
+	case binary.ValTypeF32:
+		return float32(0)
+	case binary.ValTypeFuncRef, binary.ValTypeExternRef:
+		return nil
+	default:
+		return float64(0)
+	}
+}
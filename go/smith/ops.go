@@ -0,0 +1,112 @@
+package smith
+
+import "wasm.go/binary"
+
+const (
+	i32 = binary.ValTypeI32
+	i64 = binary.ValTypeI64
+	f32 = binary.ValTypeF32
+	f64 = binary.ValTypeF64
+)
+
+var valTypes = []binary.ValType{i32, i64, f32, f64}
+
+// numeric instruction, args is only used by trunc_sat
+type op struct {
+	opcode  byte
+	args    interface{}
+	params  []binary.ValType
+	results []binary.ValType
+}
+
+var numericOps []op
+
+func init() {
+	add := func(params []binary.ValType, result binary.ValType, opcodes ...byte) {
+		for _, opcode := range opcodes {
+			numericOps = append(numericOps, op{
+				opcode:  opcode,
+				params:  params,
+				results: []binary.ValType{result},
+			})
+		}
+	}
+	rng := func(from, to byte) []byte {
+		var opcodes []byte
+		for opcode := from; opcode <= to; opcode++ {
+			opcodes = append(opcodes, opcode)
+		}
+		return opcodes
+	}
+	t := func(vts ...binary.ValType) []binary.ValType { return vts }
+
+	add(t(i32), i32, binary.I32Eqz)
+	add(t(i32, i32), i32, rng(binary.I32Eq, binary.I32GeU)...)
+	add(t(i64), i32, binary.I64Eqz)
+	add(t(i64, i64), i32, rng(binary.I64Eq, binary.I64GeU)...)
+	add(t(f32, f32), i32, rng(binary.F32Eq, binary.F32Ge)...)
+	add(t(f64, f64), i32, rng(binary.F64Eq, binary.F64Ge)...)
+	add(t(i32), i32, rng(binary.I32Clz, binary.I32PopCnt)...)
+	add(t(i32, i32), i32, rng(binary.I32Add, binary.I32Rotr)...)
+	add(t(i64), i64, rng(binary.I64Clz, binary.I64PopCnt)...)
+	add(t(i64, i64), i64, rng(binary.I64Add, binary.I64Rotr)...)
+	add(t(f32), f32, rng(binary.F32Abs, binary.F32Sqrt)...)
+	add(t(f32, f32), f32, rng(binary.F32Add, binary.F32CopySign)...)
+	add(t(f64), f64, rng(binary.F64Abs, binary.F64Sqrt)...)
+	add(t(f64, f64), f64, rng(binary.F64Add, binary.F64CopySign)...)
+	add(t(i64), i32, binary.I32WrapI64)
+	add(t(f32), i32, binary.I32TruncF32S, binary.I32TruncF32U)
+	add(t(f64), i32, binary.I32TruncF64S, binary.I32TruncF64U)
+	add(t(i32), i64, binary.I64ExtendI32S, binary.I64ExtendI32U)
+	add(t(f32), i64, binary.I64TruncF32S, binary.I64TruncF32U)
+	add(t(f64), i64, binary.I64TruncF64S, binary.I64TruncF64U)
+	add(t(i32), f32, binary.F32ConvertI32S, binary.F32ConvertI32U)
+	add(t(i64), f32, binary.F32ConvertI64S, binary.F32ConvertI64U)
+	add(t(f64), f32, binary.F32DemoteF64)
+	add(t(i32), f64, binary.F64ConvertI32S, binary.F64ConvertI32U)
+	add(t(i64), f64, binary.F64ConvertI64S, binary.F64ConvertI64U)
+	add(t(f32), f64, binary.F64PromoteF32)
+	add(t(f32), i32, binary.I32ReinterpretF32)
+	add(t(f64), i64, binary.I64ReinterpretF64)
+	add(t(i32), f32, binary.F32ReinterpretI32)
+	add(t(i64), f64, binary.F64ReinterpretI64)
+	add(t(i32), i32, binary.I32Extend8S, binary.I32Extend16S)
+	add(t(i64), i64, rng(binary.I64Extend8S, binary.I64Extend32S)...)
+
+	truncSat := [][2]binary.ValType{
+		{f32, i32}, {f32, i32}, {f64, i32}, {f64, i32},
+		{f32, i64}, {f32, i64}, {f64, i64}, {f64, i64},
+	}
+	for i, vts := range truncSat {
+		numericOps = append(numericOps, op{
+			opcode:  binary.NumericPrefix,
+			args:    binary.PrefixArgs{SubOp: uint32(i)},
+			params:  t(vts[0]),
+			results: t(vts[1]),
+		})
+	}
+}
+
+type memOp struct {
+	opcode   byte
+	vt       binary.ValType
+	maxAlign uint32 // log2 of the natural alignment
+}
+
+var loadOps = []memOp{
+	{binary.I32Load, i32, 2}, {binary.I64Load, i64, 3},
+	{binary.F32Load, f32, 2}, {binary.F64Load, f64, 3},
+	{binary.I32Load8S, i32, 0}, {binary.I32Load8U, i32, 0},
+	{binary.I32Load16S, i32, 1}, {binary.I32Load16U, i32, 1},
+	{binary.I64Load8S, i64, 0}, {binary.I64Load8U, i64, 0},
+	{binary.I64Load16S, i64, 1}, {binary.I64Load16U, i64, 1},
+	{binary.I64Load32S, i64, 2}, {binary.I64Load32U, i64, 2},
+}
+
+var storeOps = []memOp{
+	{binary.I32Store, i32, 2}, {binary.I64Store, i64, 3},
+	{binary.F32Store, f32, 2}, {binary.F64Store, f64, 3},
+	{binary.I32Store8, i32, 0}, {binary.I32Store16, i32, 1},
+	{binary.I64Store8, i64, 0}, {binary.I64Store16, i64, 1},
+	{binary.I64Store32, i64, 2},
+}
@@ -0,0 +1,217 @@
+// Package smith generates random but valid modules from a byte seed,
+// in the spirit of wasm-smith, for use in fuzz tests.
+package smith
+
+import (
+	"fmt"
+
+	"wasm.go/binary"
+)
+
+const (
+	maxTypes     = 6
+	maxParams    = 3
+	maxResults   = 2
+	maxImports   = 2
+	maxFuncs     = 6
+	maxGlobals   = 3
+	maxLocals    = 3
+	maxInstrs    = 64 // per function
+	maxDepth     = 5  // of nested blocks, the function body included
+	maxTableSize = 4  // power of two, call_indirect masks with it
+	maxDataSize  = 16
+
+	// loops and function bodies consume one unit of fuel and trap
+	// when it is exhausted, so every call terminates
+	initialFuel = 1000
+
+	ImportModule = "env"
+)
+
+type generator struct {
+	s         *source
+	module    *binary.Module
+	funcTypes []binary.FuncType // imported & internal
+	globals   []binary.GlobalType
+	fuel      uint32 // global index
+	hasTable  bool
+	hasMem    bool
+}
+
+// Generate returns a module that passes validation and only imports
+// functions and globals from ImportModule (see StubImports). The same
+// seed always yields the same module; generation never fails, a short
+// seed just makes a small module.
+func Generate(seed []byte) binary.Module {
+	g := &generator{
+		s: &source{data: seed},
+		module: &binary.Module{
+			Magic:   binary.MagicNumber,
+			Version: binary.Version,
+		},
+	}
+	g.genTypeSec()
+	g.genImportSec()
+	g.genFuncSec()
+	g.genTableSec()
+	g.genMemSec()
+	g.genGlobalSec()
+	g.genExportSec()
+	g.genElemSec()
+	g.genCodeSec()
+	g.genDataSec()
+	return *g.module
+}
+
+func (g *generator) genTypeSec() {
+	for i := 1 + g.s.intn(maxTypes); i > 0; i-- {
+		ft := binary.FuncType{Tag: binary.FtTag}
+		for j := g.s.intn(maxParams + 1); j > 0; j-- {
+			ft.ParamTypes = append(ft.ParamTypes, g.genValType())
+		}
+		for j := g.s.intn(maxResults + 1); j > 0; j-- {
+			ft.ResultTypes = append(ft.ResultTypes, g.genValType())
+		}
+		g.module.TypeSec = append(g.module.TypeSec, ft)
+	}
+}
+
+func (g *generator) genImportSec() {
+	for i := g.s.intn(maxImports + 1); i > 0; i-- {
+		ftIdx := g.genTypeIdx()
+		g.module.ImportSec = append(g.module.ImportSec, binary.Import{
+			Module: ImportModule,
+			Name:   fmt.Sprintf("f%d", len(g.funcTypes)),
+			Desc:   binary.ImportDesc{Tag: binary.ImportTagFunc, FuncType: ftIdx},
+		})
+		g.funcTypes = append(g.funcTypes, g.module.TypeSec[ftIdx])
+	}
+	for i := g.s.intn(maxImports + 1); i > 0; i-- {
+		gt := binary.GlobalType{ValType: g.genValType(), Mut: g.genMut()}
+		g.module.ImportSec = append(g.module.ImportSec, binary.Import{
+			Module: ImportModule,
+			Name:   fmt.Sprintf("g%d", len(g.globals)),
+			Desc:   binary.ImportDesc{Tag: binary.ImportTagGlobal, Global: gt},
+		})
+		g.globals = append(g.globals, gt)
+	}
+}
+
+func (g *generator) genFuncSec() {
+	for i := 1 + g.s.intn(maxFuncs); i > 0; i-- {
+		ftIdx := g.genTypeIdx()
+		g.module.FuncSec = append(g.module.FuncSec, ftIdx)
+		g.funcTypes = append(g.funcTypes, g.module.TypeSec[ftIdx])
+	}
+}
+
+func (g *generator) genTableSec() {
+	if g.hasTable = g.s.bool(); g.hasTable {
+		g.module.TableSec = []binary.TableType{{
+			ElemType: binary.FuncRef,
+			Limits:   binary.Limits{Tag: 1, Min: maxTableSize, Max: maxTableSize},
+		}}
+	}
+}
+
+func (g *generator) genMemSec() {
+	if g.hasMem = g.s.bool(); g.hasMem {
+		min := uint64(g.s.intn(2))
+		g.module.MemSec = []binary.MemType{
+			{Tag: 1, Min: min, Max: min + 1 + uint64(g.s.intn(2))},
+		}
+	}
+}
+
+func (g *generator) genGlobalSec() {
+	g.fuel = uint32(len(g.globals))
+	g.module.GlobalSec = append(g.module.GlobalSec, binary.Global{
+		Type: binary.GlobalType{ValType: i32, Mut: binary.MutVar},
+		Init: []binary.Instruction{{Opcode: binary.I32Const, Args: int32(initialFuel)}},
+	})
+	g.globals = append(g.globals, g.module.GlobalSec[0].Type)
+
+	cg := &codeGen{generator: g} // only for constants
+	cg.pushCtrl(binary.Block, nil)
+	for i := g.s.intn(maxGlobals + 1); i > 0; i-- {
+		gt := binary.GlobalType{ValType: g.genValType(), Mut: g.genMut()}
+		g.module.GlobalSec = append(g.module.GlobalSec, binary.Global{
+			Type: gt,
+			Init: []binary.Instruction{cg.genConst(gt.ValType)},
+		})
+		g.globals = append(g.globals, gt)
+	}
+}
+
+func (g *generator) genExportSec() {
+	for i := range g.funcTypes {
+		g.module.ExportSec = append(g.module.ExportSec, binary.Export{
+			Name: fmt.Sprintf("f%d", i),
+			Desc: binary.ExportDesc{Tag: binary.ExportTagFunc, Idx: uint32(i)},
+		})
+	}
+	if g.hasTable {
+		g.module.ExportSec = append(g.module.ExportSec, binary.Export{
+			Name: "table",
+			Desc: binary.ExportDesc{Tag: binary.ExportTagTable},
+		})
+	}
+	if g.hasMem {
+		g.module.ExportSec = append(g.module.ExportSec, binary.Export{
+			Name: "memory",
+			Desc: binary.ExportDesc{Tag: binary.ExportTagMem},
+		})
+	}
+}
+
+func (g *generator) genElemSec() {
+	if !g.hasTable {
+		return
+	}
+	elem := binary.Elem{
+		Type:   binary.FuncRef,
+		Offset: []binary.Instruction{{Opcode: binary.I32Const, Args: int32(0)}},
+	}
+	for i := g.s.intn(maxTableSize + 1); i > 0; i-- {
+		elem.Init = append(elem.Init, uint32(g.s.intn(len(g.funcTypes))))
+	}
+	g.module.ElemSec = []binary.Elem{elem}
+}
+
+func (g *generator) genCodeSec() {
+	for _, ftIdx := range g.module.FuncSec {
+		code := g.genCode(g.module.TypeSec[ftIdx])
+		g.module.CodeSec = append(g.module.CodeSec, code)
+	}
+}
+
+// segments always fit in the initial memory
+func (g *generator) genDataSec() {
+	if !g.hasMem || g.module.MemSec[0].Min == 0 {
+		return
+	}
+	for i := g.s.intn(3); i > 0; i-- {
+		data := binary.Data{}
+		for j := g.s.intn(maxDataSize + 1); j > 0; j-- {
+			data.Init = append(data.Init, g.s.byte())
+		}
+		offset := g.s.intn(binary.PageSize - len(data.Init))
+		data.Offset = []binary.Instruction{{Opcode: binary.I32Const, Args: int32(offset)}}
+		g.module.DataSec = append(g.module.DataSec, data)
+	}
+}
+
+func (g *generator) genValType() binary.ValType {
+	return valTypes[g.s.intn(len(valTypes))]
+}
+
+func (g *generator) genTypeIdx() uint32 {
+	return uint32(g.s.intn(len(g.module.TypeSec)))
+}
+
+func (g *generator) genMut() byte {
+	if g.s.bool() {
+		return binary.MutVar
+	}
+	return binary.MutConst
+}
@@ -0,0 +1,62 @@
+package smith
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+	"wasm.go/validator"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	seed := randSeed(rand.New(rand.NewSource(1)))
+	// NaN constants are not equal to themselves
+	require.Equal(t, fmt.Sprint(Generate(seed)), fmt.Sprint(Generate(seed)))
+}
+
+func TestGenerateEmptySeed(t *testing.T) {
+	module := Generate(nil)
+	require.NoError(t, validator.Validate(module))
+	require.Equal(t, 1, len(module.CodeSec))
+}
+
+func TestGenerate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		checkModule(t, randSeed(r))
+	}
+}
+
+func FuzzGenerate(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 8; i++ {
+		f.Add(randSeed(r))
+	}
+	f.Fuzz(checkModule)
+}
+
+// generated modules must validate, instantiate with stub imports,
+// and their exported functions must return or trap
+func checkModule(t *testing.T, seed []byte) {
+	module := Generate(seed)
+	require.NoError(t, validator.Validate(module))
+
+	inst, err := interpreter.New(module, StubImports(module))
+	require.NoError(t, err)
+	for _, exp := range module.ExportSec {
+		if exp.Desc.Tag == binary.ExportTagFunc {
+			f := inst.GetMember(exp.Name).(instance.Function)
+			_, _ = f.Call(ZeroArgs(f.Type())...)
+		}
+	}
+}
+
+func randSeed(r *rand.Rand) []byte {
+	seed := make([]byte, r.Intn(4096))
+	r.Read(seed)
+	return seed
+}
@@ -0,0 +1,49 @@
+package smith
+
+import "encoding/binary"
+
+// source hands out the seed bytes as decisions, once it is
+// exhausted every decision is 0, so generation always ends
+type source struct {
+	data []byte
+}
+
+func (s *source) exhausted() bool {
+	return len(s.data) == 0
+}
+
+func (s *source) byte() byte {
+	if len(s.data) == 0 {
+		return 0
+	}
+	b := s.data[0]
+	s.data = s.data[1:]
+	return b
+}
+
+// [0, n)
+func (s *source) intn(n int) int {
+	if n <= 1 {
+		return 0
+	}
+	if n <= 256 {
+		return int(s.byte()) % n
+	}
+	return int(s.u32() % uint32(n))
+}
+
+func (s *source) bool() bool {
+	return s.byte()&1 == 1
+}
+
+func (s *source) u32() uint32 {
+	var buf [4]byte
+	for i := range buf {
+		buf[i] = s.byte()
+	}
+	return binary.LittleEndian.Uint32(buf[:])
+}
+
+func (s *source) u64() uint64 {
+	return uint64(s.u32()) | uint64(s.u32())<<32
+}
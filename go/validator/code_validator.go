@@ -1,7 +1,9 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"math"
 
 	"wasm.go/binary"
 )
@@ -17,10 +19,11 @@ type ctrl_stack = stack(ctrl_frame)
 const (
 	Unknown = 0
 
-	I32 = binary.ValTypeI32
-	I64 = binary.ValTypeI64
-	F32 = binary.ValTypeF32
-	F64 = binary.ValTypeF64
+	I32  = binary.ValTypeI32
+	I64  = binary.ValTypeI64
+	F32  = binary.ValTypeF32
+	F64  = binary.ValTypeF64
+	V128 = binary.ValTypeV128
 )
 
 type valType = byte
@@ -53,7 +56,10 @@ type codeValidator struct {
 	ctrls      ctrlStack
 	mv         *moduleValidator
 	codeIdx    int
+	offset     uint32 // of the current instruction
 	localCount int
+	params     []valType
+	locals     []binary.Locals
 	instrPath  map[int]string // depth -> opname
 }
 
@@ -69,11 +75,29 @@ func validateCode(mv *moduleValidator, idx int,
 }
 
 func (cv *codeValidator) error(msg string) {
-	panic(fmt.Errorf("code[%d], %s: %s",
-		cv.codeIdx, cv.getInstrPath(), msg))
+	cv.fail(errors.New(msg), nil, nil)
 }
 func (cv *codeValidator) errorf(format string, a ...interface{}) {
-	cv.error(fmt.Sprintf(format, a...))
+	cv.fail(fmt.Errorf(format, a...), nil, nil)
+}
+func (cv *codeValidator) requireFeature(f binary.Feature) {
+	if err := cv.mv.opts.Features.Check(f); err != nil {
+		cv.fail(err, nil, nil)
+	}
+}
+func (cv *codeValidator) typeMismatch(expected, actual []valType) {
+	cv.fail(errors.New("type mismatch"), expected, actual)
+}
+func (cv *codeValidator) fail(err error, expected, actual []valType) {
+	panic(&Diagnostic{
+		FuncIdx:   cv.mv.getImportedFuncCount() + cv.codeIdx,
+		InstrPath: cv.getInstrPath(),
+		Offset:    cv.offset,
+		Expected:  expected,
+		Actual:    actual,
+		err: fmt.Errorf("code[%d], %s: %w",
+			cv.codeIdx, cv.getInstrPath(), err),
+	})
 }
 
 func (cv *codeValidator) getInstrPath() string {
@@ -122,15 +146,7 @@ func pop_opd() : val_type | Unknown =
   return opds.pop()
 */
 func (cv *codeValidator) popOpd() valType {
-	if ctrl0 := cv.getCtrl(0); len(cv.opds) == ctrl0.height {
-		if ctrl0.unreachable {
-			return Unknown
-		}
-		cv.error("type mismatch") // TODO
-	}
-	r := cv.opds[len(cv.opds)-1]
-	cv.opds = cv.opds[:len(cv.opds)-1]
-	return r
+	return cv.popOpdOf(Unknown)
 }
 
 /*
@@ -142,7 +158,18 @@ func pop_opd(expect : val_type | Unknown) : val_type | Unknown =
   return actual
 */
 func (cv *codeValidator) popOpdOf(expect valType) valType {
-	actual := cv.popOpd()
+	var expected []valType
+	if expect != Unknown {
+		expected = []valType{expect}
+	}
+	if ctrl0 := cv.getCtrl(0); len(cv.opds) == ctrl0.height {
+		if ctrl0.unreachable {
+			return expect
+		}
+		cv.typeMismatch(expected, nil)
+	}
+	actual := cv.opds[len(cv.opds)-1]
+	cv.opds = cv.opds[:len(cv.opds)-1]
 	if actual == Unknown {
 		return expect
 	}
@@ -150,7 +177,7 @@ func (cv *codeValidator) popOpdOf(expect valType) valType {
 		return actual
 	}
 	if actual != expect {
-		cv.error("type mismatch") // TODO
+		cv.typeMismatch(expected, []valType{actual})
 	}
 	return actual
 }
@@ -180,6 +207,15 @@ func (cv *codeValidator) popI64() { cv.popOpdOf(I64) }
 func (cv *codeValidator) popF32() { cv.popOpdOf(F32) }
 func (cv *codeValidator) popF64() { cv.popOpdOf(F64) }
 
+// pops a funcref or an externref
+func (cv *codeValidator) popRef() valType {
+	t := cv.popOpd()
+	if t != Unknown && !binary.IsRefType(t) {
+		cv.typeMismatch(nil, []valType{t})
+	}
+	return t
+}
+
 /* control stack */
 
 func (cv *codeValidator) getCtrl(n int) ctrlFrame {
@@ -217,7 +253,8 @@ func (cv *codeValidator) popCtrl() ctrlFrame {
 	frame := cv.getCtrl(0)
 	cv.popOpds(frame.endTypes)
 	if len(cv.opds) != frame.height {
-		cv.error("type mismatch") // TODO
+		actual := append([]valType{}, cv.opds[frame.height:]...)
+		cv.typeMismatch(frame.endTypes, append(actual, frame.endTypes...))
 	}
 	cv.ctrls = cv.ctrls[:len(cv.ctrls)-1]
 	return frame
@@ -239,28 +276,80 @@ func (cv *codeValidator) unreachable() {
 func (cv *codeValidator) validateCode(
 	code binary.Code, ft binary.FuncType) {
 
-	cv.pushOpds(ft.ParamTypes)
-	cv.localCount = len(ft.ParamTypes)
-	for _, local := range code.Locals {
-		for i := 0; i < int(local.N); i++ {
-			cv.pushOpd(local.Type)
-			cv.localCount++
-		}
+	// locals are looked up by index instead of being pushed onto
+	// the operand stack, there may be billions of them
+	if code.GetLocalCount() > uint64(cv.mv.opts.MaxLocals) {
+		cv.errorf("%w: %d", binary.ErrTooManyLocals, code.GetLocalCount())
+	}
+	for _, locals := range code.Locals {
+		cv.requireFeature(binary.ValTypeFeature(locals.Type))
 	}
+	cv.params = ft.ParamTypes
+	cv.locals = code.Locals
+	cv.localCount = len(ft.ParamTypes) + int(code.GetLocalCount())
 	cv.pushCtrl(binary.Block, nil, ft.ResultTypes)
 	cv.validateExpr(code.Expr)
 	cv.pushOpds(cv.popCtrl().endTypes)
 }
 
+func (cv *codeValidator) getLocalType(n int) valType {
+	if n >= cv.localCount {
+		cv.errorf("unknown local: %d", n)
+	}
+	if n < len(cv.params) {
+		return cv.params[n]
+	}
+	n -= len(cv.params)
+	for _, locals := range cv.locals {
+		if n < int(locals.N) {
+			return locals.Type
+		}
+		n -= int(locals.N)
+	}
+	return Unknown
+}
+
+func (cv *codeValidator) getBlockType(bt binary.BlockType) binary.FuncType {
+	if bt >= 0 && int(bt) >= cv.mv.getTypeCount() {
+		cv.errorf("unknown type: %d", bt)
+	}
+	return cv.mv.module.GetBlockType(bt)
+}
+
 func (cv *codeValidator) validateExpr(expr []binary.Instruction) {
 	depth := len(cv.instrPath)
+	if depth > int(cv.mv.opts.MaxNestingDepth) {
+		cv.errorf("%w", binary.ErrNestingTooDeep)
+	}
 	for _, instr := range expr {
 		cv.instrPath[depth] = instr.GetOpname()
-		cv.validateInstr(instr)
+		cv.offset = instr.Offset
+		cv.mv.check(func() {
+			defer cv.resync(len(cv.ctrls), depth)
+			cv.requireFeature(instr.Feature())
+			cv.validateInstr(instr)
+		})
 	}
 	delete(cv.instrPath, depth)
 }
 
+// resync recovers from an error in an instruction when collecting
+// diagnostics: frames of unfinished blocks are dropped and the
+// stack becomes polymorphic so that the error doesn't cascade
+func (cv *codeValidator) resync(ctrlCount, depth int) {
+	if !cv.mv.collect {
+		return
+	}
+	if r := recover(); r != nil {
+		for d := len(cv.instrPath) - 1; d > depth; d-- {
+			delete(cv.instrPath, d)
+		}
+		cv.ctrls = cv.ctrls[:ctrlCount]
+		cv.unreachable()
+		panic(r) // to be recorded by check
+	}
+}
+
 /*
 func validate(opcode) = switch (opcode)
   case (i32.add)
@@ -317,14 +406,14 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 	case binary.Nop:
 	case binary.Block, binary.Loop:
 		blockArgs := instr.Args.(binary.BlockArgs)
-		bt := cv.mv.module.GetBlockType(blockArgs.BT)
+		bt := cv.getBlockType(blockArgs.BT)
 		cv.popOpds(bt.ParamTypes)
 		cv.pushCtrl(instr.Opcode, bt.ParamTypes, bt.ResultTypes)
 		cv.validateExpr(blockArgs.Instrs)
 		cv.pushOpds(cv.popCtrl().endTypes)
 	case binary.If:
 		ifArgs := instr.Args.(binary.IfArgs)
-		bt := cv.mv.module.GetBlockType(ifArgs.BT)
+		bt := cv.getBlockType(ifArgs.BT)
 		cv.popI32()
 		cv.popOpds(bt.ParamTypes)
 		cv.pushCtrl(binary.If, bt.ParamTypes, bt.ResultTypes)
@@ -338,16 +427,43 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 		cv.validateExpr(ifArgs.Instrs2)
 		// end
 		cv.pushOpds(cv.popCtrl().endTypes)
+	case binary.Try:
+		cv.validateTry(instr.Args.(binary.TryArgs))
+	case binary.TryTable:
+		tryArgs := instr.Args.(binary.TryTableArgs)
+		bt := cv.getBlockType(tryArgs.BT)
+		cv.popOpds(bt.ParamTypes)
+		for _, catch := range tryArgs.Catches {
+			cv.checkCatch(catch)
+		}
+		cv.pushCtrl(binary.TryTable, bt.ParamTypes, bt.ResultTypes)
+		cv.validateExpr(tryArgs.Instrs)
+		cv.pushOpds(cv.popCtrl().endTypes)
+	case binary.Throw:
+		cv.popOpds(cv.getTagType(instr.Args.(uint32)).ParamTypes)
+		cv.unreachable()
+	case binary.Rethrow:
+		n := int(instr.Args.(uint32))
+		if len(cv.ctrls) <= n {
+			cv.error("unknown label")
+		}
+		if op := cv.getCtrl(n).opcode; op != binary.Catch_ && op != binary.CatchAll_ {
+			cv.error("invalid rethrow label")
+		}
+		cv.unreachable()
+	case binary.ThrowRef:
+		cv.popOpdOf(binary.ValTypeExnRef)
+		cv.unreachable()
 	case binary.Br:
 		n := int(instr.Args.(uint32))
-		if len(cv.ctrls) < n {
+		if len(cv.ctrls) <= n {
 			cv.error("unknown label")
 		}
 		cv.popOpds(cv.getCtrl(n).labelTypes())
 		cv.unreachable()
 	case binary.BrIf:
 		n := int(instr.Args.(uint32))
-		if len(cv.ctrls) < n {
+		if len(cv.ctrls) <= n {
 			cv.error("unknown label")
 		}
 		cv.popI32()
@@ -356,17 +472,17 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 	case binary.BrTable:
 		brTableArgs := instr.Args.(binary.BrTableArgs)
 		m := int(brTableArgs.Default)
-		if len(cv.ctrls) < m {
+		if len(cv.ctrls) <= m {
 			cv.error("unknown label")
 		}
 		for _, n := range brTableArgs.Labels {
-			if len(cv.ctrls) < int(n) {
+			if len(cv.ctrls) <= int(n) {
 				cv.error("unknown label")
 			}
 			t1 := cv.getCtrl(int(n)).labelTypes()
 			t2 := cv.getCtrl(m).labelTypes()
 			if !isValTypesEq(t1, t2) {
-				cv.error("type mismatch")
+				cv.typeMismatch(t2, t1)
 			}
 		}
 		cv.popI32()
@@ -377,51 +493,51 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 		cv.popOpds(cv.getCtrl(n).labelTypes())
 		cv.unreachable()
 	case binary.Call:
-		fIdx := instr.Args.(uint32)
-		ft, ok := cv.mv.getFuncType(int(fIdx))
-		if !ok {
-			cv.error("unknown function")
-		}
+		ft := cv.getCalleeType(instr.Args.(uint32))
 		cv.popOpds(ft.ParamTypes)
 		cv.pushOpds(ft.ResultTypes)
 	case binary.CallIndirect:
-		if cv.mv.getTableCount() == 0 {
-			cv.error("unknown table")
-		}
-		ftIdx := instr.Args.(uint32)
-		if int(ftIdx) >= cv.mv.getTypeCount() {
-			cv.error("unknown type")
-		}
-		ft := cv.mv.module.TypeSec[ftIdx]
+		ft := cv.getIndirectCalleeType(instr.Args.(binary.CallIndirectArgs))
 		cv.popI32()
 		cv.popOpds(ft.ParamTypes)
 		cv.pushOpds(ft.ResultTypes)
+	case binary.ReturnCall:
+		ft := cv.getCalleeType(instr.Args.(uint32))
+		cv.popOpds(ft.ParamTypes)
+		cv.checkTailCallResults(ft)
+		cv.unreachable()
+	case binary.ReturnCallIndirect:
+		ft := cv.getIndirectCalleeType(instr.Args.(binary.CallIndirectArgs))
+		cv.popI32()
+		cv.popOpds(ft.ParamTypes)
+		cv.checkTailCallResults(ft)
+		cv.unreachable()
 	case binary.Drop:
 		cv.popOpd()
 	case binary.Select:
 		cv.popI32()
 		t1 := cv.popOpd()
 		t2 := cv.popOpdOf(t1)
+		if binary.IsRefType(t1) || binary.IsRefType(t2) {
+			cv.typeMismatch(nil, []valType{t2, t1})
+		}
 		cv.pushOpd(t2)
+	case binary.SelectT:
+		t := instr.Args.([]binary.ValType)[0]
+		cv.popI32()
+		cv.popOpdOf(t)
+		cv.popOpdOf(t)
+		cv.pushOpd(t)
 	case binary.LocalGet:
-		n := int(instr.Args.(uint32))
-		if n >= cv.localCount {
-			cv.errorf("unknown local: %d", n)
-		}
-		cv.pushOpd(cv.opds[n])
+		vt := cv.getLocalType(int(instr.Args.(uint32)))
+		cv.pushOpd(vt)
 	case binary.LocalSet:
-		n := int(instr.Args.(uint32))
-		if n >= cv.localCount {
-			cv.errorf("unknown local: %d", n)
-		}
-		cv.popOpdOf(cv.opds[n])
+		vt := cv.getLocalType(int(instr.Args.(uint32)))
+		cv.popOpdOf(vt)
 	case binary.LocalTee:
-		n := int(instr.Args.(uint32))
-		if n >= cv.localCount {
-			cv.errorf("unknown local: %d", n)
-		}
-		cv.popOpdOf(cv.opds[n])
-		cv.pushOpd(cv.opds[n])
+		vt := cv.getLocalType(int(instr.Args.(uint32)))
+		cv.popOpdOf(vt)
+		cv.pushOpd(vt)
 	case binary.GlobalGet:
 		n := int(instr.Args.(uint32))
 		if n >= len(cv.mv.globalTypes) {
@@ -438,6 +554,14 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 			cv.errorf(" global is immutable: %d", n)
 		}
 		cv.popOpdOf(gt.ValType)
+	case binary.TableGet:
+		t := cv.getTableType(instr.Args.(uint32))
+		cv.popI32()
+		cv.pushOpd(t)
+	case binary.TableSet:
+		t := cv.getTableType(instr.Args.(uint32))
+		cv.popOpdOf(t)
+		cv.popI32()
 	case binary.I32Load:
 		cv.i32Load(instr.Args, 32)
 	case binary.F32Load:
@@ -475,12 +599,12 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 	case binary.I64Store32:
 		cv.i64Store(instr.Args, 32)
 	case binary.MemorySize:
-		cv.checkMem()
-		cv.pushI32()
+		addrType := cv.checkMem(instr.Args.(uint32))
+		cv.pushOpd(addrType)
 	case binary.MemoryGrow:
-		cv.checkMem()
-		cv.popI32()
-		cv.pushI32()
+		addrType := cv.checkMem(instr.Args.(uint32))
+		cv.popOpdOf(addrType)
+		cv.pushOpd(addrType)
 	case binary.I32Const:
 		cv.pushI32()
 	case binary.I64Const:
@@ -627,26 +751,379 @@ func (cv *codeValidator) validateInstr(instr binary.Instruction) {
 	case binary.I64Extend8S, binary.I64Extend16S, binary.I64Extend32S:
 		cv.popI64()
 		cv.pushI64()
-	case binary.TruncSat:
-		switch instr.Args.(byte) {
-		case 0, 1:
-			cv.popF32()
-			cv.pushI32()
-		case 2, 3:
-			cv.popF64()
-			cv.pushI32()
-		case 4, 5:
-			cv.popF32()
-			cv.pushI64()
-		case 6, 7:
-			cv.popF64()
-			cv.pushI64()
+	case binary.RefNull:
+		cv.pushOpd(instr.Args.(binary.ValType))
+	case binary.RefIsNull:
+		cv.popRef()
+		cv.pushI32()
+	case binary.RefFunc:
+		fIdx := instr.Args.(uint32)
+		if _, ok := cv.mv.getFuncType(int(fIdx)); !ok {
+			cv.errorf("unknown function: %d", fIdx)
+		}
+		if !cv.mv.refs[fIdx] {
+			cv.errorf("undeclared function reference: %d", fIdx)
 		}
+		cv.pushOpd(binary.ValTypeFuncRef)
+	case binary.NumericPrefix:
+		cv.validateNumericInstr(instr.Args.(binary.PrefixArgs))
+	case binary.SimdPrefix:
+		cv.validateSimdInstr(instr.Args.(binary.PrefixArgs))
+	case binary.AtomicPrefix:
+		cv.validateAtomicInstr(instr.Args.(binary.PrefixArgs))
 	default:
 		cv.errorf("unknown opcode: 0x%x", instr.Opcode)
 	}
 }
 
+func (cv *codeValidator) validateNumericInstr(args binary.PrefixArgs) {
+	switch args.SubOp {
+	case binary.I32TruncSatF32S, binary.I32TruncSatF32U:
+		cv.popF32()
+		cv.pushI32()
+	case binary.I32TruncSatF64S, binary.I32TruncSatF64U:
+		cv.popF64()
+		cv.pushI32()
+	case binary.I64TruncSatF32S, binary.I64TruncSatF32U:
+		cv.popF32()
+		cv.pushI64()
+	case binary.I64TruncSatF64S, binary.I64TruncSatF64U:
+		cv.popF64()
+		cv.pushI64()
+	case binary.MemoryInit:
+		initArgs := args.Args.(binary.MemoryInitArgs)
+		addrType := cv.checkMem(initArgs.Mem)
+		cv.checkData(initArgs.Data)
+		cv.popI32()
+		cv.popI32()
+		cv.popOpdOf(addrType)
+	case binary.DataDrop:
+		cv.checkData(args.Args.(uint32))
+	case binary.MemoryCopy:
+		copyArgs := args.Args.(binary.MemoryCopyArgs)
+		dstType := cv.checkMem(copyArgs.Dst)
+		srcType := cv.checkMem(copyArgs.Src)
+		if dstType == I32 || srcType == I32 {
+			cv.popI32() // the length fits the smaller memory
+		} else {
+			cv.popI64()
+		}
+		cv.popOpdOf(srcType)
+		cv.popOpdOf(dstType)
+	case binary.MemoryFill:
+		addrType := cv.checkMem(args.Args.(uint32))
+		cv.popOpdOf(addrType)
+		cv.popI32()
+		cv.popOpdOf(addrType)
+	case binary.TableInit:
+		initArgs := args.Args.(binary.TableInitArgs)
+		t1 := cv.getTableType(initArgs.Table)
+		t2 := cv.getElemType(initArgs.Elem)
+		if t1 != t2 {
+			cv.typeMismatch([]valType{t1}, []valType{t2})
+		}
+		cv.popI32()
+		cv.popI32()
+		cv.popI32()
+	case binary.ElemDrop:
+		cv.getElemType(args.Args.(uint32))
+	case binary.TableCopy:
+		copyArgs := args.Args.(binary.TableCopyArgs)
+		t1 := cv.getTableType(copyArgs.Dst)
+		t2 := cv.getTableType(copyArgs.Src)
+		if t1 != t2 {
+			cv.typeMismatch([]valType{t1}, []valType{t2})
+		}
+		cv.popI32()
+		cv.popI32()
+		cv.popI32()
+	case binary.TableGrow:
+		t := cv.getTableType(args.Args.(uint32))
+		cv.popI32()
+		cv.popOpdOf(t)
+		cv.pushI32()
+	case binary.TableSize:
+		cv.getTableType(args.Args.(uint32))
+		cv.pushI32()
+	case binary.TableFill:
+		t := cv.getTableType(args.Args.(uint32))
+		cv.popI32()
+		cv.popOpdOf(t)
+		cv.popI32()
+	default:
+		cv.errorf("unknown opcode: 0xfc 0x%x", args.SubOp)
+	}
+}
+
+func (cv *codeValidator) validateSimdInstr(args binary.PrefixArgs) {
+	switch op := args.SubOp; op {
+	case binary.V128Load:
+		cv.load(V128, 128, args.Args)
+	case binary.V128Load8x8S, binary.V128Load8x8U, binary.V128Load16x4S,
+		binary.V128Load16x4U, binary.V128Load32x2S, binary.V128Load32x2U,
+		binary.V128Load64Splat, binary.V128Load64Zero:
+		cv.load(V128, 64, args.Args)
+	case binary.V128Load8Splat:
+		cv.load(V128, 8, args.Args)
+	case binary.V128Load16Splat:
+		cv.load(V128, 16, args.Args)
+	case binary.V128Load32Splat, binary.V128Load32Zero:
+		cv.load(V128, 32, args.Args)
+	case binary.V128Store:
+		cv.store(V128, 128, args.Args)
+	case binary.V128Load8Lane, binary.V128Load16Lane,
+		binary.V128Load32Lane, binary.V128Load64Lane:
+		bitWidth := 8 << (op - binary.V128Load8Lane)
+		laneArgs := args.Args.(binary.MemLaneArgs)
+		cv.checkLane(laneArgs.Lane, 128/bitWidth)
+		cv.popOpdOf(V128)
+		cv.load(V128, bitWidth, laneArgs.MemArg)
+	case binary.V128Store8Lane, binary.V128Store16Lane,
+		binary.V128Store32Lane, binary.V128Store64Lane:
+		bitWidth := 8 << (op - binary.V128Store8Lane)
+		laneArgs := args.Args.(binary.MemLaneArgs)
+		cv.checkLane(laneArgs.Lane, 128/bitWidth)
+		cv.store(V128, bitWidth, laneArgs.MemArg)
+	case binary.V128Const:
+		cv.pushOpd(V128)
+	case binary.I8x16Shuffle:
+		for _, lane := range args.Args.([16]byte) {
+			cv.checkLane(lane, 32)
+		}
+		cv.popOpdOf(V128)
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	case binary.I8x16Splat, binary.I16x8Splat, binary.I32x4Splat,
+		binary.I64x2Splat, binary.F32x4Splat, binary.F64x2Splat:
+		cv.popOpdOf([]valType{I32, I32, I32, I64, F32, F64}[op-binary.I8x16Splat])
+		cv.pushOpd(V128)
+	case binary.I8x16ExtractLaneS, binary.I8x16ExtractLaneU,
+		binary.I16x8ExtractLaneS, binary.I16x8ExtractLaneU,
+		binary.I32x4ExtractLane, binary.I64x2ExtractLane,
+		binary.F32x4ExtractLane, binary.F64x2ExtractLane:
+		vt, laneCount := simdLaneShape(op)
+		cv.checkLane(args.Args.(byte), laneCount)
+		cv.popOpdOf(V128)
+		cv.pushOpd(vt)
+	case binary.I8x16ReplaceLane, binary.I16x8ReplaceLane,
+		binary.I32x4ReplaceLane, binary.I64x2ReplaceLane,
+		binary.F32x4ReplaceLane, binary.F64x2ReplaceLane:
+		vt, laneCount := simdLaneShape(op)
+		cv.checkLane(args.Args.(byte), laneCount)
+		cv.popOpdOf(vt)
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	case binary.V128AnyTrue,
+		binary.I8x16AllTrue, binary.I8x16Bitmask, binary.I16x8AllTrue, binary.I16x8Bitmask,
+		binary.I32x4AllTrue, binary.I32x4Bitmask, binary.I64x2AllTrue, binary.I64x2Bitmask:
+		cv.popOpdOf(V128)
+		cv.pushI32()
+	case binary.I8x16Shl, binary.I8x16ShrS, binary.I8x16ShrU,
+		binary.I16x8Shl, binary.I16x8ShrS, binary.I16x8ShrU,
+		binary.I32x4Shl, binary.I32x4ShrS, binary.I32x4ShrU,
+		binary.I64x2Shl, binary.I64x2ShrS, binary.I64x2ShrU:
+		cv.popI32()
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	case binary.V128Bitselect:
+		cv.popOpdOf(V128)
+		cv.popOpdOf(V128)
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	case binary.V128Not, binary.F32x4DemoteF64x2Zero, binary.F64x2PromoteLowF32x4,
+		binary.I8x16Abs, binary.I8x16Neg, binary.I8x16Popcnt,
+		binary.F32x4Ceil, binary.F32x4Floor, binary.F32x4Trunc, binary.F32x4Nearest,
+		binary.F64x2Ceil, binary.F64x2Floor, binary.F64x2Trunc, binary.F64x2Nearest,
+		binary.I16x8ExtaddPairwiseI8x16S, binary.I16x8ExtaddPairwiseI8x16U,
+		binary.I32x4ExtaddPairwiseI16x8S, binary.I32x4ExtaddPairwiseI16x8U,
+		binary.I16x8Abs, binary.I16x8Neg,
+		binary.I16x8ExtendLowI8x16S, binary.I16x8ExtendHighI8x16S,
+		binary.I16x8ExtendLowI8x16U, binary.I16x8ExtendHighI8x16U,
+		binary.I32x4Abs, binary.I32x4Neg,
+		binary.I32x4ExtendLowI16x8S, binary.I32x4ExtendHighI16x8S,
+		binary.I32x4ExtendLowI16x8U, binary.I32x4ExtendHighI16x8U,
+		binary.I64x2Abs, binary.I64x2Neg,
+		binary.I64x2ExtendLowI32x4S, binary.I64x2ExtendHighI32x4S,
+		binary.I64x2ExtendLowI32x4U, binary.I64x2ExtendHighI32x4U,
+		binary.F32x4Abs, binary.F32x4Neg, binary.F32x4Sqrt,
+		binary.F64x2Abs, binary.F64x2Neg, binary.F64x2Sqrt,
+		binary.I32x4TruncSatF32x4S, binary.I32x4TruncSatF32x4U,
+		binary.F32x4ConvertI32x4S, binary.F32x4ConvertI32x4U,
+		binary.I32x4TruncSatF64x2SZero, binary.I32x4TruncSatF64x2UZero,
+		binary.F64x2ConvertLowI32x4S, binary.F64x2ConvertLowI32x4U:
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	default: // binary ops
+		cv.popOpdOf(V128)
+		cv.popOpdOf(V128)
+		cv.pushOpd(V128)
+	}
+}
+
+// the scalar type and lane count of extract_lane & replace_lane
+func simdLaneShape(op uint32) (valType, int) {
+	switch {
+	case op <= binary.I8x16ReplaceLane:
+		return I32, 16
+	case op <= binary.I16x8ReplaceLane:
+		return I32, 8
+	case op <= binary.I32x4ReplaceLane:
+		return I32, 4
+	case op <= binary.I64x2ReplaceLane:
+		return I64, 2
+	case op <= binary.F32x4ReplaceLane:
+		return F32, 4
+	default:
+		return F64, 2
+	}
+}
+
+func (cv *codeValidator) checkLane(lane byte, laneCount int) {
+	if int(lane) >= laneCount {
+		cv.errorf("invalid lane index: %d", lane)
+	}
+}
+
+func (cv *codeValidator) validateAtomicInstr(args binary.PrefixArgs) {
+	switch op := args.SubOp; {
+	case op == binary.AtomicFence:
+	case op == binary.MemoryAtomicNotify:
+		addrType := cv.checkAtomicMem(32, args.Args)
+		cv.popI32()
+		cv.popOpdOf(addrType)
+		cv.pushI32()
+	case op == binary.MemoryAtomicWait32:
+		addrType := cv.checkAtomicMem(32, args.Args)
+		cv.popI64()
+		cv.popI32()
+		cv.popOpdOf(addrType)
+		cv.pushI32()
+	case op == binary.MemoryAtomicWait64:
+		addrType := cv.checkAtomicMem(64, args.Args)
+		cv.popI64()
+		cv.popI64()
+		cv.popOpdOf(addrType)
+		cv.pushI32()
+	case op >= binary.I32AtomicLoad && op <= binary.I64AtomicRmw32CmpxchgU:
+		vt, bitWidth := binary.AtomicAccess(op)
+		addrType := cv.checkAtomicMem(bitWidth, args.Args)
+		switch {
+		case op <= binary.I64AtomicLoad32U:
+			cv.popOpdOf(addrType)
+			cv.pushOpd(vt)
+		case op <= binary.I64AtomicStore32:
+			cv.popOpdOf(vt)
+			cv.popOpdOf(addrType)
+		case op < binary.I32AtomicRmwCmpxchg:
+			cv.popOpdOf(vt)
+			cv.popOpdOf(addrType)
+			cv.pushOpd(vt)
+		default:
+			cv.popOpdOf(vt)
+			cv.popOpdOf(vt)
+			cv.popOpdOf(addrType)
+			cv.pushOpd(vt)
+		}
+	default:
+		cv.errorf("unknown opcode: 0xfe 0x%x", args.SubOp)
+	}
+}
+
+/* table */
+
+func (cv *codeValidator) getTableType(tableIdx uint32) valType {
+	if int(tableIdx) >= len(cv.mv.tableTypes) {
+		cv.errorf("unknown table: %d", tableIdx)
+	}
+	return cv.mv.tableTypes[tableIdx].ElemType
+}
+
+func (cv *codeValidator) getCalleeType(fIdx uint32) binary.FuncType {
+	ft, ok := cv.mv.getFuncType(int(fIdx))
+	if !ok {
+		cv.error("unknown function")
+	}
+	return ft
+}
+
+func (cv *codeValidator) getIndirectCalleeType(args binary.CallIndirectArgs) binary.FuncType {
+	if cv.getTableType(args.Table) != binary.FuncRef {
+		cv.error("type mismatch")
+	}
+	if int(args.Type) >= cv.mv.getTypeCount() {
+		cv.error("unknown type")
+	}
+	return cv.mv.module.TypeSec[args.Type]
+}
+
+/* exceptions */
+
+func (cv *codeValidator) getTagType(tagIdx uint32) binary.FuncType {
+	if int(tagIdx) >= len(cv.mv.tagTypes) {
+		cv.errorf("unknown tag: %d", tagIdx)
+	}
+	return cv.mv.tagTypes[tagIdx]
+}
+
+// handlers are validated like the else branch of an if, they start
+// with the payload of the tag they catch and end like the try block
+func (cv *codeValidator) validateTry(tryArgs binary.TryArgs) {
+	bt := cv.getBlockType(tryArgs.BT)
+	cv.popOpds(bt.ParamTypes)
+	cv.pushCtrl(binary.Try, bt.ParamTypes, bt.ResultTypes)
+	cv.validateExpr(tryArgs.Instrs)
+	for _, handler := range tryArgs.Handlers {
+		frame := cv.popCtrl()
+		if handler.CatchAll {
+			cv.pushCtrl(binary.CatchAll_, nil, frame.endTypes)
+		} else {
+			tagType := cv.getTagType(handler.Tag)
+			cv.pushCtrl(binary.Catch_, tagType.ParamTypes, frame.endTypes)
+		}
+		cv.validateExpr(handler.Instrs)
+	}
+	frame := cv.popCtrl()
+	if tryArgs.Delegate != nil && len(cv.ctrls) <= int(*tryArgs.Delegate) {
+		cv.error("unknown label")
+	}
+	cv.pushOpds(frame.endTypes)
+}
+
+// the payload (and the exnref) is passed to a label outside try_table
+func (cv *codeValidator) checkCatch(catch binary.Catch) {
+	var types []valType
+	switch catch.Kind {
+	case binary.ClauseCatch, binary.ClauseCatchRef:
+		types = append(types, cv.getTagType(catch.Tag).ParamTypes...)
+	}
+	switch catch.Kind {
+	case binary.ClauseCatchRef, binary.ClauseCatchAllRef:
+		types = append(types, binary.ValTypeExnRef)
+	}
+	n := int(catch.Label)
+	if len(cv.ctrls) <= n {
+		cv.error("unknown label")
+	}
+	if labelTypes := cv.getCtrl(n).labelTypes(); !isValTypesEq(types, labelTypes) {
+		cv.typeMismatch(labelTypes, types)
+	}
+}
+
+// the callee of a tail call returns to the caller's caller
+func (cv *codeValidator) checkTailCallResults(ft binary.FuncType) {
+	results := cv.getCtrl(len(cv.ctrls) - 1).labelTypes()
+	if !isValTypesEq(ft.ResultTypes, results) {
+		cv.typeMismatch(results, ft.ResultTypes)
+	}
+}
+
+func (cv *codeValidator) getElemType(elemIdx uint32) valType {
+	if int(elemIdx) >= len(cv.mv.module.ElemSec) {
+		cv.errorf("unknown elem segment: %d", elemIdx)
+	}
+	return cv.mv.module.ElemSec[elemIdx].Type
+}
+
 /* memory */
 
 func (cv *codeValidator) i32Load(args interface{}, bitWidth int) {
@@ -676,25 +1153,51 @@ func (cv *codeValidator) f64Store(args interface{}, bitWidth int) {
 }
 
 func (cv *codeValidator) load(vt binary.ValType, bitWidth int, args interface{}) {
-	cv.checkMem()
+	addrType := cv.checkMemArg(args)
 	cv.checkAlign(bitWidth, args)
-	cv.popI32()
+	cv.popOpdOf(addrType)
 	cv.pushOpd(vt)
 }
 func (cv *codeValidator) store(vt binary.ValType, bitWidth int, args interface{}) {
-	cv.checkMem()
+	addrType := cv.checkMemArg(args)
 	cv.checkAlign(bitWidth, args)
 	cv.popOpdOf(vt)
-	cv.popI32()
+	cv.popOpdOf(addrType)
+}
+// returns the address type of the memory
+func (cv *codeValidator) checkMem(memIdx uint32) valType {
+	if int(memIdx) >= cv.mv.getMemCount() {
+		cv.errorf("unknown memory: %d", memIdx)
+	}
+	return addrTypeOf(cv.mv.getMemType(int(memIdx)))
+}
+func (cv *codeValidator) checkMemArg(args interface{}) valType {
+	memArg := args.(binary.MemArg)
+	addrType := cv.checkMem(memArg.Mem)
+	if addrType == I32 && memArg.Offset > math.MaxUint32 {
+		cv.error("offset out of range")
+	}
+	return addrType
 }
-func (cv *codeValidator) checkMem() {
-	if cv.mv.getMemCount() == 0 {
-		cv.error("unknown memory")
+func (cv *codeValidator) checkData(dataIdx uint32) {
+	if cv.mv.module.DataCountSec == nil {
+		cv.error("data count section required")
+	}
+	if dataIdx >= *cv.mv.module.DataCountSec {
+		cv.errorf("unknown data segment: %d", dataIdx)
+	}
+}
+func (cv *codeValidator) checkAtomicMem(bitWidth int, args interface{}) valType {
+	addrType := cv.checkMemArg(args)
+	if 1<<args.(binary.MemArg).Align != bitWidth/8 {
+		cv.errorf("alignment must be equal to natural alignment (%d)",
+			bitWidth/8)
 	}
+	return addrType
 }
 func (cv *codeValidator) checkAlign(bitWidth int, args interface{}) {
 	align := args.(binary.MemArg).Align
-	if 1<<align > bitWidth/8 {
+	if align > 4 || 1<<align > bitWidth/8 {
 		cv.errorf("alignment must not be larger than natural alignment (%d)",
 			bitWidth/8)
 	}
@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+
+	"wasm.go/binary"
+)
+
+// Diagnostic describes a validation error.
+type Diagnostic struct {
+	FuncIdx   int              // -1 if not in a function body
+	InstrPath string           // e.g. "block/loop/i32.add"
+	Offset    uint32           // of the instruction in the binary, 0 if unknown
+	Expected  []binary.ValType // operand types, on type mismatch
+	Actual    []binary.ValType
+	err       error
+}
+
+func (d *Diagnostic) Error() string {
+	return d.err.Error()
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.err
+}
+
+func toDiagnostic(x interface{}) *Diagnostic {
+	switch x := x.(type) {
+	case *Diagnostic:
+		return x
+	case error:
+		return &Diagnostic{FuncIdx: -1, err: x}
+	default:
+		return &Diagnostic{FuncIdx: -1, err: fmt.Errorf("%v", x)}
+	}
+}
+
+// ValidateAll stops after this many diagnostics
+const maxDiagnostics = 100
+
+var errTooManyDiagnostics = errors.New("too many diagnostics")
+
+// ValidateAll keeps validating after errors and returns all of them in
+// the order they are found, up to maxDiagnostics. A function body is
+// checked to its end: the operand stack becomes polymorphic after each
+// error to avoid cascades.
+func ValidateAll(module binary.Module) []*Diagnostic {
+	return ValidateAllWithOptions(module, binary.DecodeOptions{})
+}
+
+func ValidateAllWithOptions(module binary.Module,
+	opts binary.DecodeOptions) (diags []*Diagnostic) {
+
+	v := &moduleValidator{
+		module:  module,
+		opts:    opts.WithDefaults(),
+		collect: true,
+	}
+	defer func() {
+		if r := recover(); r != nil && r != errTooManyDiagnostics {
+			panic(r)
+		}
+		diags = v.diags
+	}()
+	v.check(v.validate)
+	return
+}
+
+// check runs f, when collecting diagnostics, the error it panics with
+// is recorded and validation goes on
+func (v *moduleValidator) check(f func()) {
+	if !v.collect {
+		f()
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if r == errTooManyDiagnostics {
+				panic(r)
+			}
+			v.diags = append(v.diags, toDiagnostic(r))
+			if len(v.diags) >= maxDiagnostics {
+				panic(errTooManyDiagnostics)
+			}
+		}
+	}()
+	f()
+}
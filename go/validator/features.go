@@ -0,0 +1,113 @@
+package validator
+
+import (
+	"fmt"
+
+	"wasm.go/binary"
+)
+
+// validateFeatures rejects the module-level uses of the proposals
+// which opts.Features disables, instructions are checked by the
+// codeValidator, so that modules built in memory are restricted too.
+func (v *moduleValidator) validateFeatures() {
+	m := v.module
+	for i, ft := range m.TypeSec {
+		v.requireFeature(ft.Feature(), "type[%d]", i)
+	}
+	tableCount, memCount := len(m.TableSec), len(m.MemSec)
+	for i, imp := range m.ImportSec {
+		switch imp.Desc.Tag {
+		case binary.ImportTagTable:
+			tableCount++
+			v.requireFeature(tableTypeFeature(imp.Desc.Table), "import[%d]", i)
+		case binary.ImportTagMem:
+			memCount++
+			v.requireFeature(imp.Desc.Mem.Feature(), "import[%d]", i)
+		case binary.ImportTagGlobal:
+			v.requireFeature(binary.ValTypeFeature(imp.Desc.Global.ValType), "import[%d]", i)
+		case binary.ImportTagTag:
+			v.requireFeature(binary.FeatureExceptions, "import[%d]", i)
+		}
+	}
+	if tableCount > 1 {
+		v.requireFeature(binary.FeatureReferenceTypes, "multiple tables")
+	}
+	if memCount > 1 {
+		v.requireFeature(binary.FeatureMultiMemory, "multiple memories")
+	}
+	for i, table := range m.TableSec {
+		v.requireFeature(tableTypeFeature(table), "table[%d]", i)
+	}
+	for i, mem := range m.MemSec {
+		v.requireFeature(mem.Feature(), "mem[%d]", i)
+	}
+	for i := range m.TagSec {
+		v.requireFeature(binary.FeatureExceptions, "tag[%d]", i)
+	}
+	for i, g := range m.GlobalSec {
+		where := fmt.Sprintf("global[%d]", i+v.getImportedGlobalCount())
+		v.requireFeature(binary.ValTypeFeature(g.Type.ValType), "%s", where)
+		v.requireConstExprFeature(g.Init, where)
+	}
+	for i, exp := range m.ExportSec {
+		if exp.Desc.Tag == binary.ExportTagTag {
+			v.requireFeature(binary.FeatureExceptions, "export[%d]", i)
+		}
+	}
+	for i, elem := range m.ElemSec {
+		where := fmt.Sprintf("elem[%d]", i)
+		if elem.Mode != binary.SegModeActive || elem.Exprs != nil ||
+			elem.Type != binary.ValTypeFuncRef {
+			v.requireFeature(binary.FeatureBulkMemory, "%s", where)
+		}
+		if elem.Table != 0 {
+			v.requireFeature(binary.FeatureReferenceTypes, "%s", where)
+		}
+		v.requireConstExprFeature(elem.Offset, where)
+		for _, expr := range elem.Exprs {
+			v.requireConstExprFeature(expr, where)
+		}
+	}
+	if m.DataCountSec != nil {
+		v.requireFeature(binary.FeatureBulkMemory, "data count section")
+	}
+	for i, data := range m.DataSec {
+		where := fmt.Sprintf("data[%d]", i)
+		if data.Mode == binary.SegModePassive {
+			v.requireFeature(binary.FeatureBulkMemory, "%s", where)
+		}
+		if data.Mem != 0 {
+			v.requireFeature(binary.FeatureMultiMemory, "%s", where)
+		}
+		v.requireConstExprFeature(data.Offset, where)
+	}
+}
+
+// MVP constant expressions are a single instruction
+func (v *moduleValidator) requireConstExprFeature(expr []binary.Instruction, where string) {
+	if len(expr) > 1 {
+		v.requireFeature(binary.FeatureExtendedConst, "%s", where)
+	}
+	for _, instr := range expr {
+		v.requireFeature(instr.Feature(), "%s", where)
+	}
+}
+
+func (v *moduleValidator) requireFeature(f binary.Feature,
+	format string, a ...interface{}) {
+
+	v.check(func() {
+		if err := v.opts.Features.Check(f); err != nil {
+			panic(fmt.Errorf("%s: %w", fmt.Sprintf(format, a...), err))
+		}
+	})
+}
+
+// funcref tables are MVP
+func tableTypeFeature(tt binary.TableType) binary.Feature {
+	f := tt.Limits.Feature()
+	if tt.ElemType != binary.ValTypeFuncRef {
+		f |= binary.ValTypeFeature(tt.ElemType)
+	}
+	return f
+}
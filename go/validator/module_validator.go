@@ -8,37 +8,53 @@ import (
 
 type moduleValidator struct {
 	module           binary.Module
+	opts             binary.DecodeOptions
 	importedFuncs    []binary.Import
 	importedTables   []binary.Import
 	importedMemories []binary.Import
 	importedGlobals  []binary.Import
+	tableTypes       []binary.TableType
+	tagTypes         []binary.FuncType
 	globalTypes      []binary.GlobalType
+	refs             map[uint32]bool // funcs which ref.func may take
+	collect          bool            // diagnostics instead of stopping at the first
+	diags            []*Diagnostic
 }
 
-func Validate(module binary.Module) (err error) {
+func Validate(module binary.Module) error {
+	return ValidateWithOptions(module, binary.DecodeOptions{})
+}
+
+// ValidateWithOptions also enforces the limits of opts which apply
+// to decoded modules, so that modules built in memory are bounded too.
+func ValidateWithOptions(module binary.Module, opts binary.DecodeOptions) (err error) {
 	defer func() {
 		if _err := recover(); _err != nil {
 			switch x := _err.(type) {
 			case error:
 				err = x
 			default:
-				panic(_err)
+				err = fmt.Errorf("%v", x)
 			}
 		}
 	}()
 
 	v := &moduleValidator{
 		module: module,
+		opts:   opts.WithDefaults(),
 	}
 	v.validate()
 	return
 }
 
 func (v *moduleValidator) validate()  {
+	v.refs = map[uint32]bool{}
+	v.validateFeatures()
 	v.validateImportSec()
 	v.validateFuncSec()
 	v.validateTableSec()
 	v.validateMemSec()
+	v.validateTagSec()
 	v.validateGlobalSec()
 	v.validateExportSec()
 	v.validateStartSec()
@@ -52,191 +68,300 @@ func (v *moduleValidator) validateImportSec() {
 		switch imp.Desc.Tag {
 		case binary.ImportTagFunc:
 			v.importedFuncs = append(v.importedFuncs, imp)
-			if int(imp.Desc.FuncType) >= v.getTypeCount() {
-				panic(fmt.Errorf("import[%d]: unknown type: %d",
-					i, imp.Desc.FuncType))
-			}
+			v.check(func() {
+				if int(imp.Desc.FuncType) >= v.getTypeCount() {
+					panic(fmt.Errorf("import[%d]: unknown type: %d",
+						i, imp.Desc.FuncType))
+				}
+			})
 		case binary.ImportTagTable:
-			if len(v.importedTables) > 0 {
-				panic(fmt.Errorf("multiple tables"))
-			}
 			v.importedTables = append(v.importedTables, imp)
-			if err := validateTableType(imp.Desc.Table.Limits); err != "" {
-				panic(fmt.Errorf("import[%d]: %s", i, err))
-			}
+			v.tableTypes = append(v.tableTypes, imp.Desc.Table)
+			v.check(func() {
+				if err := validateTableType(imp.Desc.Table.Limits); err != "" {
+					panic(fmt.Errorf("import[%d]: %s", i, err))
+				}
+			})
 		case binary.ImportTagMem:
-			if len(v.importedMemories) > 0 {
-				panic(fmt.Errorf("multiple memories"))
-			}
 			v.importedMemories = append(v.importedMemories, imp)
-			if err := validateMemoryType(imp.Desc.Mem); err != "" {
-				panic(fmt.Errorf("import[%d]: %s", i, err))
-			}
+			v.check(func() {
+				if err := validateMemoryType(imp.Desc.Mem); err != "" {
+					panic(fmt.Errorf("import[%d]: %s", i, err))
+				}
+			})
 		case binary.ImportTagGlobal:
 			v.importedGlobals = append(v.importedGlobals, imp)
 			v.globalTypes = append(v.globalTypes, imp.Desc.Global)
+		case binary.ImportTagTag:
+			tagIdx := len(v.tagTypes)
+			v.tagTypes = append(v.tagTypes, binary.FuncType{})
+			v.check(func() {
+				v.tagTypes[tagIdx] = v.validateTagType(
+					fmt.Sprintf("import[%d]", i), imp.Desc.TagType)
+			})
 		}
 	}
 }
 func (v *moduleValidator) validateFuncSec() {
 	for i, ftIdx := range v.module.FuncSec {
-		if int(ftIdx) >= v.getTypeCount() {
-			panic(fmt.Errorf("func[%d]: unknown type: %d", i, ftIdx))
-		}
+		v.check(func() {
+			if int(ftIdx) >= v.getTypeCount() {
+				panic(fmt.Errorf("func[%d]: unknown type: %d", i, ftIdx))
+			}
+		})
 	}
+	v.check(func() {
+		if v.getFuncCount() > int(v.opts.MaxFuncs) {
+			panic(fmt.Errorf("%w: %d", binary.ErrTooManyFuncs, v.getFuncCount()))
+		}
+	})
 }
 func (v *moduleValidator) validateTableSec() {
 	for i, table := range v.module.TableSec {
-		if i+v.getImportedTableCount() > 0 {
-			panic(fmt.Errorf("multiple tables"))
-		}
-		if err := validateTableType(table.Limits); err != "" {
-			panic(fmt.Errorf("table[%d]: %s", i, err))
-		}
+		v.check(func() {
+			if err := validateTableType(table.Limits); err != "" {
+				panic(fmt.Errorf("table[%d]: %s", i, err))
+			}
+		})
+		v.tableTypes = append(v.tableTypes, table)
 	}
 }
 func (v *moduleValidator) validateMemSec() {
 	for i, mem := range v.module.MemSec {
-		if i+v.getImportedMemCount() > 0 {
-			panic(fmt.Errorf("multiple memories"))
-		}
-		if err := validateMemoryType(mem); err != "" {
-			panic(fmt.Errorf("mem[%d]: %s", i, err))
-		}
+		v.check(func() {
+			if err := validateMemoryType(mem); err != "" {
+				panic(fmt.Errorf("mem[%d]: %s", i, err))
+			}
+		})
 	}
 }
+func (v *moduleValidator) validateTagSec() {
+	for _, tag := range v.module.TagSec {
+		tagIdx := len(v.tagTypes)
+		v.tagTypes = append(v.tagTypes, binary.FuncType{})
+		v.check(func() {
+			v.tagTypes[tagIdx] = v.validateTagType(
+				fmt.Sprintf("tag[%d]", tagIdx), tag)
+		})
+	}
+}
+
+// exception tags take a payload but return nothing
+func (v *moduleValidator) validateTagType(where string,
+	tt binary.TagType) binary.FuncType {
+
+	if int(tt.Type) >= v.getTypeCount() {
+		panic(fmt.Errorf("%s: unknown type: %d", where, tt.Type))
+	}
+	ft := v.module.TypeSec[tt.Type]
+	if len(ft.ResultTypes) > 0 {
+		panic(fmt.Errorf("%s: non-empty tag result type", where))
+	}
+	return ft
+}
 func (v *moduleValidator) validateGlobalSec() {
 	for i, g := range v.module.GlobalSec {
-		if err := v.validateConstExpr(g.Init, g.Type.ValType); err != "" {
-			panic(fmt.Errorf("global[%d]: %s",
-				i+v.getImportedGlobalCount(), err))
-		}
+		v.check(func() {
+			if err := v.validateConstExpr(g.Init, g.Type.ValType); err != "" {
+				panic(fmt.Errorf("global[%d]: %s",
+					i+v.getImportedGlobalCount(), err))
+			}
+		})
 		v.globalTypes = append(v.globalTypes, g.Type)
 	}
 }
 func (v *moduleValidator) validateExportSec() {
 	exportedNames := map[string]bool{}
 	for i, exp := range v.module.ExportSec {
-		if exportedNames[exp.Name] {
-			panic(fmt.Errorf("duplicate export name: %s", exp.Name))
-		} else {
-			exportedNames[exp.Name] = true
-		}
+		v.check(func() {
+			v.validateExport(i, exp, exportedNames)
+		})
+	}
+}
+func (v *moduleValidator) validateExport(i int, exp binary.Export,
+	exportedNames map[string]bool) {
 
-		switch exp.Desc.Tag {
-		case binary.ExportTagFunc:
-			if int(exp.Desc.Idx) >= v.getFuncCount() {
-				panic(fmt.Errorf("export[%d]: unknown function: %d",
-					i, exp.Desc.Idx))
-			}
-		case binary.ExportTagTable:
-			if int(exp.Desc.Idx) >= v.getTableCount() {
-				panic(fmt.Errorf("export[%d]: unknown table: %d",
-					i, exp.Desc.Idx))
-			}
-		case binary.ExportTagMem:
-			if int(exp.Desc.Idx) >= v.getMemCount() {
-				panic(fmt.Errorf("export[%d]: unknown memory: %d",
-					i, exp.Desc.Idx))
-			}
-		case binary.ExportTagGlobal:
-			if int(exp.Desc.Idx) >= v.getGlobalCount() {
-				panic(fmt.Errorf("export[%d]: unknown global: %d",
-					i, exp.Desc.Idx))
-			}
+	if exportedNames[exp.Name] {
+		panic(fmt.Errorf("duplicate export name: %s", exp.Name))
+	} else {
+		exportedNames[exp.Name] = true
+	}
+
+	switch exp.Desc.Tag {
+	case binary.ExportTagFunc:
+		if int(exp.Desc.Idx) >= v.getFuncCount() {
+			panic(fmt.Errorf("export[%d]: unknown function: %d",
+				i, exp.Desc.Idx))
+		}
+		v.refs[exp.Desc.Idx] = true
+	case binary.ExportTagTable:
+		if int(exp.Desc.Idx) >= v.getTableCount() {
+			panic(fmt.Errorf("export[%d]: unknown table: %d",
+				i, exp.Desc.Idx))
+		}
+	case binary.ExportTagMem:
+		if int(exp.Desc.Idx) >= v.getMemCount() {
+			panic(fmt.Errorf("export[%d]: unknown memory: %d",
+				i, exp.Desc.Idx))
+		}
+	case binary.ExportTagGlobal:
+		if int(exp.Desc.Idx) >= v.getGlobalCount() {
+			panic(fmt.Errorf("export[%d]: unknown global: %d",
+				i, exp.Desc.Idx))
+		}
+	case binary.ExportTagTag:
+		if int(exp.Desc.Idx) >= len(v.tagTypes) {
+			panic(fmt.Errorf("export[%d]: unknown tag: %d",
+				i, exp.Desc.Idx))
 		}
 	}
 }
 func (v *moduleValidator) validateStartSec() {
 	if v.module.StartSec != nil {
-		idx := int(*v.module.StartSec)
-		ft, ok := v.getFuncType(idx)
-		if !ok {
-			panic(fmt.Errorf("start function: unknown function: %d", idx))
-		}
-		if len(ft.ParamTypes) > 0 || len(ft.ResultTypes) > 0 {
-			panic(fmt.Errorf("start function: invalid type: %d", idx))
-		}
+		v.check(func() {
+			idx := int(*v.module.StartSec)
+			ft, ok := v.getFuncType(idx)
+			if !ok {
+				panic(fmt.Errorf("start function: unknown function: %d", idx))
+			}
+			if len(ft.ParamTypes) > 0 || len(ft.ResultTypes) > 0 {
+				panic(fmt.Errorf("start function: invalid type: %d", idx))
+			}
+		})
 	}
 }
 func (v *moduleValidator) validateElemSec() {
 	for i, elem := range v.module.ElemSec {
-		if int(elem.Table) >= v.getTableCount() {
-			panic(fmt.Errorf("elem[%d]: unknown table: %d", i, elem.Table))
-		}
-		if err := v.validateConstExpr(elem.Offset, binary.ValTypeI32); err != "" {
-			panic(fmt.Errorf("elem[%d]: %s", i, err))
+		v.check(func() {
+			if elem.Mode != binary.SegModeActive {
+				return
+			}
+			if int(elem.Table) >= v.getTableCount() {
+				panic(fmt.Errorf("elem[%d]: unknown table: %d", i, elem.Table))
+			}
+			if v.tableTypes[elem.Table].ElemType != elem.Type {
+				panic(fmt.Errorf("elem[%d]: type mismatch", i))
+			}
+			if err := v.validateConstExpr(elem.Offset, binary.ValTypeI32); err != "" {
+				panic(fmt.Errorf("elem[%d]: %s", i, err))
+			}
+		})
+		for j, expr := range elem.Exprs {
+			v.check(func() {
+				if err := v.validateConstExpr(expr, elem.Type); err != "" {
+					panic(fmt.Errorf("elem[%d][%d]: %s", i, j, err))
+				}
+			})
 		}
 		for j, funcIdx := range elem.Init {
-			if int(funcIdx) >= v.getFuncCount() {
-				panic(fmt.Errorf("elem[%d][%d]: unknown function: %d", i, j, funcIdx))
-			}
+			v.check(func() {
+				if int(funcIdx) >= v.getFuncCount() {
+					panic(fmt.Errorf("elem[%d][%d]: unknown function: %d", i, j, funcIdx))
+				}
+			})
+			v.refs[funcIdx] = true
 		}
 	}
 }
 func (v *moduleValidator) validateCodeSec() {
-	if len(v.module.CodeSec) != len(v.module.FuncSec) {
-		panic(fmt.Errorf("invalid code count"))
-	}
+	v.check(func() {
+		if len(v.module.CodeSec) != len(v.module.FuncSec) {
+			panic(fmt.Errorf("invalid code count"))
+		}
+	})
 	for i, code := range v.module.CodeSec {
-		ftIdx := v.module.FuncSec[i]
-		ft := v.module.TypeSec[ftIdx]
-		validateCode(v, i, code, ft)
+		// a missing or unknown type is already reported
+		ft, ok := v.getFuncType(v.getImportedFuncCount() + i)
+		if !ok {
+			continue
+		}
+		v.check(func() {
+			validateCode(v, i, code, ft)
+		})
 	}
 }
 func (v *moduleValidator) validateDataSec() {
-	for i, data := range v.module.DataSec {
-		if int(data.Mem) >= v.getMemCount() {
-			panic(fmt.Errorf("data[%d]: unknown memory: %d", i, data.Mem))
-		}
-		if err := v.validateConstExpr(data.Offset, binary.ValTypeI32); err != "" {
-			panic(fmt.Errorf("data[%d]: %s", i, err))
+	v.check(func() {
+		if n := v.module.DataCountSec; n != nil && int(*n) != len(v.module.DataSec) {
+			panic(fmt.Errorf("data count and data section have inconsistent lengths"))
 		}
+	})
+	for i, data := range v.module.DataSec {
+		v.check(func() {
+			if len(data.Init) > int(v.opts.MaxDataSize) {
+				panic(fmt.Errorf("data[%d]: %w", i, binary.ErrDataTooLarge))
+			}
+			if data.Mode == binary.SegModePassive {
+				return
+			}
+			if int(data.Mem) >= v.getMemCount() {
+				panic(fmt.Errorf("data[%d]: unknown memory: %d", i, data.Mem))
+			}
+			addrType := addrTypeOf(v.getMemType(int(data.Mem)))
+			if err := v.validateConstExpr(data.Offset, addrType); err != "" {
+				panic(fmt.Errorf("data[%d]: %s", i, err))
+			}
+		})
 	}
 }
 
-// TODO
+// extended-const allows i32/i64 add, sub and mul besides the constants,
+// global.get may only read imported immutable globals
 func (v *moduleValidator) validateConstExpr(expr []binary.Instruction,
 	expectedType binary.ValType) (errMsg string) {
 
-	if len(expr) > 1 {
-		for _, instr := range expr {
-			switch instr.Opcode {
-			case binary.I32Const, binary.I64Const,
-				binary.F32Const, binary.F64Const,
-				binary.GlobalGet:
-			default:
-				return "constant expression required"
-			}
-		}
-		return "type mismatch" // TODO
-	}
-
-	var actualType byte = 0
-	if len(expr) > 0 {
-		switch expr[0].Opcode {
+	var stack []binary.ValType
+	for _, instr := range expr {
+		switch instr.Opcode {
 		case binary.I32Const:
-			actualType = binary.ValTypeI32
+			stack = append(stack, binary.ValTypeI32)
 		case binary.I64Const:
-			actualType = binary.ValTypeI64
+			stack = append(stack, binary.ValTypeI64)
 		case binary.F32Const:
-			actualType = binary.ValTypeF32
+			stack = append(stack, binary.ValTypeF32)
 		case binary.F64Const:
-			actualType = binary.ValTypeF64
+			stack = append(stack, binary.ValTypeF64)
+		case binary.SimdPrefix:
+			if instr.Args.(binary.PrefixArgs).SubOp != binary.V128Const {
+				return "constant expression required"
+			}
+			stack = append(stack, binary.ValTypeV128)
 		case binary.GlobalGet:
-			gIdx := expr[0].Args.(uint32)
-			if int(gIdx) >= len(v.globalTypes) {
+			gIdx := instr.Args.(uint32)
+			if int(gIdx) >= v.getImportedGlobalCount() {
 				return fmt.Sprintf("unknown global: %d", gIdx)
 			}
-			actualType = v.globalTypes[gIdx].ValType
+			if v.globalTypes[gIdx].Mut != 0 {
+				return "constant expression required"
+			}
+			stack = append(stack, v.globalTypes[gIdx].ValType)
+		case binary.RefNull:
+			stack = append(stack, instr.Args.(binary.ValType))
+		case binary.RefFunc:
+			fIdx := instr.Args.(uint32)
+			if int(fIdx) >= v.getFuncCount() {
+				return fmt.Sprintf("unknown function: %d", fIdx)
+			}
+			v.refs[fIdx] = true
+			stack = append(stack, binary.ValTypeFuncRef)
+		case binary.I32Add, binary.I32Sub, binary.I32Mul,
+			binary.I64Add, binary.I64Sub, binary.I64Mul:
+			vt := binary.ValTypeI32
+			if instr.Opcode >= binary.I64Add {
+				vt = binary.ValTypeI64
+			}
+			n := len(stack)
+			if n < 2 || stack[n-1] != vt || stack[n-2] != vt {
+				return "type mismatch"
+			}
+			stack = stack[:n-1]
 		default:
 			return "constant expression required"
 		}
 	}
-	if actualType != expectedType {
-		return "type mismatch" // TODO
+	if len(stack) != 1 || stack[0] != expectedType {
+		return "type mismatch"
 	}
-
 	return ""
 }
 
@@ -282,40 +407,64 @@ func (v *moduleValidator) getGlobalCount() int {
 	return v.getImportedGlobalCount() + v.getInternalGlobalCount()
 }
 
+func (v *moduleValidator) getMemType(memIdx int) binary.MemType {
+	if memIdx < v.getImportedMemCount() {
+		return v.importedMemories[memIdx].Desc.Mem
+	}
+	return v.module.MemSec[memIdx-v.getImportedMemCount()]
+}
+
+// memory64 memories are addressed by i64
+func addrTypeOf(mt binary.MemType) binary.ValType {
+	if mt.Is64 {
+		return binary.ValTypeI64
+	}
+	return binary.ValTypeI32
+}
+
 func (v *moduleValidator) getFuncType(fIdx int) (binary.FuncType, bool) {
+	var ftIdx uint32
 	if fIdx < v.getImportedFuncCount() {
-		ftIdx := v.importedFuncs[fIdx].Desc.FuncType
-		return v.module.TypeSec[ftIdx], true
+		ftIdx = v.importedFuncs[fIdx].Desc.FuncType
+	} else if fIdx < v.getFuncCount() {
+		ftIdx = v.module.FuncSec[fIdx-v.getImportedFuncCount()]
+	} else {
+		return binary.FuncType{}, false
 	}
-	if fIdx < v.getFuncCount() {
-		ftIdx := v.module.FuncSec[fIdx-v.getImportedFuncCount()]
-		return v.module.TypeSec[ftIdx], true
+	if int(ftIdx) >= v.getTypeCount() {
+		return binary.FuncType{}, false
 	}
-	return binary.FuncType{}, false
+	return v.module.TypeSec[ftIdx], true
 }
 
 func validateTableType(limits binary.Limits) string {
+	if limits.Shared {
+		return "tables cannot be shared"
+	}
+	if limits.Is64 {
+		return "tables cannot be 64-bit"
+	}
 	return validateLimits(limits, (1<<32)-1, "table")
 }
 func validateMemoryType(limits binary.Limits) string {
-	return validateLimits(limits, 1<<16, "mem")
+	if limits.Shared && limits.Tag != 1 {
+		return "shared memory must have maximum"
+	}
+	if limits.Is64 {
+		return validateLimits(limits, binary.MaxPageCount64, "mem64")
+	}
+	return validateLimits(limits, binary.MaxPageCount, "mem")
 }
-func validateLimits(limits binary.Limits, k uint32, kind string) (errMsg string) {
-	if limits.Min > k {
-		if kind == "mem" {
+func validateLimits(limits binary.Limits, k uint64, kind string) (errMsg string) {
+	if limits.Min > k || limits.Tag == 1 && limits.Max > k {
+		switch kind {
+		case "mem":
 			return "memory size must be at most 65536 pages (4GiB)"
-		} else {
-			// TODO
+		case "mem64":
+			return "memory size must be at most 2^48 pages"
 		}
 	}
 	if limits.Tag == 1 {
-		if limits.Max > k {
-			if kind == "mem" {
-				return "memory size must be at most 65536 pages (4GiB)"
-			} else {
-				// TODO
-			}
-		}
 		if limits.Max < limits.Min {
 			return "size minimum must not be greater than maximum"
 		}
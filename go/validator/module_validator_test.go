@@ -0,0 +1,427 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/internal/fuzzseed"
+)
+
+func FuzzValidate(f *testing.F) {
+	fuzzseed.Add(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if module, err := binary.Decode(data); err == nil {
+			_ = Validate(module)
+			_ = ValidateAll(module)
+		}
+	})
+}
+
+func TestValidateLimits(t *testing.T) {
+	ft := binary.FuncType{Tag: binary.FtTag}
+	module := binary.Module{
+		TypeSec: []binary.FuncType{ft},
+		FuncSec: []binary.TypeIdx{0, 0},
+		CodeSec: make([]binary.Code, 2),
+		MemSec:  []binary.MemType{{Min: 1}},
+		DataSec: []binary.Data{{
+			Offset: []binary.Instruction{{Opcode: binary.I32Const, Args: int32(0)}},
+			Init:   make([]byte, 4),
+		}},
+	}
+	require.NoError(t, Validate(module))
+
+	err := ValidateWithOptions(module, binary.DecodeOptions{MaxFuncs: 1})
+	require.ErrorIs(t, err, binary.ErrTooManyFuncs)
+	err = ValidateWithOptions(module, binary.DecodeOptions{MaxDataSize: 3})
+	require.ErrorIs(t, err, binary.ErrDataTooLarge)
+
+	module.CodeSec[1].Locals = []binary.Locals{{N: 2, Type: binary.ValTypeI32}}
+	err = ValidateWithOptions(module, binary.DecodeOptions{MaxLocals: 1})
+	require.ErrorIs(t, err, binary.ErrTooManyLocals)
+
+	module.CodeSec[1].Expr = []binary.Instruction{{Opcode: binary.Block,
+		Args: binary.BlockArgs{BT: binary.BlockTypeEmpty}}}
+	err = ValidateWithOptions(module, binary.DecodeOptions{MaxNestingDepth: 1})
+	require.NoError(t, err)
+	module.CodeSec[1].Expr[0].Args = binary.BlockArgs{BT: binary.BlockTypeEmpty,
+		Instrs: module.CodeSec[1].Expr}
+	err = ValidateWithOptions(module, binary.DecodeOptions{MaxNestingDepth: 1})
+	require.ErrorIs(t, err, binary.ErrNestingTooDeep)
+}
+
+func TestValidateAll(t *testing.T) {
+	module, err := binary.DecodeFile("./testdata/invalid.wasm")
+	require.NoError(t, err)
+
+	diags := ValidateAll(module)
+	require.Equal(t, 4, len(diags))
+	require.Equal(t, "export[0]: unknown function: 9", diags[0].Error())
+	require.Equal(t, -1, diags[0].FuncIdx)
+
+	require.Equal(t, 0, diags[1].FuncIdx)
+	require.Equal(t, []binary.ValType{binary.ValTypeI32}, diags[1].Expected)
+	require.Equal(t, []binary.ValType{binary.ValTypeI64}, diags[1].Actual)
+
+	require.Equal(t, 1, diags[2].FuncIdx)
+	require.Equal(t, "block/i32.add", diags[2].InstrPath)
+	require.Equal(t, uint32(0x34), diags[2].Offset)
+	require.Equal(t, []binary.ValType{binary.ValTypeI32}, diags[2].Expected)
+	require.Equal(t, []binary.ValType{binary.ValTypeF32}, diags[2].Actual)
+
+	require.Equal(t, "code[1], local.get: unknown local: 5", diags[3].Error())
+	require.Equal(t, uint32(0x37), diags[3].Offset)
+
+	require.Equal(t, diags[0].Error(), Validate(module).Error())
+}
+
+func TestValidateRefTypes(t *testing.T) {
+	ft := binary.FuncType{Tag: binary.FtTag}
+	module := binary.Module{
+		TypeSec:  []binary.FuncType{ft},
+		FuncSec:  []binary.TypeIdx{0, 0},
+		CodeSec:  make([]binary.Code, 2),
+		TableSec: []binary.TableType{{ElemType: binary.ExternRef}},
+	}
+	refFunc := []binary.Instruction{
+		{Opcode: binary.RefFunc, Args: uint32(1)},
+		{Opcode: binary.Drop},
+	}
+	module.CodeSec[0].Expr = refFunc
+	require.EqualError(t, Validate(module),
+		"code[0], ref.func: undeclared function reference: 1")
+	module.ExportSec = []binary.Export{{Name: "f1",
+		Desc: binary.ExportDesc{Tag: binary.ExportTagFunc, Idx: 1}}}
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[1].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.RefNull, Args: binary.ValTypeFuncRef},
+		{Opcode: binary.TableSet, Args: uint32(0)},
+	}
+	require.EqualError(t, Validate(module), "code[1], table.set: type mismatch")
+	module.CodeSec[1].Expr[1].Args = binary.ValTypeExternRef
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[1].Expr = []binary.Instruction{
+		{Opcode: binary.RefNull, Args: binary.ValTypeExternRef},
+		{Opcode: binary.RefNull, Args: binary.ValTypeExternRef},
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.Select},
+		{Opcode: binary.Drop},
+	}
+	require.EqualError(t, Validate(module), "code[1], select: type mismatch")
+	module.CodeSec[1].Expr[3] = binary.Instruction{Opcode: binary.SelectT,
+		Args: []binary.ValType{binary.ValTypeExternRef}}
+	require.NoError(t, Validate(module))
+}
+
+func TestValidateTableBulkOps(t *testing.T) {
+	ft := binary.FuncType{Tag: binary.FtTag}
+	module := binary.Module{
+		TypeSec: []binary.FuncType{ft},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: make([]binary.Code, 1),
+		TableSec: []binary.TableType{
+			{ElemType: binary.FuncRef},
+			{ElemType: binary.ExternRef},
+		},
+		ElemSec: []binary.Elem{{Mode: binary.SegModePassive, Type: binary.ExternRef,
+			Exprs: []binary.Expr{{{Opcode: binary.RefNull, Args: binary.ValTypeExternRef}}}}},
+	}
+	i32x3 := []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Const, Args: int32(0)},
+	}
+	prefixed := func(subOp uint32, args interface{}) binary.Expr {
+		return append(i32x3[:3:3], binary.Instruction{Opcode: binary.NumericPrefix,
+			Args: binary.PrefixArgs{SubOp: subOp, Args: args}})
+	}
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[0].Expr = prefixed(binary.TableInit, binary.TableInitArgs{Elem: 0, Table: 0})
+	require.EqualError(t, Validate(module), "code[0], table.init: type mismatch")
+	module.CodeSec[0].Expr = prefixed(binary.TableInit, binary.TableInitArgs{Elem: 1, Table: 1})
+	require.EqualError(t, Validate(module), "code[0], table.init: unknown elem segment: 1")
+	module.CodeSec[0].Expr = prefixed(binary.TableInit, binary.TableInitArgs{Elem: 0, Table: 1})
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[0].Expr = prefixed(binary.TableCopy, binary.TableCopyArgs{Dst: 0, Src: 1})
+	require.EqualError(t, Validate(module), "code[0], table.copy: type mismatch")
+	module.CodeSec[0].Expr = prefixed(binary.TableCopy, binary.TableCopyArgs{Dst: 1, Src: 1})
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[0].Expr = append(i32x3[:1:1], binary.Instruction{Opcode: binary.CallIndirect,
+		Args: binary.CallIndirectArgs{Type: 0, Table: 1}})
+	require.EqualError(t, Validate(module), "code[0], call_indirect: type mismatch")
+	module.CodeSec[0].Expr[1].Args = binary.CallIndirectArgs{Type: 0, Table: 0}
+	require.NoError(t, Validate(module))
+
+	module.ElemSec[0].Mode = binary.SegModeActive
+	module.ElemSec[0].Offset = i32x3[:1]
+	require.EqualError(t, Validate(module), "elem[0]: type mismatch")
+	module.ElemSec[0].Table = 1
+	require.NoError(t, Validate(module))
+}
+
+func TestValidateMultiMemory(t *testing.T) {
+	ft := binary.FuncType{Tag: binary.FtTag}
+	module := binary.Module{
+		TypeSec: []binary.FuncType{ft},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: make([]binary.Code, 1),
+		ImportSec: []binary.Import{{Module: "env", Name: "mem",
+			Desc: binary.ImportDesc{Tag: binary.ImportTagMem}}},
+		MemSec: []binary.MemType{{Min: 1}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Load, Args: binary.MemArg{Align: 2, Mem: 2}},
+		{Opcode: binary.Drop},
+	}
+	require.EqualError(t, Validate(module), "code[0], i32.load: unknown memory: 2")
+	module.CodeSec[0].Expr[1].Args = binary.MemArg{Align: 2, Mem: 1}
+	require.NoError(t, Validate(module))
+
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.NumericPrefix, Args: binary.PrefixArgs{
+			SubOp: binary.MemoryCopy, Args: binary.MemoryCopyArgs{Dst: 1, Src: 2}}},
+	}
+	require.EqualError(t, Validate(module), "code[0], memory.copy: unknown memory: 2")
+}
+
+func TestValidateTailCalls(t *testing.T) {
+	i32, i64 := binary.ValTypeI32, binary.ValTypeI64
+	module := binary.Module{
+		TypeSec: []binary.FuncType{
+			{Tag: binary.FtTag, ResultTypes: []binary.ValType{i64}},
+			{Tag: binary.FtTag, ResultTypes: []binary.ValType{i32}},
+		},
+		FuncSec:  []binary.TypeIdx{0, 1},
+		CodeSec:  make([]binary.Code, 2),
+		TableSec: []binary.TableType{{ElemType: binary.FuncRef}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.ReturnCall, Args: uint32(1)},
+	}
+	module.CodeSec[1].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+	}
+	require.EqualError(t, Validate(module), "code[0], return_call: type mismatch")
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.ReturnCallIndirect, Args: binary.CallIndirectArgs{Type: 1}},
+	}
+	require.EqualError(t, Validate(module), "code[0], return_call_indirect: type mismatch")
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.ReturnCallIndirect, Args: binary.CallIndirectArgs{Type: 0}},
+		{Opcode: binary.Drop}, // unreachable
+	}
+	require.NoError(t, Validate(module))
+}
+
+func TestValidateExceptions(t *testing.T) {
+	i32 := binary.ValTypeI32
+	module := binary.Module{
+		TypeSec: []binary.FuncType{
+			{Tag: binary.FtTag},
+			{Tag: binary.FtTag, ParamTypes: []binary.ValType{i32}},
+			{Tag: binary.FtTag, ResultTypes: []binary.ValType{i32}},
+		},
+		FuncSec: []binary.TypeIdx{2},
+		CodeSec: make([]binary.Code, 1),
+		TagSec:  []binary.TagType{{Type: 1}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.Try, Args: binary.TryArgs{
+			BT:       binary.BlockTypeI32,
+			Instrs:   []binary.Instruction{{Opcode: binary.I32Const, Args: int32(0)}},
+			Handlers: []binary.Handler{{Tag: 0}}, // the payload is the result
+		}},
+	}
+	require.NoError(t, Validate(module))
+	module.CodeSec[0].Expr[0].Args = binary.TryArgs{
+		BT:       binary.BlockTypeI32,
+		Instrs:   []binary.Instruction{{Opcode: binary.I32Const, Args: int32(0)}},
+		Handlers: []binary.Handler{{CatchAll: true, Instrs: []binary.Instruction{{Opcode: binary.Rethrow, Args: uint32(1)}}}},
+	}
+	require.EqualError(t, Validate(module), "code[0], try/rethrow: invalid rethrow label")
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.TryTable, Args: binary.TryTableArgs{
+			BT:      binary.BlockTypeEmpty,
+			Catches: []binary.Catch{{Kind: binary.ClauseCatchRef, Tag: 0, Label: 0}},
+		}},
+		{Opcode: binary.I32Const, Args: int32(0)},
+	}
+	require.EqualError(t, Validate(module), "code[0], try_table: type mismatch")
+	module.CodeSec[0].Expr[0].Args = binary.TryTableArgs{
+		BT:      binary.BlockTypeEmpty,
+		Catches: []binary.Catch{{Kind: binary.ClauseCatch, Tag: 1}},
+	}
+	require.EqualError(t, Validate(module), "code[0], try_table: unknown tag: 1")
+
+	module.TagSec[0].Type = 2
+	require.EqualError(t, Validate(module), "tag[0]: non-empty tag result type")
+}
+
+func TestValidateAtomics(t *testing.T) {
+	i32 := binary.ValTypeI32
+	module := binary.Module{
+		TypeSec: []binary.FuncType{{Tag: binary.FtTag, ResultTypes: []binary.ValType{i32}}},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: make([]binary.Code, 1),
+		MemSec:  []binary.MemType{{Tag: 1, Shared: true, Min: 1, Max: 1}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.I32Const, Args: int32(1)},
+		{Opcode: binary.I32Const, Args: int32(2)},
+		{Opcode: binary.AtomicPrefix, Args: binary.PrefixArgs{
+			SubOp: binary.I32AtomicRmwCmpxchg, Args: binary.MemArg{Align: 2}}},
+	}
+	require.NoError(t, Validate(module))
+	module.CodeSec[0].Expr[3].Args = binary.PrefixArgs{
+		SubOp: binary.I32AtomicRmw8CmpxchgU, Args: binary.MemArg{Align: 2}}
+	require.EqualError(t, Validate(module),
+		"code[0], i32.atomic.rmw8.cmpxchg_u: alignment must be equal to natural alignment (1)")
+
+	module.MemSec[0].Tag = 0
+	require.EqualError(t, Validate(module), "mem[0]: shared memory must have maximum")
+	module.MemSec = nil
+	module.TableSec = []binary.TableType{{ElemType: binary.ValTypeFuncRef,
+		Limits: binary.Limits{Shared: true}}}
+	require.EqualError(t, Validate(module), "table[0]: tables cannot be shared")
+}
+
+func TestValidateSimd(t *testing.T) {
+	i32 := binary.ValTypeI32
+	module := binary.Module{
+		TypeSec: []binary.FuncType{{Tag: binary.FtTag, ResultTypes: []binary.ValType{i32}}},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: make([]binary.Code, 1),
+		MemSec:  []binary.MemType{{Min: 1}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I32Const, Args: int32(0)},
+		{Opcode: binary.SimdPrefix, Args: binary.PrefixArgs{
+			SubOp: binary.V128Load32Splat, Args: binary.MemArg{Align: 2}}},
+		{Opcode: binary.SimdPrefix, Args: binary.PrefixArgs{
+			SubOp: binary.V128Const, Args: [16]byte{}}},
+		{Opcode: binary.SimdPrefix, Args: binary.PrefixArgs{SubOp: binary.I32x4Add}},
+		{Opcode: binary.SimdPrefix, Args: binary.PrefixArgs{
+			SubOp: binary.I32x4ExtractLane, Args: byte(3)}},
+	}
+	require.NoError(t, Validate(module))
+	module.CodeSec[0].Expr[4].Args = binary.PrefixArgs{
+		SubOp: binary.I32x4ExtractLane, Args: byte(4)}
+	require.EqualError(t, Validate(module), "code[0], i32x4.extract_lane: invalid lane index: 4")
+	module.CodeSec[0].Expr[1].Args = binary.PrefixArgs{
+		SubOp: binary.V128Load32Splat, Args: binary.MemArg{Align: 3}}
+	require.EqualError(t, Validate(module), "code[0], v128.load32_splat: alignment must not be larger than natural alignment (4)")
+	module.CodeSec[0].Expr[1].Args = binary.PrefixArgs{
+		SubOp: binary.V128Load32Splat, Args: binary.MemArg{Align: 2}}
+	module.CodeSec[0].Expr[2] = binary.Instruction{Opcode: binary.I32Const, Args: int32(0)}
+	require.EqualError(t, Validate(module), "code[0], i32x4.add: type mismatch")
+}
+
+func TestValidateMemory64(t *testing.T) {
+	i64 := binary.ValTypeI64
+	module := binary.Module{
+		TypeSec: []binary.FuncType{{Tag: binary.FtTag, ResultTypes: []binary.ValType{i64}}},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: make([]binary.Code, 1),
+		MemSec:  []binary.MemType{{Is64: true, Min: 1 << 40}},
+		DataSec: []binary.Data{{
+			Offset: []binary.Instruction{{Opcode: binary.I64Const, Args: int64(0)}},
+		}},
+	}
+	module.CodeSec[0].Expr = []binary.Instruction{
+		{Opcode: binary.I64Const, Args: int64(1 << 40)},
+		{Opcode: binary.I64Load, Args: binary.MemArg{Align: 3, Offset: 1 << 40}},
+		{Opcode: binary.MemoryGrow, Args: uint32(0)},
+	}
+	require.NoError(t, Validate(module))
+
+	module.MemSec[0].Min = 1<<48 + 1
+	require.EqualError(t, Validate(module), "mem[0]: memory size must be at most 2^48 pages")
+	module.MemSec[0] = binary.MemType{Min: 1}
+	require.EqualError(t, Validate(module), "code[0], i64.load: offset out of range")
+	module.CodeSec[0].Expr[1].Args = binary.MemArg{Align: 3}
+	require.EqualError(t, Validate(module), "code[0], i64.load: type mismatch")
+
+	module.TableSec = []binary.TableType{{ElemType: binary.ValTypeFuncRef,
+		Limits: binary.Limits{Is64: true}}}
+	require.EqualError(t, Validate(module), "table[0]: tables cannot be 64-bit")
+}
+
+func TestValidateExtendedConst(t *testing.T) {
+	i32 := binary.ValTypeI32
+	module := binary.Module{
+		ImportSec: []binary.Import{{Module: "env", Name: "g",
+			Desc: binary.ImportDesc{Tag: binary.ImportTagGlobal,
+				Global: binary.GlobalType{ValType: i32}}}},
+		GlobalSec: []binary.Global{{Type: binary.GlobalType{ValType: i32}}},
+	}
+	init := func(instrs ...binary.Instruction) error {
+		module.GlobalSec[0].Init = instrs
+		return Validate(module)
+	}
+	get := binary.Instruction{Opcode: binary.GlobalGet, Args: uint32(0)}
+	one := binary.Instruction{Opcode: binary.I32Const, Args: int32(1)}
+	require.NoError(t, init(get, one, binary.Instruction{Opcode: binary.I32Mul}))
+	require.EqualError(t, init(get, one, binary.Instruction{Opcode: binary.I32DivS}),
+		"global[1]: constant expression required")
+	require.EqualError(t, init(get, one, binary.Instruction{Opcode: binary.I64Add}),
+		"global[1]: type mismatch")
+	require.EqualError(t, init(get, one), "global[1]: type mismatch")
+
+	module.ImportSec[0].Desc.Global.Mut = 1
+	require.EqualError(t, init(get), "global[1]: constant expression required")
+	module.ImportSec = nil
+	require.EqualError(t, init(get), "global[0]: unknown global: 0")
+}
+
+func TestValidateFeatures(t *testing.T) {
+	i32 := binary.ValTypeI32
+	ft := binary.FuncType{Tag: binary.FtTag, ResultTypes: []binary.ValType{i32}}
+	module := binary.Module{
+		TypeSec: []binary.FuncType{ft},
+		FuncSec: []binary.TypeIdx{0},
+		CodeSec: []binary.Code{{Expr: []binary.Instruction{
+			{Opcode: binary.I32Const, Args: int32(1)},
+			{Opcode: binary.I32Extend8S},
+		}}},
+		MemSec: []binary.MemType{{Min: 1}},
+		DataSec: []binary.Data{{Offset: []binary.Instruction{
+			{Opcode: binary.I32Const, Args: int32(1)},
+			{Opcode: binary.I32Const, Args: int32(1)},
+			{Opcode: binary.I32Add},
+		}}},
+	}
+	mvp := binary.DecodeOptions{Features: binary.FeaturesMVP}
+	require.NoError(t, Validate(module))
+	err := ValidateWithOptions(module, mvp)
+	require.ErrorIs(t, err, binary.ErrFeatureDisabled)
+	require.EqualError(t, err, "data[0]: feature not enabled: extended-const")
+
+	diags := ValidateAllWithOptions(module, mvp)
+	require.Equal(t, 2, len(diags))
+	require.Equal(t, 0, diags[1].FuncIdx)
+	require.Equal(t, "code[0], i32.extend8_s: feature not enabled: sign-extension",
+		diags[1].Error())
+
+	module.MemSec[0].Is64 = true
+	module.DataSec = nil
+	module.CodeSec[0].Locals = []binary.Locals{{N: 1, Type: binary.ValTypeV128}}
+	diags = ValidateAllWithOptions(module, binary.DecodeOptions{
+		Features: binary.FeaturesStandard.Disable(binary.FeatureSIMD)})
+	require.Equal(t, 2, len(diags))
+	require.Equal(t, "mem[0]: feature not enabled: memory64", diags[0].Error())
+	require.Equal(t, "code[0], : feature not enabled: simd", diags[1].Error())
+}
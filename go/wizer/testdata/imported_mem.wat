@@ -0,0 +1,7 @@
+(module
+  (import "env" "mem" (memory 1))
+  (memory $own (export "own") 1)
+  (data (memory 0) (i32.const 16) "imp")
+  (data (memory 1) (i32.const 32) "own")
+  (func (export "wizer.initialize")
+    (i32.store8 $own (i32.const 35) (i32.const 33))))
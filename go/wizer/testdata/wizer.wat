@@ -0,0 +1,36 @@
+(module
+  (type $unop (func (param i32) (result i32)))
+  (memory (export "mem") 1)
+  (global $counter (mut i32) (i32.const 0))
+  (global $f (mut f64) (f64.const 0))
+  (global $fn (mut funcref) (ref.null func))
+  (table $t 1 funcref)
+  (data $hello (i32.const 3000) "hello")
+  (data $p "xyz")
+  (elem declare func $sq)
+  (start $start)
+  (func $start (global.set $counter (i32.const 1)))
+  (func $sq (param i32) (result i32)
+    (i32.mul (local.get 0) (local.get 0)))
+  (func (export "wizer.initialize")
+    (local $i i32)
+    (loop $l
+      (i32.store (i32.add (i32.const 1024) (i32.shl (local.get $i) (i32.const 2)))
+        (call $sq (local.get $i)))
+      (br_if $l (i32.lt_u (local.tee $i (i32.add (local.get $i) (i32.const 1)))
+        (i32.const 100))))
+    (i32.store (i32.const 60000) (i32.const 7))
+    (memory.init $p (i32.const 2000) (i32.const 0) (i32.const 3))
+    (data.drop $p)
+    (global.set $counter (i32.add (global.get $counter) (i32.const 10)))
+    (global.set $f (f64.const 2.5))
+    (global.set $fn (ref.func $sq)))
+  (func (export "bad")
+    (table.set $t (i32.const 0) (ref.func $sq)))
+  (func (export "square") (param i32) (result i32)
+    (i32.load (i32.add (i32.const 1024) (i32.shl (local.get 0) (i32.const 2)))))
+  (func (export "counter") (result i32) (global.get $counter))
+  (func (export "f") (result f64) (global.get $f))
+  (func (export "call_fn") (param i32) (result i32)
+    (table.set $t (i32.const 0) (global.get $fn))
+    (call_indirect $t (type $unop) (local.get 0) (i32.const 0))))
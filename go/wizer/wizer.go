@@ -0,0 +1,172 @@
+// Package wizer pre-initializes modules in the spirit of Wizer: it
+// runs an init function once and writes the module again with the
+// resulting memories and globals as its initial state.
+package wizer
+
+import (
+	"errors"
+	"math"
+	"reflect"
+
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+// DefaultInit is the export Wizer calls by default
+const DefaultInit = "wizer.initialize"
+
+// zero runs shorter than this don't split data segments
+const minGap = 8
+
+// Initialize instantiates the module in data, calls its export init
+// and returns the module with the state init left in its memories and
+// mutable globals baked in. The start function and init are removed,
+// since they ran. Imported memories and globals aren't baked, init
+// must leave tables and passive elem segments alone.
+func Initialize(data []byte, mm map[string]instance.Module,
+	opts binary.DecodeOptions, init string) ([]byte, error) {
+
+	module, err := binary.DecodeWithOptions(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	m, err := interpreter.NewWithOptions(module, mm, opts)
+	if err != nil {
+		return nil, err
+	}
+	before, err := interpreter.TakeSnapshot(m)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = m.InvokeFunc(init); err != nil {
+		return nil, err
+	}
+	s, err := interpreter.TakeSnapshot(m)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(before.Tables, s.Tables) ||
+		!reflect.DeepEqual(before.DroppedElems, s.DroppedElems) {
+		return nil, errors.New("init changed tables, which can't be baked")
+	}
+
+	for i := range module.MemSec {
+		module.MemSec[i].Min = s.Memories[i].Pages
+	}
+	for i, g := range module.GlobalSec {
+		if g.Type.Mut == 1 {
+			module.GlobalSec[i].Init = constExpr(g.Type.ValType, s.Globals[i])
+		}
+	}
+	module.DataSec = bakeData(module, s)
+	if module.DataCountSec != nil {
+		n := uint32(len(module.DataSec))
+		module.DataCountSec = &n
+	}
+	module.StartSec = nil
+	exports := module.ExportSec[:0]
+	for _, exp := range module.ExportSec {
+		if exp.Name != init {
+			exports = append(exports, exp)
+		}
+	}
+	module.ExportSec = exports
+
+	return binary.Patch(data, module, binary.SecMemID, binary.SecGlobalID,
+		binary.SecExportID, binary.SecStartID, binary.SecDataCountID, binary.SecDataID)
+}
+
+func constExpr(vt binary.ValType, val interpreter.SnapshotVal) binary.Expr {
+	var instr binary.Instruction
+	switch vt {
+	case binary.ValTypeI32:
+		instr = binary.Instruction{Opcode: binary.I32Const, Args: int32(val.Bits[0])}
+	case binary.ValTypeI64:
+		instr = binary.Instruction{Opcode: binary.I64Const, Args: int64(val.Bits[0])}
+	case binary.ValTypeF32:
+		instr = binary.Instruction{Opcode: binary.F32Const,
+			Args: math.Float32frombits(uint32(val.Bits[0]))}
+	case binary.ValTypeF64:
+		instr = binary.Instruction{Opcode: binary.F64Const,
+			Args: math.Float64frombits(val.Bits[0])}
+	case binary.ValTypeV128:
+		var v [16]byte
+		for i := range v {
+			v[i] = byte(val.Bits[i/8] >> (i % 8 * 8))
+		}
+		instr = binary.Instruction{Opcode: binary.SimdPrefix,
+			Args: binary.PrefixArgs{SubOp: binary.V128Const, Args: v}}
+	default:
+		if val.Func == 0 {
+			instr = binary.Instruction{Opcode: binary.RefNull, Args: vt}
+		} else {
+			instr = binary.Instruction{Opcode: binary.RefFunc, Args: val.Func - 1}
+		}
+	}
+	return binary.Expr{instr}
+}
+
+// bakeData turns the non-zero runs of the memories into active
+// segments. With a data count section the indices of the old segments
+// are kept for memory.init and data.drop, those already applied or
+// dropped become empty passive ones. Active segments of imported
+// memories are kept as they are, nothing of those is baked.
+func bakeData(module binary.Module, s *interpreter.Snapshot) []binary.Data {
+	importedMemCount := 0
+	for _, imp := range module.ImportSec {
+		if imp.Desc.Tag == binary.ImportTagMem {
+			importedMemCount++
+		}
+	}
+
+	var datas []binary.Data
+	dropped := map[uint32]bool{}
+	for _, i := range s.DroppedDatas {
+		dropped[i] = true
+	}
+	for i, data := range module.DataSec {
+		switch {
+		case data.Mode == binary.SegModeActive && data.Mem < uint32(importedMemCount):
+			// applies to the imported memory again
+		case module.DataCountSec == nil:
+			continue
+		case data.Mode == binary.SegModeActive || dropped[uint32(i)]:
+			data = binary.Data{Mode: binary.SegModePassive}
+		}
+		datas = append(datas, data)
+	}
+
+	for i, ms := range s.Memories {
+		memIdx := uint32(importedMemCount + i)
+		is64 := module.MemSec[i].Is64
+		for start := 0; start < len(ms.Data); {
+			if ms.Data[start] == 0 {
+				start++
+				continue
+			}
+			end, zeros := start, 0
+			for end+zeros < len(ms.Data) && zeros < minGap {
+				if ms.Data[end+zeros] == 0 {
+					zeros++
+				} else {
+					end, zeros = end+zeros+1, 0
+				}
+			}
+			datas = append(datas, binary.Data{
+				Mem:    memIdx,
+				Offset: binary.Expr{offsetInstr(uint64(start), is64)},
+				Init:   ms.Data[start:end],
+			})
+			start = end
+		}
+	}
+	return datas
+}
+
+func offsetInstr(offset uint64, is64 bool) binary.Instruction {
+	if is64 {
+		return binary.Instruction{Opcode: binary.I64Const, Args: int64(offset)}
+	}
+	return binary.Instruction{Opcode: binary.I32Const, Args: int32(uint32(offset))}
+}
@@ -0,0 +1,91 @@
+package wizer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wasm.go/binary"
+	"wasm.go/instance"
+	"wasm.go/interpreter"
+)
+
+func TestInitialize(t *testing.T) {
+	data, err := os.ReadFile("testdata/wizer.wasm")
+	require.NoError(t, err)
+	out, err := Initialize(data, nil, binary.DecodeOptions{}, DefaultInit)
+	require.NoError(t, err)
+
+	module, err := binary.Decode(out)
+	require.NoError(t, err)
+	require.Nil(t, module.StartSec)
+	for _, exp := range module.ExportSec {
+		require.NotEqual(t, DefaultInit, exp.Name)
+	}
+	// $hello and $p are empty, squares, "xyz", "hello" and 7 active
+	require.Equal(t, 6, len(module.DataSec))
+	require.Equal(t, uint32(6), *module.DataCountSec)
+	for _, d := range module.DataSec[:2] {
+		require.Equal(t, binary.SegModePassive, int(d.Mode))
+		require.Empty(t, d.Init)
+	}
+	require.Equal(t, []byte("xyz"), module.DataSec[3].Init)
+
+	m, err := interpreter.New(module, nil)
+	require.NoError(t, err)
+	for _, test := range []struct {
+		name   string
+		args   []instance.WasmVal
+		result instance.WasmVal
+	}{
+		{"square", []instance.WasmVal{int32(9)}, int32(81)},
+		{"counter", nil, int32(11)},
+		{"f", nil, 2.5},
+		{"call_fn", []instance.WasmVal{int32(5)}, int32(25)},
+	} {
+		results, err := m.InvokeFunc(test.name, test.args...)
+		require.NoError(t, err)
+		require.Equal(t, []instance.WasmVal{test.result}, results, test.name)
+	}
+	buf := make([]byte, 5)
+	mem := m.GetMember("mem").(instance.Memory)
+	mem.Read(2000, buf)
+	require.Equal(t, []byte("xyz\x00\x00"), buf)
+	mem.Read(3000, buf)
+	require.Equal(t, []byte("hello"), buf)
+	mem.Read(60000, buf)
+	require.Equal(t, []byte{7, 0, 0, 0, 0}, buf)
+
+	_, err = Initialize(data, nil, binary.DecodeOptions{}, "bad")
+	require.EqualError(t, err, "init changed tables, which can't be baked")
+	_, err = Initialize(out, nil, binary.DecodeOptions{}, DefaultInit)
+	require.Error(t, err)
+}
+
+func TestInitializeImportedMem(t *testing.T) {
+	data, err := os.ReadFile("testdata/imported_mem.wasm")
+	require.NoError(t, err)
+	newEnv := func() instance.Module {
+		env := instance.NewNativeInstance()
+		env.Register("mem", interpreter.NewMemory(1, 1))
+		return env
+	}
+	out, err := Initialize(data, instance.Map{"env": newEnv()},
+		binary.DecodeOptions{}, DefaultInit)
+	require.NoError(t, err)
+
+	module, err := binary.Decode(out)
+	require.NoError(t, err)
+	require.Len(t, module.DataSec, 2)
+	require.Equal(t, uint32(0), module.DataSec[0].Mem)
+	require.Equal(t, []byte("imp"), module.DataSec[0].Init)
+
+	env := newEnv()
+	m, err := interpreter.New(module, instance.Map{"env": env})
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	env.GetMember("mem").(instance.Memory).Read(16, buf)
+	require.Equal(t, []byte("imp\x00"), buf)
+	m.GetMember("own").(instance.Memory).Read(32, buf)
+	require.Equal(t, []byte("own!"), buf)
+}
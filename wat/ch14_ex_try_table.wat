@@ -0,0 +1,36 @@
+;; --enable-exceptions, the try_table and throw_ref encoding
+(module
+  (import "env" "print_i32" (func $print_i32 (param i32)))
+  (import "env" "print_i64" (func $print_i64 (param i64)))
+
+  (import "env" "x0" (tag $x0 (param f32)))
+  (tag $x1 (param i32))
+  (tag $x2 (param i64 i64))
+  (export "x1" (tag $x1))
+
+  (func $f1
+    (throw $x1 (i32.const 123))
+    (throw $x2 (i64.const 123) (i64.const 456))
+  )
+  (func $f2
+    (block $b3 (result exnref)
+      (block $b2 (result i64 i64)
+        (block $b1 (result i32)
+          (try_table (catch $x1 $b1)     ;; --+
+                     (catch $x2 $b2)     ;; --|--+
+                     (catch_all_ref $b3) ;; --|--|--+
+            (call $f1)                   ;;   |  |  |
+          )                              ;;   |  |  |
+          (i32.const 0)                  ;;   |  |  |
+        )                                ;;   |  |  |
+        (call $print_i32) ;; <----------------+  |  |
+        (i64.const 1)     ;;                     |  |
+        (i64.const 2)     ;;                     |  |
+      )                   ;;                     |  |
+      (call $print_i64)   ;; <-------------------+  |
+      (call $print_i64)   ;;                        |
+      (return)            ;;                        |
+    )                     ;;                        |
+    (throw_ref)           ;; <----------------------+
+  )
+)
@@ -1,6 +1,5 @@
-;; --enable-multi-mem
+;; --enable-multi-memory
 (module
-(;;
   (import "env" "mem" (memory $m0 1 8))
   (memory $m1 1 8)
   (memory $m2 1 8)
@@ -14,5 +13,4 @@
     (i32.load $m1 offset=12 (i32.const 34))
     (i32.store $m2 offset=56 (i32.const 78))
   )
-;;)
 )
\ No newline at end of file
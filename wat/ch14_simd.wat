@@ -1,3 +1,32 @@
+;; --enable-simd
 (module
-  ;; TODO
+  (memory 1)
+  (global $g (mut v128) (v128.const i32x4 0 0 0 0))
+  (func $const (result v128)
+    (v128.const i8x16 0 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15)
+  )
+  (func $lanes (param $x i32) (result i32)
+    (local $v v128)
+    (local.set $v (i32x4.splat (local.get $x)))
+    (local.set $v (i32x4.replace_lane 3 (local.get $v) (i32.const 100)))
+    (i32x4.extract_lane 3
+      (i32x4.add (local.get $v) (local.get $v))
+    )
+  )
+  (func $shuffle (param $a v128) (param $b v128) (result v128)
+    (i8x16.shuffle 0 16 1 17 2 18 3 19 4 20 5 21 6 22 7 23
+      (local.get $a) (local.get $b)
+    )
+  )
+  (func $float (param $a f32) (param $b f32) (result v128)
+    (f32x4.mul (f32x4.splat (local.get $a)) (f32x4.splat (local.get $b)))
+  )
+  (func $mem (param $addr i32) (result i32)
+    (v128.store offset=0 (local.get $addr) (call $const))
+    (global.set $g (v128.load offset=0 (local.get $addr)))
+    (i16x8.extract_lane_u 1 (global.get $g))
+  )
+  (func $any (param $v v128) (result i32)
+    (i32.add (v128.any_true (local.get $v)) (i8x16.bitmask (local.get $v)))
+  )
 )
\ No newline at end of file
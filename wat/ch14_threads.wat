@@ -27,15 +27,15 @@
   )
   (func $wait (param $addr i32) (param $expected i32) (param $timeout i64)
     (local $wait_ret i32)
-    (i32.atomic.wait
+    (memory.atomic.wait32
       (local.get $addr) (local.get $expected) (local.get $timeout) 
     )
     (local.set $wait_ret)
   )
   (func $notify (param $addr i32) (param $count i32)
     (local $woken_waiters i32)
-    (atomic.notify (local.get $addr) (local.get $count))
+    (memory.atomic.notify (local.get $addr) (local.get $count))
     (local.set $woken_waiters)
   )
-  ;; (func $fence atomic.fence)
+  (func $fence atomic.fence)
 )
\ No newline at end of file